@@ -37,6 +37,7 @@ import (
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
 	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/status"
 )
@@ -83,6 +84,9 @@ type Client interface {
 	// GetLeaderAddr returns current leader's address. It returns "" before
 	// syncing leader from server.
 	GetLeaderAddr() string
+	// WaitForLeader blocks until the client has discovered the leader and its connection
+	// is ready, or the context is done.
+	WaitForLeader(ctx context.Context) error
 	// GetRegion gets a region and its leader Peer from PD by key.
 	// The region may expire after split. Caller is responsible for caching and
 	// taking care of region change.
@@ -139,6 +143,9 @@ type Client interface {
 	WatchGlobalConfig(ctx context.Context, configPath string, revision int64) (chan []GlobalConfigItem, error)
 	// UpdateOption updates the client option.
 	UpdateOption(option DynamicOption, value interface{}) error
+	// UpdateSecurityOption replaces the TLS config of the connections to both the PD and TSO servers,
+	// e.g., to rotate the certificates. The invalid config is rejected and the working one is kept.
+	UpdateSecurityOption(security SecurityOption) error
 
 	// GetExternalTimestamp returns external timestamp
 	GetExternalTimestamp(ctx context.Context) (uint64, error)
@@ -230,7 +237,104 @@ func WithGRPCDialOptions(opts ...grpc.DialOption) ClientOption {
 	}
 }
 
-// WithCustomTimeoutOption configures the client with timeout option.
+// WithUnaryInterceptors configures the unary interceptors chained to all the gRPC connections
+// created by the client, including the ones to the PD and TSO servers. The interceptors are
+// invoked in the given order, and can be specified multiple times.
+func WithUnaryInterceptors(interceptors ...grpc.UnaryClientInterceptor) ClientOption {
+	return func(c *client) {
+		c.option.unaryInterceptors = append(c.option.unaryInterceptors, interceptors...)
+	}
+}
+
+// WithStreamInterceptors configures the stream interceptors chained to all the gRPC connections
+// created by the client, including the ones to the PD and TSO servers. The interceptors are
+// invoked in the given order, and can be specified multiple times.
+func WithStreamInterceptors(interceptors ...grpc.StreamClientInterceptor) ClientOption {
+	return func(c *client) {
+		c.option.streamInterceptors = append(c.option.streamInterceptors, interceptors...)
+	}
+}
+
+// WithSoftMemberErrorTypes configures the header error types of GetMembers which are regarded as
+// recoverable. When updating the members, the client retries the same URL on these errors instead of
+// trying the next one. The default is NOT_BOOTSTRAPPED only, passing no types makes all the errors hard.
+func WithSoftMemberErrorTypes(types ...pdpb.ErrorType) ClientOption {
+	return func(c *client) {
+		c.option.softMemberErrorTypes = types
+	}
+}
+
+// WithLeaderLostGracePeriod configures the client to keep the known leader until it has been unreachable
+// for the given duration across the member checks, which avoids the spurious leader switches caused by
+// the brief blips. It's 0 by default, i.e., the client tries the other members once the leader fails.
+func WithLeaderLostGracePeriod(period time.Duration) ClientOption {
+	return func(c *client) {
+		c.option.leaderLostGracePeriod = period
+	}
+}
+
+// WithLeaderHintStore configures the client to persist the last-known leader with the given hooks.
+// The save hook is called every time the leader is switched, and the load hook is called once during
+// the initialization, so that a restarting client can try the previously-known leader first before
+// scanning all the given URLs. The hint is ignored if it's empty or fails to be loaded. Both hooks
+// should be fast since they are called within the member updates.
+func WithLeaderHintStore(save func(leader string) error, load func() (string, error)) ClientOption {
+	return func(c *client) {
+		c.option.saveLeaderHint = save
+		c.option.loadLeaderHint = load
+	}
+}
+
+// WithConnLivenessCheck configures the client to check the state of the cached gRPC connection every
+// time it's fetched, the connection which is shutdown or in transient failure will be closed and redialed.
+// It's disabled by default to keep the fast path of fetching the connection.
+func WithConnLivenessCheck(enable bool) ClientOption {
+	return func(c *client) {
+		c.option.checkConnLiveness = enable
+	}
+}
+
+// WithInitialWindowSize configures the initial HTTP/2 stream window size of all the gRPC
+// connections created by the client. The gRPC default is 64KB, which may limit the throughput
+// of the large responses like ScanRegions on a high-latency network. For region-list-heavy
+// deployments, a value between 4MB and 16MB is recommended. Note that setting it disables the
+// dynamic window estimation of gRPC. Values smaller than 64KB are ignored by gRPC.
+func WithInitialWindowSize(size int32) ClientOption {
+	return func(c *client) {
+		c.option.initialWindowSize = size
+	}
+}
+
+// WithInitialConnWindowSize configures the initial HTTP/2 connection window size of all the gRPC
+// connections created by the client. It is shared by all the streams on the connection, so it is
+// recommended to be at least as large as the stream window size, e.g. 2 times of it when there are
+// concurrent large responses. Values smaller than 64KB are ignored by gRPC.
+func WithInitialConnWindowSize(size int32) ClientOption {
+	return func(c *client) {
+		c.option.initialConnWindowSize = size
+	}
+}
+
+// WithMaxRecvMsgSize configures the max message size in bytes that all the gRPC connections
+// created by the client can receive. The default is 64MB, which is larger than the gRPC default 4MB
+// to avoid the ResourceExhausted errors of the large responses like ScanRegions.
+func WithMaxRecvMsgSize(size int) ClientOption {
+	return func(c *client) {
+		c.option.maxRecvMsgSize = size
+	}
+}
+
+// WithMaxSendMsgSize configures the max message size in bytes that all the gRPC connections
+// created by the client can send. The gRPC default is used if it is not set.
+func WithMaxSendMsgSize(size int) ClientOption {
+	return func(c *client) {
+		c.option.maxSendMsgSize = size
+	}
+}
+
+// WithCustomTimeoutOption configures the client with timeout option. The timeout is
+// the upper bound of each RPC, a tighter deadline set on the caller's context is
+// always respected.
 func WithCustomTimeoutOption(timeout time.Duration) ClientOption {
 	return func(c *client) {
 		c.option.timeout = timeout
@@ -265,6 +369,80 @@ func WithInitMetricsOption(initMetrics bool) ClientOption {
 	}
 }
 
+// WithRequiredServerCapabilities configures the client to verify that the PD server supports
+// the given capabilities during the initialization. A warning is logged if any of them is missing,
+// and the client creation fails instead if enforce is true.
+func WithRequiredServerCapabilities(enforce bool, capabilities ...ServerCapability) ClientOption {
+	return func(c *client) {
+		c.option.requiredServerCapabilities = append(c.option.requiredServerCapabilities, capabilities...)
+		c.option.enforceServerCapabilities = enforce
+	}
+}
+
+// WithRetryBudget configures the client with a retry budget shared by all the retry paths,
+// e.g. the initialization, the member updating and the TSO stream reconnecting. The aggregate
+// retry rate is bounded to rate retries per second with bursts of at most burst retries.
+func WithRetryBudget(rate float64, burst int) ClientOption {
+	return func(c *client) {
+		c.option.retryBudget = newRetryBudget(rate, burst)
+	}
+}
+
+// WithEagerFollowerDial configures the client to dial the followers as soon as they are discovered
+// instead of creating the connections lazily on the first use, which helps the latency-sensitive
+// follower reads.
+func WithEagerFollowerDial(eager bool) ClientOption {
+	return func(c *client) {
+		c.option.eagerFollowerDial = eager
+	}
+}
+
+// WithFollowerHealthCheckInterval configures the interval to probe the health of the followers, so that
+// GetBackupAddrs only returns the live ones for the follower reads and the TSO follower proxy. A follower
+// which recovers is returned again after the next probe. The health check is enabled by default with the
+// interval of 10s, and 0 means disabling it.
+func WithFollowerHealthCheckInterval(interval time.Duration) ClientOption {
+	return func(c *client) {
+		c.option.followerHealthCheckInterval = interval
+	}
+}
+
+// WithMemberUpdateInterval configures the interval to check the membership changes periodically, it's one
+// minute by default. It can also be changed at runtime by UpdateOption with MemberUpdateInterval.
+func WithMemberUpdateInterval(interval time.Duration) ClientOption {
+	return func(c *client) {
+		if err := c.option.setMemberUpdateInterval(interval); err != nil {
+			log.Warn("[pd] ignore the invalid member update interval", zap.Duration("interval", interval), errs.ZapError(err))
+		}
+	}
+}
+
+// WithTSOBatchWaitDuration configures the max duration the TSO dispatcher waits to collect more requests into
+// a batch, which is the initial value of the MaxTSOBatchWaitInterval option. Lower values favor the latency
+// while higher values favor the throughput under many concurrent callers. 0 disables the waiting, so a batch
+// only carries the requests which are already pending. It should be between 0 and 10ms.
+func WithTSOBatchWaitDuration(d time.Duration) ClientOption {
+	return func(c *client) {
+		if err := c.option.setMaxTSOBatchWaitInterval(d); err != nil {
+			log.Warn("[pd] ignore the invalid tso batch wait duration", zap.Duration("duration", d), errs.ZapError(err))
+		}
+	}
+}
+
+// WithTSODispatchRetry configures the retries of dispatching a TSO request when the dispatcher of the
+// dc-location is not ready, e.g., the TSO leader/primary has not been found yet. The backoff starts from
+// the given interval and is doubled for each retry. 0 times means failing the request at once.
+func WithTSODispatchRetry(times int, interval time.Duration) ClientOption {
+	return func(c *client) {
+		if times < 0 || interval < 0 {
+			log.Warn("[pd] ignore the invalid tso dispatch retry", zap.Int("times", times), zap.Duration("interval", interval))
+			return
+		}
+		c.option.tsoDispatchRetryTimes = times
+		c.option.tsoDispatchRetryInterval = interval
+	}
+}
+
 var _ Client = (*client)(nil)
 
 // serviceModeKeeper is for service mode switching.
@@ -308,6 +486,8 @@ type client struct {
 	ctx    context.Context
 	cancel context.CancelFunc
 	wg     sync.WaitGroup
+	// tlsCfg is protected by the lock of serviceModeKeeper, since it's used to create
+	// the TSO service discovery when switching the service mode.
 	tlsCfg *tlsutil.TLSConfig
 	option *option
 }
@@ -323,6 +503,28 @@ type SecurityOption struct {
 	SSLKEYBytes  []byte
 }
 
+func (s SecurityOption) toTLSConfig() *tlsutil.TLSConfig {
+	return &tlsutil.TLSConfig{
+		CAPath:   s.CAPath,
+		CertPath: s.CertPath,
+		KeyPath:  s.KeyPath,
+
+		SSLCABytes:   s.SSLCABytes,
+		SSLCertBytes: s.SSLCertBytes,
+		SSLKEYBytes:  s.SSLKEYBytes,
+	}
+}
+
+// ValidateSecurityOption loads and validates the CA, certificate and key of the security option,
+// either from the paths or the in-memory bytes. It could be called before creating the client
+// to find out the bad TLS configuration early instead of failing at the first connection.
+func ValidateSecurityOption(security SecurityOption) error {
+	if _, err := security.toTLSConfig().ToTLSConfig(); err != nil {
+		return errs.ErrSecurityConfig.FastGenByArgs(err.Error())
+	}
+	return nil
+}
+
 // NewClient creates a PD client.
 func NewClient(
 	svrAddrs []string, security SecurityOption, opts ...ClientOption,
@@ -356,15 +558,7 @@ func createClientWithKeyspace(
 	ctx context.Context, keyspaceID uint32, svrAddrs []string,
 	security SecurityOption, opts ...ClientOption,
 ) (Client, error) {
-	tlsCfg := &tlsutil.TLSConfig{
-		CAPath:   security.CAPath,
-		CertPath: security.CertPath,
-		KeyPath:  security.KeyPath,
-
-		SSLCABytes:   security.SSLCABytes,
-		SSLCertBytes: security.SSLCertBytes,
-		SSLKEYBytes:  security.SSLKEYBytes,
-	}
+	tlsCfg := security.toTLSConfig()
 
 	clientCtx, clientCancel := context.WithCancel(ctx)
 	c := &client{
@@ -471,15 +665,7 @@ func newClientWithKeyspaceName(
 	ctx context.Context, keyspaceName string, svrAddrs []string,
 	security SecurityOption, opts ...ClientOption,
 ) (Client, error) {
-	tlsCfg := &tlsutil.TLSConfig{
-		CAPath:   security.CAPath,
-		CertPath: security.CertPath,
-		KeyPath:  security.KeyPath,
-
-		SSLCABytes:   security.SSLCABytes,
-		SSLCertBytes: security.SSLCertBytes,
-		SSLKEYBytes:  security.SSLKEYBytes,
-	}
+	tlsCfg := security.toTLSConfig()
 
 	clientCtx, clientCancel := context.WithCancel(ctx)
 	c := &client{
@@ -533,6 +719,9 @@ func (c *client) initRetry(f func(s string) error, str string) error {
 			return err
 		case <-ticker.C:
 		}
+		if c.option.retryBudget.acquire(c.ctx) != nil {
+			return err
+		}
 	}
 	return errors.WithStack(err)
 }
@@ -672,6 +861,31 @@ func (c *client) GetLeaderAddr() string {
 	return c.pdSvcDiscovery.GetServingAddr()
 }
 
+// waitForLeaderInterval is the interval to check the leader in WaitForLeader.
+const waitForLeaderInterval = 50 * time.Millisecond
+
+// WaitForLeader blocks until the client has discovered the leader and the gRPC connection
+// to it is ready, or returns the error of the context if it is done before that.
+func (c *client) WaitForLeader(ctx context.Context) error {
+	ticker := time.NewTicker(waitForLeaderInterval)
+	defer ticker.Stop()
+	for {
+		if addr := c.GetLeaderAddr(); addr != "" {
+			cc, err := c.pdSvcDiscovery.GetOrCreateGRPCConn(addr)
+			if err == nil && cc.GetState() == connectivity.Ready {
+				return nil
+			}
+		}
+		// The leader is unknown or unreachable, check whether it has changed.
+		c.pdSvcDiscovery.ScheduleCheckMemberChanged()
+		select {
+		case <-ctx.Done():
+			return errors.WithStack(ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
 // GetServiceDiscovery returns the client-side service discovery object
 func (c *client) GetServiceDiscovery() ServiceDiscovery {
 	return c.pdSvcDiscovery
@@ -694,12 +908,39 @@ func (c *client) UpdateOption(option DynamicOption, value interface{}) error {
 			return errors.New("[pd] invalid value type for EnableTSOFollowerProxy option, it should be bool")
 		}
 		c.option.setEnableTSOFollowerProxy(enable)
+	case MemberUpdateInterval:
+		interval, ok := value.(time.Duration)
+		if !ok {
+			return errors.New("[pd] invalid value type for MemberUpdateInterval option, it should be time.Duration")
+		}
+		if err := c.option.setMemberUpdateInterval(interval); err != nil {
+			return err
+		}
 	default:
 		return errors.New("[pd] unsupported client option")
 	}
 	return nil
 }
 
+// UpdateSecurityOption replaces the TLS config of the client, which is pushed to both the PD service
+// discovery and the current TSO service discovery, and is used by the TSO service discovery created later.
+func (c *client) UpdateSecurityOption(security SecurityOption) error {
+	tlsCfg := security.toTLSConfig()
+	if _, err := tlsCfg.ToTLSConfig(); err != nil {
+		return errs.ErrSecurityConfig.FastGenByArgs(err.Error())
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.tlsCfg = tlsCfg
+	if err := c.pdSvcDiscovery.UpdateTLSConfig(tlsCfg); err != nil {
+		return err
+	}
+	if c.tsoSvcDiscovery != nil {
+		return c.tsoSvcDiscovery.UpdateTLSConfig(tlsCfg)
+	}
+	return nil
+}
+
 func (c *client) leaderCheckLoop() {
 	defer c.wg.Done()
 
@@ -745,14 +986,16 @@ func (c *client) GetAllMembers(ctx context.Context) ([]*pdpb.Member, error) {
 	ctx, cancel := context.WithTimeout(ctx, c.option.timeout)
 	req := &pdpb.GetMembersRequest{Header: c.requestHeader()}
 	ctx = grpcutil.BuildForwardContext(ctx, c.GetLeaderAddr())
-	protoClient := c.getClient()
+	protoClient := c.getClient(ctx)
 	if protoClient == nil {
 		cancel()
 		return nil, errs.ErrClientGetProtoClient
 	}
 	resp, err := protoClient.GetMembers(ctx, req)
 	cancel()
-	if err = c.respForErr(cmdFailDurationGetAllMembers, start, err, resp.GetHeader()); err != nil {
+	err = c.respForErr(cmdFailDurationGetAllMembers, start, err, resp.GetHeader())
+	observeRPCDuration(rpcDurationGetMembersSucceeded, rpcDurationGetMembersFailed, start, err)
+	if err != nil {
 		return nil, err
 	}
 	return resp.GetMembers(), nil
@@ -769,7 +1012,8 @@ func (c *client) leaderClient() pdpb.PDClient {
 // backupClientConn gets a grpc client connection of the current reachable and healthy
 // backup service endpoints randomly. Backup service endpoints are followers in a
 // quorum-based cluster or secondaries in a primary/secondary configured cluster.
-func (c *client) backupClientConn() (*grpc.ClientConn, string) {
+// The health checks respect the deadline of the given context.
+func (c *client) backupClientConn(ctx context.Context) (*grpc.ClientConn, string) {
 	addrs := c.pdSvcDiscovery.GetBackupAddrs()
 	if len(addrs) < 1 {
 		return nil, ""
@@ -783,7 +1027,7 @@ func (c *client) backupClientConn() (*grpc.ClientConn, string) {
 		if cc, err = c.pdSvcDiscovery.GetOrCreateGRPCConn(addr); err != nil {
 			continue
 		}
-		healthCtx, healthCancel := context.WithTimeout(c.ctx, c.option.timeout)
+		healthCtx, healthCancel := context.WithTimeout(ctx, c.option.timeout)
 		resp, err := healthpb.NewHealthClient(cc).Check(healthCtx, &healthpb.HealthCheckRequest{Service: ""})
 		healthCancel()
 		if err == nil && resp.GetStatus() == healthpb.HealthCheckResponse_SERVING {
@@ -793,9 +1037,12 @@ func (c *client) backupClientConn() (*grpc.ClientConn, string) {
 	return nil, ""
 }
 
-func (c *client) getClient() pdpb.PDClient {
+// getClient gets the client of the current PD leader, or a follower if the forwarding is enabled
+// and the leader is unreachable. The given context is the one of the caller's request, so that
+// its deadline is respected when choosing the follower.
+func (c *client) getClient(ctx context.Context) pdpb.PDClient {
 	if c.option.enableForwarding && atomic.LoadInt32(&c.leaderNetworkFailure) == 1 {
-		backupClientConn, addr := c.backupClientConn()
+		backupClientConn, addr := c.backupClientConn(ctx)
 		if backupClientConn != nil {
 			log.Debug("[pd] use follower client", zap.String("addr", addr))
 			return pdpb.NewPDClient(backupClientConn)
@@ -809,6 +1056,16 @@ func (c *client) GetTSAsync(ctx context.Context) TSFuture {
 }
 
 func (c *client) GetLocalTSAsync(ctx context.Context, dcLocation string) TSFuture {
+	return c.getLocalTSAsync(ctx, dcLocation, false)
+}
+
+// GetSharedTSAsync gets a global timestamp which may be shared with the other shared
+// requests in the same batch, without block the caller.
+func (c *client) GetSharedTSAsync(ctx context.Context) TSFuture {
+	return c.getLocalTSAsync(ctx, globalDCLocation, true)
+}
+
+func (c *client) getLocalTSAsync(ctx context.Context, dcLocation string, shared bool) TSFuture {
 	if span := opentracing.SpanFromContext(ctx); span != nil {
 		span = opentracing.StartSpan("GetLocalTSAsync", opentracing.ChildOf(span.Context()))
 		ctx = opentracing.ContextWithSpan(ctx, span)
@@ -820,30 +1077,112 @@ func (c *client) GetLocalTSAsync(ctx context.Context, dcLocation string) TSFutur
 	tsoClient := c.getTSOClient()
 	req.start = time.Now()
 	req.dcLocation = dcLocation
+	req.shared = shared
 
 	if tsoClient == nil {
 		req.done <- errs.ErrClientGetTSO.FastGenByArgs("tso client is nil")
 		return req
 	}
 
-	if err := tsoClient.dispatchRequest(dcLocation, req); err != nil {
-		// Wait for a while and try again
-		time.Sleep(50 * time.Millisecond)
-		if err = tsoClient.dispatchRequest(dcLocation, req); err != nil {
-			req.done <- err
-		}
+	if err := tsoClient.dispatchRequestWithRetry(ctx, dcLocation, req); err != nil {
+		req.done <- err
 	}
 	return req
 }
 
 func (c *client) GetTS(ctx context.Context) (physical int64, logical int64, err error) {
-	resp := c.GetTSAsync(ctx)
-	return resp.Wait()
+	return c.getLocalTS(ctx, globalDCLocation, false)
 }
 
 func (c *client) GetLocalTS(ctx context.Context, dcLocation string) (physical int64, logical int64, err error) {
-	resp := c.GetLocalTSAsync(ctx, dcLocation)
-	return resp.Wait()
+	return c.getLocalTS(ctx, dcLocation, false)
+}
+
+// GetSharedTS gets a global timestamp which may be shared with the other shared requests
+// in the same batch.
+func (c *client) GetSharedTS(ctx context.Context) (physical int64, logical int64, err error) {
+	return c.getLocalTS(ctx, globalDCLocation, true)
+}
+
+// tsoRetryOnLeaderChangeInterval is the interval to wait for the new TSO leader/primary
+// before retrying a synchronous TSO request which failed due to the leader change.
+const tsoRetryOnLeaderChangeInterval = 100 * time.Millisecond
+
+// getLocalTS gets a timestamp synchronously. If the request fails because the TSO leader/primary
+// has moved or is unavailable, it triggers a membership check and retries once against the new one.
+func (c *client) getLocalTS(ctx context.Context, dcLocation string, shared bool) (physical int64, logical int64, err error) {
+	physical, logical, err = c.getLocalTSAsync(ctx, dcLocation, shared).Wait()
+	if err == nil || !isTSORetryableError(err) {
+		return physical, logical, err
+	}
+	log.Warn("[tso] get tso failed due to the leader change, retry once",
+		zap.String("dc-location", dcLocation), errs.ZapError(err))
+	if tsoClient := c.getTSOClient(); tsoClient != nil {
+		tsoClient.svcDiscovery.ScheduleCheckMemberChanged()
+	}
+	timer := time.NewTimer(tsoRetryOnLeaderChangeInterval)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return 0, 0, errors.WithStack(ctx.Err())
+	case <-c.ctx.Done():
+		return 0, 0, errors.WithStack(c.ctx.Err())
+	case <-timer.C:
+	}
+	return c.getLocalTSAsync(ctx, dcLocation, shared).Wait()
+}
+
+// isTSORetryableError returns whether the TSO request error is caused by the leader change
+// or the unavailable leader, which is worth retrying after the new leader is found.
+func isTSORetryableError(err error) bool {
+	cause := errors.Cause(err)
+	return IsLeaderChange(cause) || status.Code(cause) == codes.Unavailable
+}
+
+const (
+	// getTSAtLeastMaxRetryTimes is the max retry times of GetTSAtLeast.
+	getTSAtLeastMaxRetryTimes = 10
+	// getTSAtLeastMaxWaitInterval is the max interval to wait for the allocator to advance in GetTSAtLeast.
+	getTSAtLeastMaxWaitInterval = 500 * time.Millisecond
+)
+
+// GetTSAtLeast gets a timestamp whose physical part is at least minPhysical, which is useful to
+// enforce the causality across services. The keyspaceID should be the one the client serves, and
+// a keyspace-agnostic client only serves the default keyspace.
+func (c *client) GetTSAtLeast(ctx context.Context, keyspaceID uint32, minPhysical int64) (physical int64, logical int64, err error) {
+	servedKeyspaceID := c.keyspaceID
+	if servedKeyspaceID == nullKeyspaceID {
+		servedKeyspaceID = defaultKeyspaceID
+	}
+	if keyspaceID != servedKeyspaceID {
+		return 0, 0, errs.ErrClientGetTSO.FastGenByArgs(
+			fmt.Sprintf("keyspace %d mismatches the keyspace %d of the client", keyspaceID, servedKeyspaceID))
+	}
+	for i := 0; i <= getTSAtLeastMaxRetryTimes; i++ {
+		physical, logical, err = c.GetTS(ctx)
+		if err != nil {
+			return 0, 0, err
+		}
+		if physical >= minPhysical || i == getTSAtLeastMaxRetryTimes {
+			break
+		}
+		// The physical time of TSO is in milliseconds, wait for the allocator to catch up.
+		waitInterval := time.Duration(minPhysical-physical) * time.Millisecond
+		if waitInterval > getTSAtLeastMaxWaitInterval {
+			waitInterval = getTSAtLeastMaxWaitInterval
+		}
+		timer := time.NewTimer(waitInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return 0, 0, errors.WithStack(ctx.Err())
+		case <-timer.C:
+		}
+	}
+	if physical < minPhysical {
+		return 0, 0, errs.ErrClientTSOBelowMinimum.FastGenByArgs(physical, minPhysical)
+	}
+	return physical, logical, nil
 }
 
 func (c *client) GetMinTS(ctx context.Context) (physical int64, logical int64, err error) {
@@ -862,7 +1201,7 @@ func (c *client) GetMinTS(ctx context.Context) (physical int64, logical int64, e
 	}
 
 	// Call GetMinTS API to get the minimal TS from the API leader.
-	protoClient := c.getClient()
+	protoClient := c.getClient(ctx)
 	if protoClient == nil {
 		return 0, 0, errs.ErrClientGetProtoClient
 	}
@@ -926,7 +1265,7 @@ func (c *client) GetRegion(ctx context.Context, key []byte, opts ...GetRegionOpt
 		NeedBuckets: options.needBuckets,
 	}
 	ctx = grpcutil.BuildForwardContext(ctx, c.GetLeaderAddr())
-	protoClient := c.getClient()
+	protoClient := c.getClient(ctx)
 	if protoClient == nil {
 		cancel()
 		return nil, errs.ErrClientGetProtoClient
@@ -934,7 +1273,9 @@ func (c *client) GetRegion(ctx context.Context, key []byte, opts ...GetRegionOpt
 	resp, err := protoClient.GetRegion(ctx, req)
 	cancel()
 
-	if err = c.respForErr(cmdFailDurationGetRegion, start, err, resp.GetHeader()); err != nil {
+	err = c.respForErr(cmdFailDurationGetRegion, start, err, resp.GetHeader())
+	observeRPCDuration(rpcDurationGetRegionSucceeded, rpcDurationGetRegionFailed, start, err)
+	if err != nil {
 		return nil, err
 	}
 	return handleRegionResponse(resp), nil
@@ -1001,7 +1342,7 @@ func (c *client) GetPrevRegion(ctx context.Context, key []byte, opts ...GetRegio
 		NeedBuckets: options.needBuckets,
 	}
 	ctx = grpcutil.BuildForwardContext(ctx, c.GetLeaderAddr())
-	protoClient := c.getClient()
+	protoClient := c.getClient(ctx)
 	if protoClient == nil {
 		cancel()
 		return nil, errs.ErrClientGetProtoClient
@@ -1034,7 +1375,7 @@ func (c *client) GetRegionByID(ctx context.Context, regionID uint64, opts ...Get
 		NeedBuckets: options.needBuckets,
 	}
 	ctx = grpcutil.BuildForwardContext(ctx, c.GetLeaderAddr())
-	protoClient := c.getClient()
+	protoClient := c.getClient(ctx)
 	if protoClient == nil {
 		cancel()
 		return nil, errs.ErrClientGetProtoClient
@@ -1069,7 +1410,7 @@ func (c *client) ScanRegions(ctx context.Context, key, endKey []byte, limit int)
 		Limit:    int32(limit),
 	}
 	scanCtx = grpcutil.BuildForwardContext(scanCtx, c.GetLeaderAddr())
-	protoClient := c.getClient()
+	protoClient := c.getClient(scanCtx)
 	if protoClient == nil {
 		cancel()
 		return nil, errs.ErrClientGetProtoClient
@@ -1125,7 +1466,7 @@ func (c *client) GetStore(ctx context.Context, storeID uint64) (*metapb.Store, e
 		StoreId: storeID,
 	}
 	ctx = grpcutil.BuildForwardContext(ctx, c.GetLeaderAddr())
-	protoClient := c.getClient()
+	protoClient := c.getClient(ctx)
 	if protoClient == nil {
 		cancel()
 		return nil, errs.ErrClientGetProtoClient
@@ -1133,7 +1474,9 @@ func (c *client) GetStore(ctx context.Context, storeID uint64) (*metapb.Store, e
 	resp, err := protoClient.GetStore(ctx, req)
 	cancel()
 
-	if err = c.respForErr(cmdFailedDurationGetStore, start, err, resp.GetHeader()); err != nil {
+	err = c.respForErr(cmdFailedDurationGetStore, start, err, resp.GetHeader())
+	observeRPCDuration(rpcDurationGetStoreSucceeded, rpcDurationGetStoreFailed, start, err)
+	if err != nil {
 		return nil, err
 	}
 	return handleStoreResponse(resp)
@@ -1170,7 +1513,7 @@ func (c *client) GetAllStores(ctx context.Context, opts ...GetStoreOption) ([]*m
 		ExcludeTombstoneStores: options.excludeTombstone,
 	}
 	ctx = grpcutil.BuildForwardContext(ctx, c.GetLeaderAddr())
-	protoClient := c.getClient()
+	protoClient := c.getClient(ctx)
 	if protoClient == nil {
 		cancel()
 		return nil, errs.ErrClientGetProtoClient
@@ -1198,7 +1541,7 @@ func (c *client) UpdateGCSafePoint(ctx context.Context, safePoint uint64) (uint6
 		SafePoint: safePoint,
 	}
 	ctx = grpcutil.BuildForwardContext(ctx, c.GetLeaderAddr())
-	protoClient := c.getClient()
+	protoClient := c.getClient(ctx)
 	if protoClient == nil {
 		cancel()
 		return 0, errs.ErrClientGetProtoClient
@@ -1233,7 +1576,7 @@ func (c *client) UpdateServiceGCSafePoint(ctx context.Context, serviceID string,
 		SafePoint: safePoint,
 	}
 	ctx = grpcutil.BuildForwardContext(ctx, c.GetLeaderAddr())
-	protoClient := c.getClient()
+	protoClient := c.getClient(ctx)
 	if protoClient == nil {
 		cancel()
 		return 0, errs.ErrClientGetProtoClient
@@ -1266,7 +1609,7 @@ func (c *client) scatterRegionsWithGroup(ctx context.Context, regionID uint64, g
 		Group:    group,
 	}
 	ctx = grpcutil.BuildForwardContext(ctx, c.GetLeaderAddr())
-	protoClient := c.getClient()
+	protoClient := c.getClient(ctx)
 	if protoClient == nil {
 		cancel()
 		return errs.ErrClientGetProtoClient
@@ -1311,7 +1654,7 @@ func (c *client) SplitAndScatterRegions(ctx context.Context, splitKeys [][]byte,
 	}
 
 	ctx = grpcutil.BuildForwardContext(ctx, c.GetLeaderAddr())
-	protoClient := c.getClient()
+	protoClient := c.getClient(ctx)
 	if protoClient == nil {
 		cancel()
 		return nil, errs.ErrClientGetProtoClient
@@ -1334,7 +1677,7 @@ func (c *client) GetOperator(ctx context.Context, regionID uint64) (*pdpb.GetOpe
 		RegionId: regionID,
 	}
 	ctx = grpcutil.BuildForwardContext(ctx, c.GetLeaderAddr())
-	protoClient := c.getClient()
+	protoClient := c.getClient(ctx)
 	if protoClient == nil {
 		cancel()
 		return nil, errs.ErrClientGetProtoClient
@@ -1362,7 +1705,7 @@ func (c *client) SplitRegions(ctx context.Context, splitKeys [][]byte, opts ...R
 		RetryLimit: options.retryLimit,
 	}
 	ctx = grpcutil.BuildForwardContext(ctx, c.GetLeaderAddr())
-	protoClient := c.getClient()
+	protoClient := c.getClient(ctx)
 	if protoClient == nil {
 		cancel()
 		return nil, errs.ErrClientGetProtoClient
@@ -1392,7 +1735,7 @@ func (c *client) scatterRegionsWithOptions(ctx context.Context, regionsID []uint
 	}
 
 	ctx = grpcutil.BuildForwardContext(ctx, c.GetLeaderAddr())
-	protoClient := c.getClient()
+	protoClient := c.getClient(ctx)
 	if protoClient == nil {
 		cancel()
 		return nil, errs.ErrClientGetProtoClient
@@ -1440,7 +1783,7 @@ func trimHTTPPrefix(str string) string {
 }
 
 func (c *client) LoadGlobalConfig(ctx context.Context, names []string, configPath string) ([]GlobalConfigItem, int64, error) {
-	protoClient := c.getClient()
+	protoClient := c.getClient(ctx)
 	if protoClient == nil {
 		return nil, 0, errs.ErrClientGetProtoClient
 	}
@@ -1469,7 +1812,7 @@ func (c *client) StoreGlobalConfig(ctx context.Context, configPath string, items
 	for i, it := range items {
 		resArr[i] = &pdpb.GlobalConfigItem{Name: it.Name, Value: it.Value, Kind: it.EventType, Payload: it.PayLoad}
 	}
-	protoClient := c.getClient()
+	protoClient := c.getClient(ctx)
 	if protoClient == nil {
 		return errs.ErrClientGetProtoClient
 	}
@@ -1484,7 +1827,7 @@ func (c *client) WatchGlobalConfig(ctx context.Context, configPath string, revis
 	// TODO: Add retry mechanism
 	// register watch components there
 	globalConfigWatcherCh := make(chan []GlobalConfigItem, 16)
-	protoClient := c.getClient()
+	protoClient := c.getClient(ctx)
 	if protoClient == nil {
 		return nil, errs.ErrClientGetProtoClient
 	}
@@ -1530,7 +1873,7 @@ func (c *client) WatchGlobalConfig(ctx context.Context, configPath string, revis
 }
 
 func (c *client) GetExternalTimestamp(ctx context.Context) (uint64, error) {
-	protoClient := c.getClient()
+	protoClient := c.getClient(ctx)
 	if protoClient == nil {
 		return 0, errs.ErrClientGetProtoClient
 	}
@@ -1548,7 +1891,7 @@ func (c *client) GetExternalTimestamp(ctx context.Context) (uint64, error) {
 }
 
 func (c *client) SetExternalTimestamp(ctx context.Context, timestamp uint64) error {
-	protoClient := c.getClient()
+	protoClient := c.getClient(ctx)
 	if protoClient == nil {
 		return errs.ErrClientGetProtoClient
 	}
@@ -1587,3 +1930,13 @@ func (c *client) GetTSOAllocators() *sync.Map {
 	}
 	return tsoClient.GetTSOAllocators()
 }
+
+// GetAvailableDCLocations returns the sorted dc-locations which have a known local TSO allocator,
+// so the callers could validate the dc-location before requesting the Local TSO.
+func (c *client) GetAvailableDCLocations() []string {
+	tsoClient := c.getTSOClient()
+	if tsoClient == nil {
+		return nil
+	}
+	return tsoClient.GetAvailableDCLocations()
+}
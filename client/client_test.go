@@ -16,17 +16,45 @@ package pd
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/pingcap/errors"
+	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/pingcap/kvproto/pkg/pdpb"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
+	"github.com/tikv/pd/client/errs"
+	"github.com/tikv/pd/client/grpcutil"
 	"github.com/tikv/pd/client/testutil"
 	"github.com/tikv/pd/client/tlsutil"
 	"github.com/tikv/pd/client/tsoutil"
 	"go.uber.org/goleak"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
 )
 
 func TestMain(m *testing.M) {
@@ -66,6 +94,972 @@ func TestUpdateURLs(t *testing.T) {
 	re.Equal(getURLs([]*pdpb.Member{members[1], members[3], members[2], members[0]}), cli.GetServiceURLs())
 }
 
+func TestSwitchLeaderWithEmptyURLs(t *testing.T) {
+	re := require.New(t)
+	cli := &pdServiceDiscovery{option: newOption()}
+	for _, addrs := range [][]string{nil, {}} {
+		re.NotPanics(func() {
+			err := cli.switchLeader(addrs)
+			re.Error(err)
+			re.True(errs.ErrClientGetLeader.Equal(err))
+		})
+	}
+	re.Empty(cli.getLeaderAddr())
+}
+
+func TestSwitchLeaderWithMultipleURLs(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	re.NoError(err)
+	s := grpc.NewServer()
+	pdpb.RegisterPDServer(s, &pdpb.UnimplementedPDServer{})
+	go s.Serve(lis)
+	defer s.Stop()
+	reachable := "http://" + lis.Addr().String()
+	// Nothing listens on the unreachable URLs.
+	unreachableURLs := make([]string, 0, 2)
+	for i := 0; i < 2; i++ {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		re.NoError(err)
+		unreachableURLs = append(unreachableURLs, "http://"+l.Addr().String())
+		re.NoError(l.Close())
+	}
+	unreachable := unreachableURLs[0]
+
+	cli := &pdServiceDiscovery{
+		ctx:    ctx,
+		cancel: cancel,
+		tlsCfg: &tlsutil.TLSConfig{},
+		option: newOption(),
+	}
+	defer cli.Close()
+	switched := 0
+	cli.AddServingAddrSwitchedCallback(func() { switched++ })
+
+	// The first URL which connects is used without waiting out the timeout.
+	start := time.Now()
+	re.NoError(cli.switchLeader([]string{unreachable, reachable}))
+	re.Less(time.Since(start), updateMemberTimeout)
+	re.Equal(reachable, cli.getLeaderAddr())
+	re.Equal(1, switched)
+	// The leader is kept as long as it's still advertised.
+	re.NoError(cli.switchLeader([]string{reachable, unreachable}))
+	re.Equal(reachable, cli.getLeaderAddr())
+	re.Equal(1, switched)
+
+	// Fail if none of the URLs connects.
+	cli.leader.Store("")
+	re.Error(cli.switchLeader(unreachableURLs))
+	re.Empty(cli.getLeaderAddr())
+	re.Equal(1, switched)
+}
+
+func TestEagerFollowerDial(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	leader := &pdpb.Member{MemberId: 1, ClientUrls: []string{"http://127.0.0.1:1"}}
+	members := []*pdpb.Member{
+		leader,
+		{MemberId: 2, ClientUrls: []string{"http://127.0.0.1:2"}},
+		{MemberId: 3, ClientUrls: []string{"http://127.0.0.1:3"}},
+	}
+	countConns := func(cli *pdServiceDiscovery) int {
+		count := 0
+		cli.GetClientConns().Range(func(_, _ interface{}) bool {
+			count++
+			return true
+		})
+		return count
+	}
+
+	for _, eager := range []bool{false, true} {
+		cli := &pdServiceDiscovery{
+			ctx:    ctx,
+			cancel: cancel,
+			tlsCfg: &tlsutil.TLSConfig{},
+			option: newOption(),
+		}
+		cli.option.eagerFollowerDial = eager
+		cli.updateFollowers(members, leader)
+		re.Equal([]string{"http://127.0.0.1:2", "http://127.0.0.1:3"}, cli.GetBackupAddrs())
+		if eager {
+			re.Equal(2, countConns(cli))
+			for _, addr := range cli.GetBackupAddrs() {
+				_, ok := cli.GetClientConns().Load(grpcutil.NormalizeAddr(addr))
+				re.True(ok)
+			}
+		} else {
+			re.Zero(countConns(cli))
+		}
+		cli.Close()
+	}
+}
+
+func TestFollowerHealthCheck(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newFollower := func(lis net.Listener) *grpc.Server {
+		s := grpc.NewServer()
+		pdpb.RegisterPDServer(s, &membersPDServer{
+			addr:    lis.Addr().String(),
+			members: func() *pdpb.GetMembersResponse { return &pdpb.GetMembersResponse{Header: &pdpb.ResponseHeader{}} },
+			calls:   make(chan string, 1000),
+		})
+		go s.Serve(lis)
+		return s
+	}
+	var (
+		servers = make([]*grpc.Server, 0, 2)
+		addrs   = make([]string, 0, 2)
+	)
+	for i := 0; i < 2; i++ {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		re.NoError(err)
+		servers = append(servers, newFollower(lis))
+		addrs = append(addrs, "http://"+lis.Addr().String())
+	}
+	defer func() {
+		for _, s := range servers {
+			s.Stop()
+		}
+	}()
+
+	interval := 100 * time.Millisecond
+	var wg sync.WaitGroup
+	cli := &pdServiceDiscovery{
+		ctx:    ctx,
+		cancel: cancel,
+		wg:     &wg,
+		tlsCfg: &tlsutil.TLSConfig{},
+		option: newOption(),
+	}
+	cli.option.followerHealthCheckInterval = interval
+	defer func() {
+		cancel()
+		wg.Wait()
+		cli.Close()
+	}()
+	leader := &pdpb.Member{MemberId: 1, ClientUrls: []string{"http://127.0.0.1:1"}}
+	cli.updateFollowers([]*pdpb.Member{
+		leader,
+		{MemberId: 2, ClientUrls: []string{addrs[0]}},
+		{MemberId: 3, ClientUrls: []string{addrs[1]}},
+	}, leader)
+	wg.Add(1)
+	go cli.followerHealthCheckLoop()
+	re.Equal(addrs, cli.GetBackupAddrs())
+
+	// The dead follower is filtered out.
+	servers[1].Stop()
+	testutil.Eventually(re, func() bool {
+		return reflect.DeepEqual([]string{addrs[0]}, cli.GetBackupAddrs())
+	}, testutil.WithWaitFor(10*interval), testutil.WithTickInterval(interval/10))
+
+	// The recovered follower is added back.
+	lis, err := net.Listen("tcp", strings.TrimPrefix(addrs[1], "http://"))
+	re.NoError(err)
+	servers[1] = newFollower(lis)
+	testutil.Eventually(re, func() bool {
+		return reflect.DeepEqual(addrs, cli.GetBackupAddrs())
+	})
+}
+
+// membersPDServer is a PD server which records the GetMembers calls it receives.
+type membersPDServer struct {
+	pdpb.UnimplementedPDServer
+	addr    string
+	members func() *pdpb.GetMembersResponse
+	calls   chan<- string
+}
+
+func (s *membersPDServer) GetMembers(context.Context, *pdpb.GetMembersRequest) (*pdpb.GetMembersResponse, error) {
+	s.calls <- s.addr
+	return s.members(), nil
+}
+
+func TestUpdateMemberByLeaderPriority(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		calls   = make(chan string, 10)
+		addrs   = make([]string, 0, 2)
+		members []*pdpb.Member
+	)
+	getMembers := func() *pdpb.GetMembersResponse {
+		// The leader is not the member with the highest priority.
+		return &pdpb.GetMembersResponse{Header: &pdpb.ResponseHeader{}, Members: members, Leader: members[0]}
+	}
+	for i := 0; i < 2; i++ {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		re.NoError(err)
+		addr := "http://" + lis.Addr().String()
+		s := grpc.NewServer()
+		pdpb.RegisterPDServer(s, &membersPDServer{addr: addr, members: getMembers, calls: calls})
+		go s.Serve(lis)
+		defer s.Stop()
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	// The latter URL has the higher priority.
+	for i, addr := range addrs {
+		members = append(members, &pdpb.Member{
+			Name: addr, MemberId: uint64(i + 1), ClientUrls: []string{addr}, LeaderPriority: int32(i * 10),
+		})
+	}
+
+	cli := &pdServiceDiscovery{
+		ctx:    ctx,
+		cancel: cancel,
+		tlsCfg: &tlsutil.TLSConfig{},
+		option: newOption(),
+	}
+	defer cli.Close()
+	cli.urls.Store(addrs)
+	// Without the priorities, the URLs are queried in order.
+	re.Equal(addrs, cli.getPrioritizedServiceURLs())
+	re.NoError(cli.updateMember())
+	re.Equal(addrs[0], <-calls)
+
+	// With the priorities learned from the members, the highest-priority member is queried first.
+	re.Equal([]string{addrs[1], addrs[0]}, cli.getPrioritizedServiceURLs())
+	re.NoError(cli.updateMember())
+	re.Equal(addrs[1], <-calls)
+	re.Empty(calls)
+	re.Equal(addrs[0], cli.getLeaderAddr())
+}
+
+func TestUpdateMemberInterval(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	re.NoError(err)
+	addr := "http://" + lis.Addr().String()
+	var members atomic.Value
+	leader := &pdpb.Member{MemberId: 1, ClientUrls: []string{addr}}
+	members.Store([]*pdpb.Member{leader})
+	s := grpc.NewServer()
+	pdpb.RegisterPDServer(s, &membersPDServer{
+		addr: addr,
+		members: func() *pdpb.GetMembersResponse {
+			return &pdpb.GetMembersResponse{Header: &pdpb.ResponseHeader{}, Members: members.Load().([]*pdpb.Member), Leader: leader}
+		},
+		calls: make(chan string, 1000),
+	})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	var wg sync.WaitGroup
+	cli := &pdServiceDiscovery{
+		checkMembershipCh: make(chan struct{}, 1),
+		ctx:               ctx,
+		cancel:            cancel,
+		wg:                &wg,
+		tlsCfg:            &tlsutil.TLSConfig{},
+		option:            newOption(),
+	}
+	defer func() {
+		cancel()
+		wg.Wait()
+		cli.Close()
+	}()
+	cli.urls.Store([]string{addr})
+	re.NoError(cli.updateMember())
+	re.Empty(cli.GetBackupAddrs())
+	wg.Add(1)
+	go cli.updateMemberLoop()
+
+	// The new follower is not found within the default interval.
+	follower := "http://127.0.0.1:1"
+	members.Store([]*pdpb.Member{leader, {MemberId: 2, ClientUrls: []string{follower}}})
+	time.Sleep(200 * time.Millisecond)
+	re.Empty(cli.GetBackupAddrs())
+	// Lower the interval at runtime to pick up the membership change faster.
+	re.NoError(cli.option.setMemberUpdateInterval(50 * time.Millisecond))
+	testutil.Eventually(re, func() bool {
+		return reflect.DeepEqual([]string{follower}, cli.GetBackupAddrs())
+	}, testutil.WithWaitFor(time.Second), testutil.WithTickInterval(10*time.Millisecond))
+}
+
+func TestUpdateMemberBackoff(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	re.NoError(err)
+	addr := "http://" + lis.Addr().String()
+	var unavailable atomic.Bool
+	leader := &pdpb.Member{MemberId: 1, ClientUrls: []string{addr}}
+	calls := make(chan string, 1000)
+	s := grpc.NewServer()
+	pdpb.RegisterPDServer(s, &membersPDServer{
+		addr: addr,
+		members: func() *pdpb.GetMembersResponse {
+			if unavailable.Load() {
+				// No leader is returned to fail the member update.
+				return &pdpb.GetMembersResponse{Header: &pdpb.ResponseHeader{}, Members: []*pdpb.Member{leader}}
+			}
+			return &pdpb.GetMembersResponse{Header: &pdpb.ResponseHeader{}, Members: []*pdpb.Member{leader}, Leader: leader}
+		},
+		calls: calls,
+	})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	var wg sync.WaitGroup
+	option := newOption()
+	// Shorten the backoff to keep the test fast.
+	option.memberUpdateMinBackoff = 10 * time.Millisecond
+	option.memberUpdateMaxBackoff = 80 * time.Millisecond
+	cli := &pdServiceDiscovery{
+		checkMembershipCh: make(chan struct{}, 1),
+		ctx:               ctx,
+		cancel:            cancel,
+		wg:                &wg,
+		tlsCfg:            &tlsutil.TLSConfig{},
+		option:            option,
+	}
+	defer func() {
+		cancel()
+		wg.Wait()
+		cli.Close()
+	}()
+	cli.urls.Store([]string{addr})
+	re.NoError(cli.updateMember())
+	<-calls
+	wg.Add(1)
+	go cli.updateMemberLoop()
+
+	// Flood the loop with the checks while the member update keeps failing.
+	unavailable.Store(true)
+	for i := 0; i < 50; i++ {
+		cli.ScheduleCheckMemberChanged()
+		time.Sleep(10 * time.Millisecond)
+	}
+	// The retries back off from 10ms to 80ms instead of following every check.
+	re.LessOrEqual(len(calls), 25)
+	// The loop follows the checks again once the member update recovers.
+	unavailable.Store(false)
+	time.Sleep(2 * option.memberUpdateMaxBackoff)
+	for len(calls) > 0 {
+		<-calls
+	}
+	for i := 0; i < 3; i++ {
+		cli.ScheduleCheckMemberChanged()
+		select {
+		case <-calls:
+		case <-time.After(time.Second):
+			re.FailNow("the member is not updated")
+		}
+	}
+
+	re.Equal(option.memberUpdateMinBackoff, cli.nextMemberUpdateBackoff(0))
+	re.Equal(2*option.memberUpdateMinBackoff, cli.nextMemberUpdateBackoff(option.memberUpdateMinBackoff))
+	re.Equal(option.memberUpdateMaxBackoff, cli.nextMemberUpdateBackoff(option.memberUpdateMaxBackoff))
+}
+
+func TestServiceDiscoveryMetrics(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	re.NoError(err)
+	addr := "http://" + lis.Addr().String()
+	var leader atomic.Value
+	members := []*pdpb.Member{
+		{MemberId: 1, ClientUrls: []string{addr}},
+		{MemberId: 2, ClientUrls: []string{"http://127.0.0.1:1"}},
+		{MemberId: 3, ClientUrls: []string{"http://127.0.0.1:2", "http://127.0.0.1:3"}},
+	}
+	leader.Store(members[0])
+	s := grpc.NewServer()
+	pdpb.RegisterPDServer(s, &membersPDServer{
+		addr: addr,
+		members: func() *pdpb.GetMembersResponse {
+			return &pdpb.GetMembersResponse{Header: &pdpb.ResponseHeader{}, Members: members, Leader: leader.Load().(*pdpb.Member)}
+		},
+		calls: make(chan string, 10),
+	})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	cli := &pdServiceDiscovery{
+		ctx:    ctx,
+		cancel: cancel,
+		tlsCfg: &tlsutil.TLSConfig{},
+		option: newOption(),
+	}
+	defer cli.Close()
+	cli.urls.Store([]string{addr})
+
+	switches := promtestutil.ToFloat64(leaderSwitchCounter)
+	// The first leader found is not counted as a switch.
+	re.NoError(cli.updateMember())
+	re.Equal(switches, promtestutil.ToFloat64(leaderSwitchCounter))
+	// The followers are counted by the members rather than the URLs.
+	re.Equal(float64(2), promtestutil.ToFloat64(followerCountGauge))
+	// The same leader is not counted either.
+	re.NoError(cli.updateMember())
+	re.Equal(switches, promtestutil.ToFloat64(leaderSwitchCounter))
+	leader.Store(members[1])
+	cli.urls.Store([]string{addr})
+	re.NoError(cli.updateMember())
+	re.Equal(switches+1, promtestutil.ToFloat64(leaderSwitchCounter))
+
+	// The failures are counted by the URL.
+	failures := promtestutil.ToFloat64(updateMemberFailedCounter.WithLabelValues(addr))
+	leader.Store(&pdpb.Member{})
+	cli.urls.Store([]string{addr})
+	re.Error(cli.updateMember())
+	re.Equal(failures+1, promtestutil.ToFloat64(updateMemberFailedCounter.WithLabelValues(addr)))
+}
+
+func TestClusterIDChangedCallback(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	re.NoError(err)
+	addr := "http://" + lis.Addr().String()
+	var clusterID atomic.Uint64
+	clusterID.Store(2)
+	leader := &pdpb.Member{MemberId: 1, ClientUrls: []string{addr}}
+	s := grpc.NewServer()
+	pdpb.RegisterPDServer(s, &membersPDServer{
+		addr: addr,
+		members: func() *pdpb.GetMembersResponse {
+			return &pdpb.GetMembersResponse{
+				Header:  &pdpb.ResponseHeader{ClusterId: clusterID.Load()},
+				Members: []*pdpb.Member{leader},
+				Leader:  leader,
+			}
+		},
+		calls: make(chan string, 100),
+	})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	cli := &pdServiceDiscovery{
+		ctx:       ctx,
+		cancel:    cancel,
+		tlsCfg:    &tlsutil.TLSConfig{},
+		option:    newOption(),
+		clusterID: 1,
+	}
+	defer cli.Close()
+	cli.urls.Store([]string{addr})
+	var changes [][2]uint64
+	cli.AddClusterIDChangedCallback(func(old, new uint64) {
+		changes = append(changes, [2]uint64{old, new})
+	})
+
+	// The callback is called only once for the same cluster ID.
+	for i := 0; i < 2; i++ {
+		re.Error(cli.updateMember())
+		re.Equal([][2]uint64{{1, 2}}, changes)
+	}
+	clusterID.Store(3)
+	re.Error(cli.updateMember())
+	re.Equal([][2]uint64{{1, 2}, {1, 3}}, changes)
+	re.Equal(uint64(1), cli.GetClusterID())
+	// The same mismatch is reported again after the cluster ID matches.
+	clusterID.Store(1)
+	re.NoError(cli.updateMember())
+	clusterID.Store(3)
+	re.Error(cli.updateMember())
+	re.Equal([][2]uint64{{1, 2}, {1, 3}, {1, 3}}, changes)
+}
+
+func TestGetAllMembers(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	re.NoError(err)
+	addr := "http://" + lis.Addr().String()
+	calls := make(chan string, 10)
+	leader := &pdpb.Member{Name: "pd1", MemberId: 1, ClientUrls: []string{addr}, PeerUrls: []string{"http://127.0.0.1:1"}}
+	members := []*pdpb.Member{leader, {Name: "pd2", MemberId: 2, ClientUrls: []string{"http://127.0.0.1:2"}}}
+	s := grpc.NewServer()
+	pdpb.RegisterPDServer(s, &membersPDServer{
+		addr: addr,
+		members: func() *pdpb.GetMembersResponse {
+			return &pdpb.GetMembersResponse{Header: &pdpb.ResponseHeader{}, Members: members, Leader: leader}
+		},
+		calls: calls,
+	})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	cli := &pdServiceDiscovery{
+		ctx:    ctx,
+		cancel: cancel,
+		tlsCfg: &tlsutil.TLSConfig{},
+		option: newOption(),
+	}
+	defer cli.Close()
+	cli.urls.Store([]string{addr})
+
+	// The members are queried from the servers before the membership is learned.
+	got, err := cli.GetAllMembers(ctx)
+	re.NoError(err)
+	re.Equal(<-calls, addr)
+	re.Equal(members, got)
+
+	// The members learned from the last membership check are returned.
+	re.NoError(cli.updateMember())
+	re.Equal(<-calls, addr)
+	got, err = cli.GetAllMembers(ctx)
+	re.NoError(err)
+	re.Empty(calls)
+	re.Equal(members, got)
+	// The cached members are not affected by the caller.
+	got[0].Name = "changed"
+	got, err = cli.GetAllMembers(ctx)
+	re.NoError(err)
+	re.Equal("pd1", got[0].GetName())
+	// The existing behaviors are unchanged.
+	re.Equal(addr, cli.GetServingAddr())
+	re.Equal([]string{"http://127.0.0.1:2"}, cli.GetBackupAddrs())
+}
+
+func TestUpdateMemberWithLeaderLostGracePeriod(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		calls   = make(chan string, 10)
+		addrs   = make([]string, 0, 2)
+		members = make([]*pdpb.Member, 0, 2)
+		// leaderUnresponsive makes the first URL, i.e., the initial leader, return a hard header error.
+		leaderUnresponsive atomic.Bool
+	)
+	for i := 0; i < 2; i++ {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		re.NoError(err)
+		addr := "http://" + lis.Addr().String()
+		idx := i
+		// Each member regards itself as the leader.
+		getMembers := func() *pdpb.GetMembersResponse {
+			resp := &pdpb.GetMembersResponse{Header: &pdpb.ResponseHeader{}, Members: members, Leader: members[idx]}
+			if idx == 0 && leaderUnresponsive.Load() {
+				resp.Header.Error = &pdpb.Error{Type: pdpb.ErrorType_UNKNOWN, Message: "injected"}
+			}
+			return resp
+		}
+		s := grpc.NewServer()
+		pdpb.RegisterPDServer(s, &membersPDServer{addr: addr, members: getMembers, calls: calls})
+		go s.Serve(lis)
+		defer s.Stop()
+		addrs = append(addrs, addr)
+		members = append(members, &pdpb.Member{Name: addr, MemberId: uint64(i + 1), ClientUrls: []string{addr}})
+	}
+
+	const gracePeriod = 500 * time.Millisecond
+	cli := &pdServiceDiscovery{
+		ctx:    ctx,
+		cancel: cancel,
+		tlsCfg: &tlsutil.TLSConfig{},
+		option: newOption(),
+	}
+	defer cli.Close()
+	WithLeaderLostGracePeriod(gracePeriod)(&client{option: cli.option})
+	// The second URL is queried first, but the known leader is always checked first.
+	cli.urls.Store([]string{addrs[1], addrs[0]})
+	cli.leader.Store(addrs[0])
+	re.NoError(cli.updateMember())
+	re.Equal(addrs[0], <-calls)
+	re.Empty(calls)
+
+	// The briefly unresponsive leader is kept within the grace period.
+	leaderUnresponsive.Store(true)
+	re.Error(cli.updateMember())
+	re.Equal(addrs[0], <-calls)
+	re.Empty(calls)
+	re.Equal(addrs[0], cli.getLeaderAddr())
+	// The leader recovers, which resets the grace period.
+	leaderUnresponsive.Store(false)
+	re.NoError(cli.updateMember())
+	re.Equal(addrs[0], <-calls)
+	re.Equal(addrs[0], cli.getLeaderAddr())
+
+	// The leader is abandoned after being unreachable for the whole grace period.
+	leaderUnresponsive.Store(true)
+	re.Error(cli.updateMember())
+	re.Equal(addrs[0], <-calls)
+	re.Equal(addrs[0], cli.getLeaderAddr())
+	time.Sleep(gracePeriod)
+	re.NoError(cli.updateMember())
+	re.Equal(addrs[0], <-calls)
+	re.Equal(addrs[1], <-calls)
+	re.Empty(calls)
+	re.Equal(addrs[1], cli.getLeaderAddr())
+}
+
+func TestInitPhaseErrors(t *testing.T) {
+	re := require.New(t)
+	calls := make(chan string, 10)
+	initWithMembers := func(resp *pdpb.GetMembersResponse) error {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		re.NoError(err)
+		addr := "http://" + lis.Addr().String()
+		s := grpc.NewServer()
+		pdpb.RegisterPDServer(s, &membersPDServer{addr: addr, members: func() *pdpb.GetMembersResponse { return resp }, calls: calls})
+		go s.Serve(lis)
+		defer s.Stop()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		cli := &pdServiceDiscovery{
+			ctx:    ctx,
+			cancel: cancel,
+			tlsCfg: &tlsutil.TLSConfig{},
+			option: newOption(),
+		}
+		defer cli.Close()
+		cli.option.maxRetryTimes = 1
+		cli.urls.Store([]string{addr})
+		return cli.Init()
+	}
+
+	// The cluster ID can't be discovered without the response header.
+	err := initWithMembers(&pdpb.GetMembersResponse{})
+	re.ErrorIs(err, errs.ErrClientInitClusterID)
+	re.NotErrorIs(err, errs.ErrClientInitMember)
+	// The cause is kept.
+	re.Equal(errFailInitClusterID, errors.Cause(err))
+	// The initial membership fails without the leader.
+	err = initWithMembers(&pdpb.GetMembersResponse{
+		Header:  &pdpb.ResponseHeader{ClusterId: 1},
+		Members: []*pdpb.Member{{Name: "pd", MemberId: 1}},
+	})
+	re.ErrorIs(err, errs.ErrClientInitMember)
+	re.NotErrorIs(err, errs.ErrClientInitClusterID)
+	re.True(errs.ErrClientGetMember.Equal(errors.Cause(err)), err)
+}
+
+func TestDumpDiscoveryState(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	re.NoError(err)
+	addr := "http://" + lis.Addr().String()
+	leader := &pdpb.Member{Name: "pd1", MemberId: 1, ClientUrls: []string{addr}}
+	members := []*pdpb.Member{
+		leader,
+		{Name: "pd2", MemberId: 2, ClientUrls: []string{"http://127.0.0.1:2"}},
+		{Name: "pd3", MemberId: 3, ClientUrls: []string{"http://127.0.0.1:3"}},
+	}
+	getMembers := func() *pdpb.GetMembersResponse {
+		return &pdpb.GetMembersResponse{
+			Header:              &pdpb.ResponseHeader{ClusterId: 1},
+			Members:             members,
+			Leader:              leader,
+			TsoAllocatorLeaders: map[string]*pdpb.Member{"dc-1": leader},
+		}
+	}
+	s := grpc.NewServer()
+	pdpb.RegisterPDServer(s, &membersPDServer{addr: addr, members: getMembers, calls: make(chan string, 100)})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	var wg sync.WaitGroup
+	cli := newPDServiceDiscovery(ctx, cancel, &wg, func(pdpb.ServiceMode) {}, nil, defaultKeyspaceID,
+		[]string{addr}, &tlsutil.TLSConfig{}, newOption())
+	re.NoError(cli.Init())
+	defer cli.Close()
+
+	data, err := cli.DumpState()
+	re.NoError(err)
+	var state discoveryState
+	re.NoError(json.Unmarshal(data, &state))
+	re.Equal(uint64(1), state.ClusterID)
+	re.Equal(addr, state.Leader)
+	re.ElementsMatch([]string{"http://127.0.0.1:2", "http://127.0.0.1:3"}, state.Followers)
+	re.Equal(map[string]string{"dc-1": addr}, state.AllocatorLeaders)
+	re.Contains(state.Connections, grpcutil.NormalizeAddr(addr))
+}
+
+func TestLeaderHintStore(t *testing.T) {
+	re := require.New(t)
+	calls := make(chan string, 100)
+	addrs := make([]string, 0, 3)
+	members := make([]*pdpb.Member, 0, 3)
+	for i := 0; i < 3; i++ {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		re.NoError(err)
+		addr := "http://" + lis.Addr().String()
+		// The last member is the leader.
+		getMembers := func() *pdpb.GetMembersResponse {
+			return &pdpb.GetMembersResponse{Header: &pdpb.ResponseHeader{ClusterId: 1}, Members: members, Leader: members[2]}
+		}
+		s := grpc.NewServer()
+		pdpb.RegisterPDServer(s, &membersPDServer{addr: addr, members: getMembers, calls: calls})
+		go s.Serve(lis)
+		defer s.Stop()
+		addrs = append(addrs, addr)
+		members = append(members, &pdpb.Member{Name: addr, MemberId: uint64(i + 1), ClientUrls: []string{addr}})
+	}
+	seeds, leader := addrs[:2], addrs[2]
+	// The server of another cluster, e.g., the leader address is reused after the hint is saved.
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	re.NoError(err)
+	otherCluster := "http://" + lis.Addr().String()
+	s := grpc.NewServer()
+	pdpb.RegisterPDServer(s, &membersPDServer{addr: otherCluster, calls: calls, members: func() *pdpb.GetMembersResponse {
+		member := &pdpb.Member{Name: otherCluster, MemberId: 1, ClientUrls: []string{otherCluster}}
+		return &pdpb.GetMembersResponse{Header: &pdpb.ResponseHeader{ClusterId: 2}, Members: []*pdpb.Member{member}, Leader: member}
+	}})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	initWithHint := func(hint string) (saved []string) {
+		ctx, cancel := context.WithCancel(context.Background())
+		var wg sync.WaitGroup
+		defer wg.Wait()
+		defer cancel()
+		option := newOption()
+		WithLeaderHintStore(
+			func(leader string) error {
+				saved = append(saved, leader)
+				return nil
+			},
+			func() (string, error) { return hint, nil },
+		)(&client{option: option})
+		cli := newPDServiceDiscovery(ctx, cancel, &wg, func(pdpb.ServiceMode) {}, nil, defaultKeyspaceID,
+			seeds, &tlsutil.TLSConfig{}, option)
+		defer cli.Close()
+		re.NoError(cli.Init())
+		re.Equal(uint64(1), cli.GetClusterID())
+		re.Equal(leader, cli.getLeaderAddr())
+		return saved
+	}
+	drain := func() []string {
+		var got []string
+		for len(calls) > 0 {
+			got = append(got, <-calls)
+		}
+		return got
+	}
+
+	// Without the hint, all the seed URLs are scanned for the cluster ID.
+	re.Equal([]string{leader}, initWithHint(""))
+	got := drain()
+	re.GreaterOrEqual(len(got), 3)
+	re.Equal([]string{seeds[0], seeds[1], seeds[0]}, got[:3])
+	// The cluster ID is still confirmed by the seed URLs with the hint, then the hinted leader is
+	// tried first, even if it's not one of the seed URLs.
+	re.Equal([]string{leader}, initWithHint(leader))
+	got = drain()
+	re.GreaterOrEqual(len(got), 3)
+	re.Equal([]string{seeds[0], seeds[1], leader}, got[:3])
+	// The hinted leader of another cluster is skipped.
+	re.Equal([]string{leader}, initWithHint(otherCluster))
+	got = drain()
+	re.GreaterOrEqual(len(got), 4)
+	re.Equal([]string{seeds[0], seeds[1], otherCluster, seeds[0]}, got[:4])
+	// The unreachable hinted leader falls back to the seed URLs.
+	re.Equal([]string{leader}, initWithHint("http://127.0.0.1:1"))
+	got = drain()
+	re.GreaterOrEqual(len(got), 3)
+	re.Equal([]string{seeds[0], seeds[1], seeds[0]}, got[:3])
+}
+
+func TestInitClusterIDWithNotReadyURL(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	calls := make(chan string, 100)
+	startServer := func(clusterID uint64) string {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		re.NoError(err)
+		addr := "http://" + lis.Addr().String()
+		getMembers := func() *pdpb.GetMembersResponse {
+			return &pdpb.GetMembersResponse{Header: &pdpb.ResponseHeader{ClusterId: clusterID}}
+		}
+		s := grpc.NewServer()
+		pdpb.RegisterPDServer(s, &membersPDServer{addr: addr, members: getMembers, calls: calls})
+		go s.Serve(lis)
+		t.Cleanup(s.Stop)
+		return addr
+	}
+	ready1, notReady, ready2, other := startServer(1), startServer(0), startServer(1), startServer(2)
+
+	cli := &pdServiceDiscovery{
+		ctx:    ctx,
+		cancel: cancel,
+		tlsCfg: &tlsutil.TLSConfig{},
+		option: newOption(),
+	}
+	defer cli.Close()
+	// The zero cluster ID is skipped wherever it is.
+	for _, urls := range [][]string{
+		{notReady, ready1, ready2},
+		{ready1, notReady, ready2},
+		{ready1, ready2, notReady},
+	} {
+		cli.clusterID = 0
+		cli.urls.Store(urls)
+		re.NoError(cli.initClusterID())
+		re.Equal(uint64(1), cli.clusterID)
+	}
+	// Only the zero cluster IDs fail to init.
+	cli.clusterID = 0
+	cli.urls.Store([]string{notReady})
+	re.ErrorIs(cli.initClusterID(), errFailInitClusterID)
+	// The different non-zero cluster IDs are still a mismatch.
+	cli.urls.Store([]string{ready1, notReady, other})
+	re.ErrorIs(cli.initClusterID(), errUnmatchedClusterID)
+}
+
+func TestUpdateMemberWithSoftHeaderError(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		calls = make(chan string, 10)
+		addrs = make([]string, 0, 2)
+		// errTypes are the header error types returned by the first URL in order.
+		errTypes []pdpb.ErrorType
+	)
+	for i := 0; i < 2; i++ {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		re.NoError(err)
+		addr := "http://" + lis.Addr().String()
+		first := i == 0
+		getMembers := func() *pdpb.GetMembersResponse {
+			leader := &pdpb.Member{Name: addr, MemberId: 1, ClientUrls: []string{addr}}
+			resp := &pdpb.GetMembersResponse{Header: &pdpb.ResponseHeader{}, Members: []*pdpb.Member{leader}, Leader: leader}
+			if first && len(errTypes) > 0 {
+				resp.Header.Error = &pdpb.Error{Type: errTypes[0], Message: "injected"}
+				errTypes = errTypes[1:]
+			}
+			return resp
+		}
+		s := grpc.NewServer()
+		pdpb.RegisterPDServer(s, &membersPDServer{addr: addr, members: getMembers, calls: calls})
+		go s.Serve(lis)
+		defer s.Stop()
+		addrs = append(addrs, addr)
+	}
+
+	cli := &pdServiceDiscovery{
+		ctx:    ctx,
+		cancel: cancel,
+		tlsCfg: &tlsutil.TLSConfig{},
+		option: newOption(),
+	}
+	defer cli.Close()
+	urls := append([]string(nil), addrs...)
+	// The soft header errors should not make the client skip the URL.
+	errTypes = []pdpb.ErrorType{pdpb.ErrorType_NOT_BOOTSTRAPPED, pdpb.ErrorType_NOT_BOOTSTRAPPED}
+	cli.urls.Store(urls)
+	re.NoError(cli.updateMember())
+	for i := 0; i < 3; i++ {
+		re.Equal(addrs[0], <-calls)
+	}
+	re.Empty(calls)
+	re.Equal(addrs[0], cli.getLeaderAddr())
+
+	// The hard header errors make the client try the next URL.
+	errTypes = []pdpb.ErrorType{pdpb.ErrorType_UNKNOWN}
+	cli.urls.Store(urls)
+	re.NoError(cli.updateMember())
+	re.Equal(addrs[0], <-calls)
+	re.Equal(addrs[1], <-calls)
+	re.Empty(calls)
+	re.Equal(addrs[1], cli.getLeaderAddr())
+
+	// The soft error types are configurable.
+	WithSoftMemberErrorTypes()(&client{option: cli.option})
+	errTypes = []pdpb.ErrorType{pdpb.ErrorType_NOT_BOOTSTRAPPED}
+	cli.urls.Store(urls)
+	re.NoError(cli.updateMember())
+	re.Equal(addrs[0], <-calls)
+	re.Equal(addrs[1], <-calls)
+	re.Empty(calls)
+}
+
+// hangingPDServer is a PD server whose health check and GetRegion hang until the request is canceled.
+type hangingPDServer struct {
+	pdpb.UnimplementedPDServer
+}
+
+func (*hangingPDServer) GetRegion(ctx context.Context, _ *pdpb.GetRegionRequest) (*pdpb.GetRegionResponse, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (*hangingPDServer) Check(ctx context.Context, _ *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (*hangingPDServer) Watch(*healthpb.HealthCheckRequest, healthpb.Health_WatchServer) error {
+	return nil
+}
+
+func TestRequestRespectsContextDeadline(t *testing.T) {
+	re := require.New(t)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	re.NoError(err)
+	addr := "http://" + lis.Addr().String()
+	s := grpc.NewServer()
+	hanging := &hangingPDServer{}
+	pdpb.RegisterPDServer(s, hanging)
+	healthpb.RegisterHealthServer(s, hanging)
+	go s.Serve(lis)
+	defer s.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	option := newOption()
+	// The internal timeout is much longer than the caller's deadline.
+	option.timeout = 10 * time.Second
+	option.enableForwarding = true
+	sd := &pdServiceDiscovery{
+		ctx:    ctx,
+		cancel: cancel,
+		tlsCfg: &tlsutil.TLSConfig{},
+		option: option,
+	}
+	defer sd.Close()
+	sd.leader.Store(addr)
+	sd.followers.Store([]string{addr})
+	_, err = sd.GetOrCreateGRPCConn(addr)
+	re.NoError(err)
+	cli := &client{ctx: ctx, cancel: cancel, option: option, pdSvcDiscovery: sd}
+
+	for _, leaderNetworkFailure := range []int32{0, 1} {
+		// If the leader is unreachable, the client will check the health of the followers first.
+		cli.leaderNetworkFailure = leaderNetworkFailure
+		reqCtx, reqCancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		start := time.Now()
+		_, err = cli.GetRegion(reqCtx, []byte("a"))
+		reqCancel()
+		re.ErrorContains(err, context.DeadlineExceeded.Error())
+		re.Less(time.Since(start), 2*time.Second)
+	}
+}
+
 const testClientURL = "tmp://test.url:5255"
 
 func TestClientCtx(t *testing.T) {
@@ -105,6 +1099,244 @@ func TestGRPCDialOption(t *testing.T) {
 	re.Greater(time.Since(start), 500*time.Millisecond)
 }
 
+type largeResponsePDServer struct {
+	pdpb.UnimplementedPDServer
+	regions []*pdpb.Region
+}
+
+func (s *largeResponsePDServer) ScanRegions(context.Context, *pdpb.ScanRegionsRequest) (*pdpb.ScanRegionsResponse, error) {
+	return &pdpb.ScanRegionsResponse{Header: &pdpb.ResponseHeader{}, Regions: s.regions}, nil
+}
+
+// BenchmarkLargeResponseWindowSize compares the throughput of the large ScanRegions responses
+// with the default and the enlarged initial window sizes.
+func BenchmarkLargeResponseWindowSize(b *testing.B) {
+	// Each response is about 8MB.
+	regions := make([]*pdpb.Region, 0, 8192)
+	for i := 0; i < cap(regions); i++ {
+		regions = append(regions, &pdpb.Region{Region: &metapb.Region{
+			Id:       uint64(i),
+			StartKey: []byte(fmt.Sprintf("%0512d", i)),
+			EndKey:   []byte(fmt.Sprintf("%0512d", i+1)),
+		}})
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	addr := "http://" + lis.Addr().String()
+	s := grpc.NewServer(
+		grpc.InitialWindowSize(16<<20),
+		grpc.InitialConnWindowSize(32<<20),
+		grpc.MaxSendMsgSize(math.MaxInt32),
+	)
+	pdpb.RegisterPDServer(s, &largeResponsePDServer{regions: regions})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	for _, bc := range []struct {
+		name                  string
+		initialWindowSize     int32
+		initialConnWindowSize int32
+	}{
+		{"default", 0, 0},
+		{"4MB", 4 << 20, 8 << 20},
+		{"16MB", 16 << 20, 32 << 20},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			option := newOption()
+			option.initialWindowSize = bc.initialWindowSize
+			option.initialConnWindowSize = bc.initialConnWindowSize
+			option.gRPCDialOptions = []grpc.DialOption{grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(math.MaxInt32))}
+			sd := &pdServiceDiscovery{ctx: ctx, cancel: cancel, tlsCfg: &tlsutil.TLSConfig{}, option: option}
+			defer sd.Close()
+			cc, err := sd.GetOrCreateGRPCConn(addr)
+			if err != nil {
+				b.Fatal(err)
+			}
+			cli := pdpb.NewPDClient(cc)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				resp, err := cli.ScanRegions(ctx, &pdpb.ScanRegionsRequest{})
+				if err != nil {
+					b.Fatal(err)
+				}
+				b.SetBytes(int64(resp.Size()))
+			}
+		})
+	}
+}
+
+func TestMaxRecvMsgSize(t *testing.T) {
+	re := require.New(t)
+	// The response is about 6MB, which is larger than the gRPC default 4MB.
+	regions := make([]*pdpb.Region, 0, 6144)
+	for i := 0; i < cap(regions); i++ {
+		regions = append(regions, &pdpb.Region{Region: &metapb.Region{
+			Id:       uint64(i),
+			StartKey: []byte(fmt.Sprintf("%0512d", i)),
+			EndKey:   []byte(fmt.Sprintf("%0512d", i+1)),
+		}})
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	re.NoError(err)
+	addr := "http://" + lis.Addr().String()
+	s := grpc.NewServer(grpc.MaxSendMsgSize(math.MaxInt32))
+	pdpb.RegisterPDServer(s, &largeResponsePDServer{regions: regions})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	scanRegions := func(opts ...ClientOption) error {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		c := &client{option: newOption()}
+		for _, opt := range opts {
+			opt(c)
+		}
+		sd := &pdServiceDiscovery{ctx: ctx, cancel: cancel, tlsCfg: &tlsutil.TLSConfig{}, option: c.option}
+		defer sd.Close()
+		cc, err := sd.GetOrCreateGRPCConn(addr)
+		re.NoError(err)
+		_, err = pdpb.NewPDClient(cc).ScanRegions(ctx, &pdpb.ScanRegionsRequest{})
+		return err
+	}
+	// The response exceeds the gRPC default limit.
+	err = scanRegions(WithMaxRecvMsgSize(4 << 20))
+	re.Error(err)
+	re.Equal(codes.ResourceExhausted, status.Code(err))
+	// The response is received with the raised limit.
+	re.NoError(scanRegions())
+	re.NoError(scanRegions(WithMaxRecvMsgSize(8 << 20)))
+}
+
+func TestGRPCInterceptors(t *testing.T) {
+	re := require.New(t)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	re.NoError(err)
+	addr := "http://" + lis.Addr().String()
+	s := grpc.NewServer()
+	pdpb.RegisterPDServer(s, &largeResponsePDServer{})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	var calls []string
+	unary := func(name string) grpc.UnaryClientInterceptor {
+		return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			calls = append(calls, name+" "+method)
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+	}
+	stream := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		calls = append(calls, "stream "+method)
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := &client{option: newOption()}
+	for _, opt := range []ClientOption{
+		WithUnaryInterceptors(unary("first"), unary("second")),
+		WithStreamInterceptors(stream),
+		// The interceptor set by the dial options should not override the chained ones.
+		WithGRPCDialOptions(grpc.WithUnaryInterceptor(unary("dial"))),
+	} {
+		opt(c)
+	}
+	pdSD := &pdServiceDiscovery{ctx: ctx, cancel: cancel, tlsCfg: &tlsutil.TLSConfig{}, option: c.option}
+	defer pdSD.Close()
+	cc, err := pdSD.GetOrCreateGRPCConn(addr)
+	re.NoError(err)
+	_, err = pdpb.NewPDClient(cc).ScanRegions(ctx, &pdpb.ScanRegionsRequest{})
+	re.NoError(err)
+	re.Equal([]string{
+		"dial /pdpb.PD/ScanRegions",
+		"first /pdpb.PD/ScanRegions",
+		"second /pdpb.PD/ScanRegions",
+	}, calls)
+
+	// The connections created by the TSO service discovery use the same interceptors.
+	calls = calls[:0]
+	tsoSD := &tsoServiceDiscovery{ctx: ctx, cancel: cancel, tlsCfg: &tlsutil.TLSConfig{}, option: c.option}
+	cc, err = tsoSD.GetOrCreateGRPCConn(addr)
+	re.NoError(err)
+	defer cc.Close()
+	streamCtx, streamCancel := context.WithCancel(ctx)
+	defer streamCancel()
+	_, err = pdpb.NewPDClient(cc).Tso(streamCtx)
+	re.NoError(err)
+	re.Equal([]string{"stream /pdpb.PD/Tso"}, calls)
+}
+
+func TestEquivalentAddrsShareGRPCConn(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sd := &pdServiceDiscovery{ctx: ctx, cancel: cancel, tlsCfg: &tlsutil.TLSConfig{}, option: newOption()}
+	defer sd.Close()
+
+	cc, err := sd.GetOrCreateGRPCConn("http://LocalHost:2379")
+	re.NoError(err)
+	for _, addr := range []string{
+		"http://localhost:2379",
+		"http://localhost:2379/",
+		"https://localhost:2379",
+		"localhost:2379",
+		"localhost:2379/",
+	} {
+		other, err := sd.GetOrCreateGRPCConn(addr)
+		re.NoError(err)
+		re.Same(cc, other, addr)
+	}
+	sd.leader.Store("localhost:2379/")
+	re.Same(cc, sd.GetServingEndpointClientConn())
+
+	// A different endpoint should not share the connection.
+	other, err := sd.GetOrCreateGRPCConn("localhost:2380")
+	re.NoError(err)
+	re.NotSame(cc, other)
+	count := 0
+	sd.GetClientConns().Range(func(_, _ interface{}) bool {
+		count++
+		return true
+	})
+	re.Equal(2, count)
+}
+
+func TestGRPCConnLivenessCheck(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sd := &pdServiceDiscovery{ctx: ctx, cancel: cancel, tlsCfg: &tlsutil.TLSConfig{}, option: newOption()}
+	defer sd.Close()
+
+	const addr = "http://127.0.0.1:2379"
+	cc, err := sd.GetOrCreateGRPCConn(addr)
+	re.NoError(err)
+	// Corrupt the cached connection.
+	re.NoError(cc.Close())
+	re.Equal(connectivity.Shutdown, cc.GetState())
+	// The cached connection is returned directly without the liveness check.
+	other, err := sd.GetOrCreateGRPCConn(addr)
+	re.NoError(err)
+	re.Same(cc, other)
+
+	// A healthy connection should be returned with the liveness check.
+	sd.option.checkConnLiveness = true
+	other, err = sd.GetOrCreateGRPCConn(addr)
+	re.NoError(err)
+	re.NotSame(cc, other)
+	re.NotEqual(connectivity.Shutdown, other.GetState())
+	cached, ok := sd.GetClientConns().Load(grpcutil.NormalizeAddr(addr))
+	re.True(ok)
+	re.Same(other, cached)
+	// The live connection is reused.
+	again, err := sd.GetOrCreateGRPCConn(addr)
+	re.NoError(err)
+	re.Same(other, again)
+}
+
 func TestTsoRequestWait(t *testing.T) {
 	re := require.New(t)
 	ctx, cancel := context.WithCancel(context.Background())
@@ -131,3 +1363,245 @@ func TestTsoRequestWait(t *testing.T) {
 	_, _, err = req.Wait()
 	re.ErrorIs(errors.Cause(err), context.Canceled)
 }
+
+func TestValidateSecurityOption(t *testing.T) {
+	re := require.New(t)
+	// No TLS is configured.
+	re.NoError(ValidateSecurityOption(SecurityOption{}))
+
+	genCertAndKey := func() (certPEM, keyPEM []byte) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		re.NoError(err)
+		template := &x509.Certificate{
+			SerialNumber:          big.NewInt(1),
+			Subject:               pkix.Name{CommonName: "pd-client-test"},
+			NotBefore:             time.Now(),
+			NotAfter:              time.Now().Add(time.Hour),
+			IsCA:                  true,
+			BasicConstraintsValid: true,
+		}
+		certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+		re.NoError(err)
+		keyDER, err := x509.MarshalECPrivateKey(key)
+		re.NoError(err)
+		return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}),
+			pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	}
+	certPEM, keyPEM := genCertAndKey()
+	_, otherKeyPEM := genCertAndKey()
+
+	// The in-memory bytes.
+	re.NoError(ValidateSecurityOption(SecurityOption{SSLCABytes: certPEM, SSLCertBytes: certPEM, SSLKEYBytes: keyPEM}))
+	err := ValidateSecurityOption(SecurityOption{SSLCABytes: certPEM, SSLCertBytes: certPEM, SSLKEYBytes: otherKeyPEM})
+	re.True(errs.ErrSecurityConfig.Equal(err))
+	re.Contains(err.Error(), "private key does not match public key")
+
+	// The file paths.
+	dir := t.TempDir()
+	writeFile := func(name string, content []byte) string {
+		path := filepath.Join(dir, name)
+		re.NoError(os.WriteFile(path, content, 0o600))
+		return path
+	}
+	caPath, certPath := writeFile("ca.pem", certPEM), writeFile("cert.pem", certPEM)
+	keyPath, otherKeyPath := writeFile("key.pem", keyPEM), writeFile("other-key.pem", otherKeyPEM)
+	re.NoError(ValidateSecurityOption(SecurityOption{CAPath: caPath, CertPath: certPath, KeyPath: keyPath}))
+	err = ValidateSecurityOption(SecurityOption{CAPath: caPath, CertPath: certPath, KeyPath: otherKeyPath})
+	re.True(errs.ErrSecurityConfig.Equal(err))
+	re.Contains(err.Error(), "private key does not match public key")
+	err = ValidateSecurityOption(SecurityOption{CAPath: caPath, CertPath: filepath.Join(dir, "not-exist.pem"), KeyPath: keyPath})
+	re.True(errs.ErrSecurityConfig.Equal(err))
+	re.Contains(err.Error(), "no such file or directory")
+}
+
+func TestUpdateTLSConfig(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	type certAuthority struct {
+		cert   *x509.Certificate
+		key    *ecdsa.PrivateKey
+		pem    []byte
+		serial int64
+	}
+	genCA := func() *certAuthority {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		re.NoError(err)
+		template := &x509.Certificate{
+			SerialNumber:          big.NewInt(1),
+			Subject:               pkix.Name{CommonName: "pd-client-test-ca"},
+			NotBefore:             time.Now(),
+			NotAfter:              time.Now().Add(time.Hour),
+			IsCA:                  true,
+			KeyUsage:              x509.KeyUsageCertSign,
+			BasicConstraintsValid: true,
+		}
+		certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+		re.NoError(err)
+		cert, err := x509.ParseCertificate(certDER)
+		re.NoError(err)
+		return &certAuthority{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}), serial: 1}
+	}
+	// issue returns the certificate and the key in PEM signed by the CA.
+	issue := func(ca *certAuthority) (certPEM, keyPEM []byte) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		re.NoError(err)
+		ca.serial++
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(ca.serial),
+			Subject:      pkix.Name{CommonName: "pd-client-test"},
+			NotBefore:    time.Now(),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+			IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		}
+		certDER, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+		re.NoError(err)
+		keyDER, err := x509.MarshalECPrivateKey(key)
+		re.NoError(err)
+		return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}),
+			pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	}
+	tlsConfigOf := func(ca *certAuthority) *tlsutil.TLSConfig {
+		certPEM, keyPEM := issue(ca)
+		return &tlsutil.TLSConfig{SSLCABytes: ca.pem, SSLCertBytes: certPEM, SSLKEYBytes: keyPEM}
+	}
+
+	// The server presents the certificate signed by the current CA.
+	oldCA, newCA := genCA(), genCA()
+	var serverCert atomic.Value
+	setServerCA := func(ca *certAuthority) {
+		certPEM, keyPEM := issue(ca)
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		re.NoError(err)
+		serverCert.Store(&cert)
+	}
+	setServerCA(oldCA)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	re.NoError(err)
+	addr := "https://" + lis.Addr().String()
+	leader := &pdpb.Member{MemberId: 1, ClientUrls: []string{addr}}
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(&tls.Config{
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return serverCert.Load().(*tls.Certificate), nil
+		},
+	})))
+	pdpb.RegisterPDServer(s, &membersPDServer{
+		addr: addr,
+		members: func() *pdpb.GetMembersResponse {
+			return &pdpb.GetMembersResponse{Header: &pdpb.ResponseHeader{}, Members: []*pdpb.Member{leader}, Leader: leader}
+		},
+		calls: make(chan string, 100),
+	})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	cli := &pdServiceDiscovery{
+		ctx:    ctx,
+		cancel: cancel,
+		tlsCfg: tlsConfigOf(oldCA),
+		option: newOption(),
+	}
+	defer cli.Close()
+	getMembers := func(cc *grpc.ClientConn) error {
+		ctx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+		_, err := pdpb.NewPDClient(cc).GetMembers(ctx, &pdpb.GetMembersRequest{}, grpc.WaitForReady(true))
+		return err
+	}
+	oldConn, err := cli.GetOrCreateGRPCConn(addr)
+	re.NoError(err)
+	re.NoError(getMembers(oldConn))
+
+	// The invalid config is rejected and the working one is kept.
+	re.Error(cli.UpdateTLSConfig(&tlsutil.TLSConfig{SSLCABytes: newCA.pem}))
+	cc, err := cli.GetOrCreateGRPCConn(addr)
+	re.NoError(err)
+	re.Same(oldConn, cc)
+
+	// Rotate the CA, the new connection trusts the new CA only.
+	setServerCA(newCA)
+	re.NoError(cli.UpdateTLSConfig(tlsConfigOf(newCA)))
+	newConn, err := cli.GetOrCreateGRPCConn(addr)
+	re.NoError(err)
+	re.NotSame(oldConn, newConn)
+	re.NoError(getMembers(newConn))
+	// The old connection is not closed at once to drain the in-flight requests.
+	re.NoError(getMembers(oldConn))
+	re.NotEqual(connectivity.Shutdown, oldConn.GetState())
+
+	// The connection with the old CA can't connect to the server anymore.
+	staleConn, err := grpcutil.GetOrCreateGRPCConn(ctx, &sync.Map{}, addr, tlsConfigOf(oldCA))
+	re.NoError(err)
+	defer staleConn.Close()
+	re.Error(getMembers(staleConn))
+}
+
+func TestClientUpdateSecurityOption(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	re.NoError(err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "pd-client-test"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	re.NoError(err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	re.NoError(err)
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+
+	option := newOption()
+	pdSvcDiscovery := &pdServiceDiscovery{ctx: ctx, cancel: cancel, tlsCfg: SecurityOption{}.toTLSConfig(), option: option}
+	c := &client{ctx: ctx, cancel: cancel, option: option, pdSvcDiscovery: pdSvcDiscovery, tlsCfg: pdSvcDiscovery.tlsCfg}
+	// Switch to the API service mode, the TSO service discovery is created with the TLS config of the client.
+	newTSOSvcDiscovery := func() *tsoServiceDiscovery {
+		return newTSOServiceDiscovery(ctx, nil, c.pdSvcDiscovery, 1, nullKeyspaceID, c.tlsCfg, c.option).(*tsoServiceDiscovery)
+	}
+	tsoSvcDiscovery := newTSOSvcDiscovery()
+	c.serviceMode = pdpb.ServiceMode_API_SVC_MODE
+	c.tsoSvcDiscovery = tsoSvcDiscovery
+	addr := "127.0.0.1:1"
+	oldPDConn, err := pdSvcDiscovery.GetOrCreateGRPCConn(addr)
+	re.NoError(err)
+	oldTSOConn, err := tsoSvcDiscovery.GetOrCreateGRPCConn(addr)
+	re.NoError(err)
+
+	// The invalid config is rejected and the working one is kept.
+	err = c.UpdateSecurityOption(SecurityOption{SSLCABytes: certPEM})
+	re.True(errs.ErrSecurityConfig.Equal(err))
+	re.Equal(SecurityOption{}.toTLSConfig(), c.tlsCfg)
+	cc, err := tsoSvcDiscovery.GetOrCreateGRPCConn(addr)
+	re.NoError(err)
+	re.Same(oldTSOConn, cc)
+
+	// Rotate the config, both discoveries take the new one and reset their connections.
+	security := SecurityOption{SSLCABytes: certPEM, SSLCertBytes: certPEM, SSLKEYBytes: keyPEM}
+	re.NoError(c.UpdateSecurityOption(security))
+	re.Equal(security.toTLSConfig(), c.tlsCfg)
+	re.Equal(security.toTLSConfig(), pdSvcDiscovery.getTLSConfig())
+	re.Equal(security.toTLSConfig(), tsoSvcDiscovery.getTLSConfig())
+	cc, err = pdSvcDiscovery.GetOrCreateGRPCConn(addr)
+	re.NoError(err)
+	re.NotSame(oldPDConn, cc)
+	cc, err = tsoSvcDiscovery.GetOrCreateGRPCConn(addr)
+	re.NoError(err)
+	re.NotSame(oldTSOConn, cc)
+
+	// The TSO service discovery created by the next switch takes the new config as well.
+	tsoSvcDiscovery.Close()
+	tsoSvcDiscovery = newTSOSvcDiscovery()
+	defer tsoSvcDiscovery.Close()
+	re.Equal(security.toTLSConfig(), tsoSvcDiscovery.getTLSConfig())
+	pdSvcDiscovery.Close()
+}
@@ -18,6 +18,7 @@ import (
 	"context"
 	"crypto/tls"
 	"net/url"
+	"strings"
 	"sync"
 	"time"
 
@@ -26,6 +27,7 @@ import (
 	"github.com/tikv/pd/client/tlsutil"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/metadata"
 )
@@ -55,6 +57,10 @@ func GetClientConn(ctx context.Context, addr string, tlsCfg *tls.Config, do ...g
 		creds := credentials.NewTLS(tlsCfg)
 		opt = grpc.WithTransportCredentials(creds)
 	}
+	// The scheme is optional, add it to make sure the host can be parsed correctly.
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
 	u, err := url.Parse(addr)
 	if err != nil {
 		return nil, errs.ErrURLParse.Wrap(err).GenWithStackByCause()
@@ -66,6 +72,17 @@ func GetClientConn(ctx context.Context, addr string, tlsCfg *tls.Config, do ...g
 	return cc, nil
 }
 
+// isConnAlive checks whether the connection can be used without redialing.
+// It only checks the current state instead of probing the remote.
+func isConnAlive(cc *grpc.ClientConn) bool {
+	switch cc.GetState() {
+	case connectivity.Shutdown, connectivity.TransientFailure:
+		return false
+	default:
+		return true
+	}
+}
+
 // BuildForwardContext creates a context with receiver metadata information.
 // It is used in client side.
 func BuildForwardContext(ctx context.Context, addr string) context.Context {
@@ -73,13 +90,44 @@ func BuildForwardContext(ctx context.Context, addr string) context.Context {
 	return metadata.NewOutgoingContext(ctx, md)
 }
 
+// NormalizeAddr returns the normalized form of the given addr which is used as the key of the
+// client connections, so that the equivalent addresses like "http://Host:2379/" and "host:2379"
+// share the same connection. The scheme and the trailing slash are stripped, and the host is lowercased.
+func NormalizeAddr(addr string) string {
+	addr = strings.TrimSpace(addr)
+	if idx := strings.Index(addr, "://"); idx >= 0 {
+		addr = addr[idx+len("://"):]
+	}
+	return strings.ToLower(strings.TrimRight(addr, "/"))
+}
+
 // GetOrCreateGRPCConn returns the corresponding grpc client connection of the given addr.
 // Returns the old one if's already existed in the clientConns; otherwise creates a new one and returns it.
+// The clientConns is keyed by the normalized addr, see NormalizeAddr for details.
 func GetOrCreateGRPCConn(ctx context.Context, clientConns *sync.Map, addr string, tlsCfg *tlsutil.TLSConfig, opt ...grpc.DialOption) (*grpc.ClientConn, error) {
-	conn, ok := clientConns.Load(addr)
+	return getOrCreateGRPCConn(ctx, clientConns, addr, tlsCfg, false, opt...)
+}
+
+// GetOrCreateLiveGRPCConn is the same as GetOrCreateGRPCConn except that it checks the state of the
+// cached connection, which will be closed and replaced by a new one if it's shutdown or in transient failure.
+func GetOrCreateLiveGRPCConn(ctx context.Context, clientConns *sync.Map, addr string, tlsCfg *tlsutil.TLSConfig, opt ...grpc.DialOption) (*grpc.ClientConn, error) {
+	return getOrCreateGRPCConn(ctx, clientConns, addr, tlsCfg, true, opt...)
+}
+
+func getOrCreateGRPCConn(ctx context.Context, clientConns *sync.Map, addr string, tlsCfg *tlsutil.TLSConfig, checkLiveness bool, opt ...grpc.DialOption) (*grpc.ClientConn, error) {
+	key := NormalizeAddr(addr)
+	conn, ok := clientConns.Load(key)
 	if ok {
-		// TODO: check the connection state.
-		return conn.(*grpc.ClientConn), nil
+		cc := conn.(*grpc.ClientConn)
+		if !checkLiveness || isConnAlive(cc) {
+			return cc, nil
+		}
+		// Only the one who deletes the bad connection closes it.
+		if clientConns.CompareAndDelete(key, cc) {
+			log.Info("close the bad connection and dial a new one", zap.String("target", cc.Target()),
+				zap.String("state", cc.GetState().String()))
+			cc.Close()
+		}
 	}
 	tlsConfig, err := tlsCfg.ToTLSConfig()
 	if err != nil {
@@ -91,7 +139,7 @@ func GetOrCreateGRPCConn(ctx context.Context, clientConns *sync.Map, addr string
 	if err != nil {
 		return nil, err
 	}
-	conn, loaded := clientConns.LoadOrStore(addr, cc)
+	conn, loaded := clientConns.LoadOrStore(key, cc)
 	if !loaded {
 		// Successfully stored the connection.
 		return cc, nil
@@ -16,6 +16,7 @@ package pd
 
 import (
 	"sync/atomic"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -42,10 +43,18 @@ var (
 	cmdDuration         *prometheus.HistogramVec
 	cmdFailedDuration   *prometheus.HistogramVec
 	requestDuration     *prometheus.HistogramVec
+	rpcDuration         *prometheus.HistogramVec
 	tsoBestBatchSize    prometheus.Histogram
 	tsoBatchSize        prometheus.Histogram
 	tsoBatchSendLatency prometheus.Histogram
 	requestForwarded    *prometheus.GaugeVec
+
+	leaderSwitchCounter       prometheus.Counter
+	updateMemberFailedCounter *prometheus.CounterVec
+	followerCountGauge        prometheus.Gauge
+
+	tsoStreamCreateCounter   prometheus.Counter
+	tsoKeyspaceGroupDuration *prometheus.HistogramVec
 )
 
 func initMetrics(constLabels prometheus.Labels) {
@@ -79,6 +88,16 @@ func initMetrics(constLabels prometheus.Labels) {
 			Buckets:     prometheus.ExponentialBuckets(0.0005, 2, 13),
 		}, []string{"type"})
 
+	rpcDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace:   "pd_client",
+			Subsystem:   "request",
+			Name:        "rpc_duration_seconds",
+			Help:        "Bucketed histogram of end-to-end processing time (s) of the client RPCs.",
+			ConstLabels: constLabels,
+			Buckets:     prometheus.ExponentialBuckets(0.0005, 2, 13),
+		}, []string{"method", "outcome"})
+
 	tsoBestBatchSize = prometheus.NewHistogram(
 		prometheus.HistogramOpts{
 			Namespace:   "pd_client",
@@ -117,6 +136,53 @@ func initMetrics(constLabels prometheus.Labels) {
 			Help:        "The status to indicate if the request is forwarded",
 			ConstLabels: constLabels,
 		}, []string{"host", "delegate"})
+
+	leaderSwitchCounter = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace:   "pd_client",
+			Subsystem:   "service_discovery",
+			Name:        "leader_switch_total",
+			Help:        "Counter of the PD leader switches observed by the client.",
+			ConstLabels: constLabels,
+		})
+
+	updateMemberFailedCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   "pd_client",
+			Subsystem:   "service_discovery",
+			Name:        "update_member_failed_total",
+			Help:        "Counter of the failures to update the member from each URL.",
+			ConstLabels: constLabels,
+		}, []string{"url"})
+
+	followerCountGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace:   "pd_client",
+			Subsystem:   "service_discovery",
+			Name:        "followers",
+			Help:        "The number of the PD followers known by the client.",
+			ConstLabels: constLabels,
+		})
+
+	tsoStreamCreateCounter = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace:   "pd_client",
+			Subsystem:   "request",
+			Name:        "tso_stream_create_total",
+			Help:        "Counter of the TSO streams created, including the recreated ones.",
+			ConstLabels: constLabels,
+		})
+
+	// It's labeled by the keyspace group rather than the keyspace to keep the cardinality bounded.
+	tsoKeyspaceGroupDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace:   "pd_client",
+			Subsystem:   "request",
+			Name:        "tso_keyspace_group_duration_seconds",
+			Help:        "Bucketed histogram of the round-trip time (s) of the TSO batches by the keyspace group.",
+			ConstLabels: constLabels,
+			Buckets:     prometheus.ExponentialBuckets(0.0005, 2, 13),
+		}, []string{"keyspace_group"})
 }
 
 var (
@@ -161,6 +227,13 @@ var (
 	cmdFailedDurationPut                      prometheus.Observer
 	cmdFailedDurationUpdateGCSafePointV2      prometheus.Observer
 	cmdFailedDurationUpdateServiceSafePointV2 prometheus.Observer
+
+	rpcDurationGetRegionSucceeded  prometheus.Observer
+	rpcDurationGetRegionFailed     prometheus.Observer
+	rpcDurationGetStoreSucceeded   prometheus.Observer
+	rpcDurationGetStoreFailed      prometheus.Observer
+	rpcDurationGetMembersSucceeded prometheus.Observer
+	rpcDurationGetMembersFailed    prometheus.Observer
 )
 
 func initCmdDurations() {
@@ -206,14 +279,37 @@ func initCmdDurations() {
 	cmdFailedDurationPut = cmdFailedDuration.WithLabelValues("put")
 	cmdFailedDurationUpdateGCSafePointV2 = cmdFailedDuration.WithLabelValues("update_gc_safe_point_v2")
 	cmdFailedDurationUpdateServiceSafePointV2 = cmdFailedDuration.WithLabelValues("update_service_safe_point_v2")
+
+	rpcDurationGetRegionSucceeded = rpcDuration.WithLabelValues("get_region", "success")
+	rpcDurationGetRegionFailed = rpcDuration.WithLabelValues("get_region", "failure")
+	rpcDurationGetStoreSucceeded = rpcDuration.WithLabelValues("get_store", "success")
+	rpcDurationGetStoreFailed = rpcDuration.WithLabelValues("get_store", "failure")
+	rpcDurationGetMembersSucceeded = rpcDuration.WithLabelValues("get_members", "success")
+	rpcDurationGetMembersFailed = rpcDuration.WithLabelValues("get_members", "failure")
+}
+
+// observeRPCDuration observes the duration of an RPC into the succeeded or the failed
+// observer according to its outcome.
+func observeRPCDuration(succeeded, failed prometheus.Observer, start time.Time, err error) {
+	if err != nil {
+		failed.Observe(time.Since(start).Seconds())
+		return
+	}
+	succeeded.Observe(time.Since(start).Seconds())
 }
 
 func registerMetrics() {
 	prometheus.MustRegister(cmdDuration)
 	prometheus.MustRegister(cmdFailedDuration)
 	prometheus.MustRegister(requestDuration)
+	prometheus.MustRegister(rpcDuration)
 	prometheus.MustRegister(tsoBestBatchSize)
 	prometheus.MustRegister(tsoBatchSize)
 	prometheus.MustRegister(tsoBatchSendLatency)
 	prometheus.MustRegister(requestForwarded)
+	prometheus.MustRegister(leaderSwitchCounter)
+	prometheus.MustRegister(updateMemberFailedCounter)
+	prometheus.MustRegister(followerCountGauge)
+	prometheus.MustRegister(tsoStreamCreateCounter)
+	prometheus.MustRegister(tsoKeyspaceGroupDuration)
 }
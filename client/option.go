@@ -19,6 +19,7 @@ import (
 	"time"
 
 	"github.com/pingcap/errors"
+	"github.com/pingcap/kvproto/pkg/pdpb"
 	"github.com/prometheus/client_golang/prometheus"
 	"google.golang.org/grpc"
 )
@@ -28,6 +29,19 @@ const (
 	maxInitClusterRetries                        = 100
 	defaultMaxTSOBatchWaitInterval time.Duration = 0
 	defaultEnableTSOFollowerProxy                = false
+	// defaultMaxRecvMsgSize is larger than the gRPC default 4MB to receive the large responses
+	// like ScanRegions and GetAllStores in the big clusters.
+	defaultMaxRecvMsgSize = 64 << 20
+	// defaultFollowerHealthCheckInterval is the interval to probe the health of the followers.
+	defaultFollowerHealthCheckInterval = 10 * time.Second
+	// defaultMemberUpdateInterval is the interval to check the membership changes periodically.
+	defaultMemberUpdateInterval = time.Minute
+	// defaultTSODispatchRetryTimes is the max retry times of dispatching a TSO request when the
+	// dispatcher of the dc-location is not ready.
+	defaultTSODispatchRetryTimes = 3
+	// defaultTSODispatchRetryInterval is the backoff before the first retry of dispatching a TSO request,
+	// it's doubled for each following retry.
+	defaultTSODispatchRetryInterval = 50 * time.Millisecond
 )
 
 // DynamicOption is used to distinguish the dynamic option type.
@@ -40,6 +54,9 @@ const (
 	// EnableTSOFollowerProxy is the TSO Follower Proxy option.
 	// It is stored as bool.
 	EnableTSOFollowerProxy
+	// MemberUpdateInterval is the interval to check the membership changes periodically.
+	// It is stored as time.Duration and should be positive.
+	MemberUpdateInterval
 
 	dynamicOptionCount
 )
@@ -54,11 +71,55 @@ type option struct {
 	enableForwarding bool
 	metricsLabels    prometheus.Labels
 	initMetrics      bool
+	// requiredServerCapabilities are checked against the PD leader during the initialization.
+	requiredServerCapabilities []ServerCapability
+	// enforceServerCapabilities makes the initialization fail if any required capability is missing.
+	enforceServerCapabilities bool
+	// retryBudget is shared by all the retry paths to bound the aggregate retry rate.
+	retryBudget *retryBudget
+	// eagerFollowerDial makes the client dial the followers as soon as they are discovered.
+	eagerFollowerDial bool
+	// followerHealthCheckInterval is the interval to probe the followers, the unhealthy ones are not
+	// returned by GetBackupAddrs. 0 means disabling the health check.
+	followerHealthCheckInterval time.Duration
+	// softMemberErrorTypes are the header error types of GetMembers which are regarded as recoverable,
+	// the same URL will be retried instead of trying the next one.
+	softMemberErrorTypes []pdpb.ErrorType
+	// checkConnLiveness makes the client check the state of the cached gRPC connection before using it.
+	checkConnLiveness bool
+	// initialWindowSize is the initial HTTP/2 stream window size of the gRPC connections.
+	// 0 means using the gRPC default.
+	initialWindowSize int32
+	// initialConnWindowSize is the initial HTTP/2 connection window size of the gRPC connections.
+	// 0 means using the gRPC default.
+	initialConnWindowSize int32
+	// maxRecvMsgSize is the max message size in bytes the gRPC connections can receive.
+	maxRecvMsgSize int
+	// maxSendMsgSize is the max message size in bytes the gRPC connections can send, 0 means using the gRPC default.
+	maxSendMsgSize int
+	// leaderLostGracePeriod is how long the known leader should be unreachable before the client
+	// tries the other members to find a new one, 0 means abandoning the leader on the first failure.
+	leaderLostGracePeriod time.Duration
+	// saveLeaderHint and loadLeaderHint persist the last-known leader across the client restarts.
+	saveLeaderHint func(leader string) error
+	loadLeaderHint func() (string, error)
+	// unaryInterceptors and streamInterceptors are chained to all the gRPC connections created by the client.
+	unaryInterceptors  []grpc.UnaryClientInterceptor
+	streamInterceptors []grpc.StreamClientInterceptor
+	// tsoDispatchRetryTimes and tsoDispatchRetryInterval bound the retries of dispatching a TSO request
+	// when the dispatcher of the dc-location is not ready.
+	tsoDispatchRetryTimes    int
+	tsoDispatchRetryInterval time.Duration
+	// memberUpdateMinBackoff and memberUpdateMaxBackoff bound the backoff between the retries of
+	// updating the member.
+	memberUpdateMinBackoff time.Duration
+	memberUpdateMaxBackoff time.Duration
 
 	// Dynamic options.
 	dynamicOptions [dynamicOptionCount]atomic.Value
 
 	enableTSOFollowerProxyCh chan struct{}
+	memberUpdateIntervalCh   chan struct{}
 }
 
 // newOption creates a new PD client option with the default values set.
@@ -67,14 +128,65 @@ func newOption() *option {
 		timeout:                  defaultPDTimeout,
 		maxRetryTimes:            maxInitClusterRetries,
 		enableTSOFollowerProxyCh: make(chan struct{}, 1),
+		memberUpdateIntervalCh:   make(chan struct{}, 1),
 		initMetrics:              true,
+		maxRecvMsgSize:           defaultMaxRecvMsgSize,
+		softMemberErrorTypes:     []pdpb.ErrorType{pdpb.ErrorType_NOT_BOOTSTRAPPED},
+
+		followerHealthCheckInterval: defaultFollowerHealthCheckInterval,
+		tsoDispatchRetryTimes:       defaultTSODispatchRetryTimes,
+		tsoDispatchRetryInterval:    defaultTSODispatchRetryInterval,
+		memberUpdateMinBackoff:      defaultMemberUpdateMinBackoff,
+		memberUpdateMaxBackoff:      defaultMemberUpdateMaxBackoff,
 	}
 
 	co.dynamicOptions[MaxTSOBatchWaitInterval].Store(defaultMaxTSOBatchWaitInterval)
 	co.dynamicOptions[EnableTSOFollowerProxy].Store(defaultEnableTSOFollowerProxy)
+	co.dynamicOptions[MemberUpdateInterval].Store(defaultMemberUpdateInterval)
 	return co
 }
 
+// isSoftMemberError checks whether the given header error type of GetMembers is recoverable.
+func (o *option) isSoftMemberError(errType pdpb.ErrorType) bool {
+	for _, t := range o.softMemberErrorTypes {
+		if t == errType {
+			return true
+		}
+	}
+	return false
+}
+
+// getGRPCDialOptions returns the gRPC dial options used by all the connections created by the client.
+func (o *option) getGRPCDialOptions() []grpc.DialOption {
+	opts := make([]grpc.DialOption, 0, len(o.gRPCDialOptions)+5)
+	callOpts := make([]grpc.CallOption, 0, 2)
+	if o.maxRecvMsgSize > 0 {
+		callOpts = append(callOpts, grpc.MaxCallRecvMsgSize(o.maxRecvMsgSize))
+	}
+	if o.maxSendMsgSize > 0 {
+		callOpts = append(callOpts, grpc.MaxCallSendMsgSize(o.maxSendMsgSize))
+	}
+	if len(callOpts) > 0 {
+		opts = append(opts, grpc.WithDefaultCallOptions(callOpts...))
+	}
+	if o.initialWindowSize > 0 {
+		opts = append(opts, grpc.WithInitialWindowSize(o.initialWindowSize))
+	}
+	if o.initialConnWindowSize > 0 {
+		opts = append(opts, grpc.WithInitialConnWindowSize(o.initialConnWindowSize))
+	}
+	// The interceptors are chained rather than set so that they will not be overridden by the ones
+	// passed by WithGRPCDialOptions.
+	if len(o.unaryInterceptors) > 0 {
+		opts = append(opts, grpc.WithChainUnaryInterceptor(o.unaryInterceptors...))
+	}
+	if len(o.streamInterceptors) > 0 {
+		opts = append(opts, grpc.WithChainStreamInterceptor(o.streamInterceptors...))
+	}
+	// The options passed by WithGRPCDialOptions are appended at last so that they can override the above ones.
+	return append(opts, o.gRPCDialOptions...)
+}
+
 // setMaxTSOBatchWaitInterval sets the max TSO batch wait interval option.
 // It only accepts the interval value between 0 and 10ms.
 func (o *option) setMaxTSOBatchWaitInterval(interval time.Duration) error {
@@ -109,3 +221,25 @@ func (o *option) setEnableTSOFollowerProxy(enable bool) {
 func (o *option) getEnableTSOFollowerProxy() bool {
 	return o.dynamicOptions[EnableTSOFollowerProxy].Load().(bool)
 }
+
+// setMemberUpdateInterval sets the interval to check the membership changes periodically,
+// the member loop will use the new interval since its next check.
+func (o *option) setMemberUpdateInterval(interval time.Duration) error {
+	if interval <= 0 {
+		return errors.New("[pd] invalid member update interval, should be positive")
+	}
+	old := o.getMemberUpdateInterval()
+	if interval != old {
+		o.dynamicOptions[MemberUpdateInterval].Store(interval)
+		select {
+		case o.memberUpdateIntervalCh <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// getMemberUpdateInterval gets the interval to check the membership changes periodically.
+func (o *option) getMemberUpdateInterval() time.Duration {
+	return o.dynamicOptions[MemberUpdateInterval].Load().(time.Duration)
+}
@@ -20,6 +20,7 @@ import (
 
 	"github.com/stretchr/testify/require"
 	"github.com/tikv/pd/client/testutil"
+	"google.golang.org/grpc"
 )
 
 func TestDynamicOptionChange(t *testing.T) {
@@ -28,6 +29,7 @@ func TestDynamicOptionChange(t *testing.T) {
 	// Check the default value setting.
 	re.Equal(defaultMaxTSOBatchWaitInterval, o.getMaxTSOBatchWaitInterval())
 	re.Equal(defaultEnableTSOFollowerProxy, o.getEnableTSOFollowerProxy())
+	re.Equal(defaultFollowerHealthCheckInterval, o.followerHealthCheckInterval)
 
 	// Check the invalid value setting.
 	re.NotNil(o.setMaxTSOBatchWaitInterval(time.Second))
@@ -55,4 +57,46 @@ func TestDynamicOptionChange(t *testing.T) {
 	close(o.enableTSOFollowerProxyCh)
 	// Setting the same value should not notify the channel.
 	o.setEnableTSOFollowerProxy(expectBool)
+
+	re.Equal(defaultMemberUpdateInterval, o.getMemberUpdateInterval())
+	re.Error(o.setMemberUpdateInterval(0))
+	re.Equal(defaultMemberUpdateInterval, o.getMemberUpdateInterval())
+	re.NoError(o.setMemberUpdateInterval(time.Second))
+	re.Equal(time.Second, o.getMemberUpdateInterval())
+	<-o.memberUpdateIntervalCh
+	close(o.memberUpdateIntervalCh)
+	// Setting the same value should not notify the channel.
+	re.NoError(o.setMemberUpdateInterval(time.Second))
+}
+
+func TestTSOBatchWaitDurationOption(t *testing.T) {
+	re := require.New(t)
+	o := newOption()
+	WithTSOBatchWaitDuration(2 * time.Millisecond)(&client{option: o})
+	re.Equal(2*time.Millisecond, o.getMaxTSOBatchWaitInterval())
+	// The invalid values are ignored.
+	WithTSOBatchWaitDuration(-time.Millisecond)(&client{option: o})
+	re.Equal(2*time.Millisecond, o.getMaxTSOBatchWaitInterval())
+	WithTSOBatchWaitDuration(time.Second)(&client{option: o})
+	re.Equal(2*time.Millisecond, o.getMaxTSOBatchWaitInterval())
+	// 0 disables the waiting.
+	WithTSOBatchWaitDuration(0)(&client{option: o})
+	re.Zero(o.getMaxTSOBatchWaitInterval())
+}
+
+func TestGRPCWindowSizeOption(t *testing.T) {
+	re := require.New(t)
+	o := newOption()
+	// Only the default max receive message size is set.
+	re.Len(o.getGRPCDialOptions(), 1)
+
+	WithGRPCDialOptions(grpc.WithBlock())(&client{option: o})
+	re.Len(o.getGRPCDialOptions(), 2)
+	WithInitialWindowSize(4 << 20)(&client{option: o})
+	WithInitialConnWindowSize(8 << 20)(&client{option: o})
+	re.Equal(int32(4<<20), o.initialWindowSize)
+	re.Equal(int32(8<<20), o.initialConnWindowSize)
+	re.Len(o.getGRPCDialOptions(), 4)
+	// The user-specified dial options should not be modified.
+	re.Len(o.gRPCDialOptions, 1)
 }
@@ -16,6 +16,8 @@ package pd
 
 import (
 	"context"
+	"encoding/json"
+	"math/rand"
 	"reflect"
 	"sort"
 	"strings"
@@ -23,6 +25,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/gogo/protobuf/proto"
 	"github.com/pingcap/errors"
 	"github.com/pingcap/failpoint"
 	"github.com/pingcap/kvproto/pkg/pdpb"
@@ -32,13 +35,25 @@ import (
 	"github.com/tikv/pd/client/tlsutil"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 const (
 	globalDCLocation          = "global"
-	memberUpdateInterval      = time.Minute
 	serviceModeUpdateInterval = 3 * time.Second
 	updateMemberTimeout       = time.Second // Use a shorter timeout to recover faster from network isolation.
+	// softMemberErrorRetryTimes is the max retry times of the same URL when getting members meets a soft header error.
+	softMemberErrorRetryTimes = 3
+	// softMemberErrorRetryInterval is the interval between two retries of the same URL on the soft header errors.
+	softMemberErrorRetryInterval = 100 * time.Millisecond
+	// defaultMemberUpdateMinBackoff is the backoff before the first retry after failing to update the member.
+	defaultMemberUpdateMinBackoff = 100 * time.Millisecond
+	// defaultMemberUpdateMaxBackoff is the max backoff between two retries of updating the member.
+	defaultMemberUpdateMaxBackoff = 10 * time.Second
+	// connDrainTimeout is the time given to the in-flight requests on the reset connections before
+	// closing them.
+	connDrainTimeout = 10 * time.Second
 )
 
 type serviceType int
@@ -69,7 +84,8 @@ type ServiceDiscovery interface {
 	// which is the leader in a quorum-based cluster or the primary in a primary/secondary
 	// configured cluster.
 	GetServingEndpointClientConn() *grpc.ClientConn
-	// GetClientConns returns the mapping {addr -> a gRPC connection}
+	// GetClientConns returns the mapping {normalized addr -> a gRPC connection},
+	// see grpcutil.NormalizeAddr for details.
 	GetClientConns() *sync.Map
 	// GetServingAddr returns the serving endpoint which is the leader in a quorum-based cluster
 	// or the primary in a primary/secondary configured cluster.
@@ -95,6 +111,17 @@ type ServiceDiscovery interface {
 	// in a quorum-based cluster or any primary/secondary in a primary/secondary configured cluster
 	// is changed.
 	AddServiceAddrsSwitchedCallback(callbacks ...func())
+	// GetAllMembers returns the full metadata of the members in a quorum-based cluster, which are
+	// learned from the last membership check. The leader is available through GetServingAddr.
+	GetAllMembers(ctx context.Context) ([]*pdpb.Member, error)
+	// AddClusterIDChangedCallback adds callbacks which will be called with the known cluster ID and
+	// the different one when the service discovery observes a different cluster ID from the servers,
+	// e.g., the cluster behind the same URLs has been rebuilt.
+	AddClusterIDChangedCallback(callbacks ...func(old, new uint64))
+	// UpdateTLSConfig replaces the TLS config used to create the gRPC connections, e.g., to rotate
+	// the certificates. The existing connections are reset and closed after the in-flight requests
+	// are drained, so the new ones will be created with the new config.
+	UpdateTLSConfig(tlsCfg *tlsutil.TLSConfig) error
 }
 
 type updateKeyspaceIDFunc func() error
@@ -122,6 +149,22 @@ type pdServiceDiscovery struct {
 	leader atomic.Value // Store as string
 	// PD follower URLs
 	followers atomic.Value // Store as []string
+	// members is the last GetMembers response used to update the membership.
+	members atomic.Value // Store as *pdpb.GetMembersResponse
+	// unhealthyFollowers are the followers failed in the last health check, which are filtered out
+	// by getFollowerAddrs. The unhealthy ones rather than the healthy ones are recorded so that the
+	// newly discovered followers are regarded as healthy before they are probed.
+	unhealthyFollowers atomic.Value // Store as map[string]struct{}
+	// urlPriorities is the leader priorities of the members, keyed by their client URLs.
+	urlPriorities atomic.Value // Store as map[string]int32
+	// tsoAllocLeaders is the latest tso allocator leaders, keyed by their DC locations.
+	tsoAllocLeaders atomic.Value // Store as map[string]string
+	// leaderUnreachableSince is the time when the known leader became unreachable, zero means it's reachable.
+	// It's only accessed in updateMember.
+	leaderUnreachableSince time.Time
+	// leaderHint is the last-known leader loaded from the hint store, it's only used to be tried first by the
+	// member update during the initialization, and its cluster ID is checked like the other URLs.
+	leaderHint string
 
 	clusterID uint64
 	// addr -> a gRPC connection
@@ -134,6 +177,12 @@ type pdServiceDiscovery struct {
 	// membersChangedCbs will be called after there is any membership change in the
 	// leader and followers
 	membersChangedCbs []func()
+	// clusterIDChangedCbs will be called when a different cluster ID is observed
+	clusterIDChangedCbs []func(old, new uint64)
+	// observedClusterID is the last different cluster ID observed from the servers, it's used to
+	// call clusterIDChangedCbs only once for the same cluster ID until the cluster ID matches again.
+	// It's only accessed in updateMember.
+	observedClusterID uint64
 	// tsoLocalAllocLeadersUpdatedCb will be called when the local tso allocator
 	// leader list is updated. The input is a map {DC Location -> Leader Addr}
 	tsoLocalAllocLeadersUpdatedCb tsoLocalServAddrsUpdatedFunc
@@ -150,7 +199,9 @@ type pdServiceDiscovery struct {
 
 	updateKeyspaceIDCb updateKeyspaceIDFunc
 	keyspaceID         uint32
-	tlsCfg             *tlsutil.TLSConfig
+	// tlsCfgMu protects tlsCfg which may be replaced by UpdateTLSConfig.
+	tlsCfgMu sync.RWMutex
+	tlsCfg   *tlsutil.TLSConfig
 	// Client option.
 	option *option
 }
@@ -184,15 +235,24 @@ func (c *pdServiceDiscovery) Init() error {
 		return nil
 	}
 
+	// The errors of the two phases are distinguished so that the caller can react differently,
+	// e.g., reconfigure the URLs if the cluster ID can't be discovered, or retry later if the
+	// initial membership is not available yet.
+	c.leaderHint = c.loadLeaderHint()
 	if err := c.initRetry(c.initClusterID); err != nil {
 		c.cancel()
-		return err
+		return errs.ErrClientInitClusterID.Wrap(err).GenWithStackByCause()
 	}
 	if err := c.initRetry(c.updateMember); err != nil {
 		c.cancel()
-		return err
+		return errs.ErrClientInitMember.Wrap(err).GenWithStackByCause()
 	}
+	c.leaderHint = ""
 	log.Info("[pd] init cluster id", zap.Uint64("cluster-id", c.clusterID))
+	if err := c.checkServerCapabilities(); err != nil {
+		c.cancel()
+		return err
+	}
 
 	// We need to update the keyspace ID before we discover and update the service mode
 	// so that TSO in API mode can be initialized with the correct keyspace ID.
@@ -209,6 +269,10 @@ func (c *pdServiceDiscovery) Init() error {
 	c.wg.Add(2)
 	go c.updateMemberLoop()
 	go c.updateServiceModeLoop()
+	if c.option.followerHealthCheckInterval > 0 {
+		c.wg.Add(1)
+		go c.followerHealthCheckLoop()
+	}
 
 	c.isInitialized = true
 	return nil
@@ -227,6 +291,9 @@ func (c *pdServiceDiscovery) initRetry(f func() error) error {
 			return err
 		case <-ticker.C:
 		}
+		if c.option.retryBudget.acquire(c.ctx) != nil {
+			return err
+		}
 	}
 	return errors.WithStack(err)
 }
@@ -236,7 +303,8 @@ func (c *pdServiceDiscovery) updateMemberLoop() {
 
 	ctx, cancel := context.WithCancel(c.ctx)
 	defer cancel()
-	ticker := time.NewTicker(memberUpdateInterval)
+	interval := c.option.getMemberUpdateInterval()
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	for {
@@ -245,16 +313,64 @@ func (c *pdServiceDiscovery) updateMemberLoop() {
 			return
 		case <-ticker.C:
 		case <-c.checkMembershipCh:
+		case <-c.option.memberUpdateIntervalCh:
+			if newInterval := c.option.getMemberUpdateInterval(); newInterval != interval {
+				log.Info("[pd] update the member update interval",
+					zap.Duration("old-interval", interval), zap.Duration("new-interval", newInterval))
+				interval = newInterval
+				ticker.Reset(interval)
+			}
+			continue
 		}
 		failpoint.Inject("skipUpdateMember", func() {
 			failpoint.Continue()
 		})
-		if err := c.updateMember(); err != nil {
-			log.Error("[pd] failed to update member", zap.Strings("urls", c.GetServiceURLs()), errs.ZapError(err))
+		// Retry with backoff until the member is updated, the checks scheduled in the meantime
+		// are coalesced into the retries rather than retrying in a tight loop.
+		for backoff := time.Duration(0); ; {
+			err := c.updateMember()
+			if err == nil {
+				break
+			}
+			backoff = c.nextMemberUpdateBackoff(backoff)
+			log.Error("[pd] failed to update member", zap.Strings("urls", c.GetServiceURLs()),
+				zap.Duration("backoff", backoff), errs.ZapError(err))
+			if !c.waitMemberUpdateBackoff(ctx, backoff) {
+				return
+			}
 		}
 	}
 }
 
+// nextMemberUpdateBackoff doubles the last backoff and caps it with the max backoff.
+func (c *pdServiceDiscovery) nextMemberUpdateBackoff(backoff time.Duration) time.Duration {
+	if backoff <= 0 {
+		return c.option.memberUpdateMinBackoff
+	}
+	if backoff *= 2; backoff > c.option.memberUpdateMaxBackoff {
+		return c.option.memberUpdateMaxBackoff
+	}
+	return backoff
+}
+
+// waitMemberUpdateBackoff waits a jittered backoff in [backoff/2, backoff] so that the clients
+// won't retry in a stampede, and drops the checks scheduled during the wait since the retry
+// follows. It returns false if the context is done.
+func (c *pdServiceDiscovery) waitMemberUpdateBackoff(ctx context.Context, backoff time.Duration) bool {
+	timer := time.NewTimer(backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1)))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+	}
+	select {
+	case <-c.checkMembershipCh:
+	default:
+	}
+	return true
+}
+
 func (c *pdServiceDiscovery) updateServiceModeLoop() {
 	defer c.wg.Done()
 	failpoint.Inject("skipUpdateServiceMode", func() {
@@ -284,6 +400,72 @@ func (c *pdServiceDiscovery) updateServiceModeLoop() {
 	}
 }
 
+// followerHealthCheckLoop probes the followers periodically to keep the unhealthy ones out of GetBackupAddrs.
+func (c *pdServiceDiscovery) followerHealthCheckLoop() {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(c.option.followerHealthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		c.checkFollowersHealth()
+	}
+}
+
+// checkFollowersHealth probes all the followers concurrently and records the unhealthy ones,
+// a follower which responds again is regarded as healthy at once.
+func (c *pdServiceDiscovery) checkFollowersHealth() {
+	followers, _ := c.followers.Load().([]string)
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		unhealthy = make(map[string]struct{})
+	)
+	for _, addr := range followers {
+		wg.Add(1)
+		go func(addr string) {
+			defer wg.Done()
+			if err := c.probeFollower(addr); err != nil {
+				mu.Lock()
+				defer mu.Unlock()
+				unhealthy[addr] = struct{}{}
+				log.Debug("[pd] failed to probe the follower", zap.String("follower", addr), errs.ZapError(err))
+			}
+		}(addr)
+	}
+	wg.Wait()
+
+	old, _ := c.unhealthyFollowers.Load().(map[string]struct{})
+	for addr := range unhealthy {
+		if _, ok := old[addr]; !ok {
+			log.Warn("[pd] the follower becomes unhealthy", zap.String("follower", addr))
+		}
+	}
+	for addr := range old {
+		if _, ok := unhealthy[addr]; !ok {
+			log.Info("[pd] the follower recovers", zap.String("follower", addr))
+		}
+	}
+	c.unhealthyFollowers.Store(unhealthy)
+}
+
+// probeFollower checks whether the follower is alive by a cheap GetMembers call.
+func (c *pdServiceDiscovery) probeFollower(addr string) error {
+	cc, err := c.GetOrCreateGRPCConn(addr)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(c.ctx, updateMemberTimeout)
+	defer cancel()
+	_, err = pdpb.NewPDClient(cc).GetMembers(ctx, &pdpb.GetMembersRequest{})
+	return errors.WithStack(err)
+}
+
 // Close releases all resources.
 func (c *pdServiceDiscovery) Close() {
 	c.closeOnce.Do(func() {
@@ -355,13 +537,13 @@ func (c *pdServiceDiscovery) GetServiceURLs() []string {
 // which is the leader in a quorum-based cluster or the primary in a primary/secondary
 // configured cluster.
 func (c *pdServiceDiscovery) GetServingEndpointClientConn() *grpc.ClientConn {
-	if cc, ok := c.clientConns.Load(c.getLeaderAddr()); ok {
+	if cc, ok := c.clientConns.Load(grpcutil.NormalizeAddr(c.getLeaderAddr())); ok {
 		return cc.(*grpc.ClientConn)
 	}
 	return nil
 }
 
-// GetClientConns returns the mapping {addr -> a gRPC connection}
+// GetClientConns returns the mapping {normalized addr -> a gRPC connection}
 func (c *pdServiceDiscovery) GetClientConns() *sync.Map {
 	return &c.clientConns
 }
@@ -377,6 +559,70 @@ func (c *pdServiceDiscovery) GetBackupAddrs() []string {
 	return c.getFollowerAddrs()
 }
 
+// GetAllMembers returns the members learned from the last membership check. If the membership is
+// not learned yet, e.g., before the initialization, the members are queried from the servers instead. The returned members are copies which can be modified by the caller.
+func (c *pdServiceDiscovery) GetAllMembers(ctx context.Context) ([]*pdpb.Member, error) {
+	resp, _ := c.members.Load().(*pdpb.GetMembersResponse)
+	if resp == nil {
+		var err error
+		for _, url := range c.GetServiceURLs() {
+			if resp, err = c.getMembers(ctx, url, updateMemberTimeout); err == nil {
+				break
+			}
+			resp = nil
+		}
+		if resp == nil {
+			if err == nil {
+				err = errs.ErrClientGetMember.FastGenByArgs()
+			}
+			return nil, err
+		}
+	}
+	members := make([]*pdpb.Member, 0, len(resp.GetMembers()))
+	for _, m := range resp.GetMembers() {
+		members = append(members, proto.Clone(m).(*pdpb.Member))
+	}
+	return members, nil
+}
+
+// discoveryState is the snapshot of the service discovery view dumped by DumpState.
+type discoveryState struct {
+	ClusterID uint64   `json:"cluster-id"`
+	Leader    string   `json:"leader"`
+	Followers []string `json:"followers"`
+	// AllocatorLeaders maps the DC locations to the addresses of their tso allocator leaders.
+	AllocatorLeaders map[string]string `json:"allocator-leaders"`
+	// Connections maps the normalized addresses to the states of their gRPC connections.
+	Connections map[string]string `json:"connections"`
+}
+
+// DumpState returns the JSON snapshot of what the client currently believes, including the
+// cluster ID, leader, followers, tso allocator leaders and the states of the gRPC connections.
+// It is used for diagnostics.
+func (c *pdServiceDiscovery) DumpState() ([]byte, error) {
+	state := &discoveryState{
+		ClusterID:        c.GetClusterID(),
+		Leader:           c.getLeaderAddr(),
+		Followers:        c.getFollowerAddrs(),
+		AllocatorLeaders: make(map[string]string),
+		Connections:      make(map[string]string),
+	}
+	if allocLeaders, ok := c.tsoAllocLeaders.Load().(map[string]string); ok {
+		for dcLocation, addr := range allocLeaders {
+			state.AllocatorLeaders[dcLocation] = addr
+		}
+	}
+	c.clientConns.Range(func(key, value interface{}) bool {
+		state.Connections[key.(string)] = value.(*grpc.ClientConn).GetState().String()
+		return true
+	})
+	data, err := json.Marshal(state)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return data, nil
+}
+
 // ScheduleCheckMemberChanged is used to check if there is any membership
 // change among the leader and the followers.
 func (c *pdServiceDiscovery) ScheduleCheckMemberChanged() {
@@ -404,6 +650,12 @@ func (c *pdServiceDiscovery) AddServiceAddrsSwitchedCallback(callbacks ...func()
 	c.membersChangedCbs = append(c.membersChangedCbs, callbacks...)
 }
 
+// AddClusterIDChangedCallback adds callbacks which will be called when a different cluster ID
+// is observed from the servers.
+func (c *pdServiceDiscovery) AddClusterIDChangedCallback(callbacks ...func(old, new uint64)) {
+	c.clusterIDChangedCbs = append(c.clusterIDChangedCbs, callbacks...)
+}
+
 // SetTSOLocalServAddrsUpdatedCallback adds a callback which will be called when the local tso
 // allocator leader list is updated.
 func (c *pdServiceDiscovery) SetTSOLocalServAddrsUpdatedCallback(callback tsoLocalServAddrsUpdatedFunc) {
@@ -429,18 +681,54 @@ func (c *pdServiceDiscovery) getLeaderAddr() string {
 	return leaderAddr.(string)
 }
 
-// getFollowerAddrs returns the follower address.
+// getFollowerAddrs returns the follower address except the unhealthy ones.
 func (c *pdServiceDiscovery) getFollowerAddrs() []string {
 	followerAddrs := c.followers.Load()
 	if followerAddrs == nil {
 		return []string{}
 	}
-	return followerAddrs.([]string)
+	addrs := followerAddrs.([]string)
+	unhealthy, _ := c.unhealthyFollowers.Load().(map[string]struct{})
+	if len(unhealthy) == 0 {
+		return addrs
+	}
+	healthy := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		if _, ok := unhealthy[addr]; !ok {
+			healthy = append(healthy, addr)
+		}
+	}
+	return healthy
+}
+
+// loadLeaderHint loads the last-known leader from the hint store, empty means there is no hint.
+func (c *pdServiceDiscovery) loadLeaderHint() string {
+	if c.option.loadLeaderHint == nil {
+		return ""
+	}
+	hint, err := c.option.loadLeaderHint()
+	if err != nil {
+		log.Warn("[pd] failed to load the leader hint", errs.ZapError(err))
+		return ""
+	}
+	return hint
+}
+
+// saveLeaderHint saves the current leader to the hint store.
+func (c *pdServiceDiscovery) saveLeaderHint(leader string) {
+	if c.option.saveLeaderHint == nil {
+		return
+	}
+	if err := c.option.saveLeaderHint(leader); err != nil {
+		log.Warn("[pd] failed to save the leader hint", zap.String("leader", leader), errs.ZapError(err))
+	}
 }
 
 func (c *pdServiceDiscovery) initClusterID() error {
 	ctx, cancel := context.WithCancel(c.ctx)
 	defer cancel()
+	// The leader hint is not used here since it may be stale, e.g., the address is reused by another
+	// cluster, so the cluster ID is always confirmed by the given URLs.
 	clusterID := uint64(0)
 	for _, url := range c.GetServiceURLs() {
 		members, err := c.getMembers(ctx, url, c.option.timeout)
@@ -448,6 +736,12 @@ func (c *pdServiceDiscovery) initClusterID() error {
 			log.Warn("[pd] failed to get cluster id", zap.String("url", url), errs.ZapError(err))
 			continue
 		}
+		// The zero cluster ID means the server is not ready yet, e.g., during the bootstrap,
+		// so it's not regarded as a mismatch.
+		if members.GetHeader().GetClusterId() == 0 {
+			log.Warn("[pd] cluster id is not ready", zap.String("url", url))
+			continue
+		}
 		if clusterID == 0 {
 			clusterID = members.GetHeader().GetClusterId()
 			continue
@@ -455,7 +749,7 @@ func (c *pdServiceDiscovery) initClusterID() error {
 		failpoint.Inject("skipClusterIDCheck", func() {
 			failpoint.Continue()
 		})
-		// All URLs passed in should have the same cluster ID.
+		// All URLs passed in should have the same non-zero cluster ID.
 		if members.GetHeader().GetClusterId() != clusterID {
 			return errors.WithStack(errUnmatchedClusterID)
 		}
@@ -493,17 +787,35 @@ func (c *pdServiceDiscovery) checkServiceModeChanged() error {
 }
 
 func (c *pdServiceDiscovery) updateMember() error {
-	for i, url := range c.GetServiceURLs() {
+	leader := c.getLeaderAddr()
+	gracePeriod := c.option.leaderLostGracePeriod
+	urls := c.getPrioritizedServiceURLs()
+	if gracePeriod > 0 && len(leader) > 0 {
+		// Check the known leader first to find out whether it's still reachable.
+		urls = moveToFront(urls, leader)
+	}
+	if len(c.leaderHint) > 0 {
+		// Try the hinted leader first during the initialization.
+		urls = moveToFront(urls, c.leaderHint)
+	}
+	for i, url := range urls {
 		failpoint.Inject("skipFirstUpdateMember", func() {
 			if i == 0 {
 				failpoint.Continue()
 			}
 		})
 
-		members, err := c.getMembers(c.ctx, url, updateMemberTimeout)
+		members, err := c.getMembersWithSoftErrorRetry(url)
 		// Check the cluster ID.
 		if err == nil && members.GetHeader().GetClusterId() != c.clusterID {
+			// The stale leader hint may point to another cluster, which is not a cluster ID change.
+			if url != c.leaderHint {
+				c.onClusterIDMismatch(members.GetHeader().GetClusterId())
+			}
 			err = errs.ErrClientUpdateMember.FastGenByArgs("cluster id does not match")
+		} else if err == nil {
+			// The cluster ID matches again, so the next mismatch is reported even with the same cluster ID.
+			c.observedClusterID = 0
 		}
 		// Check the TSO Allocator Leader.
 		var errTSO error
@@ -520,6 +832,19 @@ func (c *pdServiceDiscovery) updateMember() error {
 			log.Info("[pd] cannot update member from this address",
 				zap.String("address", url),
 				errs.ZapError(err))
+			updateMemberFailedCounter.WithLabelValues(url).Inc()
+			if gracePeriod > 0 && url == leader {
+				if c.leaderUnreachableSince.IsZero() {
+					c.leaderUnreachableSince = time.Now()
+				}
+				// Keep the leader until it has been unreachable for the whole grace period.
+				if unreachable := time.Since(c.leaderUnreachableSince); unreachable < gracePeriod {
+					log.Info("[pd] the leader is unreachable, keep it within the grace period",
+						zap.String("leader", leader), zap.Duration("unreachable", unreachable),
+						zap.Duration("grace-period", gracePeriod))
+					return errors.WithStack(err)
+				}
+			}
 			select {
 			case <-c.ctx.Done():
 				return errors.WithStack(err)
@@ -528,11 +853,27 @@ func (c *pdServiceDiscovery) updateMember() error {
 			}
 		}
 
+		if url == leader {
+			c.leaderUnreachableSince = time.Time{}
+		}
+		c.members.Store(members)
 		c.updateURLs(members.GetMembers())
 		c.updateFollowers(members.GetMembers(), members.GetLeader())
+		checkLeaderPriority(members.GetMembers(), members.GetLeader())
 		if err := c.switchLeader(members.GetLeader().GetClientUrls()); err != nil {
+			// The leader has no available client URL, try the next address.
+			if errs.ErrClientGetLeader.Equal(err) {
+				log.Info("[pd] cannot switch leader from this address",
+					zap.String("address", url),
+					errs.ZapError(err))
+				continue
+			}
 			return err
 		}
+		if c.getLeaderAddr() != leader {
+			// The grace period starts over for the new leader.
+			c.leaderUnreachableSince = time.Time{}
+		}
 
 		// If `switchLeader` succeeds but `switchTSOAllocatorLeader` has an error,
 		// the error of `switchTSOAllocatorLeader` will be returned.
@@ -541,6 +882,20 @@ func (c *pdServiceDiscovery) updateMember() error {
 	return errs.ErrClientGetMember.FastGenByArgs()
 }
 
+// onClusterIDMismatch calls the callbacks when a different cluster ID is observed for the first time,
+// so that the application can reset its state rather than retrying on the permanent mismatch.
+func (c *pdServiceDiscovery) onClusterIDMismatch(clusterID uint64) {
+	if clusterID == c.observedClusterID {
+		return
+	}
+	c.observedClusterID = clusterID
+	log.Warn("[pd] observe a different cluster id",
+		zap.Uint64("cluster-id", c.clusterID), zap.Uint64("observed-cluster-id", clusterID))
+	for _, cb := range c.clusterIDChangedCbs {
+		cb(c.clusterID, clusterID)
+	}
+}
+
 func (c *pdServiceDiscovery) getClusterInfo(ctx context.Context, url string, timeout time.Duration) (*pdpb.GetClusterInfoResponse, error) {
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
@@ -560,30 +915,102 @@ func (c *pdServiceDiscovery) getClusterInfo(ctx context.Context, url string, tim
 	return clusterInfo, nil
 }
 
-func (c *pdServiceDiscovery) getMembers(ctx context.Context, url string, timeout time.Duration) (*pdpb.GetMembersResponse, error) {
+func (c *pdServiceDiscovery) getMembers(ctx context.Context, url string, timeout time.Duration) (members *pdpb.GetMembersResponse, err error) {
+	start := time.Now()
+	defer func() { observeRPCDuration(rpcDurationGetMembersSucceeded, rpcDurationGetMembersFailed, start, err) }()
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 	cc, err := c.GetOrCreateGRPCConn(url)
 	if err != nil {
 		return nil, err
 	}
-	members, err := pdpb.NewPDClient(cc).GetMembers(ctx, &pdpb.GetMembersRequest{})
+	members, err = pdpb.NewPDClient(cc).GetMembers(ctx, &pdpb.GetMembersRequest{})
 	if err != nil {
 		attachErr := errors.Errorf("error:%s target:%s status:%s", err, cc.Target(), cc.GetState().String())
 		return nil, errs.ErrClientGetMember.Wrap(attachErr).GenWithStackByCause()
 	}
 	if members.GetHeader().GetError() != nil {
 		attachErr := errors.Errorf("error:%s target:%s status:%s", members.GetHeader().GetError().String(), cc.Target(), cc.GetState().String())
-		return nil, errs.ErrClientGetMember.Wrap(attachErr).GenWithStackByCause()
+		// Return the members as well to let the caller check the header error.
+		return members, errs.ErrClientGetMember.Wrap(attachErr).GenWithStackByCause()
 	}
 	return members, nil
 }
 
+// getMembersWithSoftErrorRetry gets the members from the given URL. Unlike the hard errors which make
+// the caller try the next URL, the soft header errors are recoverable, so the same URL will be retried.
+func (c *pdServiceDiscovery) getMembersWithSoftErrorRetry(url string) (members *pdpb.GetMembersResponse, err error) {
+	for i := 0; ; i++ {
+		members, err = c.getMembers(c.ctx, url, updateMemberTimeout)
+		if err == nil || i >= softMemberErrorRetryTimes ||
+			!c.option.isSoftMemberError(members.GetHeader().GetError().GetType()) {
+			return members, err
+		}
+		log.Info("[pd] meet a soft error when getting members, retry the same address",
+			zap.String("address", url), zap.Int("retry-times", i+1), errs.ZapError(err))
+		select {
+		case <-c.ctx.Done():
+			return members, err
+		case <-time.After(softMemberErrorRetryInterval):
+		}
+	}
+}
+
+// getPrioritizedServiceURLs returns the service URLs ordered by the leader priorities of
+// the members in descending order, so that the higher-priority members are queried first.
+func (c *pdServiceDiscovery) getPrioritizedServiceURLs() []string {
+	urls := append([]string(nil), c.GetServiceURLs()...)
+	priorities, _ := c.urlPriorities.Load().(map[string]int32)
+	if len(priorities) == 0 {
+		return urls
+	}
+	sort.SliceStable(urls, func(i, j int) bool {
+		return priorities[urls[i]] > priorities[urls[j]]
+	})
+	return urls
+}
+
+// moveToFront moves the given URL to the front of the URLs, it's prepended if not found.
+func moveToFront(urls []string, url string) []string {
+	result := make([]string, 0, len(urls)+1)
+	result = append(result, url)
+	for _, u := range urls {
+		if u != url {
+			result = append(result, u)
+		}
+	}
+	return result
+}
+
+// checkLeaderPriority logs a warning if the leader is not the member with the highest
+// leader priority, which means the priorities are not honored by the cluster.
+func checkLeaderPriority(members []*pdpb.Member, leader *pdpb.Member) {
+	var highest *pdpb.Member
+	for _, m := range members {
+		if highest == nil || m.GetLeaderPriority() > highest.GetLeaderPriority() {
+			highest = m
+		}
+	}
+	if highest == nil || leader == nil || leader.GetLeaderPriority() >= highest.GetLeaderPriority() {
+		return
+	}
+	log.Warn("[pd] the leader is not the member with the highest leader priority",
+		zap.String("leader", leader.GetName()),
+		zap.Int32("leader-priority", leader.GetLeaderPriority()),
+		zap.String("highest-priority-member", highest.GetName()),
+		zap.Int32("highest-priority", highest.GetLeaderPriority()))
+}
+
 func (c *pdServiceDiscovery) updateURLs(members []*pdpb.Member) {
 	urls := make([]string, 0, len(members))
+	priorities := make(map[string]int32, len(members))
 	for _, m := range members {
 		urls = append(urls, m.GetClientUrls()...)
+		for _, url := range m.GetClientUrls() {
+			priorities[url] = m.GetLeaderPriority()
+		}
 	}
+	c.urlPriorities.Store(priorities)
 
 	sort.Strings(urls)
 	oldURLs := c.GetServiceURLs()
@@ -603,19 +1030,24 @@ func (c *pdServiceDiscovery) updateURLs(members []*pdpb.Member) {
 }
 
 func (c *pdServiceDiscovery) switchLeader(addrs []string) error {
-	// FIXME: How to safely compare leader urls? For now, only allows one client url.
-	addr := addrs[0]
+	if len(addrs) == 0 {
+		return errs.ErrClientGetLeader.FastGenByArgs("leader address doesn't exist")
+	}
 	oldLeader := c.getLeaderAddr()
-	if addr == oldLeader {
-		return nil
+	// The leader may advertise multiple client URLs, keep using the current one if it's still advertised.
+	for _, addr := range addrs {
+		if addr == oldLeader {
+			return nil
+		}
 	}
 
-	if _, err := c.GetOrCreateGRPCConn(addr); err != nil {
-		log.Warn("[pd] failed to connect leader", zap.String("leader", addr), errs.ZapError(err))
+	addr, err := c.connectLeader(addrs)
+	if err != nil {
 		return err
 	}
 	// Set PD leader and Global TSO Allocator (which is also the PD leader)
 	c.leader.Store(addr)
+	c.saveLeaderHint(addr)
 	// Run callbacks
 	if c.tsoGlobalAllocLeaderUpdatedCb != nil {
 		if err := c.tsoGlobalAllocLeaderUpdatedCb(addr); err != nil {
@@ -625,16 +1057,77 @@ func (c *pdServiceDiscovery) switchLeader(addrs []string) error {
 	for _, cb := range c.leaderSwitchedCbs {
 		cb()
 	}
+	// The first leader found is not a switch.
+	if len(oldLeader) > 0 {
+		leaderSwitchCounter.Inc()
+	}
 	log.Info("[pd] switch leader", zap.String("new-leader", addr), zap.String("old-leader", oldLeader))
 	return nil
 }
 
+// connectLeader tries the client URLs of the leader in order and returns the first one which connects.
+// If there is only one URL, it's returned once the connection is created without waiting for it to be
+// ready since there is no other choice.
+func (c *pdServiceDiscovery) connectLeader(addrs []string) (string, error) {
+	var err error
+	for _, addr := range addrs {
+		var cc *grpc.ClientConn
+		cc, err = c.GetOrCreateGRPCConn(addr)
+		if err == nil && len(addrs) > 1 {
+			err = waitConnReady(c.ctx, cc, updateMemberTimeout)
+		}
+		if err == nil {
+			return addr, nil
+		}
+		log.Warn("[pd] failed to connect leader", zap.String("leader", addr), errs.ZapError(err))
+	}
+	return "", err
+}
+
+// waitConnReady waits for the connection to be ready until the timeout, it fails fast once the
+// connection fails to connect. An idle connection does not connect by itself, so a health check
+// with WaitForReady is issued to kick it off, which works with all the gRPC versions the client is
+// built with.
+func waitConnReady(ctx context.Context, cc *grpc.ClientConn, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	go func() {
+		// The result is ignored since the state is checked below, and the RPC is canceled on return.
+		_, _ = healthpb.NewHealthClient(cc).Check(ctx, &healthpb.HealthCheckRequest{Service: ""}, grpc.WaitForReady(true))
+	}()
+	for {
+		state := cc.GetState()
+		switch state {
+		case connectivity.Ready:
+			return nil
+		case connectivity.TransientFailure, connectivity.Shutdown:
+			return errors.Errorf("failed to connect %s, state: %s", cc.Target(), state)
+		}
+		// Keep waiting on Idle and Connecting since the health check above kicks off the connection.
+		if !cc.WaitForStateChange(ctx, state) {
+			return errors.Errorf("the connection to %s is not ready, state: %s", cc.Target(), cc.GetState())
+		}
+	}
+}
+
 func (c *pdServiceDiscovery) updateFollowers(members []*pdpb.Member, leader *pdpb.Member) {
-	var addrs []string
+	var (
+		addrs     []string
+		followers int
+	)
 	for _, member := range members {
 		if member.GetMemberId() != leader.GetMemberId() {
 			if len(member.GetClientUrls()) > 0 {
 				addrs = append(addrs, member.GetClientUrls()...)
+				followers++
+			}
+		}
+	}
+	followerCountGauge.Set(float64(followers))
+	if c.option.eagerFollowerDial {
+		for _, addr := range addrs {
+			if _, err := c.GetOrCreateGRPCConn(addr); err != nil {
+				log.Warn("[pd] failed to connect follower", zap.String("follower", addr), errs.ZapError(err))
 			}
 		}
 	}
@@ -655,6 +1148,7 @@ func (c *pdServiceDiscovery) switchTSOAllocatorLeaders(allocatorMap map[string]*
 		allocMap[dcLocation] = member.GetClientUrls()[0]
 	}
 
+	c.tsoAllocLeaders.Store(allocMap)
 	// Run the callback to reflect any possible change in the local tso allocators.
 	if c.tsoLocalAllocLeadersUpdatedCb != nil {
 		if err := c.tsoLocalAllocLeadersUpdatedCb(allocMap); err != nil {
@@ -667,5 +1161,58 @@ func (c *pdServiceDiscovery) switchTSOAllocatorLeaders(allocatorMap map[string]*
 
 // GetOrCreateGRPCConn returns the corresponding grpc client connection of the given addr
 func (c *pdServiceDiscovery) GetOrCreateGRPCConn(addr string) (*grpc.ClientConn, error) {
-	return grpcutil.GetOrCreateGRPCConn(c.ctx, &c.clientConns, addr, c.tlsCfg, c.option.gRPCDialOptions...)
+	tlsCfg := c.getTLSConfig()
+	if c.option.checkConnLiveness {
+		return grpcutil.GetOrCreateLiveGRPCConn(c.ctx, &c.clientConns, addr, tlsCfg, c.option.getGRPCDialOptions()...)
+	}
+	return grpcutil.GetOrCreateGRPCConn(c.ctx, &c.clientConns, addr, tlsCfg, c.option.getGRPCDialOptions()...)
+}
+
+func (c *pdServiceDiscovery) getTLSConfig() *tlsutil.TLSConfig {
+	c.tlsCfgMu.RLock()
+	defer c.tlsCfgMu.RUnlock()
+	return c.tlsCfg
+}
+
+// UpdateTLSConfig replaces the TLS config used to create the gRPC connections and resets the
+// existing connections.
+func (c *pdServiceDiscovery) UpdateTLSConfig(tlsCfg *tlsutil.TLSConfig) error {
+	// Validate the new config before replacing the working one.
+	if _, err := tlsCfg.ToTLSConfig(); err != nil {
+		return err
+	}
+	c.tlsCfgMu.Lock()
+	c.tlsCfg = tlsCfg
+	c.tlsCfgMu.Unlock()
+	log.Info("[pd] the tls config is updated, reset the grpc connections")
+	resetClientConns(c.ctx, &c.clientConns, connDrainTimeout)
+	return nil
+}
+
+// resetClientConns removes the connections from the given map, so that the new ones will be created
+// for the later requests, and closes them after the drain timeout to let the in-flight requests finish.
+func resetClientConns(ctx context.Context, clientConns *sync.Map, drainTimeout time.Duration) {
+	var conns []*grpc.ClientConn
+	clientConns.Range(func(key, cc interface{}) bool {
+		if clientConns.CompareAndDelete(key, cc) {
+			conns = append(conns, cc.(*grpc.ClientConn))
+		}
+		return true
+	})
+	if len(conns) == 0 {
+		return
+	}
+	go func() {
+		timer := time.NewTimer(drainTimeout)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+		case <-timer.C:
+		}
+		for _, cc := range conns {
+			if err := cc.Close(); err != nil {
+				log.Error("[pd] failed to close grpc clientConn", errs.ZapError(errs.ErrCloseGRPCConn, err))
+			}
+		}
+	}()
 }
@@ -0,0 +1,91 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pd
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// retryBudget is a token bucket shared by all the retry paths of a client, which bounds
+// the aggregate retry rate to avoid overwhelming a recovering cluster with retry storms.
+// A nil retryBudget means the retries are not limited.
+type retryBudget struct {
+	mu sync.Mutex
+	// rate is the number of tokens refilled per second.
+	rate float64
+	// burst is the max number of tokens in the bucket.
+	burst float64
+	// tokens is the number of available tokens at the time of lastRefill.
+	tokens     float64
+	lastRefill time.Time
+}
+
+// newRetryBudget creates a retry budget which allows rate retries per second on average
+// with bursts of at most burst retries.
+func newRetryBudget(rate float64, burst int) *retryBudget {
+	if burst < 1 {
+		burst = 1
+	}
+	return &retryBudget{
+		rate:       rate,
+		burst:      float64(burst),
+		tokens:     float64(burst),
+		lastRefill: time.Now(),
+	}
+}
+
+// reserve takes a token if there is any, otherwise it returns how long to wait
+// until the next token is available.
+func (b *retryBudget) reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	b.tokens += now.Sub(b.lastRefill).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.lastRefill = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	if b.rate <= 0 {
+		// The bucket will never be refilled, check again after a while in case the context is done.
+		return time.Second
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// acquire blocks until a retry is allowed by the budget or the context is done.
+func (b *retryBudget) acquire(ctx context.Context) error {
+	if b == nil {
+		return nil
+	}
+	for {
+		wait := b.reserve()
+		if wait == 0 {
+			return nil
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pd
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryBudget(t *testing.T) {
+	re := require.New(t)
+	// A nil budget never blocks.
+	var budget *retryBudget
+	re.NoError(budget.acquire(context.Background()))
+
+	budget = newRetryBudget(10, 3)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		re.NoError(budget.acquire(context.Background()))
+	}
+	re.Less(time.Since(start), 50*time.Millisecond)
+	// The bucket is empty, the next token will be refilled after about 100ms.
+	re.NoError(budget.acquire(context.Background()))
+	re.GreaterOrEqual(time.Since(start), 80*time.Millisecond)
+
+	// The context is done before a token is available.
+	budget = newRetryBudget(0, 1)
+	re.NoError(budget.acquire(context.Background()))
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	re.ErrorIs(budget.acquire(ctx), context.DeadlineExceeded)
+}
+
+func TestRetryBudgetBoundsConcurrentRetries(t *testing.T) {
+	re := require.New(t)
+	const (
+		concurrency = 50
+		rate        = 5
+		burst       = 5
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), 2500*time.Millisecond)
+	defer cancel()
+	option := newOption()
+	option.retryBudget = newRetryBudget(rate, burst)
+	cli := &pdServiceDiscovery{ctx: ctx, cancel: cancel, option: option}
+
+	var attempts atomic.Int64
+	failedCall := func() error {
+		attempts.Add(1)
+		return errors.New("cluster is struggling")
+	}
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			re.Error(cli.initRetry(failedCall))
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	// The first attempt of each call is not a retry.
+	retries := attempts.Load() - concurrency
+	re.LessOrEqual(float64(retries), burst+rate*elapsed.Seconds()+1)
+	// Without the budget, each call would retry about once per second.
+	re.Less(retries, int64(concurrency))
+}
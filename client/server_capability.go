@@ -0,0 +1,119 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pd
+
+import (
+	"context"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/kvproto/pkg/keyspacepb"
+	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/client/errs"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ServerCapability is a capability that the client may require the PD server to support.
+type ServerCapability int
+
+const (
+	// ServiceModeCapability means the server can report its service mode via GetClusterInfo,
+	// which is required to work with the API service and the TSO microservice.
+	ServiceModeCapability ServerCapability = iota
+	// KeyspaceCapability means the server provides the keyspace service.
+	KeyspaceCapability
+)
+
+// String implements fmt.Stringer.
+func (sc ServerCapability) String() string {
+	switch sc {
+	case ServiceModeCapability:
+		return "service-mode"
+	case KeyspaceCapability:
+		return "keyspace"
+	default:
+		return "unknown"
+	}
+}
+
+// probe checks whether the server behind the given connection supports the capability.
+// Only the Unimplemented error is considered as lacking the capability, any other error
+// means the server knows about the RPC.
+func (sc ServerCapability) probe(ctx context.Context, cc *grpc.ClientConn, header *pdpb.RequestHeader) (bool, error) {
+	var err error
+	switch sc {
+	case ServiceModeCapability:
+		_, err = pdpb.NewPDClient(cc).GetClusterInfo(ctx, &pdpb.GetClusterInfoRequest{})
+	case KeyspaceCapability:
+		_, err = keyspacepb.NewKeyspaceClient(cc).LoadKeyspace(ctx, &keyspacepb.LoadKeyspaceRequest{
+			Header: header,
+			Name:   defaultKeyspaceName,
+		})
+	default:
+		return false, errors.Errorf("unknown server capability %d", sc)
+	}
+	if err == nil {
+		return true, nil
+	}
+	if status.Code(err) == codes.Unimplemented {
+		return false, nil
+	}
+	return false, err
+}
+
+// checkServerCapabilities verifies that the PD leader supports all the capabilities required
+// by the client option. A warning is logged for each missing capability, and an error is returned
+// if the option asks to enforce the requirement.
+func (c *pdServiceDiscovery) checkServerCapabilities() error {
+	if len(c.option.requiredServerCapabilities) == 0 {
+		return nil
+	}
+	leaderAddr := c.getLeaderAddr()
+	if len(leaderAddr) == 0 {
+		return errs.ErrClientGetLeader.FastGenByArgs("leader address doesn't exist")
+	}
+	cc, err := c.GetOrCreateGRPCConn(leaderAddr)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(c.ctx, c.option.timeout)
+	defer cancel()
+	header := &pdpb.RequestHeader{ClusterId: c.clusterID}
+
+	var missing []string
+	for _, capability := range c.option.requiredServerCapabilities {
+		supported, err := capability.probe(ctx, cc, header)
+		if err != nil {
+			return errs.ErrClientCheckServerCapability.Wrap(err).GenWithStackByCause()
+		}
+		if !supported {
+			missing = append(missing, capability.String())
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	log.Warn("[pd] the server lacks the capabilities required by the client, please check the version compatibility",
+		zap.String("leader", leaderAddr),
+		zap.Strings("missing-capabilities", missing),
+		zap.Bool("enforce", c.option.enforceServerCapabilities))
+	if c.option.enforceServerCapabilities {
+		return errs.ErrClientIncompatibleServer.FastGenByArgs(missing)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pd
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/pd/client/errs"
+	"github.com/tikv/pd/client/tlsutil"
+	"google.golang.org/grpc"
+)
+
+// mockPDServer is a PD server which only implements GetClusterInfo and does not provide
+// the keyspace service, to simulate an old server lacking some capabilities.
+type mockPDServer struct {
+	pdpb.UnimplementedPDServer
+}
+
+func (*mockPDServer) GetClusterInfo(context.Context, *pdpb.GetClusterInfoRequest) (*pdpb.GetClusterInfoResponse, error) {
+	return &pdpb.GetClusterInfoResponse{
+		Header:       &pdpb.ResponseHeader{},
+		ServiceModes: []pdpb.ServiceMode{pdpb.ServiceMode_PD_SVC_MODE},
+	}, nil
+}
+
+func startMockPDServer(re *require.Assertions) (addr string, stop func()) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	re.NoError(err)
+	s := grpc.NewServer()
+	pdpb.RegisterPDServer(s, &mockPDServer{})
+	go s.Serve(lis)
+	return "http://" + lis.Addr().String(), s.Stop
+}
+
+func TestCheckServerCapabilities(t *testing.T) {
+	re := require.New(t)
+	addr, stop := startMockPDServer(re)
+	defer stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cli := &pdServiceDiscovery{
+		ctx:    ctx,
+		cancel: cancel,
+		tlsCfg: &tlsutil.TLSConfig{},
+		option: newOption(),
+	}
+	defer cli.Close()
+	cli.leader.Store(addr)
+
+	// No capability is required.
+	re.NoError(cli.checkServerCapabilities())
+
+	// The server supports the service mode capability.
+	cli.option.requiredServerCapabilities = []ServerCapability{ServiceModeCapability}
+	cli.option.enforceServerCapabilities = true
+	re.NoError(cli.checkServerCapabilities())
+
+	// The server lacks the keyspace capability, only a warning is logged if not enforced.
+	cli.option.requiredServerCapabilities = []ServerCapability{ServiceModeCapability, KeyspaceCapability}
+	cli.option.enforceServerCapabilities = false
+	re.NoError(cli.checkServerCapabilities())
+
+	// The check fails if enforced.
+	cli.option.enforceServerCapabilities = true
+	err := cli.checkServerCapabilities()
+	re.Error(err)
+	re.True(errs.ErrClientIncompatibleServer.Equal(err))
+	re.Contains(err.Error(), KeyspaceCapability.String())
+	re.NotContains(err.Error(), ServiceModeCapability.String())
+}
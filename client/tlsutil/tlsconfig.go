@@ -187,7 +187,7 @@ func (s TLSConfig) ToTLSConfig() (*tls.Config, error) {
 	if len(s.SSLCABytes) != 0 || len(s.SSLCertBytes) != 0 || len(s.SSLKEYBytes) != 0 {
 		cert, err := tls.X509KeyPair(s.SSLCertBytes, s.SSLKEYBytes)
 		if err != nil {
-			return nil, errs.ErrCryptoX509KeyPair.GenWithStackByCause()
+			return nil, errs.ErrCryptoX509KeyPair.Wrap(err).GenWithStackByCause()
 		}
 		certificates := []tls.Certificate{cert}
 		// Create a certificate pool from CA
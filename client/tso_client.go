@@ -18,12 +18,17 @@ import (
 	"context"
 	"fmt"
 	"math/rand"
+	"sort"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/pingcap/errors"
 	"github.com/pingcap/log"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/tikv/pd/client/errs"
+	"github.com/tikv/pd/client/grpcutil"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	healthpb "google.golang.org/grpc/health/grpc_health_v1"
@@ -39,9 +44,23 @@ type TSOClient interface {
 	GetLocalTS(ctx context.Context, dcLocation string) (int64, int64, error)
 	// GetLocalTSAsync gets a local timestamp from PD or TSO microservice, without block the caller.
 	GetLocalTSAsync(ctx context.Context, dcLocation string) TSFuture
+	// GetAvailableDCLocations returns the sorted dc-locations which have a known Local TSO allocator,
+	// so the callers could validate the dc-location before requesting the Local TSO.
+	GetAvailableDCLocations() []string
+	// GetSharedTS gets a global timestamp from PD or TSO microservice. Unlike GetTS, the
+	// timestamp is not guaranteed to be distinct: all the shared requests in the same batch
+	// get the same timestamp, which only consumes one logical. It is useful for the callers
+	// that only need a snapshot of the current time, e.g. the read-only transactions.
+	GetSharedTS(ctx context.Context) (int64, int64, error)
+	// GetSharedTSAsync is the async version of GetSharedTS.
+	GetSharedTSAsync(ctx context.Context) TSFuture
 	// GetMinTS gets a timestamp from PD or the minimal timestamp across all keyspace groups from
 	// the TSO microservice.
 	GetMinTS(ctx context.Context) (int64, int64, error)
+	// GetTSAtLeast gets a timestamp whose physical part is at least minPhysical from PD or TSO
+	// microservice. It waits for the allocator to advance and retries for a bounded number of
+	// times if the returned timestamp is below minPhysical.
+	GetTSAtLeast(ctx context.Context, keyspaceID uint32, minPhysical int64) (int64, int64, error)
 }
 
 type tsoRequest struct {
@@ -52,6 +71,9 @@ type tsoRequest struct {
 	physical   int64
 	logical    int64
 	dcLocation string
+	// shared indicates that the request doesn't need a distinct timestamp,
+	// so it could share one with the other shared requests in the same batch.
+	shared bool
 }
 
 var tsoReqPool = sync.Pool{
@@ -81,6 +103,10 @@ type tsoClient struct {
 	// tsoDispatcher is used to dispatch different TSO requests to
 	// the corresponding dc-location TSO channel.
 	tsoDispatcher sync.Map // Same as map[string]chan *tsoRequest
+	// fastPathDispatcher is the dispatcher of the Global TSO Allocator, which lets the global TSO requests,
+	// i.e., nearly all of them, skip the dc-location lookup of tsoDispatcher. The Global TSO dispatcher is
+	// never replaced once created, so it's only set on the creation and cleared in Close.
+	fastPathDispatcher atomic.Pointer[tsoDispatcher]
 	// dc-location -> deadline
 	tsDeadline sync.Map // Same as map[string]chan deadline
 	// dc-location -> *tsoInfo while the tsoInfo is the last TSO info
@@ -89,6 +115,28 @@ type tsoClient struct {
 	checkTSDeadlineCh         chan struct{}
 	checkTSODispatcherCh      chan struct{}
 	updateTSOConnectionCtxsCh chan struct{}
+
+	// keyspaceGroupDuration caches the duration observer of the keyspace group served lately,
+	// since the keyspace group rarely changes and WithLabelValues is heavy.
+	keyspaceGroupDuration atomic.Pointer[keyspaceGroupObserver]
+}
+
+type keyspaceGroupObserver struct {
+	keyspaceGroupID uint32
+	observer        prometheus.Observer
+}
+
+// getKeyspaceGroupDuration returns the duration observer of the given keyspace group.
+func (c *tsoClient) getKeyspaceGroupDuration(keyspaceGroupID uint32) prometheus.Observer {
+	if o := c.keyspaceGroupDuration.Load(); o != nil && o.keyspaceGroupID == keyspaceGroupID {
+		return o.observer
+	}
+	o := &keyspaceGroupObserver{
+		keyspaceGroupID: keyspaceGroupID,
+		observer:        tsoKeyspaceGroupDuration.WithLabelValues(strconv.FormatUint(uint64(keyspaceGroupID), 10)),
+	}
+	c.keyspaceGroupDuration.Store(o)
+	return o.observer
 }
 
 // newTSOClient returns a new TSO client.
@@ -137,6 +185,7 @@ func (c *tsoClient) Close() {
 	c.wg.Wait()
 
 	log.Info("close tso client")
+	c.fastPathDispatcher.Store(nil)
 	c.tsoDispatcher.Range(func(_, dispatcherInterface interface{}) bool {
 		if dispatcherInterface != nil {
 			dispatcher := dispatcherInterface.(*tsoDispatcher)
@@ -155,6 +204,20 @@ func (c *tsoClient) GetTSOAllocators() *sync.Map {
 	return &c.tsoAllocators
 }
 
+// GetAvailableDCLocations returns the sorted dc-locations which have a known local TSO allocator.
+// The global TSO allocator is not included.
+func (c *tsoClient) GetAvailableDCLocations() []string {
+	dcLocations := make([]string, 0)
+	c.tsoAllocators.Range(func(dcLocationKey, _ interface{}) bool {
+		if dcLocation := dcLocationKey.(string); dcLocation != globalDCLocation {
+			dcLocations = append(dcLocations, dcLocation)
+		}
+		return true
+	})
+	sort.Strings(dcLocations)
+	return dcLocations
+}
+
 // GetTSOAllocatorServingAddrByDCLocation returns the tso allocator of the given dcLocation
 func (c *tsoClient) GetTSOAllocatorServingAddrByDCLocation(dcLocation string) (string, bool) {
 	url, exist := c.tsoAllocators.Load(dcLocation)
@@ -171,7 +234,7 @@ func (c *tsoClient) GetTSOAllocatorClientConnByDCLocation(dcLocation string) (*g
 	if !ok {
 		panic(fmt.Sprintf("the allocator leader in %s should exist", dcLocation))
 	}
-	cc, ok := c.svcDiscovery.GetClientConns().Load(url)
+	cc, ok := c.svcDiscovery.GetClientConns().Load(grpcutil.NormalizeAddr(url.(string)))
 	if !ok {
 		panic(fmt.Sprintf("the client connection of %s in %s should exist", url, dcLocation))
 	}
@@ -72,6 +72,12 @@ func (c *tsoClient) scheduleUpdateTSOConnectionCtxs() {
 }
 
 func (c *tsoClient) dispatchRequest(dcLocation string, request *tsoRequest) error {
+	if dcLocation == globalDCLocation {
+		if dispatcher := c.fastPathDispatcher.Load(); dispatcher != nil {
+			dispatcher.tsoBatchController.tsoRequestCh <- request
+			return nil
+		}
+	}
 	dispatcher, ok := c.tsoDispatcher.Load(dcLocation)
 	if !ok {
 		err := errs.ErrClientGetTSO.FastGenByArgs(fmt.Sprintf("unknown dc-location %s to the client", dcLocation))
@@ -83,6 +89,32 @@ func (c *tsoClient) dispatchRequest(dcLocation string, request *tsoRequest) erro
 	return nil
 }
 
+// dispatchRequestWithRetry dispatches the request and retries with backoff if the dispatcher of the
+// dc-location is not ready. Each failed dispatch schedules a membership check to refresh the TSO
+// leader/primary, so the dispatcher may be created before the next retry.
+func (c *tsoClient) dispatchRequestWithRetry(ctx context.Context, dcLocation string, request *tsoRequest) error {
+	err := c.dispatchRequest(dcLocation, request)
+	backoff := c.option.tsoDispatchRetryInterval
+	for i := 0; err != nil && i < c.option.tsoDispatchRetryTimes; i++ {
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.WithStack(ctx.Err())
+		case <-c.ctx.Done():
+			timer.Stop()
+			return errors.WithStack(c.ctx.Err())
+		case <-timer.C:
+		}
+		if c.option.retryBudget.acquire(ctx) != nil {
+			return err
+		}
+		backoff *= 2
+		err = c.dispatchRequest(dcLocation, request)
+	}
+	return err
+}
+
 // TSFuture is a future which promises to return a TSO.
 type TSFuture interface {
 	// Wait gets the physical and logical time, it would block caller if data is not available yet.
@@ -310,6 +342,9 @@ func (c *tsoClient) createTSODispatcher(dcLocation string) {
 		// this goroutine should exit.
 		c.wg.Add(1)
 		go c.handleDispatcher(dispatcherCtx, dcLocation, dispatcher.tsoBatchController)
+		if dcLocation == globalDCLocation {
+			c.fastPathDispatcher.Store(dispatcher)
+		}
 		log.Info("[tso] tso dispatcher created", zap.String("dc-location", dcLocation))
 	} else {
 		dispatcherCancel()
@@ -365,7 +400,7 @@ func (c *tsoClient) handleDispatcher(
 					if enableTSOFollowerProxy && updateTicker.C == nil {
 						// Because the TSO Follower Proxy is enabled,
 						// the periodic check needs to be performed.
-						setNewUpdateTicker(time.NewTicker(memberUpdateInterval))
+						setNewUpdateTicker(time.NewTicker(c.option.getMemberUpdateInterval()))
 					} else if !enableTSOFollowerProxy && updateTicker.C != nil {
 						// Because the TSO Follower Proxy is disabled,
 						// the periodic check needs to be turned off.
@@ -619,6 +654,9 @@ func (c *tsoClient) tryConnectToTSO(
 			return err
 		case <-ticker.C:
 		}
+		if c.option.retryBudget.acquire(dispatcherCtx) != nil {
+			return err
+		}
 	}
 
 	if networkErrNum == maxRetryTimes {
@@ -739,17 +777,26 @@ func (c *tsoClient) processRequests(
 		span := opentracing.StartSpan("pdclient.processRequests", opts...)
 		defer span.Finish()
 	}
+	failpoint.Inject("delayProcessTSORequests", nil)
 
 	requests := tbc.getCollectedRequests()
-	count := int64(len(requests))
+	// The shared requests are coalesced into one slot of the batch to reduce the logical consumption.
+	distinctRequests, sharedRequests := splitSharedRequests(requests)
+	streamRequests := distinctRequests
+	if len(sharedRequests) > 0 {
+		streamRequests = append(streamRequests, sharedRequests[0])
+	}
+	count := int64(len(streamRequests))
 	reqKeyspaceGroupID := c.svcDiscovery.GetKeyspaceGroupID()
+	start := time.Now()
 	respKeyspaceGroupID, physical, logical, suffixBits, err := stream.processRequests(
 		c.svcDiscovery.GetClusterID(), c.svcDiscovery.GetKeyspaceID(), reqKeyspaceGroupID,
-		dcLocation, requests, tbc.batchStartTime)
+		dcLocation, streamRequests, tbc.batchStartTime)
 	if err != nil {
 		c.finishRequest(requests, 0, 0, 0, err)
 		return err
 	}
+	c.getKeyspaceGroupDuration(respKeyspaceGroupID).Observe(time.Since(start).Seconds())
 	// `logical` is the largest ts's logical part here, we need to do the subtracting before we finish each TSO request.
 	firstLogical := tsoutil.AddLogical(logical, -count+1, suffixBits)
 	curTSOInfo := &tsoInfo{
@@ -761,10 +808,36 @@ func (c *tsoClient) processRequests(
 		logical:             tsoutil.AddLogical(firstLogical, count-1, suffixBits),
 	}
 	c.compareAndSwapTS(dcLocation, curTSOInfo, physical, firstLogical)
-	c.finishRequest(requests, physical, firstLogical, suffixBits, nil)
+	c.finishRequest(distinctRequests, physical, firstLogical, suffixBits, nil)
+	// All the shared requests get the largest timestamp of the batch.
+	c.finishSharedRequest(sharedRequests, physical, curTSOInfo.logical)
 	return nil
 }
 
+// splitSharedRequests splits the requests into the ones need distinct timestamps and the shared ones.
+// It returns the original slice directly if there is no shared request to avoid the allocation.
+func splitSharedRequests(requests []*tsoRequest) (distinctRequests, sharedRequests []*tsoRequest) {
+	sharedCount := 0
+	for _, req := range requests {
+		if req.shared {
+			sharedCount++
+		}
+	}
+	if sharedCount == 0 {
+		return requests, nil
+	}
+	distinctRequests = make([]*tsoRequest, 0, len(requests)-sharedCount+1)
+	sharedRequests = make([]*tsoRequest, 0, sharedCount)
+	for _, req := range requests {
+		if req.shared {
+			sharedRequests = append(sharedRequests, req)
+		} else {
+			distinctRequests = append(distinctRequests, req)
+		}
+	}
+	return distinctRequests, sharedRequests
+}
+
 func (c *tsoClient) compareAndSwapTS(
 	dcLocation string,
 	curTSOInfo *tsoInfo,
@@ -810,6 +883,16 @@ func (c *tsoClient) compareAndSwapTS(
 	lastTSOInfo.logical = curTSOInfo.logical
 }
 
+func (c *tsoClient) finishSharedRequest(requests []*tsoRequest, physical, logical int64) {
+	for _, req := range requests {
+		if span := opentracing.SpanFromContext(req.requestCtx); span != nil {
+			span.Finish()
+		}
+		req.physical, req.logical = physical, logical
+		req.done <- nil
+	}
+}
+
 func (c *tsoClient) finishRequest(requests []*tsoRequest, physical, firstLogical int64, suffixBits uint32, err error) {
 	for i := 0; i < len(requests); i++ {
 		if span := opentracing.SpanFromContext(requests[i].requestCtx); span != nil {
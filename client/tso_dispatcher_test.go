@@ -0,0 +1,220 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pd
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pingcap/errors"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// mockTSOStream allocates the timestamps locally and records the consumed logical count.
+type mockTSOStream struct {
+	physical int64
+	logical  int64
+	consumed int64
+}
+
+func (s *mockTSOStream) getServerAddr() string {
+	return "mock"
+}
+
+func (s *mockTSOStream) processRequests(
+	_ uint64, _, _ uint32, _ string, requests []*tsoRequest, _ time.Time,
+) (respKeyspaceGroupID uint32, physical, logical int64, suffixBits uint32, err error) {
+	count := int64(len(requests))
+	s.logical += count
+	s.consumed += count
+	return defaultKeySpaceGroupID, s.physical, s.logical, 0, nil
+}
+
+func TestSharedTSORequests(t *testing.T) {
+	re := require.New(t)
+	const batchSize = 10
+	process := func(sharedCount int) (*mockTSOStream, []*tsoRequest) {
+		c := &tsoClient{svcDiscovery: &pdServiceDiscovery{}}
+		stream := &mockTSOStream{physical: 1}
+		tbc := newTSOBatchController(make(chan *tsoRequest, batchSize), batchSize)
+		requests := make([]*tsoRequest, 0, batchSize)
+		for i := 0; i < batchSize; i++ {
+			req := &tsoRequest{
+				requestCtx: context.Background(),
+				done:       make(chan error, 1),
+				shared:     i < sharedCount,
+			}
+			tbc.pushRequest(req)
+			requests = append(requests, req)
+		}
+		re.NoError(c.processRequests(stream, globalDCLocation, tbc, nil))
+		for _, req := range requests {
+			re.NoError(<-req.done)
+		}
+		return stream, requests
+	}
+
+	// Every request consumes a logical without sharing.
+	stream, requests := process(0)
+	re.Equal(int64(batchSize), stream.consumed)
+	seen := make(map[int64]struct{})
+	for _, req := range requests {
+		seen[req.logical] = struct{}{}
+	}
+	re.Len(seen, batchSize)
+
+	// All the shared requests consume only one logical and get the same timestamp.
+	stream, requests = process(batchSize)
+	re.Equal(int64(1), stream.consumed)
+	for _, req := range requests {
+		re.Equal(requests[0].physical, req.physical)
+		re.Equal(requests[0].logical, req.logical)
+	}
+
+	// The shared requests are mixed with the distinct ones.
+	stream, requests = process(batchSize / 2)
+	re.Equal(int64(batchSize/2+1), stream.consumed)
+	seen = make(map[int64]struct{})
+	for _, req := range requests[batchSize/2:] {
+		seen[req.logical] = struct{}{}
+	}
+	re.Len(seen, batchSize/2)
+	for _, req := range requests[:batchSize/2] {
+		re.Equal(stream.logical, req.logical)
+		re.NotContains(seen, req.logical)
+	}
+}
+
+func newTestTSODispatcher() *tsoDispatcher {
+	return &tsoDispatcher{
+		dispatcherCancel:   func() {},
+		tsoBatchController: newTSOBatchController(make(chan *tsoRequest, defaultMaxTSOBatchSize*2), defaultMaxTSOBatchSize),
+	}
+}
+
+func TestFastPathDispatcher(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &tsoClient{ctx: ctx, cancel: cancel, svcDiscovery: &pdServiceDiscovery{}}
+	global, local := newTestTSODispatcher(), newTestTSODispatcher()
+	c.tsoDispatcher.Store(globalDCLocation, global)
+	c.tsoDispatcher.Store("dc-1", local)
+	c.fastPathDispatcher.Store(global)
+
+	// The global TSO requests go through the fast path, the local ones look up their dc-locations.
+	req := &tsoRequest{}
+	re.NoError(c.dispatchRequest(globalDCLocation, req))
+	re.Equal(req, <-global.tsoBatchController.tsoRequestCh)
+	re.NoError(c.dispatchRequest("dc-1", req))
+	re.Equal(req, <-local.tsoBatchController.tsoRequestCh)
+	re.Empty(global.tsoBatchController.tsoRequestCh)
+
+	// The fast path is cleared once the client is closed.
+	c.Close()
+	re.Nil(c.fastPathDispatcher.Load())
+}
+
+func BenchmarkDispatchRequest(b *testing.B) {
+	bench := func(b *testing.B, fastPath bool) {
+		c := &tsoClient{svcDiscovery: &pdServiceDiscovery{}}
+		global := newTestTSODispatcher()
+		c.tsoDispatcher.Store(globalDCLocation, global)
+		// Store some Local TSO Allocators to emulate the dc-location lookup of the multi-dc clusters.
+		for i := 0; i < 3; i++ {
+			c.tsoDispatcher.Store(fmt.Sprintf("dc-%d", i), newTestTSODispatcher())
+		}
+		if fastPath {
+			c.fastPathDispatcher.Store(global)
+		}
+		req := &tsoRequest{}
+		ch := global.tsoBatchController.tsoRequestCh
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if err := c.dispatchRequest(globalDCLocation, req); err != nil {
+				b.Fatal(err)
+			}
+			<-ch
+		}
+	}
+	b.Run("dc-location-lookup", func(b *testing.B) { bench(b, false) })
+	b.Run("fast-path", func(b *testing.B) { bench(b, true) })
+}
+
+func TestDispatchRequestWithRetry(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	option := newOption()
+	option.tsoDispatchRetryTimes = 3
+	option.tsoDispatchRetryInterval = 10 * time.Millisecond
+	sd := &pdServiceDiscovery{checkMembershipCh: make(chan struct{}, 1)}
+	c := &tsoClient{ctx: ctx, option: option, svcDiscovery: sd}
+	newRequest := func() *tsoRequest {
+		return &tsoRequest{requestCtx: context.Background(), done: make(chan error, 1)}
+	}
+
+	// The retries are exhausted if the dispatcher is never ready.
+	start := time.Now()
+	err := c.dispatchRequestWithRetry(ctx, "dc-1", newRequest())
+	re.ErrorContains(err, "unknown dc-location dc-1")
+	// The backoff is doubled for each retry: 10ms + 20ms + 40ms.
+	re.GreaterOrEqual(time.Since(start), 70*time.Millisecond)
+	// A membership check is scheduled to refresh the TSO leader/primary.
+	re.Len(sd.checkMembershipCh, 1)
+
+	// The request is dispatched once the dispatcher is ready.
+	dispatcher := newTestTSODispatcher()
+	time.AfterFunc(15*time.Millisecond, func() {
+		c.tsoDispatcher.Store("dc-1", dispatcher)
+	})
+	req := newRequest()
+	re.NoError(c.dispatchRequestWithRetry(ctx, "dc-1", req))
+	re.Same(req, <-dispatcher.tsoBatchController.tsoRequestCh)
+
+	// The canceled request is not retried.
+	reqCtx, reqCancel := context.WithCancel(ctx)
+	reqCancel()
+	err = c.dispatchRequestWithRetry(reqCtx, "dc-2", newRequest())
+	re.ErrorIs(errors.Cause(err), context.Canceled)
+
+	// No retry is made if it's disabled.
+	option.tsoDispatchRetryTimes = 0
+	option.tsoDispatchRetryInterval = time.Hour
+	re.Error(c.dispatchRequestWithRetry(ctx, "dc-2", newRequest()))
+}
+
+func TestKeyspaceGroupDuration(t *testing.T) {
+	re := require.New(t)
+	c := &tsoClient{svcDiscovery: &pdServiceDiscovery{}}
+	tbc := newTSOBatchController(make(chan *tsoRequest, 1), 1)
+	req := &tsoRequest{requestCtx: context.Background(), done: make(chan error, 1)}
+	tbc.pushRequest(req)
+	re.NoError(c.processRequests(&mockTSOStream{physical: 1}, globalDCLocation, tbc, nil))
+	re.NoError(<-req.done)
+	// The latency is observed by the keyspace group of the response.
+	cached := c.keyspaceGroupDuration.Load()
+	re.NotNil(cached)
+	re.Equal(defaultKeySpaceGroupID, cached.keyspaceGroupID)
+	re.GreaterOrEqual(promtestutil.CollectAndCount(tsoKeyspaceGroupDuration), 1)
+
+	// The observer is reused until the keyspace group changes.
+	re.Equal(cached.observer, c.getKeyspaceGroupDuration(defaultKeySpaceGroupID))
+	re.Same(cached, c.keyspaceGroupDuration.Load())
+	c.getKeyspaceGroupDuration(1)
+	re.Equal(uint32(1), c.keyspaceGroupDuration.Load().keyspaceGroupID)
+}
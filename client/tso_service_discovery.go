@@ -26,6 +26,7 @@ import (
 	"github.com/gogo/protobuf/proto"
 	"github.com/pingcap/errors"
 	"github.com/pingcap/failpoint"
+	"github.com/pingcap/kvproto/pkg/pdpb"
 	"github.com/pingcap/kvproto/pkg/tsopb"
 	"github.com/pingcap/log"
 	"github.com/tikv/pd/client/errs"
@@ -149,7 +150,9 @@ type tsoServiceDiscovery struct {
 	wg                   sync.WaitGroup
 	printFallbackLogOnce sync.Once
 
-	tlsCfg *tlsutil.TLSConfig
+	// tlsCfgMu protects tlsCfg which may be replaced by UpdateTLSConfig.
+	tlsCfgMu sync.RWMutex
+	tlsCfg   *tlsutil.TLSConfig
 
 	// Client option.
 	option *option
@@ -220,6 +223,9 @@ func (c *tsoServiceDiscovery) retry(
 			return err
 		case <-ticker.C:
 		}
+		if c.option.retryBudget.acquire(c.ctx) != nil {
+			return err
+		}
 	}
 	return errors.WithStack(err)
 }
@@ -247,7 +253,7 @@ func (c *tsoServiceDiscovery) startCheckMemberLoop() {
 
 	ctx, cancel := context.WithCancel(c.ctx)
 	defer cancel()
-	ticker := time.NewTicker(memberUpdateInterval)
+	ticker := time.NewTicker(c.option.getMemberUpdateInterval())
 	defer ticker.Stop()
 
 	for {
@@ -258,7 +264,7 @@ func (c *tsoServiceDiscovery) startCheckMemberLoop() {
 			log.Info("[tso] exit check member loop")
 			return
 		}
-		// Make sure tsoQueryRetryMaxTimes * tsoQueryRetryInterval is far less than memberUpdateInterval,
+		// Make sure tsoQueryRetryMaxTimes * tsoQueryRetryInterval is far less than the member update interval,
 		// so that we can speed up the process of tso service discovery when failover happens on the
 		// tso service side and also ensures it won't call updateMember too frequently during normal time.
 		if err := c.retry(tsoQueryRetryMaxTimes, tsoQueryRetryInterval, c.updateMember); err != nil {
@@ -314,13 +320,13 @@ func (c *tsoServiceDiscovery) GetServiceURLs() []string {
 // GetServingAddr returns the grpc client connection of the serving endpoint
 // which is the primary in a primary/secondary configured cluster.
 func (c *tsoServiceDiscovery) GetServingEndpointClientConn() *grpc.ClientConn {
-	if cc, ok := c.clientConns.Load(c.getPrimaryAddr()); ok {
+	if cc, ok := c.clientConns.Load(grpcutil.NormalizeAddr(c.getPrimaryAddr())); ok {
 		return cc.(*grpc.ClientConn)
 	}
 	return nil
 }
 
-// GetClientConns returns the mapping {addr -> a gRPC connection}
+// GetClientConns returns the mapping {normalized addr -> a gRPC connection}
 func (c *tsoServiceDiscovery) GetClientConns() *sync.Map {
 	return &c.clientConns
 }
@@ -340,7 +346,31 @@ func (c *tsoServiceDiscovery) GetBackupAddrs() []string {
 
 // GetOrCreateGRPCConn returns the corresponding grpc client connection of the given addr.
 func (c *tsoServiceDiscovery) GetOrCreateGRPCConn(addr string) (*grpc.ClientConn, error) {
-	return grpcutil.GetOrCreateGRPCConn(c.ctx, &c.clientConns, addr, c.tlsCfg, c.option.gRPCDialOptions...)
+	tlsCfg := c.getTLSConfig()
+	if c.option.checkConnLiveness {
+		return grpcutil.GetOrCreateLiveGRPCConn(c.ctx, &c.clientConns, addr, tlsCfg, c.option.getGRPCDialOptions()...)
+	}
+	return grpcutil.GetOrCreateGRPCConn(c.ctx, &c.clientConns, addr, tlsCfg, c.option.getGRPCDialOptions()...)
+}
+
+func (c *tsoServiceDiscovery) getTLSConfig() *tlsutil.TLSConfig {
+	c.tlsCfgMu.RLock()
+	defer c.tlsCfgMu.RUnlock()
+	return c.tlsCfg
+}
+
+// UpdateTLSConfig replaces the TLS config used to create the gRPC connections to the TSO servers and
+// resets the existing connections. The API service discovery is updated separately by the client.
+func (c *tsoServiceDiscovery) UpdateTLSConfig(tlsCfg *tlsutil.TLSConfig) error {
+	if _, err := tlsCfg.ToTLSConfig(); err != nil {
+		return err
+	}
+	c.tlsCfgMu.Lock()
+	c.tlsCfg = tlsCfg
+	c.tlsCfgMu.Unlock()
+	log.Info("[tso] the tls config is updated, reset the grpc connections")
+	resetClientConns(c.ctx, &c.clientConns, connDrainTimeout)
+	return nil
 }
 
 // ScheduleCheckMemberChanged is used to trigger a check to see if there is any change in service endpoints.
@@ -372,6 +402,17 @@ func (c *tsoServiceDiscovery) AddServingAddrSwitchedCallback(callbacks ...func()
 func (c *tsoServiceDiscovery) AddServiceAddrsSwitchedCallback(callbacks ...func()) {
 }
 
+// GetAllMembers returns the members of the API service since the TSO service is not quorum-based.
+func (c *tsoServiceDiscovery) GetAllMembers(ctx context.Context) ([]*pdpb.Member, error) {
+	return c.apiSvcDiscovery.GetAllMembers(ctx)
+}
+
+// AddClusterIDChangedCallback adds callbacks which will be called when a different cluster ID
+// is observed. The cluster ID is discovered by the API service, so they are added to it.
+func (c *tsoServiceDiscovery) AddClusterIDChangedCallback(callbacks ...func(old, new uint64)) {
+	c.apiSvcDiscovery.AddClusterIDChangedCallback(callbacks...)
+}
+
 // SetTSOLocalServAddrsUpdatedCallback adds a callback which will be called when the local tso
 // allocator leader list is updated.
 func (c *tsoServiceDiscovery) SetTSOLocalServAddrsUpdatedCallback(callback tsoLocalServAddrsUpdatedFunc) {
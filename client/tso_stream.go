@@ -17,11 +17,13 @@ package pd
 import (
 	"context"
 	"io"
+	"math/rand"
 	"time"
 
 	"github.com/pingcap/errors"
 	"github.com/pingcap/kvproto/pkg/pdpb"
 	"github.com/pingcap/kvproto/pkg/tsopb"
+	"github.com/pingcap/log"
 	"github.com/tikv/pd/client/errs"
 	"google.golang.org/grpc"
 )
@@ -56,11 +58,9 @@ type pdTSOStreamBuilder struct {
 }
 
 func (b *pdTSOStreamBuilder) build(ctx context.Context, cancel context.CancelFunc, timeout time.Duration) (tsoStream, error) {
-	done := make(chan struct{})
-	// TODO: we need to handle a conner case that this goroutine is timeout while the stream is successfully created.
-	go checkStreamTimeout(ctx, cancel, done, timeout)
-	stream, err := b.client.Tso(ctx)
-	done <- struct{}{}
+	stream, err := createStreamWithTimeout(ctx, cancel, timeout, func(ctx context.Context) (pdpb.PD_TsoClient, error) {
+		return b.client.Tso(ctx)
+	})
 	if err == nil {
 		return &pdTSOStream{stream: stream, serverAddr: b.serverAddr}, nil
 	}
@@ -75,28 +75,65 @@ type tsoTSOStreamBuilder struct {
 func (b *tsoTSOStreamBuilder) build(
 	ctx context.Context, cancel context.CancelFunc, timeout time.Duration,
 ) (tsoStream, error) {
-	done := make(chan struct{})
-	// TODO: we need to handle a conner case that this goroutine is timeout while the stream is successfully created.
-	go checkStreamTimeout(ctx, cancel, done, timeout)
-	stream, err := b.client.Tso(ctx)
-	done <- struct{}{}
+	stream, err := createStreamWithTimeout(ctx, cancel, timeout, func(ctx context.Context) (tsopb.TSO_TsoClient, error) {
+		return b.client.Tso(ctx)
+	})
 	if err == nil {
 		return &tsoTSOStream{stream: stream, serverAddr: b.serverAddr}, nil
 	}
 	return nil, err
 }
 
-func checkStreamTimeout(ctx context.Context, cancel context.CancelFunc, done chan struct{}, timeout time.Duration) {
-	timer := time.NewTimer(timeout)
+// streamTimeoutJitterRatio is the max ratio of the jitter added to the stream creation timeout.
+const streamTimeoutJitterRatio = 0.1
+
+// jitterStreamTimeout returns a timeout randomly picked in [timeout*(1-ratio), timeout*(1+ratio)],
+// so that the streams created at the same time won't be canceled simultaneously and retry in a stampede.
+// The average timeout is still the given one.
+func jitterStreamTimeout(timeout time.Duration) time.Duration {
+	jitter := (rand.Float64()*2 - 1) * streamTimeoutJitterRatio
+	return time.Duration(float64(timeout) * (1 + jitter))
+}
+
+// createStreamWithTimeout creates a stream with create and cancels the stream context if the stream
+// is not created within the jittered timeout. The creation reports its result through a buffered
+// channel so it never blocks. If the stream is still created after the timeout fired, it's closed at
+// once and an error is returned rather than leaking it.
+func createStreamWithTimeout[S grpc.ClientStream](
+	ctx context.Context, cancel context.CancelFunc, timeout time.Duration,
+	create func(context.Context) (S, error),
+) (S, error) {
+	type result struct {
+		stream S
+		err    error
+	}
+	resultCh := make(chan result, 1)
+	go func() {
+		stream, err := create(ctx)
+		resultCh <- result{stream, err}
+	}()
+	timer := time.NewTimer(jitterStreamTimeout(timeout))
 	defer timer.Stop()
 	select {
-	case <-done:
-		return
+	case res := <-resultCh:
+		if res.err == nil {
+			tsoStreamCreateCounter.Inc()
+		}
+		return res.stream, res.err
 	case <-timer.C:
 		cancel()
 	case <-ctx.Done():
 	}
-	<-done
+	// The stream context is done, the creation will return soon.
+	res := <-resultCh
+	var nilStream S
+	if res.err != nil {
+		return nilStream, res.err
+	}
+	if err := res.stream.CloseSend(); err != nil {
+		log.Warn("[tso] failed to close the stream created after the timeout", errs.ZapError(err))
+	}
+	return nilStream, errs.ErrClientCreateTSOStream.FastGenByArgs("the stream is created after the stream context is done")
 }
 
 // TSO Stream
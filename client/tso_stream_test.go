@@ -0,0 +1,125 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pd
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/pd/client/errs"
+	"google.golang.org/grpc"
+)
+
+func TestJitterStreamTimeout(t *testing.T) {
+	re := require.New(t)
+	const timeout = time.Second
+	var sum time.Duration
+	for i := 0; i < 10000; i++ {
+		jittered := jitterStreamTimeout(timeout)
+		re.GreaterOrEqual(jittered, time.Duration(float64(timeout)*(1-streamTimeoutJitterRatio)))
+		re.LessOrEqual(jittered, time.Duration(float64(timeout)*(1+streamTimeoutJitterRatio)))
+		sum += jittered
+	}
+	// The average timeout is roughly the same as the given one.
+	re.InDelta(float64(timeout), float64(sum/10000), float64(timeout)*0.01)
+}
+
+func TestCheckStreamTimeoutSpread(t *testing.T) {
+	re := require.New(t)
+	const (
+		streamCount = 50
+		timeout     = 500 * time.Millisecond
+	)
+	var (
+		wg          sync.WaitGroup
+		mu          sync.Mutex
+		cancelTimes = make([]time.Duration, 0, streamCount)
+	)
+	start := time.Now()
+	for i := 0; i < streamCount; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			// Simulate a stream creation which is slower than the timeout.
+			_, _ = createStreamWithTimeout(ctx, cancel, timeout, func(ctx context.Context) (*mockClientStream, error) {
+				<-ctx.Done()
+				mu.Lock()
+				cancelTimes = append(cancelTimes, time.Since(start))
+				mu.Unlock()
+				return nil, ctx.Err()
+			})
+		}()
+	}
+	wg.Wait()
+	re.Len(cancelTimes, streamCount)
+	sort.Slice(cancelTimes, func(i, j int) bool { return cancelTimes[i] < cancelTimes[j] })
+	// The cancellations are spread over the jitter window rather than happening at once.
+	re.Greater(cancelTimes[streamCount-1]-cancelTimes[0], timeout/10)
+	re.GreaterOrEqual(cancelTimes[0], time.Duration(float64(timeout)*(1-streamTimeoutJitterRatio)))
+}
+
+type mockClientStream struct {
+	grpc.ClientStream
+	closed atomic.Bool
+}
+
+func (s *mockClientStream) CloseSend() error {
+	s.closed.Store(true)
+	return nil
+}
+
+func TestCreateStreamWithTimeout(t *testing.T) {
+	re := require.New(t)
+	const timeout = 50 * time.Millisecond
+
+	// The stream created in time is returned.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream := &mockClientStream{}
+	createdCount := promtestutil.ToFloat64(tsoStreamCreateCounter)
+	created, err := createStreamWithTimeout(ctx, cancel, timeout, func(context.Context) (*mockClientStream, error) {
+		return stream, nil
+	})
+	re.NoError(err)
+	re.Same(stream, created)
+	re.False(stream.closed.Load())
+	re.NoError(ctx.Err())
+	re.Equal(createdCount+1, promtestutil.ToFloat64(tsoStreamCreateCounter))
+
+	// The stream created after the timeout fired is closed rather than leaked.
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	stream = &mockClientStream{}
+	created, err = createStreamWithTimeout(ctx, cancel, timeout, func(ctx context.Context) (*mockClientStream, error) {
+		// Simulate a slow dial which doesn't notice the cancellation in time.
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond)
+		return stream, nil
+	})
+	re.True(errs.ErrClientCreateTSOStream.Equal(err))
+	re.Nil(created)
+	re.True(stream.closed.Load())
+	re.ErrorIs(ctx.Err(), context.Canceled)
+	// The discarded stream is not counted.
+	re.Equal(createdCount+1, promtestutil.ToFloat64(tsoStreamCreateCounter))
+}
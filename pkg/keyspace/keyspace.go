@@ -53,6 +53,9 @@ const (
 	// We use 120 here to leave some space for other operations.
 	// See: https://github.com/etcd-io/etcd/blob/d3e43d4de6f6d9575b489dd7850a85e37e0f6b6c/server/embed/config.go#L61
 	maxEtcdTxnOps = 120
+	// MaxMergeListSize is the max number of the keyspace groups merged at a time, since the merge loads and
+	// deletes every merged keyspace group and loads and saves the target one in a single etcd txn.
+	MaxMergeListSize = maxEtcdTxnOps/2 - 1
 )
 
 // Config is the interface for keyspace config.
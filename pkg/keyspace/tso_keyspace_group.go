@@ -17,6 +17,7 @@ package keyspace
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"sort"
 	"strconv"
 	"strings"
@@ -25,6 +26,7 @@ import (
 
 	"github.com/pingcap/errors"
 	"github.com/pingcap/failpoint"
+	"github.com/pingcap/kvproto/pkg/tsopb"
 	"github.com/pingcap/log"
 	"github.com/tikv/pd/pkg/balancer"
 	"github.com/tikv/pd/pkg/mcs/discovery"
@@ -58,6 +60,8 @@ type GroupManager struct {
 	wg        sync.WaitGroup
 	client    *clientv3.Client
 	clusterID uint64
+	// rootPath is the root path of the etcd keys of the PD cluster, i.e., "/pd/{cluster_id}".
+	rootPath string
 
 	sync.RWMutex
 	// groups is the cache of keyspace group related information.
@@ -83,6 +87,7 @@ func NewKeyspaceGroupManager(
 	store endpoint.KeyspaceGroupStorage,
 	client *clientv3.Client,
 	clusterID uint64,
+	rootPath string,
 ) *GroupManager {
 	ctx, cancel := context.WithCancel(ctx)
 	groups := make(map[endpoint.UserKind]*indexedHeap)
@@ -96,6 +101,7 @@ func NewKeyspaceGroupManager(
 		groups:             groups,
 		client:             client,
 		clusterID:          clusterID,
+		rootPath:           rootPath,
 		nodesBalancer:      balancer.GenByPolicy[string](defaultBalancerPolicy),
 		serviceRegistryMap: make(map[string]string),
 	}
@@ -516,6 +522,23 @@ func (m *GroupManager) SplitKeyspaceGroupByID(
 	splitSourceID, splitTargetID uint32,
 	keyspaces []uint32, keyspaceIDRange ...uint32,
 ) error {
+	_, err := m.splitKeyspaceGroupByID(splitSourceID, splitTargetID, false, keyspaces, keyspaceIDRange...)
+	return err
+}
+
+// PreviewSplitKeyspaceGroupByID returns the split source and target keyspace groups as if the split
+// were applied, together with the number of etcd txn operations the split needs, without saving anything.
+func (m *GroupManager) PreviewSplitKeyspaceGroupByID(
+	splitSourceID, splitTargetID uint32,
+	keyspaces []uint32, keyspaceIDRange ...uint32,
+) (*KeyspaceGroupOperationPreview, error) {
+	return m.splitKeyspaceGroupByID(splitSourceID, splitTargetID, true, keyspaces, keyspaceIDRange...)
+}
+
+func (m *GroupManager) splitKeyspaceGroupByID(
+	splitSourceID, splitTargetID uint32, dryRun bool,
+	keyspaces []uint32, keyspaceIDRange ...uint32,
+) (*KeyspaceGroupOperationPreview, error) {
 	var splitSourceKg, splitTargetKg *endpoint.KeyspaceGroup
 	m.Lock()
 	defer m.Unlock()
@@ -559,12 +582,11 @@ func (m *GroupManager) SplitKeyspaceGroupByID(
 			return ErrKeyspaceGroupExists
 		}
 		// Update the old keyspace group.
+		startTime := time.Now().Unix()
 		splitSourceKg.Keyspaces = splitSourceKeyspaces
 		splitSourceKg.SplitState = &endpoint.SplitState{
 			SplitSource: splitSourceKg.ID,
-		}
-		if err = m.store.SaveKeyspaceGroup(txn, splitSourceKg); err != nil {
-			return err
+			StartTime:   startTime,
 		}
 		splitTargetKg = &endpoint.KeyspaceGroup{
 			ID: splitTargetID,
@@ -574,17 +596,33 @@ func (m *GroupManager) SplitKeyspaceGroupByID(
 			Keyspaces: splitTargetKeyspaces,
 			SplitState: &endpoint.SplitState{
 				SplitSource: splitSourceKg.ID,
+				StartTime:   startTime,
 			},
 		}
+		if dryRun {
+			return nil
+		}
+		if err = m.store.SaveKeyspaceGroup(txn, splitSourceKg); err != nil {
+			return err
+		}
 		// Create the new split keyspace group.
 		return m.store.SaveKeyspaceGroup(txn, splitTargetKg)
 	}); err != nil {
-		return err
+		return nil, err
+	}
+	preview := &KeyspaceGroupOperationPreview{
+		KeyspaceGroups: []*endpoint.KeyspaceGroup{splitSourceKg, splitTargetKg},
+		// The split loads and saves both the split source and target keyspace groups.
+		EtcdTxnOps:    4,
+		MaxEtcdTxnOps: maxEtcdTxnOps,
+	}
+	if dryRun {
+		return preview, nil
 	}
 	// Update the keyspace group cache.
 	m.groups[endpoint.StringUserKind(splitSourceKg.UserKind)].Put(splitSourceKg)
 	m.groups[endpoint.StringUserKind(splitTargetKg.UserKind)].Put(splitTargetKg)
-	return nil
+	return preview, nil
 }
 
 func buildSplitKeyspaces(
@@ -709,6 +747,259 @@ func (m *GroupManager) FinishSplitKeyspaceByID(splitTargetID uint32) error {
 	return nil
 }
 
+// RollbackSplitKeyspaceGroupByID aborts the in-progress split of the given split source or split target
+// keyspace group. The split-out keyspaces are moved back to the split source, its split state is cleared
+// and the split target is deleted in one transaction. It returns the restored split source keyspace group.
+func (m *GroupManager) RollbackSplitKeyspaceGroupByID(id uint32) (*endpoint.KeyspaceGroup, error) {
+	m.Lock()
+	defer m.Unlock()
+	// All the keyspace group modifications are protected by the lock,
+	// so it's safe to load all groups to find the split pair here.
+	groups, err := m.store.LoadKeyspaceGroups(utils.DefaultKeyspaceGroupID, 0)
+	if err != nil {
+		return nil, err
+	}
+	var kg, splitSourceKg, splitTargetKg *endpoint.KeyspaceGroup
+	for _, g := range groups {
+		if g.ID == id {
+			kg = g
+			break
+		}
+	}
+	if kg == nil {
+		return nil, ErrKeyspaceGroupNotExists(id)
+	}
+	// The split has been finished or never started.
+	if !kg.IsSplitting() {
+		return nil, ErrKeyspaceGroupNotInSplit(id)
+	}
+	splitSourceID := kg.SplitSource()
+	for _, g := range groups {
+		switch {
+		case g.ID == splitSourceID:
+			splitSourceKg = g
+		case g.IsSplitTarget() && g.SplitSource() == splitSourceID:
+			splitTargetKg = g
+		}
+	}
+	if splitSourceKg == nil {
+		return nil, ErrKeyspaceGroupNotExists(splitSourceID)
+	}
+	// The split source has already finished the split.
+	if !splitSourceKg.IsSplitSource() {
+		return nil, ErrKeyspaceGroupNotInSplit(splitSourceID)
+	}
+	if splitTargetKg == nil {
+		return nil, ErrKeyspaceGroupInconsistent(splitSourceID, "the split target keyspace group does not exist")
+	}
+
+	splitSourceKg.Keyspaces = append(splitSourceKg.Keyspaces, splitTargetKg.Keyspaces...)
+	sort.Slice(splitSourceKg.Keyspaces, func(i, j int) bool {
+		return splitSourceKg.Keyspaces[i] < splitSourceKg.Keyspaces[j]
+	})
+	splitSourceKg.SplitState = nil
+	if err := m.store.RunInTxn(m.ctx, func(txn kv.Txn) error {
+		if err := m.store.SaveKeyspaceGroup(txn, splitSourceKg); err != nil {
+			return err
+		}
+		return m.store.DeleteKeyspaceGroup(txn, splitTargetKg.ID)
+	}); err != nil {
+		return nil, err
+	}
+	// Update the keyspace group cache.
+	m.groups[endpoint.StringUserKind(splitSourceKg.UserKind)].Put(splitSourceKg)
+	m.groups[endpoint.StringUserKind(splitTargetKg.UserKind)].Remove(splitTargetKg.ID)
+	log.Warn("rollback split keyspace group",
+		zap.Uint32("split-source-id", splitSourceKg.ID),
+		zap.Uint32("split-target-id", splitTargetKg.ID),
+		zap.Int("restored-keyspace-count", len(splitTargetKg.Keyspaces)))
+	return splitSourceKg, nil
+}
+
+// KeyspaceGroupPrimary is a keyspace group with its TSO primary.
+type KeyspaceGroupPrimary struct {
+	ID uint32 `json:"id"`
+	// Primary is the listen URL of the TSO primary of the keyspace group,
+	// which is empty if the primary is not elected yet or has been gone.
+	Primary string `json:"primary,omitempty"`
+}
+
+// KeyspaceGroupProgress is the progress of the split or merge of a keyspace group.
+type KeyspaceGroupProgress struct {
+	// SplitSource and SplitTarget are the keyspace groups involved in the split.
+	SplitSource *KeyspaceGroupPrimary `json:"split-source,omitempty"`
+	SplitTarget *KeyspaceGroupPrimary `json:"split-target,omitempty"`
+	// MergeTarget and MergeList are the keyspace groups involved in the merge.
+	MergeTarget *KeyspaceGroupPrimary `json:"merge-target,omitempty"`
+	MergeList   []uint32              `json:"merge-list,omitempty"`
+	// PendingMergeList is the merge sources whose TSO primaries are not gone yet,
+	// the merge could be finished only after all of them are gone.
+	PendingMergeList []*KeyspaceGroupPrimary `json:"pending-merge-list,omitempty"`
+}
+
+// GetKeyspaceGroupProgress returns the progress of the split or merge of the given keyspace group,
+// i.e., the keyspace groups involved in the split or merge with their TSO primaries.
+func (m *GroupManager) GetKeyspaceGroupProgress(id uint32) (*KeyspaceGroupProgress, error) {
+	if m.client == nil {
+		return nil, ErrEtcdClientUnavailable
+	}
+	kg, err := m.GetKeyspaceGroupByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if kg == nil {
+		return nil, ErrKeyspaceGroupNotExists(id)
+	}
+	switch {
+	case kg.IsSplitting():
+		sourceID, targetID := kg.SplitSource(), id
+		if kg.IsSplitSource() {
+			// Find the split target of this split source.
+			groups, err := m.store.LoadKeyspaceGroups(utils.DefaultKeyspaceGroupID, 0)
+			if err != nil {
+				return nil, err
+			}
+			targetID = 0
+			for _, g := range groups {
+				if g.IsSplitTarget() && g.SplitSource() == id {
+					targetID = g.ID
+					break
+				}
+			}
+			if targetID == 0 {
+				return nil, ErrKeyspaceGroupInconsistent(id, "the split target does not exist")
+			}
+		}
+		progress := &KeyspaceGroupProgress{}
+		if progress.SplitSource, err = m.getKeyspaceGroupPrimary(sourceID); err != nil {
+			return nil, err
+		}
+		if progress.SplitTarget, err = m.getKeyspaceGroupPrimary(targetID); err != nil {
+			return nil, err
+		}
+		return progress, nil
+	case kg.IsMergeTarget():
+		progress := &KeyspaceGroupProgress{MergeList: kg.MergeState.MergeList}
+		if progress.MergeTarget, err = m.getKeyspaceGroupPrimary(id); err != nil {
+			return nil, err
+		}
+		for _, mergeID := range kg.MergeState.MergeList {
+			primary, err := m.getKeyspaceGroupPrimary(mergeID)
+			if err != nil {
+				return nil, err
+			}
+			if len(primary.Primary) != 0 {
+				progress.PendingMergeList = append(progress.PendingMergeList, primary)
+			}
+		}
+		return progress, nil
+	default:
+		return nil, ErrKeyspaceGroupNotInTransition(id)
+	}
+}
+
+// getKeyspaceGroupPrimary returns the given keyspace group with its TSO primary loaded from etcd.
+func (m *GroupManager) getKeyspaceGroupPrimary(id uint32) (*KeyspaceGroupPrimary, error) {
+	primaryPath := utils.KeyspaceGroupPrimaryPath(utils.TSOSvcRootPath(m.clusterID), id)
+	primary := &tsopb.Participant{}
+	ok, _, err := etcdutil.GetProtoMsgWithModRev(m.client, primaryPath, primary)
+	if err != nil {
+		return nil, err
+	}
+	kgp := &KeyspaceGroupPrimary{ID: id}
+	if ok {
+		kgp.Primary = primary.GetName()
+		if len(primary.GetListenUrls()) > 0 {
+			kgp.Primary = primary.GetListenUrls()[0]
+		}
+	}
+	return kgp, nil
+}
+
+// ResetKeyspaceGroupState forcibly clears the split or merge state of the given keyspace group,
+// which is used to recover a keyspace group stuck in the split/merge state, e.g., the split/merge
+// is not finished due to a crash. If the keyspace group is a split target, the state of its split
+// source will be cleared together, and vice versa. The keyspaces of the reset keyspace groups are
+// validated to not belong to any other keyspace group before resetting. If dryRun is true, the
+// keyspace groups are not saved and only the preview of the reset ones is returned.
+func (m *GroupManager) ResetKeyspaceGroupState(id uint32, dryRun bool) ([]*endpoint.KeyspaceGroup, error) {
+	m.Lock()
+	defer m.Unlock()
+	// All the keyspace group modifications are protected by the lock,
+	// so it's safe to load all groups to validate the keyspaces here.
+	groups, err := m.store.LoadKeyspaceGroups(utils.DefaultKeyspaceGroupID, 0)
+	if err != nil {
+		return nil, err
+	}
+	groupsByID := make(map[uint32]*endpoint.KeyspaceGroup, len(groups))
+	for _, g := range groups {
+		groupsByID[g.ID] = g
+	}
+	kg := groupsByID[id]
+	if kg == nil {
+		return nil, ErrKeyspaceGroupNotExists(id)
+	}
+	if !kg.IsSplitting() && !kg.IsMerging() {
+		return nil, ErrKeyspaceGroupNotInTransition(id)
+	}
+	resetGroups := []*endpoint.KeyspaceGroup{kg}
+	if kg.IsSplitTarget() {
+		if splitSourceKg := groupsByID[kg.SplitSource()]; splitSourceKg.IsSplitSource() {
+			resetGroups = append(resetGroups, splitSourceKg)
+		}
+	}
+	if kg.IsSplitSource() {
+		for _, g := range groups {
+			if g.IsSplitTarget() && g.SplitSource() == id {
+				resetGroups = append(resetGroups, g)
+			}
+		}
+	}
+	if kg.IsMergeTarget() {
+		for _, mergeID := range kg.MergeState.MergeList {
+			if _, ok := groupsByID[mergeID]; ok {
+				return nil, ErrKeyspaceGroupInconsistent(id,
+					fmt.Sprintf("the merging keyspace group %d still exists", mergeID))
+			}
+		}
+	}
+	// Make sure every keyspace only belongs to one keyspace group.
+	owners := make(map[uint32]uint32)
+	for _, g := range groups {
+		for _, keyspace := range g.Keyspaces {
+			if owner, ok := owners[keyspace]; ok {
+				return nil, ErrKeyspaceGroupInconsistent(g.ID,
+					fmt.Sprintf("keyspace %d also belongs to keyspace group %d", keyspace, owner))
+			}
+			owners[keyspace] = g.ID
+		}
+	}
+
+	for _, g := range resetGroups {
+		g.SplitState = nil
+		g.MergeState = nil
+	}
+	if dryRun {
+		return resetGroups, nil
+	}
+	if err := m.store.RunInTxn(m.ctx, func(txn kv.Txn) error {
+		for _, g := range resetGroups {
+			if err := m.store.SaveKeyspaceGroup(txn, g); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	// Update the keyspace group cache.
+	for _, g := range resetGroups {
+		m.groups[endpoint.StringUserKind(g.UserKind)].Put(g)
+	}
+	log.Warn("reset keyspace group state", zap.Uint32("keyspace-group-id", id), zap.Int("reset-count", len(resetGroups)))
+	return resetGroups, nil
+}
+
 // GetNodesCount returns the count of nodes.
 func (m *GroupManager) GetNodesCount() int {
 	if m.nodesBalancer == nil {
@@ -787,6 +1078,129 @@ func (m *GroupManager) AllocNodesForKeyspaceGroup(id uint32, desiredReplicaCount
 	return nodes, nil
 }
 
+// KeyspaceGroupOperationPreview records the projected result of a split or merge in the dry-run mode.
+type KeyspaceGroupOperationPreview struct {
+	// KeyspaceGroups are the created or updated keyspace groups after the operation.
+	KeyspaceGroups []*endpoint.KeyspaceGroup `json:"keyspace-groups"`
+	// DeletedKeyspaceGroups are the IDs of the keyspace groups deleted by the operation.
+	DeletedKeyspaceGroups []uint32 `json:"deleted-keyspace-groups,omitempty"`
+	// EtcdTxnOps is the number of the etcd txn operations the operation needs,
+	// including the comparisons of the loaded keyspace groups.
+	EtcdTxnOps int `json:"etcd-txn-ops"`
+	// MaxEtcdTxnOps is the limit of the etcd txn operations of one operation.
+	MaxEtcdTxnOps int `json:"max-etcd-txn-ops"`
+}
+
+// KeyspaceGroupReallocation records the nodes reallocation of an under-replicated keyspace group.
+type KeyspaceGroupReallocation struct {
+	ID uint32 `json:"id"`
+	// Members are the members of the keyspace group before the reallocation.
+	Members []endpoint.KeyspaceGroupMember `json:"members"`
+	// AddedMembers are the newly allocated members, which is empty in the dry-run mode.
+	AddedMembers []endpoint.KeyspaceGroupMember `json:"added-members,omitempty"`
+}
+
+// ReallocateKeyspaceGroups allocates the available nodes for all the keyspace groups whose member
+// count is below the default replica count, e.g., after adding new TSO nodes. The keyspace groups
+// in the split/merge state are skipped. If dryRun is true, nothing is allocated and only the
+// under-replicated keyspace groups are returned.
+func (m *GroupManager) ReallocateKeyspaceGroups(dryRun bool) ([]*KeyspaceGroupReallocation, error) {
+	if m.GetNodesCount() < utils.DefaultKeyspaceGroupReplicaCount {
+		return nil, ErrNoAvailableNode
+	}
+	groups, err := m.store.LoadKeyspaceGroups(utils.DefaultKeyspaceGroupID, 0)
+	if err != nil {
+		return nil, err
+	}
+	reallocations := make([]*KeyspaceGroupReallocation, 0)
+	for _, group := range groups {
+		if len(group.Members) >= utils.DefaultKeyspaceGroupReplicaCount || group.IsSplitting() || group.IsMerging() {
+			continue
+		}
+		reallocation := &KeyspaceGroupReallocation{ID: group.ID, Members: group.Members}
+		reallocations = append(reallocations, reallocation)
+		if dryRun {
+			continue
+		}
+		nodes, err := m.AllocNodesForKeyspaceGroup(group.ID, utils.DefaultKeyspaceGroupReplicaCount)
+		if err != nil {
+			return reallocations, err
+		}
+		exists := make(map[string]struct{}, len(group.Members))
+		for _, member := range group.Members {
+			exists[member.Address] = struct{}{}
+		}
+		for _, node := range nodes {
+			if _, ok := exists[node.Address]; !ok {
+				reallocation.AddedMembers = append(reallocation.AddedMembers, node)
+			}
+		}
+	}
+	return reallocations, nil
+}
+
+// KeyspaceGroupEvent is a change event of the keyspace group.
+type KeyspaceGroupEvent struct {
+	// Type is the type of the change, which is either "put" or "delete".
+	Type string `json:"type"`
+	ID   uint32 `json:"id"`
+	// Revision is the etcd revision of the change.
+	Revision int64 `json:"revision"`
+	// KeyspaceGroup is the keyspace group after the change, which is nil for the delete event.
+	KeyspaceGroup *endpoint.KeyspaceGroup `json:"keyspace-group,omitempty"`
+}
+
+// WatchKeyspaceGroups watches the changes of all the keyspace groups from now on. The events are
+// sent to the returned channel, which is closed once the context is canceled or the watch fails.
+func (m *GroupManager) WatchKeyspaceGroups(ctx context.Context) (<-chan *KeyspaceGroupEvent, error) {
+	if m.client == nil {
+		return nil, errors.New("etcd client is not initialized")
+	}
+	prefix := endpoint.AppendToRootPath(m.rootPath, endpoint.KeyspaceGroupIDPrefix()) + "/"
+	watchChan := m.client.Watch(clientv3.WithRequireLeader(ctx), prefix, clientv3.WithPrefix())
+	idRegexp := endpoint.GetCompiledKeyspaceGroupIDRegexp()
+	events := make(chan *KeyspaceGroupEvent)
+	go func() {
+		defer logutil.LogPanic()
+		defer close(events)
+		for resp := range watchChan {
+			if err := resp.Err(); err != nil {
+				log.Warn("failed to watch the keyspace groups", zap.String("prefix", prefix), zap.Error(err))
+				return
+			}
+			for _, ev := range resp.Events {
+				matches := idRegexp.FindStringSubmatch(string(ev.Kv.Key))
+				if len(matches) < 2 {
+					continue
+				}
+				id, err := strconv.ParseUint(matches[1], 10, 32)
+				if err != nil {
+					continue
+				}
+				event := &KeyspaceGroupEvent{ID: uint32(id), Revision: ev.Kv.ModRevision}
+				switch ev.Type {
+				case clientv3.EventTypePut:
+					kg := &endpoint.KeyspaceGroup{}
+					if err := json.Unmarshal(ev.Kv.Value, kg); err != nil {
+						log.Warn("failed to unmarshal the keyspace group",
+							zap.String("event-kv-key", string(ev.Kv.Key)), zap.Error(err))
+						continue
+					}
+					event.Type, event.KeyspaceGroup = "put", kg
+				case clientv3.EventTypeDelete:
+					event.Type = "delete"
+				}
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return events, nil
+}
+
 // SetNodesForKeyspaceGroup sets the nodes for the keyspace group.
 func (m *GroupManager) SetNodesForKeyspaceGroup(id uint32, nodes []string) error {
 	m.Lock()
@@ -879,19 +1293,34 @@ func (m *GroupManager) IsExistNode(addr string) bool {
 
 // MergeKeyspaceGroups merges the keyspace group in the list into the target keyspace group.
 func (m *GroupManager) MergeKeyspaceGroups(mergeTargetID uint32, mergeList []uint32) error {
-	mergeListNum := len(mergeList)
-	if mergeListNum == 0 {
+	if len(mergeList) == 0 {
 		return nil
 	}
+	_, err := m.mergeKeyspaceGroups(mergeTargetID, mergeList, false)
+	return err
+}
+
+// PreviewMergeKeyspaceGroups returns the merge target keyspace group as if the merge were applied,
+// together with the number of etcd txn operations the merge needs, without saving anything.
+// Unlike MergeKeyspaceGroups, it does not fail if the merge exceeds the etcd txn operation limit.
+func (m *GroupManager) PreviewMergeKeyspaceGroups(mergeTargetID uint32, mergeList []uint32) (*KeyspaceGroupOperationPreview, error) {
+	return m.mergeKeyspaceGroups(mergeTargetID, mergeList, true)
+}
+
+func (m *GroupManager) mergeKeyspaceGroups(
+	mergeTargetID uint32, mergeList []uint32, dryRun bool,
+) (*KeyspaceGroupOperationPreview, error) {
+	mergeListNum := len(mergeList)
 	// The transaction below will:
 	//   - Load and delete the keyspace groups in the merge list.
 	//   - Load and update the target keyspace group.
 	// So we pre-check the number of operations to avoid exceeding the maximum number of etcd transaction.
-	if (mergeListNum+1)*2 > maxEtcdTxnOps {
-		return ErrExceedMaxEtcdTxnOps
+	etcdTxnOps := (mergeListNum + 1) * 2
+	if !dryRun && etcdTxnOps > maxEtcdTxnOps {
+		return nil, ErrExceedMaxEtcdTxnOps
 	}
 	if slice.Contains(mergeList, utils.DefaultKeyspaceGroupID) {
-		return ErrModifyDefaultKeyspaceGroup
+		return nil, ErrModifyDefaultKeyspaceGroup
 	}
 	var (
 		groups        = make(map[uint32]*endpoint.KeyspaceGroup, mergeListNum+1)
@@ -942,6 +1371,10 @@ func (m *GroupManager) MergeKeyspaceGroups(mergeTargetID uint32, mergeList []uin
 		// Update the merge state of the target keyspace group.
 		mergeTargetKg.MergeState = &endpoint.MergeState{
 			MergeList: mergeList,
+			StartTime: time.Now().Unix(),
+		}
+		if dryRun {
+			return nil
 		}
 		err = m.store.SaveKeyspaceGroup(txn, mergeTargetKg)
 		if err != nil {
@@ -955,7 +1388,16 @@ func (m *GroupManager) MergeKeyspaceGroups(mergeTargetID uint32, mergeList []uin
 		}
 		return nil
 	}); err != nil {
-		return err
+		return nil, err
+	}
+	preview := &KeyspaceGroupOperationPreview{
+		KeyspaceGroups:        []*endpoint.KeyspaceGroup{mergeTargetKg},
+		DeletedKeyspaceGroups: mergeList,
+		EtcdTxnOps:            etcdTxnOps,
+		MaxEtcdTxnOps:         maxEtcdTxnOps,
+	}
+	if dryRun {
+		return preview, nil
 	}
 	// Update the keyspace group cache.
 	m.groups[endpoint.StringUserKind(mergeTargetKg.UserKind)].Put(mergeTargetKg)
@@ -963,7 +1405,7 @@ func (m *GroupManager) MergeKeyspaceGroups(mergeTargetID uint32, mergeList []uin
 		kg := groups[kgID]
 		m.groups[endpoint.StringUserKind(kg.UserKind)].Remove(kgID)
 	}
-	return nil
+	return preview, nil
 }
 
 // FinishMergeKeyspaceByID finishes the merging keyspace group by the merge target ID.
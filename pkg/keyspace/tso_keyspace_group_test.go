@@ -45,7 +45,7 @@ func TestKeyspaceGroupTestSuite(t *testing.T) {
 func (suite *keyspaceGroupTestSuite) SetupTest() {
 	suite.ctx, suite.cancel = context.WithCancel(context.Background())
 	store := endpoint.NewStorageEndpoint(kv.NewMemoryKV(), nil)
-	suite.kgm = NewKeyspaceGroupManager(suite.ctx, store, nil, 0)
+	suite.kgm = NewKeyspaceGroupManager(suite.ctx, store, nil, 0, "")
 	idAllocator := mockid.NewIDAllocator()
 	cluster := mockcluster.NewCluster(suite.ctx, mockconfig.NewTestOptions())
 	suite.kg = NewKeyspaceManager(suite.ctx, store, cluster, idAllocator, &mockConfig{}, suite.kgm)
@@ -327,6 +327,125 @@ func (suite *keyspaceGroupTestSuite) TestKeyspaceGroupSplit() {
 	re.ErrorIs(err, ErrKeyspaceNotInKeyspaceGroup)
 }
 
+func (suite *keyspaceGroupTestSuite) TestKeyspaceGroupRollbackSplit() {
+	re := suite.Require()
+
+	keyspaceGroups := []*endpoint.KeyspaceGroup{
+		{
+			ID:        uint32(1),
+			UserKind:  endpoint.Standard.String(),
+			Keyspaces: []uint32{111, 222, 333, 444},
+			Members:   make([]endpoint.KeyspaceGroupMember, utils.DefaultKeyspaceGroupReplicaCount),
+		},
+	}
+	err := suite.kgm.CreateKeyspaceGroups(keyspaceGroups)
+	re.NoError(err)
+	// rollback a non-existing keyspace group
+	_, err = suite.kgm.RollbackSplitKeyspaceGroupByID(2)
+	re.ErrorContains(err, ErrKeyspaceGroupNotExists(2).Error())
+	// rollback a keyspace group which is not in split
+	_, err = suite.kgm.RollbackSplitKeyspaceGroupByID(1)
+	re.ErrorContains(err, ErrKeyspaceGroupNotInSplit(1).Error())
+
+	// rollback the split by the split target
+	err = suite.kgm.SplitKeyspaceGroupByID(1, 2, []uint32{222, 444})
+	re.NoError(err)
+	kg1, err := suite.kgm.RollbackSplitKeyspaceGroupByID(2)
+	re.NoError(err)
+	re.Equal([]uint32{111, 222, 333, 444}, kg1.Keyspaces)
+	re.False(kg1.IsSplitting())
+	kg1, err = suite.kgm.GetKeyspaceGroupByID(1)
+	re.NoError(err)
+	re.Equal([]uint32{111, 222, 333, 444}, kg1.Keyspaces)
+	re.False(kg1.IsSplitting())
+	kg2, err := suite.kgm.GetKeyspaceGroupByID(2)
+	re.NoError(err)
+	re.Nil(kg2)
+
+	// rollback the split by the split source
+	err = suite.kgm.SplitKeyspaceGroupByID(1, 3, []uint32{333})
+	re.NoError(err)
+	kg1, err = suite.kgm.RollbackSplitKeyspaceGroupByID(1)
+	re.NoError(err)
+	re.Equal([]uint32{111, 222, 333, 444}, kg1.Keyspaces)
+	re.False(kg1.IsSplitting())
+	kg3, err := suite.kgm.GetKeyspaceGroupByID(3)
+	re.NoError(err)
+	re.Nil(kg3)
+
+	// rollback the finished split
+	err = suite.kgm.SplitKeyspaceGroupByID(1, 3, []uint32{333})
+	re.NoError(err)
+	err = suite.kgm.FinishSplitKeyspaceByID(3)
+	re.NoError(err)
+	_, err = suite.kgm.RollbackSplitKeyspaceGroupByID(3)
+	re.ErrorContains(err, ErrKeyspaceGroupNotInSplit(3).Error())
+	_, err = suite.kgm.RollbackSplitKeyspaceGroupByID(1)
+	re.ErrorContains(err, ErrKeyspaceGroupNotInSplit(1).Error())
+	kg3, err = suite.kgm.GetKeyspaceGroupByID(3)
+	re.NoError(err)
+	re.Equal([]uint32{333}, kg3.Keyspaces)
+}
+
+func (suite *keyspaceGroupTestSuite) TestKeyspaceGroupResetState() {
+	re := suite.Require()
+
+	keyspaceGroups := []*endpoint.KeyspaceGroup{
+		{
+			ID:        uint32(1),
+			UserKind:  endpoint.Standard.String(),
+			Keyspaces: []uint32{111, 222, 333, 444},
+			Members:   make([]endpoint.KeyspaceGroupMember, utils.DefaultKeyspaceGroupReplicaCount),
+		},
+	}
+	err := suite.kgm.CreateKeyspaceGroups(keyspaceGroups)
+	re.NoError(err)
+	// reset a non-existing keyspace group
+	_, err = suite.kgm.ResetKeyspaceGroupState(2, false)
+	re.ErrorContains(err, ErrKeyspaceGroupNotExists(2).Error())
+	// reset a keyspace group which is not in split/merge
+	_, err = suite.kgm.ResetKeyspaceGroupState(1, false)
+	re.ErrorContains(err, ErrKeyspaceGroupNotInTransition(1).Error())
+
+	// reset the state by the split target
+	err = suite.kgm.SplitKeyspaceGroupByID(1, 2, []uint32{222})
+	re.NoError(err)
+	kgs, err := suite.kgm.ResetKeyspaceGroupState(2, false)
+	re.NoError(err)
+	re.Len(kgs, 2)
+	re.Equal(uint32(2), kgs[0].ID)
+	re.Equal(uint32(1), kgs[1].ID)
+	for _, id := range []uint32{1, 2} {
+		kg, err := suite.kgm.GetKeyspaceGroupByID(id)
+		re.NoError(err)
+		re.False(kg.IsSplitting())
+	}
+
+	// reset the state by the split source
+	err = suite.kgm.SplitKeyspaceGroupByID(1, 3, []uint32{333})
+	re.NoError(err)
+	// preview the reset without saving it
+	kgs, err = suite.kgm.ResetKeyspaceGroupState(1, true)
+	re.NoError(err)
+	re.Len(kgs, 2)
+	kg3, err := suite.kgm.GetKeyspaceGroupByID(3)
+	re.NoError(err)
+	re.True(kg3.IsSplitTarget())
+	kgs, err = suite.kgm.ResetKeyspaceGroupState(1, false)
+	re.NoError(err)
+	re.Len(kgs, 2)
+	re.Equal(uint32(1), kgs[0].ID)
+	re.Equal(uint32(3), kgs[1].ID)
+	kg1, err := suite.kgm.GetKeyspaceGroupByID(1)
+	re.NoError(err)
+	re.False(kg1.IsSplitting())
+	re.Equal([]uint32{111, 444}, kg1.Keyspaces)
+	kg3, err = suite.kgm.GetKeyspaceGroupByID(3)
+	re.NoError(err)
+	re.False(kg3.IsSplitting())
+	re.Equal([]uint32{333}, kg3.Keyspaces)
+}
+
 func (suite *keyspaceGroupTestSuite) TestKeyspaceGroupSplitRange() {
 	re := suite.Require()
 
@@ -455,6 +574,77 @@ func (suite *keyspaceGroupTestSuite) TestKeyspaceGroupMerge() {
 	re.ErrorIs(err, ErrModifyDefaultKeyspaceGroup)
 }
 
+func (suite *keyspaceGroupTestSuite) TestKeyspaceGroupPreviewSplitAndMerge() {
+	re := suite.Require()
+
+	keyspaceGroups := []*endpoint.KeyspaceGroup{
+		{
+			ID:        uint32(1),
+			UserKind:  endpoint.Basic.String(),
+			Keyspaces: []uint32{111, 222, 333},
+			Members:   make([]endpoint.KeyspaceGroupMember, utils.DefaultKeyspaceGroupReplicaCount),
+		},
+		{
+			ID:        uint32(3),
+			UserKind:  endpoint.Basic.String(),
+			Keyspaces: []uint32{444, 555},
+		},
+	}
+	err := suite.kgm.CreateKeyspaceGroups(keyspaceGroups)
+	re.NoError(err)
+	// preview the split of the keyspace group 1 to 2
+	preview, err := suite.kgm.PreviewSplitKeyspaceGroupByID(1, 2, []uint32{333})
+	re.NoError(err)
+	re.Len(preview.KeyspaceGroups, 2)
+	re.Equal(uint32(1), preview.KeyspaceGroups[0].ID)
+	re.Equal([]uint32{111, 222}, preview.KeyspaceGroups[0].Keyspaces)
+	re.True(preview.KeyspaceGroups[0].IsSplitSource())
+	re.Equal(uint32(2), preview.KeyspaceGroups[1].ID)
+	re.Equal([]uint32{333}, preview.KeyspaceGroups[1].Keyspaces)
+	re.True(preview.KeyspaceGroups[1].IsSplitTarget())
+	re.Equal(preview.KeyspaceGroups[0].Members, preview.KeyspaceGroups[1].Members)
+	re.Equal(4, preview.EtcdTxnOps)
+	re.Equal(maxEtcdTxnOps, preview.MaxEtcdTxnOps)
+	// nothing is saved
+	kg1, err := suite.kgm.GetKeyspaceGroupByID(1)
+	re.NoError(err)
+	re.Equal([]uint32{111, 222, 333}, kg1.Keyspaces)
+	re.False(kg1.IsSplitting())
+	kg2, err := suite.kgm.GetKeyspaceGroupByID(2)
+	re.NoError(err)
+	re.Nil(kg2)
+	// preview the invalid split
+	_, err = suite.kgm.PreviewSplitKeyspaceGroupByID(3, 2, []uint32{444})
+	re.ErrorIs(err, ErrKeyspaceGroupNotEnoughReplicas)
+
+	// preview the merge of the keyspace group 3 into 1
+	preview, err = suite.kgm.PreviewMergeKeyspaceGroups(1, []uint32{3})
+	re.NoError(err)
+	re.Len(preview.KeyspaceGroups, 1)
+	re.Equal([]uint32{111, 222, 333, 444, 555}, preview.KeyspaceGroups[0].Keyspaces)
+	re.True(preview.KeyspaceGroups[0].IsMergeTarget())
+	re.Equal([]uint32{3}, preview.DeletedKeyspaceGroups)
+	re.Equal(4, preview.EtcdTxnOps)
+	// nothing is saved
+	kg1, err = suite.kgm.GetKeyspaceGroupByID(1)
+	re.NoError(err)
+	re.Equal([]uint32{111, 222, 333}, kg1.Keyspaces)
+	re.False(kg1.IsMerging())
+	kg3, err := suite.kgm.GetKeyspaceGroupByID(3)
+	re.NoError(err)
+	re.NotNil(kg3)
+	// preview the merge which exceeds the etcd txn ops limit
+	mergeList := make([]uint32, 0, maxEtcdTxnOps/2)
+	for i := 0; i < maxEtcdTxnOps/2; i++ {
+		mergeList = append(mergeList, 3)
+	}
+	preview, err = suite.kgm.PreviewMergeKeyspaceGroups(1, mergeList)
+	re.NoError(err)
+	re.Greater(preview.EtcdTxnOps, preview.MaxEtcdTxnOps)
+	err = suite.kgm.MergeKeyspaceGroups(1, mergeList)
+	re.ErrorIs(err, ErrExceedMaxEtcdTxnOps)
+}
+
 func TestBuildSplitKeyspaces(t *testing.T) {
 	re := require.New(t)
 	testCases := []struct {
@@ -68,6 +68,14 @@ var (
 	ErrKeyspaceGroupNotInMerging = func(groupID uint32) error {
 		return errors.Errorf("keyspace group %v is not in merging state", groupID)
 	}
+	// ErrKeyspaceGroupNotInTransition is used to indicate target keyspace group is neither in split nor merging state.
+	ErrKeyspaceGroupNotInTransition = func(groupID uint32) error {
+		return errors.Errorf("keyspace group %v is neither in split nor merging state", groupID)
+	}
+	// ErrKeyspaceGroupInconsistent is used to indicate the keyspaces of target keyspace group are inconsistent.
+	ErrKeyspaceGroupInconsistent = func(groupID uint32, reason string) error {
+		return errors.Errorf("keyspace group %v is inconsistent: %s", groupID, reason)
+	}
 	// ErrKeyspaceNotInKeyspaceGroup is used to indicate target keyspace is not in this keyspace group.
 	ErrKeyspaceNotInKeyspaceGroup = errors.New("keyspace is not in this keyspace group")
 	// ErrNodeNotInKeyspaceGroup is used to indicate the tso node is not in this keyspace group.
@@ -78,6 +86,8 @@ var (
 	ErrModifyDefaultKeyspaceGroup = errors.New("default keyspace group cannot be modified")
 	// ErrNoAvailableNode is used to indicate no available node in the keyspace group.
 	ErrNoAvailableNode = errors.New("no available node")
+	// ErrEtcdClientUnavailable is used to indicate the etcd client is not available to the keyspace group manager.
+	ErrEtcdClientUnavailable = errors.New("etcd client is unavailable")
 	// ErrExceedMaxEtcdTxnOps is used to indicate the number of etcd txn operations exceeds the limit.
 	ErrExceedMaxEtcdTxnOps = errors.New("exceed max etcd txn operations")
 	// ErrModifyDefaultKeyspace is used to indicate that default keyspace cannot be modified.
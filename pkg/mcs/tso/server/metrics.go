@@ -14,7 +14,10 @@
 
 package server
 
-import "github.com/prometheus/client_golang/prometheus"
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tikv/pd/pkg/utils/etcdutil"
+)
 
 const (
 	namespace = "tso"
@@ -81,4 +84,5 @@ func init() {
 	prometheus.MustRegister(tsoProxyHandleDuration)
 	prometheus.MustRegister(tsoProxyBatchSize)
 	prometheus.MustRegister(tsoHandleDuration)
+	etcdutil.RegisterMetrics(prometheus.DefaultRegisterer)
 }
@@ -59,6 +59,10 @@ const (
 	ResourceManagerServiceName = "resource_manager"
 	// KeyspaceGroupsKey is the path component of keyspace groups.
 	KeyspaceGroupsKey = "keyspace_groups"
+	// KeyspaceGroupsElectionKey is the path component of the keyspace group primary elections.
+	KeyspaceGroupsElectionKey = "election"
+	// PrimaryKey is the path component of the keyspace group primary.
+	PrimaryKey = "primary"
 
 	// MaxKeyspaceGroupCount is the max count of keyspace groups. keyspace group in tso
 	// is the sharding unit, i.e., by the definition here, the max count of the shards
@@ -16,6 +16,9 @@ package utils
 
 import (
 	"context"
+	"fmt"
+	"path"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -61,3 +64,26 @@ func PromHandler() gin.HandlerFunc {
 		})).ServeHTTP(c.Writer, c.Request)
 	}
 }
+
+// TSOSvcRootPath returns the root path of the etcd keys used by the TSO microservice.
+// Path: /ms/{cluster_id}/tso
+func TSOSvcRootPath(clusterID uint64) string {
+	return path.Join("/", MicroserviceKey, strconv.FormatUint(clusterID, 10), TSOServiceName)
+}
+
+// KeyspaceGroupIDElectionPath returns the path of the keyspace group primary election.
+// default keyspace group: "/ms/{cluster_id}/tso/00000".
+// non-default keyspace group: "/ms/{cluster_id}/tso/keyspace_groups/election/{group}".
+func KeyspaceGroupIDElectionPath(tsoSvcRootPath string, id uint32) string {
+	if id == DefaultKeyspaceGroupID {
+		return path.Join(tsoSvcRootPath, fmt.Sprintf("%05d", id))
+	}
+	return path.Join(tsoSvcRootPath, KeyspaceGroupsKey, KeyspaceGroupsElectionKey, fmt.Sprintf("%05d", id))
+}
+
+// KeyspaceGroupPrimaryPath returns the path of the keyspace group primary.
+// default keyspace group: "/ms/{cluster_id}/tso/00000/primary".
+// non-default keyspace group: "/ms/{cluster_id}/tso/keyspace_groups/election/{group}/primary".
+func KeyspaceGroupPrimaryPath(tsoSvcRootPath string, id uint32) string {
+	return path.Join(KeyspaceGroupIDElectionPath(tsoSvcRootPath, id), PrimaryKey)
+}
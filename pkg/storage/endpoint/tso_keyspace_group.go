@@ -86,12 +86,16 @@ type SplitState struct {
 	// When the keyspace group is being split to another keyspace group, the split-source will
 	// be set to its own ID.
 	SplitSource uint32 `json:"split-source"`
+	// StartTime is the unix timestamp in seconds when the split started.
+	StartTime int64 `json:"start-time,omitempty"`
 }
 
 // MergeState defines the merging state of a keyspace group.
 type MergeState struct {
 	// MergeList is the list of keyspace group IDs which are merging to this target keyspace group.
 	MergeList []uint32 `json:"merge-list"`
+	// StartTime is the unix timestamp in seconds when the merge started.
+	StartTime int64 `json:"start-time,omitempty"`
 }
 
 // KeyspaceGroup is the keyspace group.
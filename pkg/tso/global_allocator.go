@@ -18,6 +18,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -25,6 +26,7 @@ import (
 	"github.com/pingcap/failpoint"
 	"github.com/pingcap/kvproto/pkg/pdpb"
 	"github.com/pingcap/log"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/tikv/pd/pkg/errs"
 	mcsutils "github.com/tikv/pd/pkg/mcs/utils"
 	"github.com/tikv/pd/pkg/slice"
@@ -80,6 +82,8 @@ type GlobalTSOAllocator struct {
 	// which is used to estimate the MaxTS in a Global TSO generation
 	// to reduce the gRPC network IO latency.
 	syncRTT atomic.Value // store as int64 milliseconds
+	// watermarkGauge records the physical part of the last allocated timestamp.
+	watermarkGauge prometheus.Gauge
 }
 
 // NewGlobalTSOAllocator creates a new global TSO allocator.
@@ -104,6 +108,7 @@ func NewGlobalTSOAllocator(
 			dcLocation:             GlobalDCLocation,
 			tsoMux:                 &tsoObject{},
 		},
+		watermarkGauge: tsoWatermark.WithLabelValues(strconv.FormatUint(uint64(am.kgID), 10), GlobalDCLocation),
 	}
 
 	if startGlobalLeaderLoop {
@@ -119,6 +124,8 @@ func NewGlobalTSOAllocator(
 func (gta *GlobalTSOAllocator) close() {
 	gta.cancel()
 	gta.wg.Wait()
+	// Remove the watermark of the closed allocator, e.g., its keyspace group is deleted or moved away.
+	tsoWatermark.DeleteLabelValues(strconv.FormatUint(uint64(gta.getGroupID()), 10), GlobalDCLocation)
 }
 
 // getGroupID returns the keyspace group ID of the allocator.
@@ -202,6 +209,14 @@ func (gta *GlobalTSOAllocator) SetTSO(tso uint64, ignoreSmaller, skipUpperBoundC
 //  2. Estimate a MaxTS and try to write it to all Local TSO Allocator leaders directly to reduce the RTT.
 //     During the process, if the estimated MaxTS is not accurate, it will fallback to the collecting way.
 func (gta *GlobalTSOAllocator) GenerateTSO(count uint32) (pdpb.Timestamp, error) {
+	ts, err := gta.generateTSO(count)
+	if err == nil && gta.watermarkGauge != nil {
+		gta.watermarkGauge.Set(float64(ts.GetPhysical()))
+	}
+	return ts, err
+}
+
+func (gta *GlobalTSOAllocator) generateTSO(count uint32) (pdpb.Timestamp, error) {
 	if !gta.member.GetLeadership().Check() {
 		tsoCounter.WithLabelValues("not_leader", gta.timestampOracle.dcLocation).Inc()
 		return pdpb.Timestamp{}, errs.ErrGenerateTimestamp.FastGenByArgs(fmt.Sprintf("requested pd %s of cluster", errs.NotLeaderErr))
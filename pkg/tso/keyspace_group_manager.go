@@ -20,7 +20,6 @@ import (
 	"fmt"
 	"math"
 	"net/http"
-	"path"
 	"regexp"
 	"sort"
 	"strings"
@@ -51,9 +50,9 @@ import (
 )
 
 const (
-	keyspaceGroupsElectionPath = mcsutils.KeyspaceGroupsKey + "/election"
+	keyspaceGroupsElectionPath = mcsutils.KeyspaceGroupsKey + "/" + mcsutils.KeyspaceGroupsElectionKey
 	// primaryKey is the key for keyspace group primary election.
-	primaryKey = "primary"
+	primaryKey = mcsutils.PrimaryKey
 	// mergingCheckInterval is the interval for merging check to see if the keyspace groups
 	// merging process could be moved forward.
 	mergingCheckInterval = 5 * time.Second
@@ -237,18 +236,13 @@ func (s *state) getNextPrimaryToReset(
 type kgPrimaryPathBuilder struct {
 	// rootPath is "/ms/{cluster_id}/tso".
 	rootPath string
-	// defaultKeyspaceGroupIDPath is "/ms/{cluster_id}/tso/00000".
-	defaultKeyspaceGroupIDPath string
 }
 
 // getKeyspaceGroupIDPath returns the keyspace group primary ID path.
 // default keyspace group: "/ms/{cluster_id}/tso/00000".
 // non-default keyspace group: "/ms/{cluster_id}/tso/keyspace_groups/election/{group}".
 func (p *kgPrimaryPathBuilder) getKeyspaceGroupIDPath(keyspaceGroupID uint32) string {
-	if keyspaceGroupID == mcsutils.DefaultKeyspaceGroupID {
-		return p.defaultKeyspaceGroupIDPath
-	}
-	return path.Join(p.rootPath, keyspaceGroupsElectionPath, fmt.Sprintf("%05d", keyspaceGroupID))
+	return mcsutils.KeyspaceGroupIDElectionPath(p.rootPath, keyspaceGroupID)
 }
 
 // getCompiledNonDefaultIDRegexp returns the compiled regular expression for matching non-default keyspace group id.
@@ -382,8 +376,7 @@ func NewKeyspaceGroupManager(
 		kv.NewEtcdKVBase(kgm.etcdClient, kgm.tsoSvcRootPath), nil)
 	kgm.compiledKGMembershipIDRegexp = endpoint.GetCompiledKeyspaceGroupIDRegexp()
 	kgm.primaryPathBuilder = &kgPrimaryPathBuilder{
-		rootPath:                   kgm.tsoSvcRootPath,
-		defaultKeyspaceGroupIDPath: path.Join(kgm.tsoSvcRootPath, "00000"),
+		rootPath: kgm.tsoSvcRootPath,
 	}
 	kgm.state.initialize()
 	return kgm
@@ -1141,6 +1134,9 @@ func (kgm *KeyspaceGroupManager) finishSplitKeyspaceGroup(id uint32) error {
 	if kgm.httpClient == nil {
 		return nil
 	}
+	failpoint.Inject("failedToFinishSplit", func() {
+		failpoint.Return(errs.ErrSendRequest.FastGenByArgs())
+	})
 	statusCode, err := apiutil.DoDelete(
 		kgm.httpClient,
 		kgm.cfg.GeBackendEndpoints()+keyspaceGroupsAPIPrefix+fmt.Sprintf("/%d/split", id))
@@ -1248,7 +1244,7 @@ func (kgm *KeyspaceGroupManager) mergingChecker(ctx context.Context, mergeTarget
 		// Check if the keyspace group primaries in the merge map are all gone.
 		if len(mergeMap) != 0 {
 			for id := range mergeMap {
-				leaderPath := path.Join(kgm.primaryPathBuilder.getKeyspaceGroupIDPath(id), primaryKey)
+				leaderPath := mcsutils.KeyspaceGroupPrimaryPath(kgm.tsoSvcRootPath, id)
 				val, err := kgm.tsoSvcStorage.Load(leaderPath)
 				if err != nil {
 					log.Error("failed to check if the keyspace group primary in the merge list has gone",
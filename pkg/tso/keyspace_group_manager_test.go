@@ -29,6 +29,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/pingcap/failpoint"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 	"github.com/tikv/pd/pkg/errs"
@@ -629,6 +630,40 @@ func (suite *keyspaceGroupManagerTestSuite) TestHandleTSORequestWithWrongMembers
 	re.Equal(uint32(1), keyspaceGroupBelongTo)
 }
 
+// TestWatermarkMetricCleanup tests that the watermark gauge of a keyspace group is removed
+// after the keyspace group is deleted or the keyspace group manager is closed.
+func (suite *keyspaceGroupManagerTestSuite) TestWatermarkMetricCleanup() {
+	re := suite.Require()
+
+	mgr := suite.newUniqueKeyspaceGroupManager(1)
+	re.NotNil(mgr)
+
+	rootPath := mgr.legacySvcRootPath
+	svcAddr := mgr.tsoServiceID.ServiceAddr
+	addKeyspaceGroupAssignment(
+		suite.ctx, suite.etcdClient, uint32(0), rootPath, []string{svcAddr}, []int{0}, []uint32{0})
+	addKeyspaceGroupAssignment(
+		suite.ctx, suite.etcdClient, uint32(1), rootPath, []string{svcAddr}, []int{0}, []uint32{1})
+
+	err := mgr.Initialize()
+	re.NoError(err)
+	testutil.Eventually(re, func() bool {
+		return reflect.DeepEqual([]uint32{0, 1}, collectAssignedKeyspaceGroupIDs(re, mgr))
+	})
+	count := promtestutil.CollectAndCount(tsoWatermark)
+
+	// Delete keyspace group 1, its watermark should be removed.
+	event := generateKeyspaceGroupDeleteEvent(1)
+	suite.applyEtcdEvents(re, rootPath, []*etcdEvent{event})
+	testutil.Eventually(re, func() bool {
+		return promtestutil.CollectAndCount(tsoWatermark) == count-1
+	})
+
+	// Close the keyspace group manager, the watermark of keyspace group 0 should be removed.
+	mgr.Close()
+	re.Equal(count-2, promtestutil.CollectAndCount(tsoWatermark))
+}
+
 type etcdEvent struct {
 	eventType mvccpb.Event_EventType
 	ksg       *endpoint.KeyspaceGroup
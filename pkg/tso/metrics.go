@@ -17,8 +17,9 @@ package tso
 import "github.com/prometheus/client_golang/prometheus"
 
 const (
-	dcLabel   = "dc"
-	typeLabel = "type"
+	dcLabel    = "dc"
+	typeLabel  = "type"
+	groupLabel = "group"
 )
 
 var (
@@ -47,6 +48,14 @@ var (
 			Help:      "The minimal (non-zero) TSO gap for each DC.",
 		}, []string{dcLabel})
 
+	tsoWatermark = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "pd",
+			Subsystem: "tso",
+			Name:      "keyspace_group_watermark",
+			Help:      "The physical part in milliseconds of the last timestamp allocated by each keyspace group.",
+		}, []string{groupLabel, dcLabel})
+
 	tsoAllocatorRole = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Namespace: "pd",
@@ -60,5 +69,6 @@ func init() {
 	prometheus.MustRegister(tsoCounter)
 	prometheus.MustRegister(tsoGauge)
 	prometheus.MustRegister(tsoGap)
+	prometheus.MustRegister(tsoWatermark)
 	prometheus.MustRegister(tsoAllocatorRole)
 }
@@ -15,7 +15,6 @@
 package tso
 
 import (
-	"path"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -77,8 +76,7 @@ func TestExtractKeyspaceGroupIDFromKeyspaceGroupPrimaryPath(t *testing.T) {
 
 	tsoSvcRootPath := "/ms/111/tso"
 	primaryPathBuilder := &kgPrimaryPathBuilder{
-		rootPath:                   tsoSvcRootPath,
-		defaultKeyspaceGroupIDPath: path.Join(tsoSvcRootPath, "00000"),
+		rootPath: tsoSvcRootPath,
 	}
 
 	compiledRegexp := primaryPathBuilder.getCompiledNonDefaultIDRegexp()
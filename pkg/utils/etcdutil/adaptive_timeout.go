@@ -0,0 +1,170 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdutil
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultAdaptiveTimeoutMultiplier = 3
+	defaultAdaptiveTimeoutWindowSize = 1000
+	// defaultAdaptiveTimeoutRecomputeInterval recomputes the timeout 10 times per full window.
+	defaultAdaptiveTimeoutRecomputeInterval = 100
+	defaultAdaptiveTimeoutMinTimeout        = time.Second
+	defaultAdaptiveTimeoutMaxTimeout        = DefaultRequestTimeout
+)
+
+// AdaptiveTimeoutOptions is the options of the adaptive request timeout.
+type AdaptiveTimeoutOptions struct {
+	// Multiplier is the multiple of the observed p99 latency used as the timeout.
+	Multiplier float64
+	// MinTimeout is the lower bound of the timeout.
+	MinTimeout time.Duration
+	// MaxTimeout is the upper bound of the timeout, which is also used before
+	// any latency is observed.
+	MaxTimeout time.Duration
+	// WindowSize is the number of the recent latencies used to compute the p99.
+	WindowSize int
+	// RecomputeInterval is the number of the observations between two computations of the
+	// timeout, since computing the p99 sorts the whole window.
+	RecomputeInterval int
+}
+
+func (o *AdaptiveTimeoutOptions) adjust() {
+	if o.Multiplier <= 0 {
+		o.Multiplier = defaultAdaptiveTimeoutMultiplier
+	}
+	if o.MinTimeout <= 0 {
+		o.MinTimeout = defaultAdaptiveTimeoutMinTimeout
+	}
+	if o.MaxTimeout <= 0 {
+		o.MaxTimeout = defaultAdaptiveTimeoutMaxTimeout
+	}
+	if o.MaxTimeout < o.MinTimeout {
+		o.MaxTimeout = o.MinTimeout
+	}
+	if o.WindowSize <= 0 {
+		o.WindowSize = defaultAdaptiveTimeoutWindowSize
+	}
+	if o.RecomputeInterval <= 0 {
+		o.RecomputeInterval = defaultAdaptiveTimeoutRecomputeInterval
+	}
+}
+
+// AdaptiveTimeout tracks the recent request latencies and computes the request timeout
+// as a multiple of the observed p99 latency, bounded by the min and max timeout.
+type AdaptiveTimeout struct {
+	opts AdaptiveTimeoutOptions
+
+	mu sync.RWMutex
+	// latencies is a ring buffer of the recent latencies.
+	latencies []time.Duration
+	next      int
+	// observed is the number of the observations since the last computation of the timeout.
+	observed int
+	// timeout is the cached timeout which is recomputed after the first observation and
+	// then every RecomputeInterval observations.
+	timeout time.Duration
+}
+
+// NewAdaptiveTimeout creates a new AdaptiveTimeout with the given options, the zero
+// value of each option means using the default value.
+func NewAdaptiveTimeout(opts AdaptiveTimeoutOptions) *AdaptiveTimeout {
+	opts.adjust()
+	return &AdaptiveTimeout{
+		opts:      opts,
+		latencies: make([]time.Duration, 0, opts.WindowSize),
+		timeout:   opts.MaxTimeout,
+	}
+}
+
+// Observe records the latency of a finished request.
+func (t *AdaptiveTimeout) Observe(latency time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.latencies) < t.opts.WindowSize {
+		t.latencies = append(t.latencies, latency)
+	} else {
+		t.latencies[t.next] = latency
+	}
+	t.next = (t.next + 1) % t.opts.WindowSize
+	t.observed++
+	if len(t.latencies) == 1 || t.observed >= t.opts.RecomputeInterval {
+		t.timeout = t.computeTimeout()
+		t.observed = 0
+	}
+}
+
+// Timeout returns the current request timeout.
+func (t *AdaptiveTimeout) Timeout() time.Duration {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.timeout
+}
+
+func (t *AdaptiveTimeout) computeTimeout() time.Duration {
+	timeout := time.Duration(float64(t.p99()) * t.opts.Multiplier)
+	if timeout < t.opts.MinTimeout {
+		return t.opts.MinTimeout
+	}
+	if timeout > t.opts.MaxTimeout {
+		return t.opts.MaxTimeout
+	}
+	return timeout
+}
+
+func (t *AdaptiveTimeout) p99() time.Duration {
+	sorted := make([]time.Duration, len(t.latencies))
+	copy(sorted, t.latencies)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	idx := int(math.Ceil(float64(len(sorted))*0.99)) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	return sorted[idx]
+}
+
+// adaptiveTimeout is the adaptive request timeout used by the etcd requests, nil means disabled.
+var adaptiveTimeout atomic.Pointer[AdaptiveTimeout]
+
+// EnableAdaptiveRequestTimeout makes the etcd requests made by the helpers of etcdutil use the adaptive
+// timeout instead of DefaultRequestTimeout. Passing nil disables it.
+func EnableAdaptiveRequestTimeout(opts *AdaptiveTimeoutOptions) {
+	if opts == nil {
+		adaptiveTimeout.Store(nil)
+		return
+	}
+	adaptiveTimeout.Store(NewAdaptiveTimeout(*opts))
+}
+
+// requestTimeout returns the timeout of an etcd request.
+func requestTimeout() time.Duration {
+	if t := adaptiveTimeout.Load(); t != nil {
+		return t.Timeout()
+	}
+	return DefaultRequestTimeout
+}
+
+// observeRequestLatency records the latency of an etcd request if the adaptive timeout is enabled.
+func observeRequestLatency(latency time.Duration) {
+	if t := adaptiveTimeout.Load(); t != nil {
+		t.Observe(latency)
+	}
+}
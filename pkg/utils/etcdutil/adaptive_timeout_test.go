@@ -0,0 +1,87 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdaptiveTimeout(t *testing.T) {
+	re := require.New(t)
+	at := NewAdaptiveTimeout(AdaptiveTimeoutOptions{
+		Multiplier: 2,
+		MinTimeout: 100 * time.Millisecond,
+		MaxTimeout: 5 * time.Second,
+		WindowSize: 100,
+		// Recompute the timeout after each observation.
+		RecomputeInterval: 1,
+	})
+	// The max timeout is used before any latency is observed.
+	re.Equal(5*time.Second, at.Timeout())
+
+	// 99 fast requests and 1 slow request, the p99 is the fast one.
+	for i := 0; i < 99; i++ {
+		at.Observe(100 * time.Millisecond)
+	}
+	at.Observe(time.Second)
+	re.Equal(200*time.Millisecond, at.Timeout())
+	// 2 slow requests in the window make the p99 the slow one.
+	at.Observe(time.Second)
+	re.Equal(2*time.Second, at.Timeout())
+
+	// The timeout tracks the p99 of the recent latencies only.
+	for i := 0; i < 100; i++ {
+		at.Observe(300 * time.Millisecond)
+	}
+	re.Equal(600*time.Millisecond, at.Timeout())
+
+	// The timeout is bounded by the min and max timeout.
+	for i := 0; i < 100; i++ {
+		at.Observe(time.Millisecond)
+	}
+	re.Equal(100*time.Millisecond, at.Timeout())
+	for i := 0; i < 100; i++ {
+		at.Observe(10 * time.Second)
+	}
+	re.Equal(5*time.Second, at.Timeout())
+
+	// The timeout is recomputed after the first observation and then every RecomputeInterval observations.
+	at = NewAdaptiveTimeout(AdaptiveTimeoutOptions{Multiplier: 1, MaxTimeout: 10 * time.Second, RecomputeInterval: 10})
+	at.Observe(2 * time.Second)
+	re.Equal(2*time.Second, at.Timeout())
+	for i := 0; i < 9; i++ {
+		at.Observe(3 * time.Second)
+		re.Equal(2*time.Second, at.Timeout())
+	}
+	at.Observe(3 * time.Second)
+	re.Equal(3*time.Second, at.Timeout())
+
+	// The default options are used for the zero values.
+	at = NewAdaptiveTimeout(AdaptiveTimeoutOptions{})
+	re.Equal(DefaultRequestTimeout, at.Timeout())
+	at.Observe(time.Second)
+	re.Equal(3*time.Second, at.Timeout())
+
+	// The adaptive timeout is only used after being enabled.
+	re.Equal(DefaultRequestTimeout, requestTimeout())
+	EnableAdaptiveRequestTimeout(&AdaptiveTimeoutOptions{MinTimeout: time.Second, MaxTimeout: 2 * time.Second})
+	observeRequestLatency(time.Millisecond)
+	re.Equal(time.Second, requestTimeout())
+	EnableAdaptiveRequestTimeout(nil)
+	re.Equal(DefaultRequestTimeout, requestTimeout())
+}
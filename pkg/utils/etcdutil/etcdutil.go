@@ -17,10 +17,15 @@ package etcdutil
 import (
 	"context"
 	"crypto/tls"
+	"math"
 	"math/rand"
 	"net/http"
 	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gogo/protobuf/proto"
@@ -32,9 +37,13 @@ import (
 	"github.com/tikv/pd/pkg/utils/typeutil"
 	"go.etcd.io/etcd/clientv3"
 	"go.etcd.io/etcd/etcdserver"
+	"go.etcd.io/etcd/etcdserver/api/v3rpc/rpctypes"
+	"go.etcd.io/etcd/etcdserver/etcdserverpb"
 	"go.etcd.io/etcd/mvcc/mvccpb"
 	"go.etcd.io/etcd/pkg/types"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 const (
@@ -61,10 +70,16 @@ const (
 	// DefaultSlowRequestTime 1s for the threshold for normal request, for those
 	// longer then 1s, they are considered as slow requests.
 	DefaultSlowRequestTime = time.Second
+
+	// checkClusterIDTimeout is the timeout to check the cluster ID with all the remote peers.
+	checkClusterIDTimeout = 10 * time.Second
+	// maxCheckClusterIDWorkers is the max number of the remote peers probed concurrently.
+	maxCheckClusterIDWorkers = 8
 )
 
 // CheckClusterID checks etcd cluster ID, returns an error if mismatch.
-// This function will never block even quorum is not satisfied.
+// This function will never block even quorum is not satisfied. The peers are probed concurrently
+// and the unreachable ones are skipped, the whole check is bounded by checkClusterIDTimeout.
 func CheckClusterID(localClusterID types.ID, um types.URLsMap, tlsConfig *tls.Config) error {
 	if len(um) == 0 {
 		return nil
@@ -75,75 +90,417 @@ func CheckClusterID(localClusterID types.ID, um types.URLsMap, tlsConfig *tls.Co
 		peerURLs = append(peerURLs, urls.StringSlice()...)
 	}
 
+	ctx, cancel := context.WithTimeout(context.Background(), checkClusterIDTimeout)
+	defer cancel()
+	trp := &http.Transport{
+		TLSClientConfig: tlsConfig,
+	}
+	defer trp.CloseIdleConnections()
+	rt := &ctxRoundTripper{ctx: ctx, rt: trp}
+
+	workers := maxCheckClusterIDWorkers
+	if len(peerURLs) < workers {
+		workers = len(peerURLs)
+	}
+	var (
+		wg    sync.WaitGroup
+		urlCh = make(chan string)
+		errCh = make(chan error, 1)
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer logutil.LogPanic()
+			defer wg.Done()
+			for u := range urlCh {
+				remoteCluster, gerr := etcdserver.GetClusterFromRemotePeers(nil, []string{u}, rt)
+				if gerr != nil {
+					// Do not return error, because other members may be not ready.
+					log.Error("failed to get cluster from remote", zap.String("peer-url", u), errs.ZapError(errs.ErrEtcdGetCluster, gerr))
+					continue
+				}
+
+				remoteClusterID := remoteCluster.ID()
+				if remoteClusterID != localClusterID {
+					select {
+					case errCh <- errors.Errorf("Etcd cluster ID mismatch, expect %d, got %d", localClusterID, remoteClusterID):
+					default:
+					}
+					// Stop probing the other peers since the mismatch is definitive.
+					cancel()
+					return
+				}
+			}
+		}()
+	}
+feed:
 	for _, u := range peerURLs {
-		trp := &http.Transport{
-			TLSClientConfig: tlsConfig,
-		}
-		remoteCluster, gerr := etcdserver.GetClusterFromRemotePeers(nil, []string{u}, trp)
-		trp.CloseIdleConnections()
-		if gerr != nil {
-			// Do not return error, because other members may be not ready.
-			log.Error("failed to get cluster from remote", errs.ZapError(errs.ErrEtcdGetCluster, gerr))
-			continue
+		select {
+		case urlCh <- u:
+		case <-ctx.Done():
+			break feed
 		}
+	}
+	close(urlCh)
+	wg.Wait()
 
-		remoteClusterID := remoteCluster.ID()
-		if remoteClusterID != localClusterID {
-			return errors.Errorf("Etcd cluster ID mismatch, expect %d, got %d", localClusterID, remoteClusterID)
-		}
+	select {
+	case err := <-errCh:
+		return err
+	default:
+		return nil
 	}
-	return nil
+}
+
+// ctxRoundTripper binds the requests to the given context, so the in-flight ones can be canceled together.
+type ctxRoundTripper struct {
+	ctx context.Context
+	rt  http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *ctxRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return r.rt.RoundTrip(req.WithContext(r.ctx))
 }
 
 // AddEtcdMember adds an etcd member.
 func AddEtcdMember(client *clientv3.Client, urls []string) (*clientv3.MemberAddResponse, error) {
 	ctx, cancel := context.WithTimeout(client.Ctx(), DefaultRequestTimeout)
+	defer cancel()
+	return AddEtcdMemberWithContext(ctx, client, urls)
+}
+
+// AddEtcdMemberWithContext adds an etcd member with the deadline controlled by the given context.
+func AddEtcdMemberWithContext(ctx context.Context, client *clientv3.Client, urls []string) (*clientv3.MemberAddResponse, error) {
 	addResp, err := client.MemberAdd(ctx, urls)
-	cancel()
 	return addResp, errors.WithStack(err)
 }
 
+// AddEtcdLearner adds an etcd member as a learner, which doesn't vote until it is promoted.
+func AddEtcdLearner(client *clientv3.Client, urls []string) (*clientv3.MemberAddResponse, error) {
+	ctx, cancel := context.WithTimeout(client.Ctx(), DefaultRequestTimeout)
+	defer cancel()
+	return AddEtcdLearnerWithContext(ctx, client, urls)
+}
+
+// AddEtcdLearnerWithContext adds an etcd member as a learner with the deadline controlled by the given context.
+func AddEtcdLearnerWithContext(ctx context.Context, client *clientv3.Client, urls []string) (*clientv3.MemberAddResponse, error) {
+	addResp, err := client.MemberAddAsLearner(ctx, urls)
+	if err != nil {
+		return addResp, errs.ErrEtcdMemberAddLearner.Wrap(err).GenWithStackByCause()
+	}
+	return addResp, nil
+}
+
+// PromoteEtcdLearner promotes a learner by the given id to a voting member.
+func PromoteEtcdLearner(client *clientv3.Client, id uint64) (*clientv3.MemberPromoteResponse, error) {
+	ctx, cancel := context.WithTimeout(client.Ctx(), DefaultRequestTimeout)
+	defer cancel()
+	return PromoteEtcdLearnerWithContext(ctx, client, id)
+}
+
+// PromoteEtcdLearnerWithContext promotes a learner by the given id to a voting member with the deadline
+// controlled by the given context.
+func PromoteEtcdLearnerWithContext(ctx context.Context, client *clientv3.Client, id uint64) (*clientv3.MemberPromoteResponse, error) {
+	promoteResp, err := client.MemberPromote(ctx, id)
+	if err != nil {
+		return promoteResp, errs.ErrEtcdMemberPromote.Wrap(err).GenWithStackByCause()
+	}
+	return promoteResp, nil
+}
+
 // ListEtcdMembers returns a list of internal etcd members.
 func ListEtcdMembers(client *clientv3.Client) (*clientv3.MemberListResponse, error) {
 	ctx, cancel := context.WithTimeout(client.Ctx(), DefaultRequestTimeout)
+	defer cancel()
+	return ListEtcdMembersWithContext(ctx, client)
+}
+
+// ListEtcdMembersWithContext returns a list of internal etcd members with the deadline controlled by the given context.
+func ListEtcdMembersWithContext(ctx context.Context, client *clientv3.Client) (*clientv3.MemberListResponse, error) {
 	listResp, err := client.MemberList(ctx)
-	cancel()
 	if err != nil {
 		return listResp, errs.ErrEtcdMemberList.Wrap(err).GenWithStackByCause()
 	}
 	return listResp, nil
 }
 
+// ListEtcdLearners returns the internal etcd members which are learners.
+func ListEtcdLearners(client *clientv3.Client) ([]*etcdserverpb.Member, error) {
+	listResp, err := ListEtcdMembers(client)
+	if err != nil {
+		return nil, err
+	}
+	learners := make([]*etcdserverpb.Member, 0, len(listResp.Members))
+	for _, m := range listResp.Members {
+		if m.IsLearner {
+			learners = append(learners, m)
+		}
+	}
+	return learners, nil
+}
+
 // RemoveEtcdMember removes a member by the given id.
 func RemoveEtcdMember(client *clientv3.Client, id uint64) (*clientv3.MemberRemoveResponse, error) {
 	ctx, cancel := context.WithTimeout(client.Ctx(), DefaultRequestTimeout)
+	defer cancel()
+	return RemoveEtcdMemberWithContext(ctx, client, id)
+}
+
+// RemoveEtcdMemberWithContext removes a member by the given id with the deadline controlled by the given context.
+func RemoveEtcdMemberWithContext(ctx context.Context, client *clientv3.Client, id uint64) (*clientv3.MemberRemoveResponse, error) {
 	rmResp, err := client.MemberRemove(ctx, id)
-	cancel()
 	if err != nil {
 		return rmResp, errs.ErrEtcdMemberRemove.Wrap(err).GenWithStackByCause()
 	}
 	return rmResp, nil
 }
 
+// MoveEtcdLeader transfers the etcd leadership to the member with the given id, which must be a started voter.
+// The client must be connected to the current leader, otherwise ErrEtcdNotLeader is returned.
+func MoveEtcdLeader(client *clientv3.Client, targetID uint64) error {
+	listResp, err := ListEtcdMembers(client)
+	if err != nil {
+		return err
+	}
+	var target *etcdserverpb.Member
+	for _, m := range listResp.Members {
+		if m.ID == targetID {
+			target = m
+			break
+		}
+	}
+	// The name of a member is empty until it starts.
+	if target == nil || target.IsLearner || len(target.Name) == 0 {
+		return errs.ErrEtcdMoveLeaderTarget.GenWithStackByArgs(targetID)
+	}
+	ctx, cancel := context.WithTimeout(client.Ctx(), requestTimeout())
+	start := time.Now()
+	_, err = client.MoveLeader(ctx, targetID)
+	cost := time.Since(start)
+	cancel()
+	observeRequestLatency(cost)
+	if reportSlowRequest("move-leader", strconv.FormatUint(targetID, 10), cost) {
+		log.Warn("move etcd leader too slow", zap.Uint64("target-id", targetID), zap.Duration("cost", cost), errs.ZapError(err))
+	}
+	if err != nil {
+		if errors.Cause(err) == rpctypes.ErrNotLeader {
+			return errs.ErrEtcdNotLeader.Wrap(err).GenWithStackByCause()
+		}
+		return errs.ErrEtcdMoveLeader.Wrap(err).GenWithStackByCause()
+	}
+	return nil
+}
+
+// KVFormatter renders a key or value stored in etcd in the logs, e.g., decodes the binary-encoded
+// region keys to make them human-readable.
+type KVFormatter func([]byte) string
+
+type kvFormatters struct {
+	key   KVFormatter
+	value KVFormatter
+}
+
+var logFormatters atomic.Pointer[kvFormatters]
+
+// SetLogKVFormatter sets the formatters used to render the keys and values in the logs of etcdutil.
+// A nil formatter means the raw bytes are logged, which is the default.
+func SetLogKVFormatter(keyFormatter, valueFormatter KVFormatter) {
+	logFormatters.Store(&kvFormatters{key: keyFormatter, value: valueFormatter})
+}
+
+func zapKey(name string, key []byte) zap.Field {
+	if f := logFormatters.Load(); f != nil && f.key != nil {
+		return zap.String(name, f.key(key))
+	}
+	return zap.ByteString(name, key)
+}
+
+func zapKeys(name string, keys []string) zap.Field {
+	f := logFormatters.Load()
+	if f == nil || f.key == nil {
+		return zap.Strings(name, keys)
+	}
+	formatted := make([]string, 0, len(keys))
+	for _, key := range keys {
+		formatted = append(formatted, f.key([]byte(key)))
+	}
+	return zap.Strings(name, formatted)
+}
+
+func zapValue(name string, value []byte) zap.Field {
+	if f := logFormatters.Load(); f != nil && f.value != nil {
+		return zap.String(name, f.value(value))
+	}
+	return zap.ByteString(name, value)
+}
+
+// SlowRequestHook is called with the operation, e.g., "get", "put", "delete" or "txn", the request key
+// and the cost of an etcd request which takes longer than DefaultSlowRequestTime.
+type SlowRequestHook func(op string, key string, cost time.Duration)
+
+// SlowRequestHookOption is used to configure the slow request hook.
+type SlowRequestHookOption func(*slowRequestReporter)
+
+// WithoutSlowRequestLog makes the slow requests only reported to the hook instead of being logged.
+func WithoutSlowRequestLog() SlowRequestHookOption {
+	return func(r *slowRequestReporter) { r.skipLog = true }
+}
+
+type slowRequestReporter struct {
+	hook    SlowRequestHook
+	skipLog bool
+}
+
+var (
+	slowRequestReporterPtr atomic.Pointer[slowRequestReporter]
+	// slowRequestTime is the threshold of the slow requests, it's only changed in the tests.
+	slowRequestTime = DefaultSlowRequestTime
+)
+
+// SetSlowRequestHook sets the hook to report the slow etcd requests made by the helpers of etcdutil,
+// they are still logged in addition to the hook unless WithoutSlowRequestLog is given. A nil hook
+// means only logging the slow requests, which is the default.
+func SetSlowRequestHook(hook SlowRequestHook, opts ...SlowRequestHookOption) {
+	r := &slowRequestReporter{hook: hook}
+	for _, opt := range opts {
+		opt(r)
+	}
+	slowRequestReporterPtr.Store(r)
+}
+
+// reportSlowRequest reports the request to the hook if it's slow, and returns true if the caller should
+// also log the slow request.
+func reportSlowRequest(op, key string, cost time.Duration) bool {
+	if cost <= slowRequestTime {
+		return false
+	}
+	r := slowRequestReporterPtr.Load()
+	if r == nil {
+		return true
+	}
+	if r.hook != nil {
+		r.hook(op, key, cost)
+	}
+	return !r.skipLog
+}
+
 // EtcdKVGet returns the etcd GetResponse by given key or key prefix
 func EtcdKVGet(c *clientv3.Client, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
-	ctx, cancel := context.WithTimeout(c.Ctx(), DefaultRequestTimeout)
+	ctx, cancel := context.WithTimeout(c.Ctx(), requestTimeout())
 	defer cancel()
+	return EtcdKVGetWithContext(ctx, c, key, opts...)
+}
 
+// EtcdKVGetWithContext returns the etcd GetResponse by given key or key prefix with the deadline
+// controlled by the given context, e.g., a longer one for the large range scans.
+func EtcdKVGetWithContext(ctx context.Context, c *clientv3.Client, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
 	start := time.Now()
 	resp, err := clientv3.NewKV(c).Get(ctx, key, opts...)
-	if cost := time.Since(start); cost > DefaultSlowRequestTime {
-		log.Warn("kv gets too slow", zap.String("request-key", key), zap.Duration("cost", cost), errs.ZapError(err))
+	cost := time.Since(start)
+	observeRequestLatency(cost)
+	if reportSlowRequest("get", key, cost) {
+		log.Warn("kv gets too slow", zapKey("request-key", []byte(key)), zap.Duration("cost", cost), errs.ZapError(err))
 	}
 
 	if err != nil {
 		e := errs.ErrEtcdKVGet.Wrap(err).GenWithStackByCause()
-		log.Error("load from etcd meet error", zap.String("key", key), errs.ZapError(e))
+		log.Error("load from etcd meet error", zapKey("key", []byte(key)), errs.ZapError(e))
 		return resp, e
 	}
 	return resp, nil
 }
 
+// EtcdKVDelete deletes the given key or the keys in the range given by the options, e.g., clientv3.WithPrefix().
+// The number of the deleted keys is returned by the Deleted field of the response.
+func EtcdKVDelete(c *clientv3.Client, key string, opts ...clientv3.OpOption) (*clientv3.DeleteResponse, error) {
+	ctx, cancel := context.WithTimeout(c.Ctx(), requestTimeout())
+	defer cancel()
+	return EtcdKVDeleteWithContext(ctx, c, key, opts...)
+}
+
+// EtcdKVDeleteWithContext deletes the given key or the keys in the range given by the options with the deadline
+// controlled by the given context.
+func EtcdKVDeleteWithContext(ctx context.Context, c *clientv3.Client, key string, opts ...clientv3.OpOption) (*clientv3.DeleteResponse, error) {
+	start := time.Now()
+	resp, err := clientv3.NewKV(c).Delete(ctx, key, opts...)
+	cost := time.Since(start)
+	observeRequestLatency(cost)
+	if reportSlowRequest("delete", key, cost) {
+		log.Warn("kv deletes too slow", zapKey("request-key", []byte(key)), zap.Duration("cost", cost), errs.ZapError(err))
+	}
+
+	if err != nil {
+		e := errs.ErrEtcdKVDelete.Wrap(err).GenWithStackByCause()
+		log.Error("delete from etcd meet error", zapKey("key", []byte(key)), errs.ZapError(e))
+		return resp, e
+	}
+	return resp, nil
+}
+
+// commitTxn commits the transaction on the given key built by the caller with the request timeout,
+// and reports it if it's slow.
+func commitTxn(c *clientv3.Client, key string, build func(clientv3.Txn) clientv3.Txn) (*clientv3.TxnResponse, error) {
+	ctx, cancel := context.WithTimeout(c.Ctx(), requestTimeout())
+	defer cancel()
+	start := time.Now()
+	resp, err := build(c.Txn(ctx)).Commit()
+	cost := time.Since(start)
+	observeRequestLatency(cost)
+	if reportSlowRequest("txn", key, cost) {
+		log.Warn("kv txn too slow", zapKey("request-key", []byte(key)), zap.Duration("cost", cost), errs.ZapError(err))
+	}
+	if err != nil {
+		return nil, errs.ErrEtcdTxnInternal.Wrap(err).GenWithStackByCause()
+	}
+	return resp, nil
+}
+
+// EtcdKVGetMultiAtRevision returns the values of the given keys read at the same revision,
+// so the result is a consistent snapshot even if the keys are being modified concurrently.
+// If rev is 0, the keys are read at the current revision. Keys that do not exist are not
+// included in the returned map.
+func EtcdKVGetMultiAtRevision(c *clientv3.Client, keys []string, rev int64) (map[string][]byte, error) {
+	ctx, cancel := context.WithTimeout(c.Ctx(), requestTimeout())
+	defer cancel()
+
+	ops := make([]clientv3.Op, 0, len(keys))
+	for _, key := range keys {
+		if rev > 0 {
+			ops = append(ops, clientv3.OpGet(key, clientv3.WithRev(rev)))
+		} else {
+			ops = append(ops, clientv3.OpGet(key))
+		}
+	}
+
+	start := time.Now()
+	// All the read operations in a transaction are served at the same revision.
+	resp, err := c.Txn(ctx).Then(ops...).Commit()
+	cost := time.Since(start)
+	observeRequestLatency(cost)
+	if reportSlowRequest("get", strings.Join(keys, ","), cost) {
+		log.Warn("kv gets too slow", zapKeys("request-keys", keys), zap.Int64("revision", rev),
+			zap.Duration("cost", cost), errs.ZapError(err))
+	}
+	if err != nil {
+		e := errs.ErrEtcdKVGet.Wrap(err).GenWithStackByCause()
+		log.Error("load from etcd meet error", zapKeys("keys", keys), zap.Int64("revision", rev), errs.ZapError(e))
+		return nil, e
+	}
+
+	values := make(map[string][]byte, len(keys))
+	for _, r := range resp.Responses {
+		rangeResp := r.GetResponseRange()
+		if rangeResp == nil {
+			continue
+		}
+		for _, kv := range rangeResp.Kvs {
+			values[string(kv.Key)] = kv.Value
+		}
+	}
+	return values, nil
+}
+
 // GetValue gets value with key from etcd.
 func GetValue(c *clientv3.Client, key string, opts ...clientv3.OpOption) ([]byte, error) {
 	resp, err := get(c, key, opts...)
@@ -156,6 +513,72 @@ func GetValue(c *clientv3.Client, key string, opts ...clientv3.OpOption) ([]byte
 	return resp.Kvs[0].Value, nil
 }
 
+// GetValueSerializable gets value with key from etcd by a serializable read, which is served
+// by the local member without going through the raft quorum. It's cheaper than GetValue, but
+// the value may be stale, e.g., when the member is partitioned from the leader or lagging behind.
+// Only use it when the caller can tolerate a stale value.
+func GetValueSerializable(c *clientv3.Client, key string) ([]byte, error) {
+	return GetValue(c, key, clientv3.WithSerializable())
+}
+
+// GetValueWithRetry gets value with key from etcd like GetValue, but retries on the transient errors,
+// e.g., the leader is lost or the request times out. The interval between two retries starts from
+// the given interval and doubles after each retry until reaching defaultLoadFromEtcdMaxRetryInterval.
+// If retries or interval is not positive, defaultLoadFromEtcdRetryTimes and defaultLoadFromEtcdRetryInterval
+// are used instead. It stops retrying once the context of the client is done.
+func GetValueWithRetry(c *clientv3.Client, key string, retries int, interval time.Duration, opts ...clientv3.OpOption) ([]byte, error) {
+	if retries <= 0 {
+		retries = defaultLoadFromEtcdRetryTimes
+	}
+	if interval <= 0 {
+		interval = defaultLoadFromEtcdRetryInterval
+	}
+	var (
+		value []byte
+		err   error
+	)
+	for i := 0; i <= retries; i++ {
+		if i > 0 {
+			timer := time.NewTimer(interval)
+			select {
+			case <-c.Ctx().Done():
+				timer.Stop()
+				return nil, errs.ErrEtcdKVGet.Wrap(c.Ctx().Err()).GenWithStackByCause()
+			case <-timer.C:
+			}
+			interval *= 2
+			if interval > defaultLoadFromEtcdMaxRetryInterval {
+				interval = defaultLoadFromEtcdMaxRetryInterval
+			}
+		}
+		failpoint.Inject("getValueTemporaryFail", func(val failpoint.Value) {
+			if maxFailTimes, ok := val.(int); ok && i < maxFailTimes {
+				err = errs.ErrEtcdKVGet.Wrap(rpctypes.ErrLeaderChanged).GenWithStackByCause()
+				failpoint.Continue()
+			}
+		})
+		value, err = GetValue(c, key, opts...)
+		if err == nil || !isRetriableEtcdError(err) {
+			return value, err
+		}
+		log.Warn("failed to get value from etcd, retry later",
+			zapKey("key", []byte(key)), zap.Int("retry", i), errs.ZapError(err))
+	}
+	return nil, err
+}
+
+// isRetriableEtcdError returns true if the error is transient and the request may succeed on retry.
+func isRetriableEtcdError(err error) bool {
+	cause := errors.Cause(err)
+	switch cause {
+	case rpctypes.ErrNoLeader, rpctypes.ErrLeaderChanged, rpctypes.ErrNotCapable, rpctypes.ErrTooManyRequests,
+		rpctypes.ErrTimeout, rpctypes.ErrTimeoutDueToLeaderFail, rpctypes.ErrTimeoutDueToConnectionLost,
+		context.DeadlineExceeded:
+		return true
+	}
+	return status.Code(cause) == codes.Unavailable
+}
+
 func get(c *clientv3.Client, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
 	resp, err := EtcdKVGet(c, key, opts...)
 	if err != nil {
@@ -186,6 +609,82 @@ func GetProtoMsgWithModRev(c *clientv3.Client, key string, msg proto.Message, op
 	return true, resp.Kvs[0].ModRevision, nil
 }
 
+// GetProtoMsgsWithPrefix loads and unmarshals all the values under the given prefix, the messages are
+// created by newMsg and returned in the order of their keys. The keys are loaded in batches of at most
+// defaultLoadBatchSize keys at the same revision, so the result is a consistent snapshot. It also returns
+// the highest ModRevision of the loaded keys, which is 0 if there is no key under the prefix.
+func GetProtoMsgsWithPrefix(c *clientv3.Client, prefix string, newMsg func() proto.Message) ([]proto.Message, int64, error) {
+	var (
+		msgs     []proto.Message
+		maxRev   int64
+		rev      int64
+		startKey = prefix
+		rangeEnd = clientv3.GetPrefixRangeEnd(prefix)
+	)
+	for {
+		// Load one more key to know where the next batch starts.
+		opts := []clientv3.OpOption{
+			clientv3.WithRange(rangeEnd),
+			clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend),
+			clientv3.WithLimit(defaultLoadBatchSize + 1),
+		}
+		if rev > 0 {
+			opts = append(opts, clientv3.WithRev(rev))
+		}
+		resp, err := EtcdKVGet(c, startKey, opts...)
+		if err != nil {
+			return nil, 0, err
+		}
+		rev = resp.Header.Revision
+		kvs := resp.Kvs
+		if resp.More && len(kvs) > 0 {
+			startKey = string(kvs[len(kvs)-1].Key)
+			kvs = kvs[:len(kvs)-1]
+		}
+		for _, kv := range kvs {
+			msg := newMsg()
+			if err := proto.Unmarshal(kv.Value, msg); err != nil {
+				return nil, 0, errs.ErrProtoUnmarshal.Wrap(err).GenWithStack("failed to unmarshal proto of key %s", kv.Key)
+			}
+			msgs = append(msgs, msg)
+			if kv.ModRevision > maxRev {
+				maxRev = kv.ModRevision
+			}
+		}
+		if !resp.More {
+			return msgs, maxRev, nil
+		}
+	}
+}
+
+// PutProtoMsgIfModRev marshals the msg and puts it to the key only if the ModRevision of the key
+// still equals expectedModRev, which should be 0 if the key is expected to be absent. It returns
+// true and the new ModRevision of the key if the put succeeds. Otherwise, it returns false and the
+// current ModRevision of the key, which is 0 if the key does not exist.
+func PutProtoMsgIfModRev(c *clientv3.Client, key string, msg proto.Message, expectedModRev int64) (bool, int64, error) {
+	value, err := proto.Marshal(msg)
+	if err != nil {
+		return false, 0, errs.ErrProtoMarshal.Wrap(err).GenWithStackByCause()
+	}
+	resp, err := commitTxn(c, key, func(txn clientv3.Txn) clientv3.Txn {
+		return txn.If(clientv3.Compare(clientv3.ModRevision(key), "=", expectedModRev)).
+			Then(clientv3.OpPut(key, string(value))).
+			Else(clientv3.OpGet(key))
+	})
+	if err != nil {
+		return false, 0, err
+	}
+	if resp.Succeeded {
+		// The ModRevision of the put key is the revision of the transaction.
+		return true, resp.Header.Revision, nil
+	}
+	var currentModRev int64
+	if kvs := resp.Responses[0].GetResponseRange().GetKvs(); len(kvs) > 0 {
+		currentModRev = kvs[0].ModRevision
+	}
+	return false, currentModRev, nil
+}
+
 // EtcdKVPutWithTTL put (key, value) into etcd with a ttl of ttlSeconds
 func EtcdKVPutWithTTL(ctx context.Context, c *clientv3.Client, key string, value string, ttlSeconds int64) (*clientv3.PutResponse, error) {
 	kv := clientv3.NewKV(c)
@@ -193,7 +692,218 @@ func EtcdKVPutWithTTL(ctx context.Context, c *clientv3.Client, key string, value
 	if err != nil {
 		return nil, err
 	}
-	return kv.Put(ctx, key, value, clientv3.WithLease(grantResp.ID))
+	start := time.Now()
+	resp, err := kv.Put(ctx, key, value, clientv3.WithLease(grantResp.ID))
+	cost := time.Since(start)
+	observeRequestLatency(cost)
+	if reportSlowRequest("put", key, cost) {
+		log.Warn("kv puts too slow", zapKey("request-key", []byte(key)), zap.Duration("cost", cost), errs.ZapError(err))
+	}
+	return resp, err
+}
+
+// EtcdKVPutWithLease put (key, value) into etcd attached to the given lease, which is usually created once
+// by GrantLeaseWithKeepAlive and shared by many puts, e.g., by a heartbeat-style writer.
+func EtcdKVPutWithLease(ctx context.Context, c *clientv3.Client, key string, value string, lease clientv3.LeaseID) (*clientv3.PutResponse, error) {
+	start := time.Now()
+	resp, err := clientv3.NewKV(c).Put(ctx, key, value, clientv3.WithLease(lease))
+	cost := time.Since(start)
+	observeRequestLatency(cost)
+	if reportSlowRequest("put", key, cost) {
+		log.Warn("kv puts too slow", zapKey("request-key", []byte(key)), zap.Duration("cost", cost), errs.ZapError(err))
+	}
+	if err != nil {
+		return resp, errs.ErrEtcdKVPut.Wrap(err).GenWithStackByCause()
+	}
+	return resp, nil
+}
+
+// GrantLeaseWithKeepAlive grants a lease with a ttl of ttlSeconds and keeps it alive in the background
+// until the given context is done, so its ID can be reused across many puts by EtcdKVPutWithLease.
+// The caller owns the lease: after canceling the context, the lease is not revoked but expires after
+// its ttl, the caller should call Revoke of the client to delete the attached keys at once.
+func GrantLeaseWithKeepAlive(ctx context.Context, c *clientv3.Client, ttlSeconds int64) (clientv3.LeaseID, error) {
+	grantCtx, cancel := context.WithTimeout(ctx, DefaultRequestTimeout)
+	grantResp, err := c.Grant(grantCtx, ttlSeconds)
+	cancel()
+	if err != nil {
+		return clientv3.NoLease, errs.ErrEtcdGrantLease.Wrap(err).GenWithStackByCause()
+	}
+	ch, err := c.KeepAlive(ctx, grantResp.ID)
+	if err != nil {
+		return clientv3.NoLease, errs.ErrEtcdGrantLease.Wrap(err).GenWithStackByCause()
+	}
+	go func() {
+		defer logutil.LogPanic()
+		// The channel is closed once the context is done or the lease can't be kept alive anymore,
+		// the responses must be consumed, otherwise the keepalive queue is full.
+		for range ch {
+		}
+		log.Info("stop keeping the lease alive", zap.Int64("lease-id", int64(grantResp.ID)))
+	}()
+	return grantResp.ID, nil
+}
+
+// GetValueWithTTL gets value with key from etcd together with the remaining TTL in seconds of its lease,
+// e.g., the key put by EtcdKVPutWithTTL. The TTL is -1 if the key has no lease or doesn't exist.
+func GetValueWithTTL(c *clientv3.Client, key string) (value []byte, ttlSeconds int64, err error) {
+	resp, err := get(c, key)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp == nil {
+		return nil, -1, nil
+	}
+	kv := resp.Kvs[0]
+	if kv.Lease == 0 {
+		return kv.Value, -1, nil
+	}
+	ctx, cancel := context.WithTimeout(c.Ctx(), requestTimeout())
+	defer cancel()
+	start := time.Now()
+	// The TTL is -1 if the lease has expired after the key is read.
+	ttlResp, err := c.TimeToLive(ctx, clientv3.LeaseID(kv.Lease))
+	cost := time.Since(start)
+	observeRequestLatency(cost)
+	if reportSlowRequest("ttl", key, cost) {
+		log.Warn("kv ttl gets too slow", zapKey("request-key", []byte(key)), zap.Duration("cost", cost), errs.ZapError(err))
+	}
+	if err != nil {
+		return nil, 0, errs.ErrEtcdKVGet.Wrap(err).GenWithStackByCause()
+	}
+	return kv.Value, ttlResp.TTL, nil
+}
+
+// maxIncrementRetryTimes is the max retry times of EtcdKVIncrement when the transaction conflicts.
+const maxIncrementRetryTimes = 32
+
+// EtcdKVIncrement atomically adds delta to the uint64-encoded counter stored in the given key
+// and returns the new value. A missing key is treated as zero. The increment is done with a CAS
+// transaction and will be retried on conflict up to maxIncrementRetryTimes.
+func EtcdKVIncrement(c *clientv3.Client, key string, delta int64) (int64, error) {
+	for i := 0; i < maxIncrementRetryTimes; i++ {
+		resp, err := EtcdKVGet(c, key)
+		if err != nil {
+			return 0, err
+		}
+		var (
+			current uint64
+			modRev  int64
+		)
+		if len(resp.Kvs) > 0 {
+			current, err = typeutil.BytesToUint64(resp.Kvs[0].Value)
+			if err != nil {
+				return 0, err
+			}
+			modRev = resp.Kvs[0].ModRevision
+		}
+		next := int64(current) + delta
+		if current > math.MaxInt64 || next < 0 {
+			return 0, errs.ErrEtcdKVIncrement.FastGenByArgs(current, delta)
+		}
+
+		// The key is not modified since read if its mod revision is not changed,
+		// and the mod revision of a missing key is 0.
+		txnResp, err := commitTxn(c, key, func(txn clientv3.Txn) clientv3.Txn {
+			return txn.If(clientv3.Compare(clientv3.ModRevision(key), "=", modRev)).
+				Then(clientv3.OpPut(key, string(typeutil.Uint64ToBytes(uint64(next)))))
+		})
+		if err != nil {
+			return 0, err
+		}
+		if txnResp.Succeeded {
+			return next, nil
+		}
+	}
+	return 0, errs.ErrEtcdTxnConflict.FastGenByArgs()
+}
+
+// DefaultMaxTxnOps is the max number of operations in an etcd transaction by default.
+const DefaultMaxTxnOps = 128
+
+// BatchTxnOption is used to configure BatchTxn.
+type BatchTxnOption func(*batchTxnConfig)
+
+type batchTxnConfig struct {
+	atomic bool
+}
+
+// WithBatchTxnAtomic makes BatchTxn apply the transactions atomically in a best-effort way. Every
+// transaction only commits if none of the keys it puts or deletes has been modified by others since
+// BatchTxn starts, otherwise BatchTxn stops with ErrEtcdTxnConflict. Note that the transactions
+// committed before are not rolled back, and the range operations can't be guarded, so it only
+// prevents the batch from overwriting the concurrent modifications instead of making it atomic.
+// The operations on the same key should not be split into different transactions in this mode.
+func WithBatchTxnAtomic() BatchTxnOption {
+	return func(cfg *batchTxnConfig) { cfg.atomic = true }
+}
+
+// BatchTxn commits the operations in multiple transactions with at most maxOpsPerTxn operations each,
+// because etcd limits the number of operations in a transaction, which is DefaultMaxTxnOps by default.
+// DefaultMaxTxnOps is used if maxOpsPerTxn is not positive. The transactions are committed one by one
+// and the first error is returned, the operations in the transactions committed before are applied.
+func BatchTxn(c *clientv3.Client, ops []clientv3.Op, maxOpsPerTxn int, opts ...BatchTxnOption) error {
+	cfg := &batchTxnConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if maxOpsPerTxn <= 0 {
+		maxOpsPerTxn = DefaultMaxTxnOps
+	}
+	if len(ops) == 0 {
+		return nil
+	}
+	var startRev int64
+	if cfg.atomic {
+		// Any read returns the current revision in its header.
+		resp, err := EtcdKVGet(c, string(ops[0].KeyBytes()), clientv3.WithCountOnly())
+		if err != nil {
+			return err
+		}
+		startRev = resp.Header.Revision
+	}
+	for i, start := 0, 0; start < len(ops); i, start = i+1, start+maxOpsPerTxn {
+		end := start + maxOpsPerTxn
+		if end > len(ops) {
+			end = len(ops)
+		}
+		failpoint.Inject("delayBatchTxn", func(val failpoint.Value) {
+			if sleepIntervalMilliseconds, ok := val.(int); ok && i > 0 {
+				time.Sleep(time.Duration(sleepIntervalMilliseconds) * time.Millisecond)
+			}
+		})
+		chunk := ops[start:end]
+		var cmps []clientv3.Cmp
+		if cfg.atomic {
+			cmps = make([]clientv3.Cmp, 0, len(chunk))
+			for _, op := range chunk {
+				if (op.IsPut() || op.IsDelete()) && len(op.RangeBytes()) == 0 {
+					cmps = append(cmps, clientv3.Compare(clientv3.ModRevision(string(op.KeyBytes())), "<", startRev+1))
+				}
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(c.Ctx(), requestTimeout())
+		begin := time.Now()
+		resp, err := c.Txn(ctx).If(cmps...).Then(chunk...).Commit()
+		cost := time.Since(begin)
+		cancel()
+		observeRequestLatency(cost)
+		if reportSlowRequest("txn", string(chunk[0].KeyBytes()), cost) {
+			log.Warn("kv txn too slow", zap.Int("txn-index", i), zap.Int("ops", len(chunk)),
+				zap.Duration("cost", cost), errs.ZapError(err))
+		}
+		if err != nil {
+			e := errs.ErrEtcdTxnInternal.Wrap(err).GenWithStackByCause()
+			log.Error("commit batch txn meet error", zap.Int("txn-index", i), zap.Int("ops", len(chunk)),
+				errs.ZapError(e))
+			return e
+		}
+		if !resp.Succeeded {
+			return errs.ErrEtcdTxnConflict.FastGenByArgs()
+		}
+	}
+	return nil
 }
 
 // CreateClients creates etcd v3 client and http client.
@@ -206,10 +916,11 @@ func CreateClients(tlsConfig *tls.Config, acUrls url.URL) (*clientv3.Client, *ht
 	return client, httpClient, nil
 }
 
-// createEtcdClientWithMultiEndpoint creates etcd v3 client.
+// CreateEtcdClientWithMultiEndpoint creates etcd v3 client.
 // Note: it will be used by micro service server and support multi etcd endpoints.
-// FIXME: But it cannot switch etcd endpoints as soon as possible when one of endpoints is with io hang.
-func createEtcdClientWithMultiEndpoint(tlsConfig *tls.Config, acUrls []url.URL) (*clientv3.Client, error) {
+// A health checker probes the endpoints periodically and puts the healthy ones first in the client,
+// so it can switch etcd endpoints as soon as possible when one of endpoints is with io hang.
+func CreateEtcdClientWithMultiEndpoint(tlsConfig *tls.Config, acUrls []url.URL, opts ...HealthCheckOption) (*clientv3.Client, error) {
 	if len(acUrls) == 0 {
 		return nil, errs.ErrNewEtcdClient.FastGenByArgs("no available etcd address")
 	}
@@ -222,6 +933,7 @@ func createEtcdClientWithMultiEndpoint(tlsConfig *tls.Config, acUrls []url.URL)
 	autoSyncInterval := defaultAutoSyncInterval
 	dialKeepAliveTime := defaultDialKeepAliveTime
 	dialKeepAliveTimeout := defaultDialKeepAliveTimeout
+	enableHealthCheck := true
 	failpoint.Inject("autoSyncInterval", func() {
 		autoSyncInterval = 10 * time.Millisecond
 	})
@@ -229,6 +941,7 @@ func createEtcdClientWithMultiEndpoint(tlsConfig *tls.Config, acUrls []url.URL)
 		autoSyncInterval = 0
 		dialKeepAliveTime = 0
 		dialKeepAliveTimeout = 0
+		enableHealthCheck = false
 	})
 	client, err := clientv3.New(clientv3.Config{
 		Endpoints:            endpoints,
@@ -241,6 +954,9 @@ func createEtcdClientWithMultiEndpoint(tlsConfig *tls.Config, acUrls []url.URL)
 	})
 	if err == nil {
 		log.Info("create etcd v3 client", zap.Strings("endpoints", endpoints))
+		if enableHealthCheck {
+			go newHealthChecker(client, opts...).run()
+		}
 	}
 	return client, err
 }
@@ -276,14 +992,20 @@ func createHTTPClient(tlsConfig *tls.Config) *http.Client {
 // cheaper read to retrieve it; if it doesn't exist, invoke the more expensive
 // operation InitOrGetClusterID().
 func InitClusterID(c *clientv3.Client, key string) (clusterID uint64, err error) {
+	return InitClusterIDWithGenerator(c, key, DefaultClusterIDGenerator)
+}
+
+// InitClusterIDWithGenerator is the same as InitClusterID, except that the cluster ID is
+// generated by the given generator if it hasn't existed.
+func InitClusterIDWithGenerator(c *clientv3.Client, key string, gen ClusterIDGenerator) (clusterID uint64, err error) {
 	// Get any cluster key to parse the cluster ID.
 	resp, err := EtcdKVGet(c, key)
 	if err != nil {
 		return 0, err
 	}
-	// If no key exist, generate a random cluster ID.
+	// If no key exist, generate a new cluster ID.
 	if len(resp.Kvs) == 0 {
-		return InitOrGetClusterID(c, key)
+		return InitOrGetClusterIDWithGenerator(c, key, gen)
 	}
 	return typeutil.BytesToUint64(resp.Kvs[0].Value)
 }
@@ -302,80 +1024,164 @@ func GetClusterID(c *clientv3.Client, key string) (clusterID uint64, err error)
 	return typeutil.BytesToUint64(resp.Kvs[0].Value)
 }
 
+// ClusterIDGenerator generates a new cluster ID, which must not be 0.
+type ClusterIDGenerator func() (uint64, error)
+
+// DefaultClusterIDGenerator generates the cluster ID by combining the current unix time in
+// seconds as the higher 32 bits and a random number as the lower 32 bits.
+func DefaultClusterIDGenerator() (uint64, error) {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	ts := uint64(time.Now().Unix())
+	return (ts << 32) + uint64(r.Uint32()), nil
+}
+
 // InitOrGetClusterID creates a cluster ID for the given key with a CAS operation,
 // if the cluster ID doesn't exist.
 func InitOrGetClusterID(c *clientv3.Client, key string) (uint64, error) {
-	ctx, cancel := context.WithTimeout(c.Ctx(), DefaultRequestTimeout)
-	defer cancel()
+	return InitOrGetClusterIDWithGenerator(c, key, DefaultClusterIDGenerator)
+}
 
-	// Generate a random cluster ID.
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-	ts := uint64(time.Now().Unix())
-	clusterID := (ts << 32) + uint64(r.Uint32())
+// InitOrGetClusterIDWithGenerator creates a cluster ID generated by the given generator for
+// the given key with a CAS operation, if the cluster ID doesn't exist. It allows the deployments
+// to use their own scheme, e.g., derived from a UUID or assigned by a coordinator.
+func InitOrGetClusterIDWithGenerator(c *clientv3.Client, key string, gen ClusterIDGenerator) (uint64, error) {
+	clusterID, err := gen()
+	if err != nil {
+		return 0, err
+	}
+	if clusterID == 0 {
+		return 0, errors.New("the generated cluster id is 0")
+	}
 	value := typeutil.Uint64ToBytes(clusterID)
 
 	// Multiple servers may try to init the cluster ID at the same time.
 	// Only one server can commit this transaction, then other servers
 	// can get the committed cluster ID.
-	resp, err := c.Txn(ctx).
-		If(clientv3.Compare(clientv3.CreateRevision(key), "=", 0)).
-		Then(clientv3.OpPut(key, string(value))).
-		Else(clientv3.OpGet(key)).
-		Commit()
+	ok, existing, err := EtcdKVPutIfAbsent(c, key, string(value))
 	if err != nil {
-		return 0, errs.ErrEtcdTxnInternal.Wrap(err).GenWithStackByCause()
+		return 0, err
 	}
-
 	// Txn commits ok, return the generated cluster ID.
-	if resp.Succeeded {
+	if ok {
 		return clusterID, nil
 	}
-
 	// Otherwise, parse the committed cluster ID.
-	if len(resp.Responses) == 0 {
-		return 0, errs.ErrEtcdTxnConflict.FastGenByArgs()
+	return typeutil.BytesToUint64([]byte(existing))
+}
+
+// EtcdKVCompareAndSwap sets the key to newValue only if its current value is expectedValue,
+// and returns whether the swap succeeded. A missing key never matches the expected value,
+// use EtcdKVPutIfAbsent to create it.
+func EtcdKVCompareAndSwap(c *clientv3.Client, key, expectedValue, newValue string) (bool, error) {
+	resp, err := commitTxn(c, key, func(txn clientv3.Txn) clientv3.Txn {
+		return txn.If(clientv3.Compare(clientv3.Value(key), "=", expectedValue)).
+			Then(clientv3.OpPut(key, newValue))
+	})
+	if err != nil {
+		return false, err
+	}
+	return resp.Succeeded, nil
+}
+
+// EtcdKVPutIfAbsent puts the key with the value only if it doesn't exist, and returns whether
+// the put succeeded. The existing value is returned if the key already exists.
+func EtcdKVPutIfAbsent(c *clientv3.Client, key, value string) (ok bool, existing string, err error) {
+	resp, err := commitTxn(c, key, func(txn clientv3.Txn) clientv3.Txn {
+		return txn.If(clientv3.Compare(clientv3.CreateRevision(key), "=", 0)).
+			Then(clientv3.OpPut(key, value)).
+			Else(clientv3.OpGet(key))
+	})
+	if err != nil {
+		return false, "", err
+	}
+	if resp.Succeeded {
+		return true, "", nil
 	}
 
+	if len(resp.Responses) == 0 {
+		return false, "", errs.ErrEtcdTxnConflict.FastGenByArgs()
+	}
 	response := resp.Responses[0].GetResponseRange()
 	if response == nil || len(response.Kvs) != 1 {
-		return 0, errs.ErrEtcdTxnConflict.FastGenByArgs()
+		return false, "", errs.ErrEtcdTxnConflict.FastGenByArgs()
 	}
-
-	return typeutil.BytesToUint64(response.Kvs[0].Value)
+	return false, string(response.Kvs[0].Value), nil
 }
 
 const (
-	defaultLoadDataFromEtcdTimeout   = 30 * time.Second
-	defaultLoadFromEtcdRetryInterval = 200 * time.Millisecond
-	defaultLoadFromEtcdRetryTimes    = int(defaultLoadDataFromEtcdTimeout / defaultLoadFromEtcdRetryInterval)
-	defaultLoadBatchSize             = 400
-	defaultWatchChangeRetryInterval  = 1 * time.Second
-	defaultForceLoadMinimalInterval  = 200 * time.Millisecond
+	defaultLoadDataFromEtcdTimeout      = 30 * time.Second
+	defaultLoadFromEtcdRetryInterval    = 200 * time.Millisecond
+	defaultLoadFromEtcdMaxRetryInterval = 3 * time.Second
+	defaultLoadFromEtcdRetryTimes       = int(defaultLoadDataFromEtcdTimeout / defaultLoadFromEtcdRetryInterval)
+	defaultLoadBatchSize                = 400
+	defaultWatchChangeRetryInterval     = 1 * time.Second
+	defaultWatchChangeMaxRetryInterval  = 3 * time.Second
+	defaultWatchRetryResetThreshold     = 10 * time.Second
+	defaultForceLoadMinimalInterval     = 200 * time.Millisecond
 )
 
+// watchRetryJitterRatio is the max ratio of the jitter added to the backoff between two watch retries.
+const watchRetryJitterRatio = 0.1
+
+// errWatchChanClosed is returned when the watch channel is closed without an error response.
+var errWatchChanClosed = errors.New("watch channel is closed")
+
+// LoopWatchTarget is a key watched by the LoopWatcher together with its own event handlers.
+type LoopWatchTarget struct {
+	// Key is the etcd key to watch.
+	Key string
+	// Opts is used to set etcd options, e.g., clientv3.WithPrefix().
+	Opts []clientv3.OpOption
+	// PutFn is used to handle the put event.
+	PutFn func(*mvccpb.KeyValue) error
+	// BatchPutFn is used to handle the keys loaded from etcd page by page instead of PutFn if it's set,
+	// so that the consumer can apply a whole page under a single lock. The events from the watch are
+	// still handled by PutFn.
+	BatchPutFn func([]*mvccpb.KeyValue) error
+	// DeleteFn is used to handle the delete event.
+	DeleteFn func(*mvccpb.KeyValue) error
+}
+
 // LoopWatcher loads data from etcd and sets a watcher for it.
 type LoopWatcher struct {
 	ctx    context.Context
+	cancel context.CancelFunc
 	wg     *sync.WaitGroup
 	name   string
 	client *clientv3.Client
 
-	// key is the etcd key to watch.
+	// started is used to indicate whether the watch loop has been started.
+	started atomic.Bool
+	// closed is used to indicate whether the watcher has been closed, a watch loop
+	// started after it's closed exits immediately.
+	closed atomic.Bool
+	// stoppedCh is closed when the watch loop exits.
+	stoppedCh chan struct{}
+
+	// targets are the keys to watch with their handlers.
+	targets []*LoopWatchTarget
+	// key is the etcd key to watch, the keys are joined by commas if there are multiple targets.
+	// It's only used for logging.
 	key string
-	// opts is used to set etcd options.
-	opts []clientv3.OpOption
 
 	// forceLoadCh is used to force loading data from etcd.
 	forceLoadCh chan struct{}
 	// isLoadedCh is used to notify that the data has been loaded from etcd first time.
 	isLoadedCh chan error
 
-	// putFn is used to handle the put event.
-	putFn func(*mvccpb.KeyValue) error
-	// deleteFn is used to handle the delete event.
-	deleteFn func(*mvccpb.KeyValue) error
 	// postEventFn is used to call after handling all events.
 	postEventFn func() error
+	// rawWatchObserver is used to observe every raw watch response before handling its events.
+	rawWatchObserver func(clientv3.WatchResponse)
+	// fatalOnPostEventError is used to stop the watch loop once postEventFn returns an error.
+	fatalOnPostEventError bool
+	// fatalErrCh is used to notify the error which stops the watch loop.
+	fatalErrCh chan error
+
+	// metrics is the metrics of the watcher labeled by its name.
+	metrics *loopWatcherMetrics
+	// lastSyncedRevision is the highest revision of the responses handled by load and watch.
+	lastSyncedRevision atomic.Int64
 
 	// forceLoadMu is used to ensure two force loads have minimal interval.
 	forceLoadMu sync.RWMutex
@@ -386,10 +1192,28 @@ type LoopWatcher struct {
 	loadTimeout time.Duration
 	// loadRetryTimes is used to set the retry times for loading data from etcd.
 	loadRetryTimes int
+	// loadRetryBaseInterval is the backoff before the first retry of loading data from etcd,
+	// it will be doubled after each failed retry until reaching loadRetryMaxInterval.
+	loadRetryBaseInterval time.Duration
+	// loadRetryMaxInterval is the upper bound of the backoff between two load retries.
+	loadRetryMaxInterval time.Duration
 	// loadBatchSize is used to set the batch size for loading data from etcd.
 	loadBatchSize int64
-	// watchChangeRetryInterval is used to set the retry interval for watching etcd change.
+	// minStartRevision is the lower bound of the revision to start watching from.
+	minStartRevision int64
+	// watchChangeRetryInterval is the backoff before the first retry of watching etcd change,
+	// it will be doubled after each consecutive failure until reaching watchChangeMaxRetryInterval.
 	watchChangeRetryInterval time.Duration
+	// watchChangeMaxRetryInterval is the upper bound of the backoff between two watch retries.
+	watchChangeMaxRetryInterval time.Duration
+	// watchRetryResetThreshold is how long a watch should run before failing to be regarded as
+	// healthy, the backoff starts over from watchChangeRetryInterval after such a watch.
+	watchRetryResetThreshold time.Duration
+	// watchFailures is the number of consecutive failed watches, it's only accessed in the watch loop.
+	watchFailures int
+	// watchIdleTimeout is how long the watch can receive neither events nor progress notifications
+	// before it's regarded as stalled and re-established. 0 means the watchdog is disabled.
+	watchIdleTimeout time.Duration
 	// updateClientCh is used to update the etcd client.
 	// It's only used for testing.
 	updateClientCh chan *clientv3.Client
@@ -398,24 +1222,54 @@ type LoopWatcher struct {
 // NewLoopWatcher creates a new LoopWatcher.
 func NewLoopWatcher(ctx context.Context, wg *sync.WaitGroup, client *clientv3.Client, name, key string,
 	putFn, deleteFn func(*mvccpb.KeyValue) error, postEventFn func() error, opts ...clientv3.OpOption) *LoopWatcher {
+	return NewMultiLoopWatcher(ctx, wg, client, name, []LoopWatchTarget{{
+		Key:      key,
+		Opts:     opts,
+		PutFn:    putFn,
+		DeleteFn: deleteFn,
+	}}, postEventFn)
+}
+
+// NewMultiLoopWatcher creates a new LoopWatcher which watches multiple keys in a single watch loop.
+// All the keys are watched by the same etcd watcher with the same context, so the etcd client
+// multiplexes them over one watch stream instead of one goroutine and stream per key. Each event
+// is dispatched to the handlers of the target it's watched by, and postEventFn is called after
+// handling the events of every watch response. The first load is regarded as finished only after
+// all the targets are loaded, and ForceLoad reloads all of them.
+func NewMultiLoopWatcher(ctx context.Context, wg *sync.WaitGroup, client *clientv3.Client, name string,
+	targets []LoopWatchTarget, postEventFn func() error) *LoopWatcher {
+	ctx, cancel := context.WithCancel(ctx)
+	lwTargets := make([]*LoopWatchTarget, 0, len(targets))
+	keys := make([]string, 0, len(targets))
+	for i := range targets {
+		target := targets[i]
+		lwTargets = append(lwTargets, &target)
+		keys = append(keys, target.Key)
+	}
 	return &LoopWatcher{
-		ctx:                      ctx,
-		client:                   client,
-		name:                     name,
-		key:                      key,
-		wg:                       wg,
-		forceLoadCh:              make(chan struct{}, 1),
-		isLoadedCh:               make(chan error, 1),
-		updateClientCh:           make(chan *clientv3.Client, 1),
-		putFn:                    putFn,
-		deleteFn:                 deleteFn,
-		postEventFn:              postEventFn,
-		opts:                     opts,
-		lastTimeForceLoad:        time.Now(),
-		loadTimeout:              defaultLoadDataFromEtcdTimeout,
-		loadRetryTimes:           defaultLoadFromEtcdRetryTimes,
-		loadBatchSize:            defaultLoadBatchSize,
-		watchChangeRetryInterval: defaultWatchChangeRetryInterval,
+		ctx:                         ctx,
+		cancel:                      cancel,
+		stoppedCh:                   make(chan struct{}),
+		client:                      client,
+		name:                        name,
+		targets:                     lwTargets,
+		key:                         strings.Join(keys, ","),
+		metrics:                     newLoopWatcherMetrics(name),
+		wg:                          wg,
+		forceLoadCh:                 make(chan struct{}, 1),
+		isLoadedCh:                  make(chan error, 1),
+		fatalErrCh:                  make(chan error, 1),
+		updateClientCh:              make(chan *clientv3.Client, 1),
+		postEventFn:                 postEventFn,
+		lastTimeForceLoad:           time.Now(),
+		loadTimeout:                 defaultLoadDataFromEtcdTimeout,
+		loadRetryTimes:              defaultLoadFromEtcdRetryTimes,
+		loadRetryBaseInterval:       defaultLoadFromEtcdRetryInterval,
+		loadRetryMaxInterval:        defaultLoadFromEtcdMaxRetryInterval,
+		loadBatchSize:               defaultLoadBatchSize,
+		watchChangeRetryInterval:    defaultWatchChangeRetryInterval,
+		watchChangeMaxRetryInterval: defaultWatchChangeMaxRetryInterval,
+		watchRetryResetThreshold:    defaultWatchRetryResetThreshold,
 	}
 }
 
@@ -423,10 +1277,18 @@ func NewLoopWatcher(ctx context.Context, wg *sync.WaitGroup, client *clientv3.Cl
 func (lw *LoopWatcher) StartWatchLoop() {
 	defer logutil.LogPanic()
 	defer lw.wg.Done()
+	lw.started.Store(true)
+	defer close(lw.stoppedCh)
+	if lw.closed.Load() {
+		return
+	}
 
 	ctx, cancel := context.WithCancel(lw.ctx)
 	defer cancel()
-	watchStartRevision := lw.initFromEtcd(ctx)
+	watchStartRevisions, err := lw.initFromEtcd(ctx)
+	if lw.stopOnFatalError(err) {
+		return
+	}
 
 	log.Info("start to watch loop", zap.String("name", lw.name), zap.String("key", lw.key))
 	for {
@@ -436,16 +1298,29 @@ func (lw *LoopWatcher) StartWatchLoop() {
 			return
 		default:
 		}
-		nextRevision, err := lw.watch(ctx, watchStartRevision)
+		nextRevisions, err := lw.watch(ctx, watchStartRevisions)
+		if lw.stopOnFatalError(err) {
+			return
+		}
 		if err != nil {
+			retryInterval := lw.nextWatchRetryInterval()
 			log.Error("watcher canceled unexpectedly and a new watcher will start after a while for watch loop",
 				zap.String("name", lw.name),
 				zap.String("key", lw.key),
-				zap.Int64("next-revision", nextRevision),
-				zap.Time("retry-at", time.Now().Add(lw.watchChangeRetryInterval)),
+				zap.Int64s("next-revisions", nextRevisions),
+				zap.Int("consecutive-failures", lw.watchFailures),
+				zap.Time("retry-at", time.Now().Add(retryInterval)),
 				zap.Error(err))
-			watchStartRevision = nextRevision
-			time.Sleep(lw.watchChangeRetryInterval)
+			watchStartRevisions = nextRevisions
+			timer := time.NewTimer(retryInterval)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				log.Info("server is closed, exit watch loop", zap.String("name", lw.name), zap.String("key", lw.key))
+				return
+			case <-timer.C:
+			}
+			lw.metrics.watchRetryCounter.Inc()
 			failpoint.Inject("updateClient", func() {
 				lw.client = <-lw.updateClientCh
 			})
@@ -453,17 +1328,62 @@ func (lw *LoopWatcher) StartWatchLoop() {
 	}
 }
 
-func (lw *LoopWatcher) initFromEtcd(ctx context.Context) int64 {
+// postEventError is the error returned by postEventFn when fatalOnPostEventError is set.
+type postEventError struct {
+	error
+}
+
+// stopOnFatalError returns true if the error should stop the watch loop, the error will be
+// delivered to the fatal error channel in this case.
+func (lw *LoopWatcher) stopOnFatalError(err error) bool {
+	postEventErr, ok := err.(*postEventError)
+	if !ok {
+		return false
+	}
+	log.Error("watch loop is stopped due to the post event error", zap.String("name", lw.name),
+		zap.String("key", lw.key), zap.Error(postEventErr.error))
+	select {
+	case lw.fatalErrCh <- postEventErr.error:
+	default:
+	}
+	return true
+}
+
+func (lw *LoopWatcher) runPostEventFn() error {
+	err := lw.postEventFn()
+	if err == nil {
+		return nil
+	}
+	log.Error("run post event failed in watch loop", zap.String("name", lw.name),
+		zap.String("key", lw.key), zap.Error(err))
+	if lw.fatalOnPostEventError {
+		return &postEventError{err}
+	}
+	return nil
+}
+
+func (lw *LoopWatcher) initFromEtcd(ctx context.Context) ([]int64, error) {
 	var (
-		watchStartRevision int64
-		err                error
+		watchStartRevisions = make([]int64, len(lw.targets))
+		err                 error
 	)
-	ticker := time.NewTicker(defaultLoadFromEtcdRetryInterval)
-	defer ticker.Stop()
 	ctx, cancel := context.WithTimeout(ctx, lw.loadTimeout)
 	defer cancel()
 
+	backoff := lw.loadRetryBaseInterval
 	for i := 0; i < lw.loadRetryTimes; i++ {
+		if i > 0 {
+			timer := time.NewTimer(backoff)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				lw.isLoadedCh <- errors.Errorf("ctx is done before load data from etcd")
+				return watchStartRevisions, nil
+			case <-timer.C:
+			}
+			backoff = lw.nextLoadRetryInterval(backoff)
+			lw.metrics.loadRetryCounter.Inc()
+		}
 		failpoint.Inject("loadTemporaryFail", func(val failpoint.Value) {
 			if maxFailTimes, ok := val.(int); ok && i < maxFailTimes {
 				err = errors.New("fail to read from etcd")
@@ -475,15 +1395,13 @@ func (lw *LoopWatcher) initFromEtcd(ctx context.Context) int64 {
 				time.Sleep(time.Duration(sleepIntervalSeconds) * time.Second)
 			}
 		})
-		watchStartRevision, err = lw.load(ctx)
+		watchStartRevisions, err = lw.load(ctx)
 		if err == nil {
 			break
 		}
-		select {
-		case <-ctx.Done():
-			lw.isLoadedCh <- errors.Errorf("ctx is done before load data from etcd")
-			return watchStartRevision
-		case <-ticker.C:
+		if postEventErr, ok := err.(*postEventError); ok {
+			lw.isLoadedCh <- postEventErr.error
+			return watchStartRevisions, err
 		}
 	}
 	if err != nil {
@@ -492,10 +1410,23 @@ func (lw *LoopWatcher) initFromEtcd(ctx context.Context) int64 {
 		log.Info("load finished in watch loop", zap.String("name", lw.name), zap.String("key", lw.key))
 	}
 	lw.isLoadedCh <- err
-	return watchStartRevision
+	return watchStartRevisions, nil
 }
 
-func (lw *LoopWatcher) watch(ctx context.Context, revision int64) (nextRevision int64, err error) {
+// watch watches all the targets from their own revisions. The revisions are tracked per target,
+// so restarting the watches never skips the events of a target whose responses are not handled yet.
+func (lw *LoopWatcher) watch(ctx context.Context, revisions []int64) (nextRevisions []int64, err error) {
+	start := time.Now()
+	defer func() {
+		if err == nil {
+			return
+		}
+		// The backoff starts over if the watch has run long enough before failing.
+		if time.Since(start) >= lw.watchRetryResetThreshold {
+			lw.watchFailures = 0
+		}
+		lw.watchFailures++
+	}()
 	watcher := clientv3.NewWatcher(lw.client)
 	defer watcher.Close()
 
@@ -505,107 +1436,231 @@ func (lw *LoopWatcher) watch(ctx context.Context, revision int64) (nextRevision
 		// make sure to wrap context with "WithRequireLeader".
 		watchChanCtx, watchChanCancel := context.WithCancel(clientv3.WithRequireLeader(ctx))
 		defer watchChanCancel()
-		opts := append(lw.opts, clientv3.WithRev(revision))
-		watchChan := watcher.Watch(watchChanCtx, lw.key, opts...)
-		select {
-		case <-ctx.Done():
-			return revision, nil
-		case <-lw.forceLoadCh:
-			revision, err = lw.load(ctx)
+		// The watches sharing the same context are multiplexed over one watch stream by the etcd client.
+		cases := make([]reflect.SelectCase, 0, len(lw.targets)+2)
+		cases = append(cases,
+			reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+			reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(lw.forceLoadCh)})
+		for i, target := range lw.targets {
+			opts := append(target.Opts, clientv3.WithRev(revisions[i]))
+			if lw.watchIdleTimeout > 0 {
+				// Ask the server to notify the progress periodically, so an idle but healthy watch
+				// can be told apart from a stalled one.
+				opts = append(opts, clientv3.WithProgressNotify())
+			}
+			watchChan := watcher.Watch(watchChanCtx, target.Key, opts...)
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(watchChan)})
+		}
+		failpoint.Inject("closeWatchChan", func() {
+			// Canceling the context closes the watch channel without an error response.
+			watchChanCancel()
+		})
+		failpoint.Inject("stallWatchChan", func() {
+			// Replace the watch channels with the ones never receiving anything.
+			for i := 2; i < len(cases); i++ {
+				cases[i].Chan = reflect.ValueOf(make(chan clientv3.WatchResponse))
+			}
+		})
+		idleCase := -1
+		var idleTimer *time.Timer
+		if lw.watchIdleTimeout > 0 {
+			idleTimer = time.NewTimer(lw.watchIdleTimeout)
+			idleCase = len(cases)
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(idleTimer.C)})
+		}
+		chosen, recv, ok := reflect.Select(cases)
+		if idleTimer != nil {
+			idleTimer.Stop()
+		}
+		switch chosen {
+		case idleCase:
+			log.Warn("watch stream is idle for too long, re-establish it in watch loop",
+				zap.String("name", lw.name), zap.String("key", lw.key),
+				zap.Int64s("revisions", revisions), zap.Duration("idle-timeout", lw.watchIdleTimeout))
+			lw.metrics.watchIdleCounter.Inc()
+			watchChanCancel()
+			goto WatchChan
+		case 0:
+			return revisions, nil
+		case 1:
+			revisions, err = lw.load(ctx)
+			if _, ok := err.(*postEventError); ok {
+				return revisions, err
+			}
 			if err != nil {
 				log.Warn("force load key failed in watch loop", zap.String("name", lw.name),
 					zap.String("key", lw.key), zap.Error(err))
 			}
 			watchChanCancel()
 			goto WatchChan
-		case wresp := <-watchChan:
-			if wresp.CompactRevision != 0 {
-				log.Warn("required revision has been compacted, use the compact revision in watch loop",
-					zap.Int64("required-revision", revision),
-					zap.Int64("compact-revision", wresp.CompactRevision))
-				revision = wresp.CompactRevision
-				watchChanCancel()
-				goto WatchChan
-			} else if wresp.Err() != nil { // wresp.Err() contains CompactRevision not equal to 0
-				log.Error("watcher is canceled in watch loop",
-					zap.Int64("revision", revision),
-					errs.ZapError(errs.ErrEtcdWatcherCancel, wresp.Err()))
-				return revision, wresp.Err()
-			}
-			for _, event := range wresp.Events {
-				switch event.Type {
-				case clientv3.EventTypePut:
-					if err := lw.putFn(event.Kv); err != nil {
-						log.Error("put failed in watch loop", zap.String("name", lw.name),
-							zap.String("key", lw.key), zap.Error(err))
-					} else {
-						log.Debug("put in watch loop", zap.String("name", lw.name),
-							zap.ByteString("key", event.Kv.Key),
-							zap.ByteString("value", event.Kv.Value))
-					}
-				case clientv3.EventTypeDelete:
-					if err := lw.deleteFn(event.Kv); err != nil {
-						log.Error("delete failed in watch loop", zap.String("name", lw.name),
-							zap.String("key", lw.key), zap.Error(err))
-					} else {
-						log.Debug("delete in watch loop", zap.String("name", lw.name),
-							zap.ByteString("key", event.Kv.Key))
-					}
+		}
+		idx := chosen - 2
+		target := lw.targets[idx]
+		if !ok {
+			// The watch channel may be closed without an error response, e.g., the client is closed.
+			// Return to restart the watcher after a while instead of spinning on the closed channel.
+			log.Warn("watch channel is closed in watch loop", zap.String("name", lw.name),
+				zap.String("key", target.Key), zap.Int64("revision", revisions[idx]))
+			return revisions, errWatchChanClosed
+		}
+		wresp := recv.Interface().(clientv3.WatchResponse)
+		if lw.rawWatchObserver != nil {
+			lw.rawWatchObserver(wresp)
+		}
+		if wresp.CompactRevision != 0 {
+			log.Warn("required revision has been compacted, use the compact revision in watch loop",
+				zap.String("key", target.Key),
+				zap.Int64("required-revision", revisions[idx]),
+				zap.Int64("compact-revision", wresp.CompactRevision))
+			revisions[idx] = wresp.CompactRevision
+			watchChanCancel()
+			goto WatchChan
+		} else if wresp.Err() != nil { // wresp.Err() contains CompactRevision not equal to 0
+			log.Error("watcher is canceled in watch loop",
+				zap.String("key", target.Key),
+				zap.Int64("revision", revisions[idx]),
+				errs.ZapError(errs.ErrEtcdWatcherCancel, wresp.Err()))
+			return revisions, wresp.Err()
+		}
+		if wresp.IsProgressNotify() {
+			// All the events before the header revision have been delivered.
+			revisions[idx] = wresp.Header.Revision + 1
+			lw.updateLastSyncedRevision(wresp.Header.Revision)
+			watchChanCancel()
+			continue
+		}
+		for _, event := range wresp.Events {
+			switch event.Type {
+			case clientv3.EventTypePut:
+				lw.metrics.putCounter.Inc()
+				if err := target.PutFn(event.Kv); err != nil {
+					log.Error("put failed in watch loop", zap.String("name", lw.name),
+						zap.String("key", target.Key), zap.Error(err))
+				} else {
+					log.Debug("put in watch loop", zap.String("name", lw.name),
+						zapKey("key", event.Kv.Key),
+						zapValue("value", event.Kv.Value))
+				}
+			case clientv3.EventTypeDelete:
+				lw.metrics.deleteCounter.Inc()
+				if err := target.DeleteFn(event.Kv); err != nil {
+					log.Error("delete failed in watch loop", zap.String("name", lw.name),
+						zap.String("key", target.Key), zap.Error(err))
+				} else {
+					log.Debug("delete in watch loop", zap.String("name", lw.name),
+						zapKey("key", event.Kv.Key))
 				}
 			}
-			if err := lw.postEventFn(); err != nil {
-				log.Error("run post event failed in watch loop", zap.String("name", lw.name),
-					zap.String("key", lw.key), zap.Error(err))
-			}
-			revision = wresp.Header.Revision + 1
+		}
+		revisions[idx] = wresp.Header.Revision + 1
+		lw.updateLastSyncedRevision(wresp.Header.Revision)
+		if err := lw.runPostEventFn(); err != nil {
+			return revisions, err
 		}
 		watchChanCancel()
 	}
 }
 
-func (lw *LoopWatcher) load(ctx context.Context) (nextRevision int64, err error) {
+// load loads all the targets one by one and returns the revision to start watching from for each
+// of them. postEventFn is called only once after all the targets are loaded.
+func (lw *LoopWatcher) load(ctx context.Context) (nextRevisions []int64, err error) {
 	ctx, cancel := context.WithTimeout(ctx, DefaultRequestTimeout)
 	defer cancel()
-	startKey := lw.key
+	start := time.Now()
+	defer func() {
+		lw.metrics.loadCounter.Inc()
+		lw.metrics.loadDuration.Observe(time.Since(start).Seconds())
+	}()
+	nextRevisions = make([]int64, len(lw.targets))
+	for i, target := range lw.targets {
+		nextRevisions[i], err = lw.loadTarget(ctx, target)
+		if err != nil {
+			return make([]int64, len(lw.targets)), err
+		}
+	}
+	if postEventErr := lw.runPostEventFn(); postEventErr != nil {
+		return nextRevisions, postEventErr
+	}
+	return nextRevisions, nil
+}
+
+func (lw *LoopWatcher) loadTarget(ctx context.Context, target *LoopWatchTarget) (nextRevision int64, err error) {
+	startKey := target.Key
 	// If limit is 0, it means no limit.
 	// If limit is not 0, we need to add 1 to limit to get the next key.
 	limit := lw.loadBatchSize
 	if limit != 0 {
 		limit++
 	}
+	// Keep the range end of the target key, otherwise the options like clientv3.WithPrefix()
+	// would compute it from the start key of each batch and miss the keys after it.
+	rangeEnd := clientv3.OpGet(target.Key, target.Opts...).RangeBytes()
 	for {
 		// Sort by key to get the next key and we don't need to worry about the performance,
 		// Because the default sort is just SortByKey and SortAscend
-		opts := append(lw.opts, clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend), clientv3.WithLimit(limit))
+		opts := append(target.Opts, clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend), clientv3.WithLimit(limit))
+		if len(rangeEnd) > 0 {
+			opts = append(opts, clientv3.WithRange(string(rangeEnd)))
+		}
 		resp, err := clientv3.NewKV(lw.client).Get(ctx, startKey, opts...)
 		if err != nil {
 			log.Error("load failed in watch loop", zap.String("name", lw.name),
-				zap.String("key", lw.key), zap.Error(err))
+				zap.String("key", target.Key), zap.Error(err))
 			return 0, err
 		}
-		for i, item := range resp.Kvs {
-			if resp.More && i == len(resp.Kvs)-1 {
-				// The last key is the start key of the next batch.
-				// To avoid to get the same key in the next load, we need to skip the last key.
-				startKey = string(item.Key)
-				continue
+		kvs := resp.Kvs
+		if resp.More && len(kvs) > 0 {
+			// The last key is the start key of the next batch.
+			// To avoid to get the same key in the next load, we need to skip the last key.
+			startKey = string(kvs[len(kvs)-1].Key)
+			kvs = kvs[:len(kvs)-1]
+		}
+		if target.BatchPutFn != nil {
+			if len(kvs) > 0 {
+				if err := target.BatchPutFn(kvs); err != nil {
+					log.Error("batch put failed in watch loop when loading", zap.String("name", lw.name),
+						zap.String("key", target.Key), zap.Int("count", len(kvs)), zap.Error(err))
+				}
 			}
-			err = lw.putFn(item)
-			if err != nil {
-				log.Error("put failed in watch loop when loading", zap.String("name", lw.name), zap.String("key", lw.key), zap.Error(err))
+		} else {
+			for _, item := range kvs {
+				if err := target.PutFn(item); err != nil {
+					log.Error("put failed in watch loop when loading", zap.String("name", lw.name), zap.String("key", target.Key), zap.Error(err))
+				}
 			}
 		}
 		// Note: if there are no keys in etcd, the resp.More is false. It also means the load is finished.
 		if !resp.More {
-			if err := lw.postEventFn(); err != nil {
-				log.Error("run post event failed in watch loop", zap.String("name", lw.name),
-					zap.String("key", lw.key), zap.Error(err))
+			lw.updateLastSyncedRevision(resp.Header.Revision)
+			nextRevision = resp.Header.Revision + 1
+			if nextRevision < lw.minStartRevision {
+				log.Info("use the min start revision to watch in watch loop", zap.String("name", lw.name),
+					zap.String("key", target.Key), zap.Int64("load-revision", nextRevision),
+					zap.Int64("min-start-revision", lw.minStartRevision))
+				nextRevision = lw.minStartRevision
 			}
-			return resp.Header.Revision + 1, err
+			return nextRevision, nil
+		}
+	}
+}
+
+// updateLastSyncedRevision updates the last synced revision if the given one is higher.
+func (lw *LoopWatcher) updateLastSyncedRevision(revision int64) {
+	for {
+		last := lw.lastSyncedRevision.Load()
+		if revision <= last || lw.lastSyncedRevision.CompareAndSwap(last, revision) {
+			return
 		}
 	}
 }
 
+// GetLastSyncedRevision returns the highest revision the watcher has synced to, i.e., the revision
+// of the latest loaded data or the latest handled watch response. It's 0 before the first load.
+// Comparing it with the current revision of the etcd cluster tells how far behind the watcher is.
+func (lw *LoopWatcher) GetLastSyncedRevision() int64 {
+	return lw.lastSyncedRevision.Load()
+}
+
 // ForceLoad forces to load the key.
 func (lw *LoopWatcher) ForceLoad() {
 	// When NotLeader error happens, a large volume of force load requests will be received here,
@@ -633,16 +1688,181 @@ func (lw *LoopWatcher) ForceLoad() {
 	}
 }
 
+// ReplayEvents replays the events of the watched key happened within the revision range
+// [fromRev, toRev] with a historical watch, and feeds them to the handler in order. It's
+// used for debugging and won't touch the state maintained by the putFn and deleteFn. If toRev
+// is greater than the current revision, it waits for the future events until ctx is done.
+// If there are multiple targets, their events are replayed one target after another.
+func (lw *LoopWatcher) ReplayEvents(ctx context.Context, fromRev, toRev int64, handler func(*clientv3.Event) error) error {
+	if fromRev <= 0 || toRev < fromRev {
+		return errors.Errorf("invalid revision range [%d, %d] to replay", fromRev, toRev)
+	}
+	watcher := clientv3.NewWatcher(lw.client)
+	defer watcher.Close()
+	for _, target := range lw.targets {
+		if err := replayTargetEvents(ctx, watcher, target, fromRev, toRev, handler); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func replayTargetEvents(ctx context.Context, watcher clientv3.Watcher, target *LoopWatchTarget,
+	fromRev, toRev int64, handler func(*clientv3.Event) error) error {
+	ctx, cancel := context.WithCancel(clientv3.WithRequireLeader(ctx))
+	defer cancel()
+
+	opts := append(target.Opts, clientv3.WithRev(fromRev))
+	watchChan := watcher.Watch(ctx, target.Key, opts...)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case wresp, ok := <-watchChan:
+			if !ok {
+				return errors.Errorf("watch channel is closed before replaying to revision %d", toRev)
+			}
+			if wresp.CompactRevision != 0 {
+				return errors.Errorf("required revision %d has been compacted, compact revision %d",
+					fromRev, wresp.CompactRevision)
+			}
+			if err := wresp.Err(); err != nil {
+				return errs.ErrEtcdWatcherCancel.Wrap(err).GenWithStackByCause()
+			}
+			for _, event := range wresp.Events {
+				if event.Kv.ModRevision > toRev {
+					return nil
+				}
+				if err := handler(event); err != nil {
+					return err
+				}
+			}
+			if wresp.Header.Revision >= toRev {
+				return nil
+			}
+		}
+	}
+}
+
+// Stop stops the watch loop and waits for it to exit, which is independent of the parent context.
+// No more events will be handled after it returns. It's the same as Close.
+func (lw *LoopWatcher) Stop() {
+	lw.Close()
+}
+
+// Close cancels the watch loop and returns once it has exited. The batch of putFn/deleteFn
+// calls being handled and its postEventFn are finished before that, so a new watcher built
+// right after Close never races with this one on the same state. A watch loop started after
+// Close exits immediately without handling any event. It's safe to call Close multiple times.
+func (lw *LoopWatcher) Close() {
+	lw.closed.Store(true)
+	lw.cancel()
+	if lw.started.Load() {
+		<-lw.stoppedCh
+	}
+}
+
 // WaitLoad waits for the result to obtain whether data is loaded.
 func (lw *LoopWatcher) WaitLoad() error {
 	return <-lw.isLoadedCh
 }
 
+// FatalErrorCh returns the channel to notify the error which stops the watch loop.
+func (lw *LoopWatcher) FatalErrorCh() <-chan error {
+	return lw.fatalErrCh
+}
+
+// SetFatalOnPostEventError sets whether to stop the watch loop once postEventFn returns an error.
+// The error will be delivered through FatalErrorCh instead of only being logged, and it will also
+// be returned by WaitLoad if it happens during the first load.
+func (lw *LoopWatcher) SetFatalOnPostEventError(fatal bool) {
+	lw.fatalOnPostEventError = fatal
+}
+
+// SetRawWatchObserver sets the observer which is invoked with every raw watch response before
+// its events are handled, e.g., for logging, metrics or auditing. The observer should not block.
+func (lw *LoopWatcher) SetRawWatchObserver(observer func(clientv3.WatchResponse)) {
+	lw.rawWatchObserver = observer
+}
+
+// SetBatchPutFn sets the function to handle the keys loaded from etcd page by page instead of
+// putFn, e.g., to apply a whole page under a single lock. It's applied to all the targets, and
+// the events from the watch are still handled by putFn.
+func (lw *LoopWatcher) SetBatchPutFn(batchPutFn func([]*mvccpb.KeyValue) error) {
+	for _, target := range lw.targets {
+		target.BatchPutFn = batchPutFn
+	}
+}
+
+// SetMinStartRevision sets the min revision to start watching from, the events before it will
+// be skipped if it's higher than the revision of the loaded data. This is used to avoid reprocessing
+// the events which have been applied by the others. Since it only takes effect when it's higher
+// than the revision of the loaded data, a compacted revision will never be used to start watching.
+func (lw *LoopWatcher) SetMinStartRevision(revision int64) {
+	lw.minStartRevision = revision
+}
+
 // SetLoadRetryTimes sets the retry times when loading data from etcd.
 func (lw *LoopWatcher) SetLoadRetryTimes(times int) {
 	lw.loadRetryTimes = times
 }
 
+// SetLoadRetryBackoff sets the backoff between two retries when loading data from etcd.
+// The backoff starts from base and doubles after each failed retry, but never exceeds max.
+func (lw *LoopWatcher) SetLoadRetryBackoff(base, max time.Duration) {
+	if max < base {
+		max = base
+	}
+	lw.loadRetryBaseInterval = base
+	lw.loadRetryMaxInterval = max
+}
+
+func (lw *LoopWatcher) nextLoadRetryInterval(backoff time.Duration) time.Duration {
+	backoff *= 2
+	if backoff > lw.loadRetryMaxInterval {
+		backoff = lw.loadRetryMaxInterval
+	}
+	return backoff
+}
+
+// SetWatchRetryBackoff sets the backoff between two retries when the watch fails.
+// The backoff starts from base and doubles after each consecutive failure, but never exceeds max.
+// It starts over from base once a watch has run for a while before failing.
+func (lw *LoopWatcher) SetWatchRetryBackoff(base, max time.Duration) {
+	if max < base {
+		max = base
+	}
+	lw.watchChangeRetryInterval = base
+	lw.watchChangeMaxRetryInterval = max
+}
+
+// nextWatchRetryInterval returns the backoff before restarting the failed watch according to the
+// consecutive failures. A jitter is added so that the watchers don't retry at the same time.
+func (lw *LoopWatcher) nextWatchRetryInterval() time.Duration {
+	backoff := lw.watchChangeRetryInterval
+	for i := 1; i < lw.watchFailures && backoff < lw.watchChangeMaxRetryInterval; i++ {
+		backoff *= 2
+	}
+	jitter := (rand.Float64()*2 - 1) * watchRetryJitterRatio
+	backoff = time.Duration(float64(backoff) * (1 + jitter))
+	if backoff > lw.watchChangeMaxRetryInterval {
+		backoff = lw.watchChangeMaxRetryInterval
+	}
+	return backoff
+}
+
+// SetWatchIdleTimeout sets how long the watch can receive neither events nor progress notifications
+// before it's regarded as stalled and re-established, e.g., the watch stream is broken silently while
+// the connection is still alive. The progress notification is requested from etcd once it's set, so
+// the timeout should be longer than the progress notify interval of the etcd server, which is 10
+// minutes by default. It's disabled by default, and a non-positive timeout disables it.
+func (lw *LoopWatcher) SetWatchIdleTimeout(timeout time.Duration) {
+	if timeout < 0 {
+		timeout = 0
+	}
+	lw.watchIdleTimeout = timeout
+}
+
 // SetLoadTimeout sets the timeout when loading data from etcd.
 func (lw *LoopWatcher) SetLoadTimeout(timeout time.Duration) {
 	lw.loadTimeout = timeout
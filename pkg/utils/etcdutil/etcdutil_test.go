@@ -17,22 +17,32 @@ package etcdutil
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
 	"net"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/gogo/protobuf/proto"
 	"github.com/pingcap/failpoint"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/log"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	"github.com/tikv/pd/pkg/errs"
 	"github.com/tikv/pd/pkg/utils/tempurl"
 	"github.com/tikv/pd/pkg/utils/testutil"
+	"github.com/tikv/pd/pkg/utils/typeutil"
 	"go.etcd.io/etcd/clientv3"
 	"go.etcd.io/etcd/embed"
+	"go.etcd.io/etcd/etcdserver/api/v3rpc/rpctypes"
 	"go.etcd.io/etcd/etcdserver/etcdserverpb"
 	"go.etcd.io/etcd/mvcc/mvccpb"
 	"go.etcd.io/etcd/pkg/types"
@@ -100,6 +110,157 @@ func TestMemberHelpers(t *testing.T) {
 	re.Equal(uint64(etcd1.Server.ID()), listResp3.Members[0].ID)
 }
 
+func TestCheckClusterID(t *testing.T) {
+	re := require.New(t)
+	cfg1 := NewTestSingleConfig(t)
+	etcd1, err := embed.StartEtcd(cfg1)
+	re.NoError(err)
+	defer etcd1.Close()
+	cfg2 := NewTestSingleConfig(t)
+	etcd2, err := embed.StartEtcd(cfg2)
+	re.NoError(err)
+	defer etcd2.Close()
+	<-etcd1.Server.ReadyNotify()
+	<-etcd2.Server.ReadyNotify()
+	re.NotEqual(etcd1.Server.Cluster().ID(), etcd2.Server.Cluster().ID())
+
+	// The hanging peers accept the connections but never respond.
+	var hangingPeers []string
+	for i := 0; i < 3; i++ {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		re.NoError(err)
+		defer l.Close()
+		go func() {
+			for {
+				conn, err := l.Accept()
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+			}
+		}()
+		hangingPeers = append(hangingPeers, fmt.Sprintf("hang%d=http://%s", i, l.Addr().String()))
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	unreachablePeer := fmt.Sprintf("unreachable=%s", tempurl.Alloc())
+
+	// The unreachable peers are tolerated.
+	urlsMap, err := types.NewURLsMap(cfg1.InitialCluster + "," + unreachablePeer)
+	re.NoError(err)
+	re.NoError(CheckClusterID(etcd1.Server.Cluster().ID(), urlsMap, tlsConfig))
+
+	// Return at once on the mismatch without waiting for the hanging peers.
+	urlsMap, err = types.NewURLsMap(strings.Join(append(hangingPeers, cfg2.InitialCluster, unreachablePeer), ","))
+	re.NoError(err)
+	start := time.Now()
+	err = CheckClusterID(etcd1.Server.Cluster().ID(), urlsMap, tlsConfig)
+	re.ErrorContains(err, "Etcd cluster ID mismatch")
+	re.Less(time.Since(start), checkClusterIDTimeout/2)
+}
+
+func TestLearnerHelpers(t *testing.T) {
+	re := require.New(t)
+	cfg1 := NewTestSingleConfig(t)
+	etcd1, err := embed.StartEtcd(cfg1)
+	re.NoError(err)
+	defer etcd1.Close()
+
+	ep1 := cfg1.LCUrls[0].String()
+	client1, err := clientv3.New(clientv3.Config{
+		Endpoints: []string{ep1},
+	})
+	re.NoError(err)
+	defer client1.Close()
+
+	<-etcd1.Server.ReadyNotify()
+
+	learners, err := ListEtcdLearners(client1)
+	re.NoError(err)
+	re.Empty(learners)
+
+	// Test AddEtcdLearner
+	cfg2 := NewTestSingleConfig(t)
+	cfg2.Name = genRandName()
+	cfg2.InitialCluster = cfg1.InitialCluster + fmt.Sprintf(",%s=%s", cfg2.Name, &cfg2.LPUrls[0])
+	cfg2.ClusterState = embed.ClusterStateFlagExisting
+	addResp, err := AddEtcdLearner(client1, []string{cfg2.LPUrls[0].String()})
+	re.NoError(err)
+	re.True(addResp.Member.IsLearner)
+	etcd2, err := embed.StartEtcd(cfg2)
+	re.NoError(err)
+	defer etcd2.Close()
+	re.Equal(uint64(etcd2.Server.ID()), addResp.Member.ID)
+	<-etcd2.Server.ReadyNotify()
+	checkMembers(re, client1, []*embed.Etcd{etcd1, etcd2})
+
+	learners, err = ListEtcdLearners(client1)
+	re.NoError(err)
+	re.Len(learners, 1)
+	re.Equal(addResp.Member.ID, learners[0].ID)
+
+	// Test PromoteEtcdLearner, the learner can only be promoted after it catches up with the leader.
+	testutil.Eventually(re, func() bool {
+		_, err = PromoteEtcdLearner(client1, addResp.Member.ID)
+		return err == nil
+	})
+	learners, err = ListEtcdLearners(client1)
+	re.NoError(err)
+	re.Empty(learners)
+
+	// Promote a member which is not a learner.
+	_, err = PromoteEtcdLearner(client1, addResp.Member.ID)
+	re.ErrorContains(err, "PD:etcd:ErrEtcdMemberPromote")
+}
+
+func TestMoveEtcdLeader(t *testing.T) {
+	re := require.New(t)
+	cfg1 := NewTestSingleConfig(t)
+	etcd1, err := embed.StartEtcd(cfg1)
+	re.NoError(err)
+	defer etcd1.Close()
+
+	ep1 := cfg1.LCUrls[0].String()
+	client1, err := clientv3.New(clientv3.Config{
+		Endpoints: []string{ep1},
+	})
+	re.NoError(err)
+	defer client1.Close()
+
+	<-etcd1.Server.ReadyNotify()
+
+	etcd2 := checkAddEtcdMember(t, cfg1, client1)
+	defer etcd2.Close()
+	ep2 := etcd2.Config().LCUrls[0].String()
+	client2, err := clientv3.New(clientv3.Config{
+		Endpoints: []string{ep2},
+	})
+	re.NoError(err)
+	defer client2.Close()
+	checkMembers(re, client1, []*embed.Etcd{etcd1, etcd2})
+	testutil.Eventually(re, func() bool {
+		return etcd1.Server.Leader() == etcd1.Server.ID()
+	})
+
+	// Move the leadership to a member which doesn't exist.
+	err = MoveEtcdLeader(client1, uint64(etcd2.Server.ID())+1)
+	re.ErrorContains(err, "PD:etcd:ErrEtcdMoveLeaderTarget")
+	// Move the leadership through a client which is not connected to the leader.
+	err = MoveEtcdLeader(client2, uint64(etcd1.Server.ID()))
+	re.ErrorContains(err, "PD:etcd:ErrEtcdNotLeader")
+
+	re.NoError(MoveEtcdLeader(client1, uint64(etcd2.Server.ID())))
+	testutil.Eventually(re, func() bool {
+		return etcd1.Server.Leader() == etcd2.Server.ID() && etcd2.Server.Leader() == etcd2.Server.ID()
+	})
+	// The leader of client1 has been moved away.
+	err = MoveEtcdLeader(client1, uint64(etcd1.Server.ID()))
+	re.ErrorContains(err, "PD:etcd:ErrEtcdNotLeader")
+	re.NoError(MoveEtcdLeader(client2, uint64(etcd1.Server.ID())))
+	testutil.Eventually(re, func() bool {
+		return etcd2.Server.Leader() == etcd1.Server.ID()
+	})
+}
+
 func TestEtcdKVGet(t *testing.T) {
 	re := require.New(t)
 	cfg := NewTestSingleConfig(t)
@@ -120,37 +281,724 @@ func TestEtcdKVGet(t *testing.T) {
 
 	<-etcd.Server.ReadyNotify()
 
-	keys := []string{"test/key1", "test/key2", "test/key3", "test/key4", "test/key5"}
-	vals := []string{"val1", "val2", "val3", "val4", "val5"}
-
-	kv := clientv3.NewKV(client)
-	for i := range keys {
-		_, err = kv.Put(context.TODO(), keys[i], vals[i])
+	keys := []string{"test/key1", "test/key2", "test/key3", "test/key4", "test/key5"}
+	vals := []string{"val1", "val2", "val3", "val4", "val5"}
+
+	kv := clientv3.NewKV(client)
+	for i := range keys {
+		_, err = kv.Put(context.TODO(), keys[i], vals[i])
+		re.NoError(err)
+	}
+
+	// Test simple point get
+	resp, err := EtcdKVGet(client, "test/key1")
+	re.NoError(err)
+	re.Equal("val1", string(resp.Kvs[0].Value))
+
+	// Test range get
+	withRange := clientv3.WithRange("test/zzzz")
+	withLimit := clientv3.WithLimit(3)
+	resp, err = EtcdKVGet(client, "test/", withRange, withLimit, clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend))
+	re.NoError(err)
+	re.Len(resp.Kvs, 3)
+
+	for i := range resp.Kvs {
+		re.Equal(keys[i], string(resp.Kvs[i].Key))
+		re.Equal(vals[i], string(resp.Kvs[i].Value))
+	}
+
+	lastKey := string(resp.Kvs[len(resp.Kvs)-1].Key)
+	next := clientv3.GetPrefixRangeEnd(lastKey)
+	resp, err = EtcdKVGet(client, next, withRange, withLimit, clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend))
+	re.NoError(err)
+	re.Len(resp.Kvs, 2)
+}
+
+func TestEtcdKVDelete(t *testing.T) {
+	re := require.New(t)
+	cfg := NewTestSingleConfig(t)
+	etcd, err := embed.StartEtcd(cfg)
+	defer func() {
+		etcd.Close()
+	}()
+	re.NoError(err)
+
+	ep := cfg.LCUrls[0].String()
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints: []string{ep},
+	})
+	defer func() {
+		client.Close()
+	}()
+	re.NoError(err)
+
+	<-etcd.Server.ReadyNotify()
+
+	keys := []string{"test/key1", "test/key2", "test/key3", "test/other"}
+	kv := clientv3.NewKV(client)
+	for _, key := range keys {
+		_, err = kv.Put(context.TODO(), key, "val")
+		re.NoError(err)
+	}
+
+	// Test simple point delete
+	resp, err := EtcdKVDelete(client, "test/key1")
+	re.NoError(err)
+	re.Equal(int64(1), resp.Deleted)
+	resp, err = EtcdKVDelete(client, "test/key1")
+	re.NoError(err)
+	re.Zero(resp.Deleted)
+
+	// Test prefix delete
+	resp, err = EtcdKVDelete(client, "test/key", clientv3.WithPrefix())
+	re.NoError(err)
+	re.Equal(int64(2), resp.Deleted)
+	getResp, err := EtcdKVGet(client, "test/", clientv3.WithPrefix())
+	re.NoError(err)
+	re.Len(getResp.Kvs, 1)
+	re.Equal("test/other", string(getResp.Kvs[0].Key))
+
+	// Test the error is wrapped.
+	client.Close()
+	_, err = EtcdKVDelete(client, "test/other")
+	re.ErrorContains(err, "PD:etcd:ErrEtcdKVDelete")
+}
+
+func TestEtcdRequestsWithContext(t *testing.T) {
+	re := require.New(t)
+	cfg := NewTestSingleConfig(t)
+	etcd, err := embed.StartEtcd(cfg)
+	defer func() {
+		etcd.Close()
+	}()
+	re.NoError(err)
+
+	ep := cfg.LCUrls[0].String()
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints: []string{ep},
+	})
+	defer func() {
+		client.Close()
+	}()
+	re.NoError(err)
+
+	<-etcd.Server.ReadyNotify()
+
+	key := "test/ctx"
+	_, err = client.Put(context.TODO(), key, "val")
+	re.NoError(err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	resp, err := EtcdKVGetWithContext(ctx, client, key)
+	re.NoError(err)
+	re.Len(resp.Kvs, 1)
+	listResp, err := ListEtcdMembersWithContext(ctx, client)
+	re.NoError(err)
+	re.Len(listResp.Members, 1)
+	delResp, err := EtcdKVDeleteWithContext(ctx, client, key)
+	re.NoError(err)
+	re.Equal(int64(1), delResp.Deleted)
+	cancel()
+
+	// The deadline is fully controlled by the caller.
+	ctx, cancel = context.WithDeadline(context.Background(), time.Now())
+	defer cancel()
+	_, err = EtcdKVGetWithContext(ctx, client, key)
+	re.ErrorContains(err, "PD:etcd:ErrEtcdKVGet")
+	_, err = EtcdKVDeleteWithContext(ctx, client, key)
+	re.ErrorContains(err, "PD:etcd:ErrEtcdKVDelete")
+	_, err = ListEtcdMembersWithContext(ctx, client)
+	re.ErrorContains(err, "PD:etcd:ErrEtcdMemberList")
+	_, err = RemoveEtcdMemberWithContext(ctx, client, uint64(etcd.Server.ID()))
+	re.ErrorContains(err, "PD:etcd:ErrEtcdMemberRemove")
+}
+
+func TestSlowRequestHook(t *testing.T) {
+	re := require.New(t)
+	cfg := NewTestSingleConfig(t)
+	etcd, err := embed.StartEtcd(cfg)
+	defer func() {
+		etcd.Close()
+	}()
+	re.NoError(err)
+
+	ep := cfg.LCUrls[0].String()
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints: []string{ep},
+	})
+	defer func() {
+		client.Close()
+	}()
+	re.NoError(err)
+
+	<-etcd.Server.ReadyNotify()
+
+	writer := &logWriter{}
+	lg, p, err := log.InitLoggerWithWriteSyncer(&log.Config{Level: "info"}, writer, writer)
+	re.NoError(err)
+	restore := log.ReplaceGlobals(lg, p)
+	// Treat all the requests as slow ones.
+	slowRequestTime = -1
+	var (
+		mu      sync.Mutex
+		reports []string
+	)
+	hook := func(op string, key string, cost time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		reports = append(reports, op+":"+key)
+	}
+	SetSlowRequestHook(hook)
+	defer func() {
+		slowRequestTime = DefaultSlowRequestTime
+		slowRequestReporterPtr.Store(nil)
+		restore()
+	}()
+
+	key := "test/slow"
+	_, err = EtcdKVPutWithTTL(context.TODO(), client, key, "val", 10)
+	re.NoError(err)
+	_, err = EtcdKVGet(client, key)
+	re.NoError(err)
+	_, err = EtcdKVGetMultiAtRevision(client, []string{key, key + "2"}, 0)
+	re.NoError(err)
+	_, _, err = GetValueWithTTL(client, key)
+	re.NoError(err)
+	re.NoError(BatchTxn(client, []clientv3.Op{clientv3.OpPut(key, "val2")}, DefaultMaxTxnOps))
+	_, err = EtcdKVCompareAndSwap(client, key, "val2", "val3")
+	re.NoError(err)
+	_, _, err = EtcdKVPutIfAbsent(client, key, "val4")
+	re.NoError(err)
+	_, _, err = PutProtoMsgIfModRev(client, key, &metapb.Store{Id: 1}, 0)
+	re.NoError(err)
+	_, err = EtcdKVIncrement(client, key+"/counter", 1)
+	re.NoError(err)
+	_, err = EtcdKVDelete(client, key)
+	re.NoError(err)
+	re.Equal([]string{
+		"put:" + key, "get:" + key, "get:" + key + "," + key + "2", "get:" + key, "ttl:" + key,
+		"txn:" + key, "txn:" + key, "txn:" + key, "txn:" + key, "get:" + key + "/counter", "txn:" + key + "/counter",
+		"delete:" + key,
+	}, reports)
+	// The slow requests are also logged.
+	for _, msg := range []string{"kv puts too slow", "kv gets too slow", "kv ttl gets too slow", "kv txn too slow", "kv deletes too slow"} {
+		re.Contains(writer.String(), msg)
+	}
+
+	// Only report the slow requests to the hook.
+	reports = reports[:0]
+	SetSlowRequestHook(hook, WithoutSlowRequestLog())
+	writer.Lock()
+	writer.Builder.Reset()
+	writer.Unlock()
+	_, err = EtcdKVGet(client, key)
+	re.NoError(err)
+	re.Equal([]string{"get:" + key}, reports)
+	re.NotContains(writer.String(), "kv gets too slow")
+}
+
+func TestGetValueSerializable(t *testing.T) {
+	re := require.New(t)
+	cfg1 := NewTestSingleConfig(t)
+	etcd1, err := embed.StartEtcd(cfg1)
+	defer func() {
+		etcd1.Close()
+	}()
+	re.NoError(err)
+	ep1 := cfg1.LCUrls[0].String()
+	client1, err := clientv3.New(clientv3.Config{
+		Endpoints: []string{ep1},
+	})
+	defer func() {
+		client1.Close()
+	}()
+	re.NoError(err)
+	<-etcd1.Server.ReadyNotify()
+
+	etcd2 := checkAddEtcdMember(t, cfg1, client1)
+	defer etcd2.Close()
+	ep2 := etcd2.Config().LCUrls[0].String()
+	client2, err := clientv3.New(clientv3.Config{
+		Endpoints: []string{ep2},
+	})
+	defer func() {
+		client2.Close()
+	}()
+	re.NoError(err)
+
+	// The linearizable read is always fresh.
+	key := "test/serializable"
+	for _, val := range []string{"val1", "val2"} {
+		_, err = client1.Put(context.TODO(), key, val)
+		re.NoError(err)
+		value, err := GetValue(client2, key)
+		re.NoError(err)
+		re.Equal(val, string(value))
+	}
+
+	// Stop the other member to make the cluster lose its quorum, then the value
+	// in etcd2 can not be confirmed to be the latest one.
+	etcd1.Close()
+	// The serializable read is served locally and returns the possibly stale value.
+	value, err := GetValueSerializable(client2, key)
+	re.NoError(err)
+	re.Equal("val2", string(value))
+	// The linearizable read can not be served without the quorum.
+	_, err = GetValue(client2, key)
+	re.Error(err)
+}
+
+func TestGetValueWithRetry(t *testing.T) {
+	re := require.New(t)
+	cfg := NewTestSingleConfig(t)
+	etcd, err := embed.StartEtcd(cfg)
+	defer func() {
+		etcd.Close()
+	}()
+	re.NoError(err)
+
+	ep := cfg.LCUrls[0].String()
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints: []string{ep},
+	})
+	defer func() {
+		client.Close()
+	}()
+	re.NoError(err)
+
+	<-etcd.Server.ReadyNotify()
+
+	key := "test/retry"
+	_, err = client.Put(context.TODO(), key, "val")
+	re.NoError(err)
+
+	// Retry on the transient errors.
+	re.True(isRetriableEtcdError(errs.ErrEtcdKVGet.Wrap(rpctypes.ErrLeaderChanged).GenWithStackByCause()))
+	re.True(isRetriableEtcdError(errs.ErrEtcdKVGet.Wrap(context.DeadlineExceeded).GenWithStackByCause()))
+	re.False(isRetriableEtcdError(errs.ErrEtcdKVGet.Wrap(context.Canceled).GenWithStackByCause()))
+	re.NoError(failpoint.Enable("github.com/tikv/pd/pkg/utils/etcdutil/getValueTemporaryFail", "return(2)"))
+	value, err := GetValueWithRetry(client, key, 3, 10*time.Millisecond)
+	re.NoError(err)
+	re.Equal("val", string(value))
+	// Give up after running out of the retries.
+	_, err = GetValueWithRetry(client, key, 1, 10*time.Millisecond)
+	re.ErrorContains(err, "PD:etcd:ErrEtcdKVGet")
+	re.NoError(failpoint.Disable("github.com/tikv/pd/pkg/utils/etcdutil/getValueTemporaryFail"))
+
+	// Don't retry on the definitive errors.
+	_, err = client.Put(context.TODO(), key+"/1", "val1")
+	re.NoError(err)
+	start := time.Now()
+	_, err = GetValueWithRetry(client, key, 3, time.Second, clientv3.WithPrefix())
+	re.ErrorContains(err, "PD:etcd:ErrEtcdKVGetResponse")
+	re.Less(time.Since(start), time.Second)
+
+	// Stop retrying once the client is closed.
+	re.NoError(failpoint.Enable("github.com/tikv/pd/pkg/utils/etcdutil/getValueTemporaryFail", "return(100)"))
+	defer func() {
+		re.NoError(failpoint.Disable("github.com/tikv/pd/pkg/utils/etcdutil/getValueTemporaryFail"))
+	}()
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		client.Close()
+	}()
+	start = time.Now()
+	_, err = GetValueWithRetry(client, key, 100, time.Second)
+	re.ErrorContains(err, context.Canceled.Error())
+	re.Less(time.Since(start), 2*time.Second)
+}
+
+func TestEtcdKVGetMultiAtRevision(t *testing.T) {
+	re := require.New(t)
+	cfg := NewTestSingleConfig(t)
+	etcd, err := embed.StartEtcd(cfg)
+	defer func() {
+		etcd.Close()
+	}()
+	re.NoError(err)
+
+	ep := cfg.LCUrls[0].String()
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints: []string{ep},
+	})
+	defer func() {
+		client.Close()
+	}()
+	re.NoError(err)
+
+	<-etcd.Server.ReadyNotify()
+
+	keys := []string{"test/snapshot/key1", "test/snapshot/key2", "test/snapshot/key3"}
+	putAll := func(val string) int64 {
+		ops := make([]clientv3.Op, 0, len(keys))
+		for _, key := range keys {
+			ops = append(ops, clientv3.OpPut(key, val))
+		}
+		resp, err := client.Txn(context.TODO()).Then(ops...).Commit()
+		re.NoError(err)
+		return resp.Header.Revision
+	}
+
+	// Test reading at a specified revision.
+	rev := putAll("val1")
+	putAll("val2")
+	values, err := EtcdKVGetMultiAtRevision(client, keys, rev)
+	re.NoError(err)
+	re.Len(values, len(keys))
+	for _, key := range keys {
+		re.Equal("val1", string(values[key]))
+	}
+	values, err = EtcdKVGetMultiAtRevision(client, keys, 0)
+	re.NoError(err)
+	for _, key := range keys {
+		re.Equal("val2", string(values[key]))
+	}
+
+	// Test the non-existing key is not returned.
+	values, err = EtcdKVGetMultiAtRevision(client, append(keys, "test/snapshot/key4"), 0)
+	re.NoError(err)
+	re.Len(values, len(keys))
+
+	// Test the snapshot is not torn by concurrent writes.
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-ctx.Done():
+				return
+			default:
+			}
+			putAll(fmt.Sprintf("val%d", i))
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		values, err = EtcdKVGetMultiAtRevision(client, keys, 0)
+		re.NoError(err)
+		re.Len(values, len(keys))
+		for _, key := range keys[1:] {
+			re.Equal(string(values[keys[0]]), string(values[key]))
+		}
+	}
+	cancel()
+	wg.Wait()
+}
+
+func TestPutProtoMsgIfModRev(t *testing.T) {
+	re := require.New(t)
+	cfg := NewTestSingleConfig(t)
+	etcd, err := embed.StartEtcd(cfg)
+	defer func() {
+		etcd.Close()
+	}()
+	re.NoError(err)
+
+	ep := cfg.LCUrls[0].String()
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints: []string{ep},
+	})
+	defer func() {
+		client.Close()
+	}()
+	re.NoError(err)
+
+	<-etcd.Server.ReadyNotify()
+
+	key := "test/proto"
+	// The key is expected to be absent with the ModRevision 0.
+	ok, rev, err := PutProtoMsgIfModRev(client, key, &metapb.Store{Id: 1, Address: "v1"}, 0)
+	re.NoError(err)
+	re.True(ok)
+	store := &metapb.Store{}
+	exist, modRev, err := GetProtoMsgWithModRev(client, key, store)
+	re.NoError(err)
+	re.True(exist)
+	re.Equal(rev, modRev)
+	re.Equal("v1", store.GetAddress())
+	// Putting it again with ModRevision 0 should fail since the key exists now.
+	ok, rev, err = PutProtoMsgIfModRev(client, key, &metapb.Store{Id: 1, Address: "v0"}, 0)
+	re.NoError(err)
+	re.False(ok)
+	re.Equal(modRev, rev)
+
+	// Mutate the key concurrently based on the same ModRevision, only one of them should succeed.
+	const workers = 8
+	var (
+		wg        sync.WaitGroup
+		succeeded atomic.Int64
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ok, _, err := PutProtoMsgIfModRev(client, key, &metapb.Store{Id: 1, Address: fmt.Sprintf("v%d", i+2)}, modRev)
+			re.NoError(err)
+			if ok {
+				succeeded.Add(1)
+			}
+		}(i)
+	}
+	wg.Wait()
+	re.Equal(int64(1), succeeded.Load())
+
+	// The stale CAS should fail and return the current ModRevision.
+	exist, currentModRev, err := GetProtoMsgWithModRev(client, key, store)
+	re.NoError(err)
+	re.True(exist)
+	re.Greater(currentModRev, modRev)
+	ok, rev, err = PutProtoMsgIfModRev(client, key, &metapb.Store{Id: 1, Address: "stale"}, modRev)
+	re.NoError(err)
+	re.False(ok)
+	re.Equal(currentModRev, rev)
+	written := &metapb.Store{}
+	_, _, err = GetProtoMsgWithModRev(client, key, written)
+	re.NoError(err)
+	re.Equal(store.GetAddress(), written.GetAddress())
+	re.NotEqual("stale", written.GetAddress())
+}
+
+func TestGetProtoMsgsWithPrefix(t *testing.T) {
+	re := require.New(t)
+	cfg := NewTestSingleConfig(t)
+	etcd, err := embed.StartEtcd(cfg)
+	defer func() {
+		etcd.Close()
+	}()
+	re.NoError(err)
+
+	ep := cfg.LCUrls[0].String()
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints: []string{ep},
+	})
+	defer func() {
+		client.Close()
+	}()
+	re.NoError(err)
+
+	<-etcd.Server.ReadyNotify()
+
+	newMsg := func() proto.Message { return &metapb.Store{} }
+	prefix := "test/protos/"
+	msgs, rev, err := GetProtoMsgsWithPrefix(client, prefix, newMsg)
+	re.NoError(err)
+	re.Empty(msgs)
+	re.Zero(rev)
+
+	// Put more keys than a batch to check the paging.
+	count := defaultLoadBatchSize + 10
+	var maxRev int64
+	for i := 0; i < count; i++ {
+		value, err := proto.Marshal(&metapb.Store{Id: uint64(i)})
 		re.NoError(err)
+		resp, err := client.Put(context.TODO(), fmt.Sprintf("%s%04d", prefix, i), string(value))
+		re.NoError(err)
+		maxRev = resp.Header.Revision
+	}
+	// The key out of the prefix should not be loaded.
+	_, err = client.Put(context.TODO(), "test/protos0", "invalid")
+	re.NoError(err)
+	msgs, rev, err = GetProtoMsgsWithPrefix(client, prefix, newMsg)
+	re.NoError(err)
+	re.Equal(maxRev, rev)
+	re.Len(msgs, count)
+	for i, msg := range msgs {
+		re.Equal(uint64(i), msg.(*metapb.Store).GetId())
 	}
 
-	// Test simple point get
-	resp, err := EtcdKVGet(client, "test/key1")
+	// The offending key is reported.
+	_, err = client.Put(context.TODO(), prefix+"invalid", "invalid")
 	re.NoError(err)
-	re.Equal("val1", string(resp.Kvs[0].Value))
+	_, _, err = GetProtoMsgsWithPrefix(client, prefix, newMsg)
+	re.ErrorContains(err, "PD:proto:ErrProtoUnmarshal")
+	re.ErrorContains(err, prefix+"invalid")
+}
 
-	// Test range get
-	withRange := clientv3.WithRange("test/zzzz")
-	withLimit := clientv3.WithLimit(3)
-	resp, err = EtcdKVGet(client, "test/", withRange, withLimit, clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend))
+func TestEtcdKVIncrement(t *testing.T) {
+	re := require.New(t)
+	cfg := NewTestSingleConfig(t)
+	etcd, err := embed.StartEtcd(cfg)
+	defer func() {
+		etcd.Close()
+	}()
 	re.NoError(err)
-	re.Len(resp.Kvs, 3)
 
-	for i := range resp.Kvs {
-		re.Equal(keys[i], string(resp.Kvs[i].Key))
-		re.Equal(vals[i], string(resp.Kvs[i].Value))
+	ep := cfg.LCUrls[0].String()
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints: []string{ep},
+	})
+	defer func() {
+		client.Close()
+	}()
+	re.NoError(err)
+
+	<-etcd.Server.ReadyNotify()
+
+	// Test the missing key is treated as zero.
+	key := "test/counter"
+	val, err := EtcdKVIncrement(client, key, 5)
+	re.NoError(err)
+	re.Equal(int64(5), val)
+	val, err = EtcdKVIncrement(client, key, -2)
+	re.NoError(err)
+	re.Equal(int64(3), val)
+	value, err := GetValue(client, key)
+	re.NoError(err)
+	re.Equal(typeutil.Uint64ToBytes(3), value)
+	// Test the counter can not be decreased below zero.
+	_, err = EtcdKVIncrement(client, key, -4)
+	re.True(errs.ErrEtcdKVIncrement.Equal(err))
+
+	// Test concurrent increments without lost updates.
+	key = "test/concurrent-counter"
+	const (
+		workers    = 8
+		increments = 20
+	)
+	var (
+		wg        sync.WaitGroup
+		succeeded atomic.Int64
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < increments; j++ {
+				_, err := EtcdKVIncrement(client, key, 1)
+				if err != nil {
+					// Only the retry bound can fail the increment.
+					re.True(errs.ErrEtcdTxnConflict.Equal(err))
+					continue
+				}
+				succeeded.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	re.Positive(succeeded.Load())
+	value, err = GetValue(client, key)
+	re.NoError(err)
+	counter, err := typeutil.BytesToUint64(value)
+	re.NoError(err)
+	re.Equal(uint64(succeeded.Load()), counter)
+}
+
+func TestBatchTxn(t *testing.T) {
+	re := require.New(t)
+	cfg := NewTestSingleConfig(t)
+	etcd, err := embed.StartEtcd(cfg)
+	defer func() {
+		etcd.Close()
+	}()
+	re.NoError(err)
+
+	ep := cfg.LCUrls[0].String()
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints: []string{ep},
+	})
+	defer func() {
+		client.Close()
+	}()
+	re.NoError(err)
+
+	<-etcd.Server.ReadyNotify()
+
+	const count = 300
+	ops := make([]clientv3.Op, 0, count)
+	for i := 0; i < count; i++ {
+		ops = append(ops, clientv3.OpPut(fmt.Sprintf("test/batch/%03d", i), strconv.Itoa(i)))
 	}
+	// The operations exceed the default max txn ops of etcd.
+	_, err = client.Txn(context.Background()).Then(ops...).Commit()
+	re.Error(err)
+	re.NoError(BatchTxn(client, ops, DefaultMaxTxnOps))
+	resp, err := EtcdKVGet(client, "test/batch/", clientv3.WithPrefix(), clientv3.WithCountOnly())
+	re.NoError(err)
+	re.Equal(int64(count), resp.Count)
 
-	lastKey := string(resp.Kvs[len(resp.Kvs)-1].Key)
-	next := clientv3.GetPrefixRangeEnd(lastKey)
-	resp, err = EtcdKVGet(client, next, withRange, withLimit, clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend))
+	// The default max ops is used if it's not positive.
+	ops = ops[:0]
+	for i := 0; i < count; i++ {
+		ops = append(ops, clientv3.OpDelete(fmt.Sprintf("test/batch/%03d", i)))
+	}
+	re.NoError(BatchTxn(client, ops, 0))
+	resp, err = EtcdKVGet(client, "test/batch/", clientv3.WithPrefix(), clientv3.WithCountOnly())
 	re.NoError(err)
-	re.Len(resp.Kvs, 2)
+	re.Zero(resp.Count)
+	// The batch is too large for the server if the max ops is larger than its limit.
+	re.Error(BatchTxn(client, ops, count))
+
+	// The atomic batch stops once the keys are modified by others.
+	ops = ops[:0]
+	for i := 0; i < count; i++ {
+		ops = append(ops, clientv3.OpPut(fmt.Sprintf("test/atomic/%03d", i), strconv.Itoa(i)))
+	}
+	re.NoError(failpoint.Enable("github.com/tikv/pd/pkg/utils/etcdutil/delayBatchTxn", "return(1000)"))
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- BatchTxn(client, ops, DefaultMaxTxnOps, WithBatchTxnAtomic())
+	}()
+	testutil.Eventually(re, func() bool {
+		value, err := GetValue(client, "test/atomic/000")
+		re.NoError(err)
+		return value != nil
+	})
+	// Modify a key in the second txn before it's committed.
+	_, err = client.Put(context.Background(), fmt.Sprintf("test/atomic/%03d", DefaultMaxTxnOps), "modified")
+	re.NoError(err)
+	re.True(errs.ErrEtcdTxnConflict.Equal(<-errCh))
+	re.NoError(failpoint.Disable("github.com/tikv/pd/pkg/utils/etcdutil/delayBatchTxn"))
+	// The first txn is committed and the later ones are not.
+	resp, err = EtcdKVGet(client, "test/atomic/", clientv3.WithPrefix())
+	re.NoError(err)
+	re.Len(resp.Kvs, DefaultMaxTxnOps+1)
+	value, err := GetValue(client, fmt.Sprintf("test/atomic/%03d", DefaultMaxTxnOps))
+	re.NoError(err)
+	re.Equal("modified", string(value))
+}
+
+func TestGetValueWithTTL(t *testing.T) {
+	re := require.New(t)
+	cfg := NewTestSingleConfig(t)
+	etcd, err := embed.StartEtcd(cfg)
+	defer func() {
+		etcd.Close()
+	}()
+	re.NoError(err)
+
+	ep := cfg.LCUrls[0].String()
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints: []string{ep},
+	})
+	defer func() {
+		client.Close()
+	}()
+	re.NoError(err)
+
+	<-etcd.Server.ReadyNotify()
+
+	_, err = EtcdKVPutWithTTL(context.TODO(), client, "test/ttl", "val1", 10)
+	re.NoError(err)
+	value, ttl, err := GetValueWithTTL(client, "test/ttl")
+	re.NoError(err)
+	re.Equal("val1", string(value))
+	re.Greater(ttl, int64(0))
+	re.LessOrEqual(ttl, int64(10))
+
+	// The key without a lease.
+	_, err = client.Put(context.TODO(), "test/no-ttl", "val2")
+	re.NoError(err)
+	value, ttl, err = GetValueWithTTL(client, "test/no-ttl")
+	re.NoError(err)
+	re.Equal("val2", string(value))
+	re.Equal(int64(-1), ttl)
+
+	// The key doesn't exist.
+	value, ttl, err = GetValueWithTTL(client, "test/not-exist")
+	re.NoError(err)
+	re.Nil(value)
+	re.Equal(int64(-1), ttl)
 }
 
 func TestEtcdKVPutWithTTL(t *testing.T) {
@@ -196,6 +1044,58 @@ func TestEtcdKVPutWithTTL(t *testing.T) {
 	re.Equal(int64(0), resp.Count)
 }
 
+func TestEtcdKVPutWithLease(t *testing.T) {
+	re := require.New(t)
+	cfg := NewTestSingleConfig(t)
+	etcd, err := embed.StartEtcd(cfg)
+	defer func() {
+		etcd.Close()
+	}()
+	re.NoError(err)
+
+	ep := cfg.LCUrls[0].String()
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints: []string{ep},
+	})
+	defer func() {
+		client.Close()
+	}()
+	re.NoError(err)
+
+	<-etcd.Server.ReadyNotify()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lease, err := GrantLeaseWithKeepAlive(ctx, client, 1)
+	re.NoError(err)
+	for i := 0; i < 10; i++ {
+		_, err = EtcdKVPutWithLease(ctx, client, "test/lease", strconv.Itoa(i), lease)
+		re.NoError(err)
+	}
+	// The repeated puts don't accumulate the leases.
+	leases, err := client.Leases(ctx)
+	re.NoError(err)
+	re.Len(leases.Leases, 1)
+	re.Equal(lease, leases.Leases[0].ID)
+
+	// The lease is kept alive beyond its ttl.
+	time.Sleep(2 * time.Second)
+	value, ttl, err := GetValueWithTTL(client, "test/lease")
+	re.NoError(err)
+	re.Equal("9", string(value))
+	re.Positive(ttl)
+
+	// The caller revokes the lease to delete the keys.
+	cancel()
+	_, err = client.Revoke(context.TODO(), lease)
+	re.NoError(err)
+	resp, err := EtcdKVGet(client, "test/lease")
+	re.NoError(err)
+	re.Zero(resp.Count)
+	_, err = EtcdKVPutWithLease(context.TODO(), client, "test/lease", "val", lease)
+	re.ErrorContains(err, "PD:etcd:ErrEtcdKVPut")
+}
+
 func TestInitClusterID(t *testing.T) {
 	re := require.New(t)
 	cfg := NewTestSingleConfig(t)
@@ -231,6 +1131,129 @@ func TestInitClusterID(t *testing.T) {
 	re.Equal(clusterID, clusterID1)
 }
 
+func TestInitClusterIDWithGenerator(t *testing.T) {
+	re := require.New(t)
+	cfg := NewTestSingleConfig(t)
+	etcd, err := embed.StartEtcd(cfg)
+	defer func() {
+		etcd.Close()
+	}()
+	re.NoError(err)
+
+	ep := cfg.LCUrls[0].String()
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints: []string{ep},
+	})
+	defer func() {
+		client.Close()
+	}()
+	re.NoError(err)
+
+	<-etcd.Server.ReadyNotify()
+
+	pdClusterIDPath := "test/TestInitClusterIDWithGenerator/pd/cluster_id"
+	// The invalid cluster IDs are not stored.
+	_, err = InitClusterIDWithGenerator(client, pdClusterIDPath, func() (uint64, error) { return 0, nil })
+	re.Error(err)
+	_, err = InitClusterIDWithGenerator(client, pdClusterIDPath, func() (uint64, error) { return 0, errors.New("generate failed") })
+	re.ErrorContains(err, "generate failed")
+	resp, err := EtcdKVGet(client, pdClusterIDPath)
+	re.NoError(err)
+	re.Empty(resp.Kvs)
+
+	const expected uint64 = 12345
+	clusterID, err := InitClusterIDWithGenerator(client, pdClusterIDPath, func() (uint64, error) { return expected, nil })
+	re.NoError(err)
+	re.Equal(expected, clusterID)
+	clusterID, err = GetClusterID(client, pdClusterIDPath)
+	re.NoError(err)
+	re.Equal(expected, clusterID)
+
+	// The existing cluster ID is not overwritten by the generator.
+	clusterID, err = InitOrGetClusterIDWithGenerator(client, pdClusterIDPath, func() (uint64, error) { return expected + 1, nil })
+	re.NoError(err)
+	re.Equal(expected, clusterID)
+}
+
+func TestEtcdKVCompareAndSwap(t *testing.T) {
+	re := require.New(t)
+	cfg := NewTestSingleConfig(t)
+	etcd, err := embed.StartEtcd(cfg)
+	defer func() {
+		etcd.Close()
+	}()
+	re.NoError(err)
+
+	ep := cfg.LCUrls[0].String()
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints: []string{ep},
+	})
+	defer func() {
+		client.Close()
+	}()
+	re.NoError(err)
+
+	<-etcd.Server.ReadyNotify()
+
+	// Test the missing key never matches.
+	key := "test/cas"
+	ok, err := EtcdKVCompareAndSwap(client, key, "", "v1")
+	re.NoError(err)
+	re.False(ok)
+	ok, existing, err := EtcdKVPutIfAbsent(client, key, "v1")
+	re.NoError(err)
+	re.True(ok)
+	re.Empty(existing)
+	ok, existing, err = EtcdKVPutIfAbsent(client, key, "v2")
+	re.NoError(err)
+	re.False(ok)
+	re.Equal("v1", existing)
+
+	// Test the swap only succeeds with the expected value.
+	ok, err = EtcdKVCompareAndSwap(client, key, "v2", "v3")
+	re.NoError(err)
+	re.False(ok)
+	ok, err = EtcdKVCompareAndSwap(client, key, "v1", "v2")
+	re.NoError(err)
+	re.True(ok)
+	value, err := GetValue(client, key)
+	re.NoError(err)
+	re.Equal("v2", string(value))
+
+	// Test only one of the conflicting writers succeeds.
+	const writers = 8
+	var (
+		wg           sync.WaitGroup
+		swapped      atomic.Int64
+		created      atomic.Int64
+		createdValue atomic.Value
+	)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ok, err := EtcdKVCompareAndSwap(client, key, "v2", fmt.Sprintf("swap-%d", i))
+			re.NoError(err)
+			if ok {
+				swapped.Add(1)
+			}
+			ok, _, err = EtcdKVPutIfAbsent(client, "test/absent", fmt.Sprintf("create-%d", i))
+			re.NoError(err)
+			if ok {
+				created.Add(1)
+				createdValue.Store(fmt.Sprintf("create-%d", i))
+			}
+		}(i)
+	}
+	wg.Wait()
+	re.Equal(int64(1), swapped.Load())
+	re.Equal(int64(1), created.Load())
+	ok, existing, err = EtcdKVPutIfAbsent(client, "test/absent", "")
+	re.NoError(err)
+	re.False(ok)
+	re.Equal(createdValue.Load(), existing)
+}
+
 func TestEtcdClientSync(t *testing.T) {
 	re := require.New(t)
 	re.NoError(failpoint.Enable("github.com/tikv/pd/pkg/utils/etcdutil/autoSyncInterval", "return(true)"))
@@ -247,7 +1270,7 @@ func TestEtcdClientSync(t *testing.T) {
 	ep1 := cfg1.LCUrls[0].String()
 	urls, err := types.NewURLs([]string{ep1})
 	re.NoError(err)
-	client1, err := createEtcdClientWithMultiEndpoint(nil, urls)
+	client1, err := CreateEtcdClientWithMultiEndpoint(nil, urls)
 	defer func() {
 		client1.Close()
 	}()
@@ -283,11 +1306,67 @@ func TestEtcdWithHangLeaderEnableCheck(t *testing.T) {
 	re.NoError(err)
 	re.NoError(failpoint.Disable("github.com/tikv/pd/pkg/utils/etcdutil/autoSyncInterval"))
 
-	// Test with disable check.
-	re.NoError(failpoint.Enable("github.com/tikv/pd/pkg/utils/etcdutil/closeKeepAliveCheck", "return(true)"))
-	err = checkEtcdWithHangLeader(t)
-	re.Error(err)
-	re.NoError(failpoint.Disable("github.com/tikv/pd/pkg/utils/etcdutil/closeKeepAliveCheck"))
+	// Test with disable check.
+	re.NoError(failpoint.Enable("github.com/tikv/pd/pkg/utils/etcdutil/closeKeepAliveCheck", "return(true)"))
+	err = checkEtcdWithHangLeader(t)
+	re.Error(err)
+	re.NoError(failpoint.Disable("github.com/tikv/pd/pkg/utils/etcdutil/closeKeepAliveCheck"))
+}
+
+func TestEtcdClientWithHangEndpoint(t *testing.T) {
+	re := require.New(t)
+	// Start a etcd server.
+	cfg1 := NewTestSingleConfig(t)
+	etcd1, err := embed.StartEtcd(cfg1)
+	defer func() {
+		etcd1.Close()
+	}()
+	re.NoError(err)
+	ep1 := cfg1.LCUrls[0].String()
+	<-etcd1.Server.ReadyNotify()
+
+	// Create a proxy to etcd1.
+	proxyAddr := tempurl.Alloc()
+	var enableDiscard atomic.Bool
+	go proxyWithDiscard(re, ep1, proxyAddr, &enableDiscard)
+
+	// Create a etcd client with the proxy as the pinned endpoint.
+	urls, err := types.NewURLs([]string{proxyAddr})
+	re.NoError(err)
+	client1, err := CreateEtcdClientWithMultiEndpoint(nil, urls,
+		WithProbeInterval(100*time.Millisecond), WithProbeTimeout(100*time.Millisecond))
+	defer func() {
+		client1.Close()
+	}()
+	re.NoError(err)
+	etcd2 := checkAddEtcdMember(t, cfg1, client1)
+	defer etcd2.Close()
+	checkMembers(re, client1, []*embed.Etcd{etcd1, etcd2})
+	client1.SetEndpoints(proxyAddr, etcd2.Config().LCUrls[0].String())
+
+	// Hang the proxy, the health checker should rebuild the endpoints from the members before
+	// the keepalive finds the connection broken, and the requests should still succeed quickly.
+	enableDiscard.Store(true)
+	ep2 := etcd2.Config().LCUrls[0].String()
+	memberEps := []string{ep1, ep2}
+	sort.Strings(memberEps)
+	testutil.Eventually(re, func() bool {
+		eps := client1.Endpoints()
+		sort.Strings(eps)
+		return typeutil.StringsEqual(eps, memberEps)
+	}, testutil.WithWaitFor(defaultDialKeepAliveTime))
+	for i := 0; i < 10; i++ {
+		start := time.Now()
+		_, err = EtcdKVGet(client1, "test/key1")
+		re.NoError(err)
+		re.Less(time.Since(start), time.Second)
+	}
+
+	// Stop etcd2, the health checker should put it after the healthy one rather than removing it.
+	etcd2.Close()
+	testutil.Eventually(re, func() bool {
+		return typeutil.StringsEqual(client1.Endpoints(), []string{ep1, ep2})
+	})
 }
 
 func TestEtcdScaleInAndOutWithoutMultiPoint(t *testing.T) {
@@ -349,7 +1428,7 @@ func checkEtcdWithHangLeader(t *testing.T) error {
 	// Create a etcd client with etcd1 as endpoint.
 	urls, err := types.NewURLs([]string{proxyAddr})
 	re.NoError(err)
-	client1, err := createEtcdClientWithMultiEndpoint(nil, urls)
+	client1, err := CreateEtcdClientWithMultiEndpoint(nil, urls)
 	defer func() {
 		client1.Close()
 	}()
@@ -468,127 +1547,644 @@ type loopWatcherTestSuite struct {
 	config *embed.Config
 }
 
-func TestLoopWatcherTestSuite(t *testing.T) {
-	suite.Run(t, new(loopWatcherTestSuite))
+func TestLoopWatcherTestSuite(t *testing.T) {
+	suite.Run(t, new(loopWatcherTestSuite))
+}
+
+func (suite *loopWatcherTestSuite) SetupSuite() {
+	t := suite.T()
+	suite.ctx, suite.cancel = context.WithCancel(context.Background())
+	suite.cleans = make([]func(), 0)
+	// Start a etcd server and create a client with etcd1 as endpoint.
+	suite.config = NewTestSingleConfig(t)
+	suite.startEtcd()
+	ep1 := suite.config.LCUrls[0].String()
+	urls, err := types.NewURLs([]string{ep1})
+	suite.NoError(err)
+	suite.client, err = CreateEtcdClient(nil, urls[0])
+	suite.NoError(err)
+	suite.cleans = append(suite.cleans, func() {
+		suite.client.Close()
+	})
+}
+
+func (suite *loopWatcherTestSuite) TearDownSuite() {
+	suite.cancel()
+	suite.wg.Wait()
+	for _, clean := range suite.cleans {
+		clean()
+	}
+}
+
+func (suite *loopWatcherTestSuite) TestLoadWithoutKey() {
+	cache := struct {
+		sync.RWMutex
+		data map[string]struct{}
+	}{
+		data: make(map[string]struct{}),
+	}
+	watcher := NewLoopWatcher(
+		suite.ctx,
+		&suite.wg,
+		suite.client,
+		"test",
+		"TestLoadWithoutKey",
+		func(kv *mvccpb.KeyValue) error {
+			cache.Lock()
+			defer cache.Unlock()
+			cache.data[string(kv.Key)] = struct{}{}
+			return nil
+		},
+		func(kv *mvccpb.KeyValue) error { return nil },
+		func() error { return nil },
+	)
+
+	defer watcher.Stop()
+	suite.wg.Add(1)
+	go watcher.StartWatchLoop()
+	err := watcher.WaitLoad()
+	suite.NoError(err) // although no key, watcher returns no error
+	cache.RLock()
+	defer cache.RUnlock()
+	suite.Len(cache.data, 0)
+}
+
+func (suite *loopWatcherTestSuite) TestLoadRetryBackoff() {
+	const baseInterval = 100 * time.Millisecond
+	loadWithFailures := func(failTimes int, maxInterval time.Duration) time.Duration {
+		suite.NoError(failpoint.Enable("github.com/tikv/pd/pkg/utils/etcdutil/loadTemporaryFail", fmt.Sprintf("return(%d)", failTimes)))
+		defer func() {
+			suite.NoError(failpoint.Disable("github.com/tikv/pd/pkg/utils/etcdutil/loadTemporaryFail"))
+		}()
+		watcher := NewLoopWatcher(
+			suite.ctx,
+			&suite.wg,
+			suite.client,
+			"test",
+			"TestLoadRetryBackoff",
+			func(kv *mvccpb.KeyValue) error { return nil },
+			func(kv *mvccpb.KeyValue) error { return nil },
+			func() error { return nil },
+		)
+		watcher.SetLoadRetryTimes(failTimes + 1)
+		watcher.SetLoadRetryBackoff(baseInterval, maxInterval)
+		defer watcher.Stop()
+		start := time.Now()
+		suite.wg.Add(1)
+		go watcher.StartWatchLoop()
+		suite.NoError(watcher.WaitLoad())
+		return time.Since(start)
+	}
+
+	// The spacing between two retries should be doubled after each failure.
+	var (
+		lastElapsed = loadWithFailures(0, time.Second)
+		lastSpacing time.Duration
+	)
+	for failTimes := 1; failTimes <= 3; failTimes++ {
+		elapsed := loadWithFailures(failTimes, time.Second)
+		spacing := elapsed - lastElapsed
+		suite.GreaterOrEqual(elapsed, baseInterval*time.Duration(1<<failTimes-1))
+		suite.Greater(spacing, lastSpacing)
+		lastElapsed, lastSpacing = elapsed, spacing
+	}
+
+	// The spacing should never exceed the max interval.
+	elapsed := loadWithFailures(3, 150*time.Millisecond)
+	suite.GreaterOrEqual(elapsed, 400*time.Millisecond)
+	suite.Less(elapsed, 700*time.Millisecond)
+}
+
+func (suite *loopWatcherTestSuite) TestWatchRetryBackoff() {
+	watcher := NewLoopWatcher(
+		suite.ctx,
+		&suite.wg,
+		suite.client,
+		"test",
+		"TestWatchRetryBackoff",
+		func(kv *mvccpb.KeyValue) error { return nil },
+		func(kv *mvccpb.KeyValue) error { return nil },
+		func() error { return nil },
+	)
+	// The default backoff stays close to the fixed interval used before.
+	watcher.watchFailures = 1
+	interval := watcher.nextWatchRetryInterval()
+	suite.GreaterOrEqual(interval, 900*time.Millisecond)
+	suite.LessOrEqual(interval, 1100*time.Millisecond)
+
+	// The backoff doubles after each consecutive failure until reaching the max interval.
+	const base = 100 * time.Millisecond
+	watcher.SetWatchRetryBackoff(base, 400*time.Millisecond)
+	for failures, expected := range []time.Duration{base, base, 2 * base, 4 * base, 4 * base, 4 * base} {
+		watcher.watchFailures = failures
+		interval := watcher.nextWatchRetryInterval()
+		suite.GreaterOrEqual(interval, time.Duration(float64(expected)*(1-watchRetryJitterRatio)), failures)
+		suite.LessOrEqual(interval, time.Duration(float64(expected)*(1+watchRetryJitterRatio)), failures)
+		suite.LessOrEqual(interval, 4*base, failures)
+	}
+
+	// The failures are counted by the failed watches, and start over after a long enough watch.
+	suite.NoError(failpoint.Enable("github.com/tikv/pd/pkg/utils/etcdutil/closeWatchChan", "return(true)"))
+	defer func() {
+		suite.NoError(failpoint.Disable("github.com/tikv/pd/pkg/utils/etcdutil/closeWatchChan"))
+	}()
+	watcher.watchFailures = 0
+	for i := 1; i <= 3; i++ {
+		_, err := watcher.watch(suite.ctx, []int64{0})
+		suite.ErrorIs(err, errWatchChanClosed)
+		suite.Equal(i, watcher.watchFailures)
+	}
+	watcher.watchRetryResetThreshold = 0
+	_, err := watcher.watch(suite.ctx, []int64{0})
+	suite.ErrorIs(err, errWatchChanClosed)
+	suite.Equal(1, watcher.watchFailures)
+}
+
+func (suite *loopWatcherTestSuite) TestWatchIdleTimeout() {
+	var puts atomic.Int64
+	watcher := NewLoopWatcher(
+		suite.ctx,
+		&suite.wg,
+		suite.client,
+		"test",
+		"TestWatchIdleTimeout",
+		func(kv *mvccpb.KeyValue) error {
+			puts.Add(1)
+			return nil
+		},
+		func(kv *mvccpb.KeyValue) error { return nil },
+		func() error { return nil },
+	)
+	// The watchdog is disabled by default.
+	suite.Zero(watcher.watchIdleTimeout)
+	watcher.SetWatchIdleTimeout(200 * time.Millisecond)
+	suite.wg.Add(1)
+	go watcher.StartWatchLoop()
+	defer watcher.Stop()
+	suite.NoError(watcher.WaitLoad())
+
+	suite.put("TestWatchIdleTimeout", "1")
+	testutil.Eventually(suite.Require(), func() bool {
+		return puts.Load() == 1
+	})
+
+	// The failpoint takes effect once the watch is re-established after the idle timeout.
+	suite.NoError(failpoint.Enable("github.com/tikv/pd/pkg/utils/etcdutil/stallWatchChan", "return(true)"))
+	idles := promtestutil.ToFloat64(watcher.metrics.watchIdleCounter)
+	testutil.Eventually(suite.Require(), func() bool {
+		return promtestutil.ToFloat64(watcher.metrics.watchIdleCounter) > idles
+	})
+	// The event is not received by the stalled watch, which keeps being re-established.
+	suite.put("TestWatchIdleTimeout", "2")
+	idles = promtestutil.ToFloat64(watcher.metrics.watchIdleCounter)
+	testutil.Eventually(suite.Require(), func() bool {
+		return promtestutil.ToFloat64(watcher.metrics.watchIdleCounter) > idles
+	})
+	suite.Equal(int64(1), puts.Load())
+
+	// The watch catches up with the missed event once the stream is healthy again.
+	suite.NoError(failpoint.Disable("github.com/tikv/pd/pkg/utils/etcdutil/stallWatchChan"))
+	testutil.Eventually(suite.Require(), func() bool {
+		return puts.Load() == 2
+	})
+}
+
+func (suite *loopWatcherTestSuite) TestCallBack() {
+	cache := struct {
+		sync.RWMutex
+		data map[string]struct{}
+	}{
+		data: make(map[string]struct{}),
+	}
+	result := make([]string, 0)
+	watcher := NewLoopWatcher(
+		suite.ctx,
+		&suite.wg,
+		suite.client,
+		"test",
+		"TestCallBack",
+		func(kv *mvccpb.KeyValue) error {
+			result = append(result, string(kv.Key))
+			return nil
+		},
+		func(kv *mvccpb.KeyValue) error {
+			cache.Lock()
+			defer cache.Unlock()
+			delete(cache.data, string(kv.Key))
+			return nil
+		},
+		func() error {
+			cache.Lock()
+			defer cache.Unlock()
+			for _, r := range result {
+				cache.data[r] = struct{}{}
+			}
+			result = result[:0]
+			return nil
+		},
+		clientv3.WithPrefix(),
+	)
+	defer watcher.Stop()
+
+	suite.wg.Add(1)
+	go watcher.StartWatchLoop()
+	err := watcher.WaitLoad()
+	suite.NoError(err)
+
+	// put 10 keys
+	for i := 0; i < 10; i++ {
+		suite.put(fmt.Sprintf("TestCallBack%d", i), "")
+	}
+	time.Sleep(time.Second)
+	cache.RLock()
+	suite.Len(cache.data, 10)
+	cache.RUnlock()
+
+	// delete 10 keys
+	for i := 0; i < 10; i++ {
+		key := fmt.Sprintf("TestCallBack%d", i)
+		_, err = suite.client.Delete(suite.ctx, key)
+		suite.NoError(err)
+	}
+	time.Sleep(time.Second)
+	cache.RLock()
+	suite.Empty(cache.data)
+	cache.RUnlock()
+}
+
+func (suite *loopWatcherTestSuite) TestFatalOnPostEventError() {
+	var (
+		wg         sync.WaitGroup
+		postEvents atomic.Int64
+		puts       atomic.Int64
+	)
+	watcher := NewLoopWatcher(
+		suite.ctx,
+		&wg,
+		suite.client,
+		"test",
+		"TestFatalOnPostEventError",
+		func(kv *mvccpb.KeyValue) error {
+			puts.Add(1)
+			return nil
+		},
+		func(kv *mvccpb.KeyValue) error { return nil },
+		func() error {
+			// Only the post event of the first load succeeds.
+			if postEvents.Add(1) > 1 {
+				return errors.New("half-applied state")
+			}
+			return nil
+		},
+	)
+	watcher.SetFatalOnPostEventError(true)
+	wg.Add(1)
+	go watcher.StartWatchLoop()
+	suite.NoError(watcher.WaitLoad())
+
+	suite.put("TestFatalOnPostEventError", "1")
+	select {
+	case err := <-watcher.FatalErrorCh():
+		suite.ErrorContains(err, "half-applied state")
+	case <-time.After(5 * time.Second):
+		suite.FailNow("the fatal error is not delivered")
+	}
+	// The watch loop should be stopped.
+	wg.Wait()
+	suite.Equal(int64(1), puts.Load())
+	suite.put("TestFatalOnPostEventError", "2")
+	time.Sleep(100 * time.Millisecond)
+	suite.Equal(int64(1), puts.Load())
+	suite.Equal(int64(2), postEvents.Load())
+}
+
+func (suite *loopWatcherTestSuite) TestStop() {
+	var (
+		wg   sync.WaitGroup
+		puts atomic.Int64
+	)
+	watcher := NewLoopWatcher(
+		suite.ctx,
+		&wg,
+		suite.client,
+		"test",
+		"TestStop",
+		func(kv *mvccpb.KeyValue) error {
+			puts.Add(1)
+			return nil
+		},
+		func(kv *mvccpb.KeyValue) error { return nil },
+		func() error { return nil },
+	)
+	wg.Add(1)
+	go watcher.StartWatchLoop()
+	suite.NoError(watcher.WaitLoad())
+	suite.put("TestStop", "1")
+	testutil.Eventually(suite.Require(), func() bool {
+		return puts.Load() == 1
+	})
+
+	// The watch loop should exit although the parent context is not done.
+	stopped := make(chan struct{})
+	go func() {
+		watcher.Stop()
+		wg.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		suite.FailNow("the watch loop is not stopped")
+	}
+	suite.NoError(suite.ctx.Err())
+	// No more events should be handled.
+	suite.put("TestStop", "2")
+	time.Sleep(100 * time.Millisecond)
+	suite.Equal(int64(1), puts.Load())
+	// Stop is idempotent.
+	watcher.Stop()
+}
+
+func (suite *loopWatcherTestSuite) TestClose() {
+	var (
+		wg         sync.WaitGroup
+		puts       atomic.Int64
+		postEvents atomic.Int64
+	)
+	putStarted := make(chan struct{}, 1)
+	releasePut := make(chan struct{})
+	watcher := NewLoopWatcher(
+		suite.ctx,
+		&wg,
+		suite.client,
+		"test",
+		"TestClose",
+		func(kv *mvccpb.KeyValue) error {
+			putStarted <- struct{}{}
+			<-releasePut
+			puts.Add(1)
+			return nil
+		},
+		func(kv *mvccpb.KeyValue) error { return nil },
+		func() error {
+			postEvents.Add(1)
+			return nil
+		},
+	)
+	wg.Add(1)
+	go watcher.StartWatchLoop()
+	suite.NoError(watcher.WaitLoad())
+	suite.Equal(int64(1), postEvents.Load())
+	suite.put("TestClose", "1")
+	<-putStarted
+
+	// Close should wait for the in-flight event handling.
+	closed := make(chan struct{})
+	go func() {
+		watcher.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		suite.FailNow("close returns before the in-flight event is handled")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(releasePut)
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		suite.FailNow("the watch loop is not closed")
+	}
+	wg.Wait()
+	// The batch has been flushed by postEventFn before Close returns.
+	suite.Equal(int64(1), puts.Load())
+	suite.Equal(int64(2), postEvents.Load())
+	suite.NoError(suite.ctx.Err())
+	// Close is idempotent.
+	watcher.Close()
+
+	// A watch loop started after Close exits immediately.
+	watcher = NewLoopWatcher(
+		suite.ctx,
+		&wg,
+		suite.client,
+		"test",
+		"TestClose",
+		func(kv *mvccpb.KeyValue) error {
+			puts.Add(1)
+			return nil
+		},
+		func(kv *mvccpb.KeyValue) error { return nil },
+		func() error { return nil },
+	)
+	watcher.Close()
+	wg.Add(1)
+	go watcher.StartWatchLoop()
+	wg.Wait()
+	suite.Equal(int64(1), puts.Load())
+}
+
+func (suite *loopWatcherTestSuite) TestMinStartRevision() {
+	resp, err := clientv3.NewKV(suite.client).Put(suite.ctx, "TestMinStartRevision/0", "0")
+	suite.NoError(err)
+	loadRevision := resp.Header.Revision
+	cache := struct {
+		sync.RWMutex
+		data []string
+	}{}
+	newWatcher := func(minStartRevision int64) *LoopWatcher {
+		watcher := NewLoopWatcher(
+			suite.ctx,
+			&suite.wg,
+			suite.client,
+			"test",
+			"TestMinStartRevision/",
+			func(kv *mvccpb.KeyValue) error {
+				cache.Lock()
+				defer cache.Unlock()
+				cache.data = append(cache.data, string(kv.Key))
+				return nil
+			},
+			func(kv *mvccpb.KeyValue) error { return nil },
+			func() error { return nil },
+			clientv3.WithPrefix(),
+		)
+		watcher.SetMinStartRevision(minStartRevision)
+		suite.wg.Add(1)
+		go watcher.StartWatchLoop()
+		suite.NoError(watcher.WaitLoad())
+		return watcher
+	}
+	// The min start revision is higher than the load revision, so the events
+	// before it should be skipped.
+	watcher := newWatcher(loadRevision + 3)
+	for i := 1; i <= 3; i++ {
+		suite.put(fmt.Sprintf("TestMinStartRevision/%d", i), "")
+	}
+	testutil.Eventually(suite.Require(), func() bool {
+		cache.RLock()
+		defer cache.RUnlock()
+		return len(cache.data) == 2
+	})
+	cache.RLock()
+	suite.Equal([]string{"TestMinStartRevision/0", "TestMinStartRevision/3"}, cache.data)
+	cache.RUnlock()
+	watcher.Stop()
+
+	// The min start revision is lower than the load revision, so it takes no effect.
+	cache.Lock()
+	cache.data = nil
+	cache.Unlock()
+	watcher = newWatcher(loadRevision)
+	defer watcher.Stop()
+	suite.put("TestMinStartRevision/4", "")
+	testutil.Eventually(suite.Require(), func() bool {
+		cache.RLock()
+		defer cache.RUnlock()
+		return len(cache.data) == 5
+	})
 }
 
-func (suite *loopWatcherTestSuite) SetupSuite() {
-	t := suite.T()
-	suite.ctx, suite.cancel = context.WithCancel(context.Background())
-	suite.cleans = make([]func(), 0)
-	// Start a etcd server and create a client with etcd1 as endpoint.
-	suite.config = NewTestSingleConfig(t)
-	suite.startEtcd()
-	ep1 := suite.config.LCUrls[0].String()
-	urls, err := types.NewURLs([]string{ep1})
+func (suite *loopWatcherTestSuite) TestLastSyncedRevision() {
+	kv := clientv3.NewKV(suite.client)
+	resp, err := kv.Put(suite.ctx, "TestLastSyncedRevision/0", "0")
 	suite.NoError(err)
-	suite.client, err = CreateEtcdClient(nil, urls[0])
+	watcher := NewLoopWatcher(
+		suite.ctx,
+		&suite.wg,
+		suite.client,
+		"test",
+		"TestLastSyncedRevision/",
+		func(kv *mvccpb.KeyValue) error { return nil },
+		func(kv *mvccpb.KeyValue) error { return nil },
+		func() error { return nil },
+		clientv3.WithPrefix(),
+	)
+	defer watcher.Stop()
+	suite.Zero(watcher.GetLastSyncedRevision())
+	suite.wg.Add(1)
+	go watcher.StartWatchLoop()
+	suite.NoError(watcher.WaitLoad())
+	suite.GreaterOrEqual(watcher.GetLastSyncedRevision(), resp.Header.Revision)
+
+	// The revision follows the watched events.
+	resp, err = kv.Put(suite.ctx, "TestLastSyncedRevision/1", "1")
 	suite.NoError(err)
-	suite.cleans = append(suite.cleans, func() {
-		suite.client.Close()
+	testutil.Eventually(suite.Require(), func() bool {
+		return watcher.GetLastSyncedRevision() == resp.Header.Revision
 	})
-}
 
-func (suite *loopWatcherTestSuite) TearDownSuite() {
-	suite.cancel()
-	suite.wg.Wait()
-	for _, clean := range suite.cleans {
-		clean()
-	}
+	// The force load catches up with the current revision, including the unwatched changes.
+	resp, err = kv.Put(suite.ctx, "TestLastSyncedRevisionOther", "1")
+	suite.NoError(err)
+	suite.Less(watcher.GetLastSyncedRevision(), resp.Header.Revision)
+	time.Sleep(defaultForceLoadMinimalInterval)
+	watcher.ForceLoad()
+	testutil.Eventually(suite.Require(), func() bool {
+		return watcher.GetLastSyncedRevision() >= resp.Header.Revision
+	})
 }
 
-func (suite *loopWatcherTestSuite) TestLoadWithoutKey() {
-	cache := struct {
-		sync.RWMutex
-		data map[string]struct{}
-	}{
-		data: make(map[string]struct{}),
+func (suite *loopWatcherTestSuite) TestMetrics() {
+	const name = "TestMetrics"
+	counter := func(typ string) float64 {
+		return promtestutil.ToFloat64(loopWatcherEventCounter.WithLabelValues(name, typ))
 	}
+	suite.NoError(failpoint.Enable("github.com/tikv/pd/pkg/utils/etcdutil/loadTemporaryFail", "return(2)"))
 	watcher := NewLoopWatcher(
 		suite.ctx,
 		&suite.wg,
 		suite.client,
-		"test",
-		"TestLoadWithoutKey",
-		func(kv *mvccpb.KeyValue) error {
-			cache.Lock()
-			defer cache.Unlock()
-			cache.data[string(kv.Key)] = struct{}{}
-			return nil
-		},
+		name,
+		"TestMetrics/",
+		func(kv *mvccpb.KeyValue) error { return nil },
 		func(kv *mvccpb.KeyValue) error { return nil },
 		func() error { return nil },
+		clientv3.WithPrefix(),
 	)
-
+	defer watcher.Stop()
+	watcher.SetLoadRetryBackoff(10*time.Millisecond, 10*time.Millisecond)
+	watcher.watchChangeRetryInterval = 100 * time.Millisecond
 	suite.wg.Add(1)
 	go watcher.StartWatchLoop()
-	err := watcher.WaitLoad()
-	suite.NoError(err) // although no key, watcher returns no error
-	cache.RLock()
-	defer cache.RUnlock()
-	suite.Len(cache.data, 0)
+	suite.NoError(watcher.WaitLoad())
+	suite.NoError(failpoint.Disable("github.com/tikv/pd/pkg/utils/etcdutil/loadTemporaryFail"))
+	suite.Equal(float64(2), counter(eventTypeLoadRetry))
+	suite.Equal(float64(1), counter(eventTypeLoad))
+	suite.Positive(promtestutil.CollectAndCount(loopWatcherLoadDuration))
+
+	suite.put("TestMetrics/1", "1")
+	suite.put("TestMetrics/2", "2")
+	_, err := clientv3.NewKV(suite.client).Delete(suite.ctx, "TestMetrics/1")
+	suite.NoError(err)
+	testutil.Eventually(suite.Require(), func() bool {
+		return counter(eventTypePut) == 2 && counter(eventTypeDelete) == 1
+	})
+
+	time.Sleep(defaultForceLoadMinimalInterval)
+	watcher.ForceLoad()
+	testutil.Eventually(suite.Require(), func() bool {
+		return counter(eventTypeLoad) == 2
+	})
+
+	suite.Zero(counter(eventTypeWatchRetry))
+	suite.NoError(failpoint.Enable("github.com/tikv/pd/pkg/utils/etcdutil/closeWatchChan", "return(true)"))
+	defer func() {
+		suite.NoError(failpoint.Disable("github.com/tikv/pd/pkg/utils/etcdutil/closeWatchChan"))
+	}()
+	// The failpoint takes effect once the watch is recreated after handling the next response.
+	suite.put("TestMetrics/3", "3")
+	testutil.Eventually(suite.Require(), func() bool {
+		return counter(eventTypeWatchRetry) > 0
+	})
 }
 
-func (suite *loopWatcherTestSuite) TestCallBack() {
-	cache := struct {
-		sync.RWMutex
-		data map[string]struct{}
-	}{
-		data: make(map[string]struct{}),
-	}
-	result := make([]string, 0)
+func (suite *loopWatcherTestSuite) TestRawWatchObserver() {
+	var (
+		puts      atomic.Int64
+		observed  atomic.Int64
+		responses = make(chan clientv3.WatchResponse, 10)
+	)
 	watcher := NewLoopWatcher(
 		suite.ctx,
 		&suite.wg,
 		suite.client,
 		"test",
-		"TestCallBack",
-		func(kv *mvccpb.KeyValue) error {
-			result = append(result, string(kv.Key))
-			return nil
-		},
+		"TestRawWatchObserver",
 		func(kv *mvccpb.KeyValue) error {
-			cache.Lock()
-			defer cache.Unlock()
-			delete(cache.data, string(kv.Key))
+			puts.Add(1)
 			return nil
 		},
-		func() error {
-			cache.Lock()
-			defer cache.Unlock()
-			for _, r := range result {
-				cache.data[r] = struct{}{}
-			}
-			result = result[:0]
-			return nil
-		},
-		clientv3.WithPrefix(),
+		func(kv *mvccpb.KeyValue) error { return nil },
+		func() error { return nil },
 	)
-
+	watcher.SetRawWatchObserver(func(wresp clientv3.WatchResponse) {
+		// The observer is invoked before the events are handled.
+		suite.Equal(observed.Add(1)-1, puts.Load())
+		responses <- wresp
+	})
 	suite.wg.Add(1)
 	go watcher.StartWatchLoop()
-	err := watcher.WaitLoad()
-	suite.NoError(err)
+	suite.NoError(watcher.WaitLoad())
+	defer watcher.Stop()
 
-	// put 10 keys
-	for i := 0; i < 10; i++ {
-		suite.put(fmt.Sprintf("TestCallBack%d", i), "")
-	}
-	time.Sleep(time.Second)
-	cache.RLock()
-	suite.Len(cache.data, 10)
-	cache.RUnlock()
-
-	// delete 10 keys
-	for i := 0; i < 10; i++ {
-		key := fmt.Sprintf("TestCallBack%d", i)
-		_, err = suite.client.Delete(suite.ctx, key)
+	kv := clientv3.NewKV(suite.client)
+	for i := 0; i < 3; i++ {
+		resp, err := kv.Put(suite.ctx, "TestRawWatchObserver", fmt.Sprintf("%d", i))
 		suite.NoError(err)
+		select {
+		case wresp := <-responses:
+			suite.Equal(resp.Header.Revision, wresp.Header.Revision)
+			suite.Zero(wresp.CompactRevision)
+			suite.Len(wresp.Events, 1)
+			suite.Equal(resp.Header.Revision, wresp.Events[0].Kv.ModRevision)
+		case <-time.After(5 * time.Second):
+			suite.FailNow("the watch response is not observed")
+		}
 	}
-	time.Sleep(time.Second)
-	cache.RLock()
-	suite.Empty(cache.data)
-	cache.RUnlock()
+	testutil.Eventually(suite.Require(), func() bool {
+		return puts.Load() == 3
+	})
 }
 
 func (suite *loopWatcherTestSuite) TestWatcherLoadLimit() {
@@ -636,6 +2232,132 @@ func (suite *loopWatcherTestSuite) TestWatcherLoadLimit() {
 	}
 }
 
+func (suite *loopWatcherTestSuite) TestBatchPutFn() {
+	const count = 10
+	for i := 0; i < count; i++ {
+		suite.put(fmt.Sprintf("TestBatchPutFn/%02d", i), "")
+	}
+	cache := struct {
+		sync.RWMutex
+		batches [][]string
+		puts    []string
+	}{}
+	var postEvents atomic.Int64
+	watcher := NewLoopWatcher(
+		suite.ctx,
+		&suite.wg,
+		suite.client,
+		"test",
+		"TestBatchPutFn/",
+		func(kv *mvccpb.KeyValue) error {
+			cache.Lock()
+			defer cache.Unlock()
+			cache.puts = append(cache.puts, string(kv.Key))
+			return nil
+		},
+		func(kv *mvccpb.KeyValue) error { return nil },
+		func() error {
+			postEvents.Add(1)
+			return nil
+		},
+		clientv3.WithPrefix(),
+	)
+	watcher.SetLoadBatchSize(3)
+	watcher.SetBatchPutFn(func(kvs []*mvccpb.KeyValue) error {
+		cache.Lock()
+		defer cache.Unlock()
+		batch := make([]string, 0, len(kvs))
+		for _, kv := range kvs {
+			batch = append(batch, string(kv.Key))
+		}
+		cache.batches = append(cache.batches, batch)
+		return nil
+	})
+	suite.wg.Add(1)
+	go watcher.StartWatchLoop()
+	defer watcher.Stop()
+	suite.NoError(watcher.WaitLoad())
+	// Each page is handed to batchPutFn as a whole, and postEventFn is called once after the load.
+	cache.RLock()
+	suite.Equal([][]string{
+		{"TestBatchPutFn/00", "TestBatchPutFn/01", "TestBatchPutFn/02"},
+		{"TestBatchPutFn/03", "TestBatchPutFn/04", "TestBatchPutFn/05"},
+		// The extra key fetched to check whether there are more keys is kept in the last page.
+		{"TestBatchPutFn/06", "TestBatchPutFn/07", "TestBatchPutFn/08", "TestBatchPutFn/09"},
+	}, cache.batches)
+	suite.Empty(cache.puts)
+	cache.RUnlock()
+	suite.Equal(int64(1), postEvents.Load())
+
+	// The events from the watch are still handled by putFn.
+	suite.put("TestBatchPutFn/10", "")
+	testutil.Eventually(suite.Require(), func() bool {
+		cache.RLock()
+		defer cache.RUnlock()
+		return len(cache.puts) == 1
+	})
+	cache.RLock()
+	suite.Equal([]string{"TestBatchPutFn/10"}, cache.puts)
+	suite.Len(cache.batches, 3)
+	cache.RUnlock()
+}
+
+func (suite *loopWatcherTestSuite) TestReplayEvents() {
+	putCount := 0
+	watcher := NewLoopWatcher(
+		suite.ctx,
+		&suite.wg,
+		suite.client,
+		"test",
+		"TestReplayEvents",
+		func(kv *mvccpb.KeyValue) error {
+			putCount++
+			return nil
+		},
+		func(kv *mvccpb.KeyValue) error { return nil },
+		func() error { return nil },
+		clientv3.WithPrefix(),
+	)
+	// Write a sequence of events and record their revisions.
+	revisions := make([]int64, 0, 6)
+	for i := 0; i < 5; i++ {
+		resp, err := suite.client.Put(suite.ctx, fmt.Sprintf("TestReplayEvents%d", i), fmt.Sprintf("%d", i))
+		suite.NoError(err)
+		revisions = append(revisions, resp.Header.Revision)
+	}
+	resp, err := suite.client.Delete(suite.ctx, "TestReplayEvents1")
+	suite.NoError(err)
+	revisions = append(revisions, resp.Header.Revision)
+	// Some events out of the watched key.
+	suite.put("TestReplayOther", "")
+
+	// Replay a sub-range.
+	replayed := make([]*clientv3.Event, 0)
+	err = watcher.ReplayEvents(suite.ctx, revisions[2], revisions[5], func(event *clientv3.Event) error {
+		replayed = append(replayed, event)
+		return nil
+	})
+	suite.NoError(err)
+	suite.Len(replayed, 4)
+	for i, event := range replayed[:3] {
+		suite.Equal(clientv3.EventTypePut, event.Type)
+		suite.Equal(fmt.Sprintf("TestReplayEvents%d", i+2), string(event.Kv.Key))
+		suite.Equal(revisions[i+2], event.Kv.ModRevision)
+	}
+	suite.Equal(clientv3.EventTypeDelete, replayed[3].Type)
+	suite.Equal("TestReplayEvents1", string(replayed[3].Kv.Key))
+	// The live callbacks are not touched.
+	suite.Zero(putCount)
+
+	// The error of handler is returned.
+	err = watcher.ReplayEvents(suite.ctx, revisions[0], revisions[5], func(*clientv3.Event) error {
+		return errors.New("handler failed")
+	})
+	suite.ErrorContains(err, "handler failed")
+	// Invalid revision range.
+	suite.Error(watcher.ReplayEvents(suite.ctx, revisions[5], revisions[0], func(*clientv3.Event) error { return nil }))
+}
+
 func (suite *loopWatcherTestSuite) TestWatcherBreak() {
 	cache := struct {
 		sync.RWMutex
@@ -723,6 +2445,151 @@ func (suite *loopWatcherTestSuite) TestWatcherBreak() {
 	failpoint.Disable("github.com/tikv/pd/pkg/utils/etcdutil/updateClient")
 }
 
+func (suite *loopWatcherTestSuite) TestWatchChanClosed() {
+	cache := struct {
+		sync.RWMutex
+		data string
+	}{}
+	watcher := NewLoopWatcher(
+		suite.ctx,
+		&suite.wg,
+		suite.client,
+		"test",
+		"TestWatchChanClosed",
+		func(kv *mvccpb.KeyValue) error {
+			cache.Lock()
+			defer cache.Unlock()
+			cache.data = string(kv.Value)
+			return nil
+		},
+		func(kv *mvccpb.KeyValue) error { return nil },
+		func() error { return nil },
+	)
+	watcher.watchChangeRetryInterval = 100 * time.Millisecond
+	var responseCount atomic.Int32
+	watcher.SetRawWatchObserver(func(clientv3.WatchResponse) {
+		responseCount.Add(1)
+	})
+	suite.wg.Add(1)
+	go watcher.StartWatchLoop()
+	suite.NoError(watcher.WaitLoad())
+
+	// The closed watch channel should not be regarded as the empty responses and make the loop spin.
+	suite.NoError(failpoint.Enable("github.com/tikv/pd/pkg/utils/etcdutil/closeWatchChan", "return(true)"))
+	time.Sleep(500 * time.Millisecond)
+	suite.NoError(failpoint.Disable("github.com/tikv/pd/pkg/utils/etcdutil/closeWatchChan"))
+	suite.Zero(responseCount.Load())
+
+	// The watch loop restarts cleanly.
+	suite.put("TestWatchChanClosed", "1")
+	testutil.Eventually(suite.Require(), func() bool {
+		cache.RLock()
+		defer cache.RUnlock()
+		return cache.data == "1"
+	}, testutil.WithWaitFor(time.Second))
+	suite.Equal(int32(1), responseCount.Load())
+	watcher.Stop()
+}
+
+func (suite *loopWatcherTestSuite) TestMultipleTargets() {
+	type cache struct {
+		sync.RWMutex
+		data map[string]string
+	}
+	newTarget := func(prefix string, c *cache) LoopWatchTarget {
+		c.data = make(map[string]string)
+		return LoopWatchTarget{
+			Key:  prefix,
+			Opts: []clientv3.OpOption{clientv3.WithPrefix()},
+			PutFn: func(kv *mvccpb.KeyValue) error {
+				c.Lock()
+				defer c.Unlock()
+				c.data[string(kv.Key)] = string(kv.Value)
+				return nil
+			},
+			DeleteFn: func(kv *mvccpb.KeyValue) error {
+				c.Lock()
+				defer c.Unlock()
+				delete(c.data, string(kv.Key))
+				return nil
+			},
+		}
+	}
+	get := func(c *cache, key string) (string, bool) {
+		c.RLock()
+		defer c.RUnlock()
+		value, ok := c.data[key]
+		return value, ok
+	}
+	suite.put("TestMultipleTargets/a/1", "a1")
+	suite.put("TestMultipleTargets/b/1", "b1")
+	var cacheA, cacheB cache
+	var postEventCount atomic.Int32
+	watcher := NewMultiLoopWatcher(
+		suite.ctx,
+		&suite.wg,
+		suite.client,
+		"test",
+		[]LoopWatchTarget{
+			newTarget("TestMultipleTargets/a/", &cacheA),
+			newTarget("TestMultipleTargets/b/", &cacheB),
+		},
+		func() error {
+			postEventCount.Add(1)
+			return nil
+		},
+	)
+	suite.wg.Add(1)
+	go watcher.StartWatchLoop()
+	// The loaded signal is sent only once after all the targets are loaded.
+	suite.NoError(watcher.WaitLoad())
+	value, ok := get(&cacheA, "TestMultipleTargets/a/1")
+	suite.True(ok)
+	suite.Equal("a1", value)
+	value, ok = get(&cacheB, "TestMultipleTargets/b/1")
+	suite.True(ok)
+	suite.Equal("b1", value)
+	suite.Equal(int32(1), postEventCount.Load())
+	select {
+	case err := <-watcher.isLoadedCh:
+		suite.FailNow("unexpected loaded signal", "%v", err)
+	default:
+	}
+
+	// Each event is dispatched to the handlers of its own target.
+	suite.put("TestMultipleTargets/a/2", "a2")
+	suite.put("TestMultipleTargets/b/2", "b2")
+	_, err := clientv3.NewKV(suite.client).Delete(suite.ctx, "TestMultipleTargets/a/1")
+	suite.NoError(err)
+	testutil.Eventually(suite.Require(), func() bool {
+		_, ok1 := get(&cacheA, "TestMultipleTargets/a/1")
+		v2, ok2 := get(&cacheA, "TestMultipleTargets/a/2")
+		v3, ok3 := get(&cacheB, "TestMultipleTargets/b/2")
+		return !ok1 && ok2 && v2 == "a2" && ok3 && v3 == "b2"
+	}, testutil.WithWaitFor(time.Second))
+	_, ok = get(&cacheA, "TestMultipleTargets/b/2")
+	suite.False(ok)
+	_, ok = get(&cacheB, "TestMultipleTargets/a/2")
+	suite.False(ok)
+
+	// The force load reloads all the targets.
+	cacheA.Lock()
+	cacheA.data = make(map[string]string)
+	cacheA.Unlock()
+	cacheB.Lock()
+	cacheB.data = make(map[string]string)
+	cacheB.Unlock()
+	time.Sleep(defaultForceLoadMinimalInterval)
+	watcher.ForceLoad()
+	testutil.Eventually(suite.Require(), func() bool {
+		_, ok1 := get(&cacheA, "TestMultipleTargets/a/2")
+		_, ok2 := get(&cacheB, "TestMultipleTargets/b/1")
+		_, ok3 := get(&cacheB, "TestMultipleTargets/b/2")
+		return ok1 && ok2 && ok3
+	}, testutil.WithWaitFor(time.Second))
+	watcher.Stop()
+}
+
 func (suite *loopWatcherTestSuite) startEtcd() {
 	etcd1, err := embed.StartEtcd(suite.config)
 	suite.NoError(err)
@@ -733,6 +2600,65 @@ func (suite *loopWatcherTestSuite) startEtcd() {
 	})
 }
 
+// logWriter is a WriteSyncer that collects the logs in memory.
+type logWriter struct {
+	sync.Mutex
+	strings.Builder
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.Lock()
+	defer w.Unlock()
+	return w.Builder.Write(p)
+}
+
+func (w *logWriter) Sync() error {
+	return nil
+}
+
+func (w *logWriter) String() string {
+	w.Lock()
+	defer w.Unlock()
+	return w.Builder.String()
+}
+
+func (suite *loopWatcherTestSuite) TestLogKVFormatter() {
+	writer := &logWriter{}
+	lg, p, err := log.InitLoggerWithWriteSyncer(&log.Config{Level: "debug"}, writer, writer)
+	suite.NoError(err)
+	restore := log.ReplaceGlobals(lg, p)
+	SetLogKVFormatter(func(key []byte) string {
+		return fmt.Sprintf("hex-key:%X", key)
+	}, func(value []byte) string {
+		return fmt.Sprintf("hex-value:%X", value)
+	})
+	defer func() {
+		SetLogKVFormatter(nil, nil)
+		restore()
+	}()
+
+	watcher := NewLoopWatcher(
+		suite.ctx,
+		&suite.wg,
+		suite.client,
+		"test",
+		"TestLogKVFormatter",
+		func(kv *mvccpb.KeyValue) error { return nil },
+		func(kv *mvccpb.KeyValue) error { return nil },
+		func() error { return nil },
+	)
+	suite.wg.Add(1)
+	go watcher.StartWatchLoop()
+	defer watcher.Stop()
+	suite.NoError(watcher.WaitLoad())
+	suite.put("TestLogKVFormatter", "\x01\x02")
+	expectedKey := fmt.Sprintf("hex-key:%X", "TestLogKVFormatter")
+	testutil.Eventually(suite.Require(), func() bool {
+		return strings.Contains(writer.String(), expectedKey)
+	})
+	suite.Contains(writer.String(), "hex-value:0102")
+}
+
 func (suite *loopWatcherTestSuite) put(key, value string) {
 	kv := clientv3.NewKV(suite.client)
 	_, err := kv.Put(suite.ctx, key, value)
@@ -0,0 +1,168 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdutil
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/utils/logutil"
+	"github.com/tikv/pd/pkg/utils/typeutil"
+	"go.etcd.io/etcd/clientv3"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultEndpointProbeInterval is the interval to probe the health of the etcd endpoints.
+	defaultEndpointProbeInterval = time.Second
+	// defaultEndpointProbeTimeout is the timeout of probing the health of an etcd endpoint.
+	defaultEndpointProbeTimeout = time.Second
+)
+
+// healthChecker probes the etcd endpoints periodically and puts the healthy ones in front of the others
+// in the client, so that the requests won't hang on an endpoint with io hang until the keepalive finds it broken.
+type healthChecker struct {
+	client        *clientv3.Client
+	probeInterval time.Duration
+	probeTimeout  time.Duration
+	// endpoints are the client URLs of the etcd members in the last round, which are used
+	// if the member list fails to be fetched in the current round.
+	endpoints []string
+}
+
+// HealthCheckOption is used to configure the health checker.
+type HealthCheckOption func(*healthChecker)
+
+// WithProbeInterval sets the interval to probe the health of the etcd endpoints.
+func WithProbeInterval(interval time.Duration) HealthCheckOption {
+	return func(checker *healthChecker) { checker.probeInterval = interval }
+}
+
+// WithProbeTimeout sets the timeout of probing the health of an etcd endpoint.
+func WithProbeTimeout(timeout time.Duration) HealthCheckOption {
+	return func(checker *healthChecker) { checker.probeTimeout = timeout }
+}
+
+func newHealthChecker(client *clientv3.Client, opts ...HealthCheckOption) *healthChecker {
+	checker := &healthChecker{
+		client:        client,
+		probeInterval: defaultEndpointProbeInterval,
+		probeTimeout:  defaultEndpointProbeTimeout,
+		endpoints:     client.Endpoints(),
+	}
+	for _, opt := range opts {
+		opt(checker)
+	}
+	return checker
+}
+
+// run probes the endpoints periodically until the client is closed.
+func (checker *healthChecker) run() {
+	defer logutil.LogPanic()
+	ticker := time.NewTicker(checker.probeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-checker.client.Ctx().Done():
+			log.Info("etcd client is closed, exit the health checker")
+			return
+		case <-ticker.C:
+			checker.check()
+		}
+	}
+}
+
+func (checker *healthChecker) check() {
+	usedEps := checker.client.Endpoints()
+	// Rebuild the endpoints from the current members, so the removed members are dropped
+	// and the new members are probed as well.
+	checker.endpoints = checker.memberEndpoints()
+	healthy := checker.probe(checker.endpoints)
+	if len(healthy) == 0 {
+		// Keep the endpoints as they are since there is nothing better to switch to.
+		log.Warn("no healthy etcd endpoint", zap.Strings("endpoints", checker.endpoints))
+		return
+	}
+	// Put the healthy endpoints first and keep the unhealthy ones after them,
+	// so the client could still fall back to them once they recover.
+	eps := make([]string, 0, len(checker.endpoints))
+	for _, ep := range checker.endpoints {
+		if healthy[ep] {
+			eps = append(eps, ep)
+		}
+	}
+	for _, ep := range checker.endpoints {
+		if !healthy[ep] {
+			eps = append(eps, ep)
+		}
+	}
+	if !typeutil.StringsEqual(eps, usedEps) {
+		checker.client.SetEndpoints(eps...)
+		log.Info("update the etcd endpoints with the healthy ones first",
+			zap.Strings("last-endpoints", usedEps), zap.Strings("endpoints", eps),
+			zap.Int("healthy-count", len(healthy)))
+	}
+}
+
+// memberEndpoints returns the client URLs of the current etcd members,
+// or the endpoints of the last round if it fails to list the members.
+func (checker *healthChecker) memberEndpoints() []string {
+	ctx, cancel := context.WithTimeout(checker.client.Ctx(), checker.probeTimeout)
+	defer cancel()
+	resp, err := checker.client.MemberList(ctx)
+	if err != nil {
+		log.Warn("failed to list the etcd members, probe the last endpoints",
+			zap.Strings("endpoints", checker.endpoints), zap.Error(err))
+		return checker.endpoints
+	}
+	eps := make([]string, 0, len(resp.Members))
+	for _, m := range resp.Members {
+		// The member which has not been started yet has no client URLs.
+		eps = append(eps, m.GetClientURLs()...)
+	}
+	if len(eps) == 0 {
+		return checker.endpoints
+	}
+	return eps
+}
+
+// probe checks the health of the endpoints concurrently and returns the healthy ones.
+func (checker *healthChecker) probe(endpoints []string) map[string]bool {
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		healthy = make(map[string]bool, len(endpoints))
+	)
+	for _, ep := range endpoints {
+		wg.Add(1)
+		go func(ep string) {
+			defer logutil.LogPanic()
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(checker.client.Ctx(), checker.probeTimeout)
+			defer cancel()
+			if _, err := checker.client.Status(ctx, ep); err != nil {
+				log.Warn("etcd endpoint is unhealthy", zap.String("endpoint", ep), zap.Error(err))
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			healthy[ep] = true
+		}(ep)
+	}
+	wg.Wait()
+	return healthy
+}
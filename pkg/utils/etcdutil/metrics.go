@@ -0,0 +1,86 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdutil
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	nameLabel = "name"
+	typeLabel = "type"
+
+	eventTypePut        = "put"
+	eventTypeDelete     = "delete"
+	eventTypeLoad       = "load"
+	eventTypeLoadRetry  = "load_retry"
+	eventTypeWatchRetry = "watch_retry"
+	eventTypeWatchIdle  = "watch_idle"
+)
+
+var (
+	loopWatcherEventCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "pd",
+			Subsystem: "loop_watcher",
+			Name:      "events",
+			Help:      "Counter of the puts, deletes, loads, retries and idle watches handled by each loop watcher.",
+		}, []string{nameLabel, typeLabel})
+
+	loopWatcherLoadDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "pd",
+			Subsystem: "loop_watcher",
+			Name:      "load_duration_seconds",
+			Help:      "Bucketed histogram of the duration (s) of loading data from etcd by each loop watcher.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 16), // 1ms ~ 32s
+		}, []string{nameLabel})
+)
+
+var registerOnce sync.Once
+
+// RegisterMetrics registers the metrics of the loop watchers to the given registerer.
+// It only takes effect once, the later calls are ignored.
+func RegisterMetrics(registerer prometheus.Registerer) {
+	registerOnce.Do(func() {
+		registerer.MustRegister(loopWatcherEventCounter)
+		registerer.MustRegister(loopWatcherLoadDuration)
+	})
+}
+
+// loopWatcherMetrics is the metrics of a loop watcher, which are labeled by its name.
+type loopWatcherMetrics struct {
+	putCounter        prometheus.Counter
+	deleteCounter     prometheus.Counter
+	loadCounter       prometheus.Counter
+	loadRetryCounter  prometheus.Counter
+	watchRetryCounter prometheus.Counter
+	watchIdleCounter  prometheus.Counter
+	loadDuration      prometheus.Observer
+}
+
+func newLoopWatcherMetrics(name string) *loopWatcherMetrics {
+	return &loopWatcherMetrics{
+		putCounter:        loopWatcherEventCounter.WithLabelValues(name, eventTypePut),
+		deleteCounter:     loopWatcherEventCounter.WithLabelValues(name, eventTypeDelete),
+		loadCounter:       loopWatcherEventCounter.WithLabelValues(name, eventTypeLoad),
+		loadRetryCounter:  loopWatcherEventCounter.WithLabelValues(name, eventTypeLoadRetry),
+		watchRetryCounter: loopWatcherEventCounter.WithLabelValues(name, eventTypeWatchRetry),
+		watchIdleCounter:  loopWatcherEventCounter.WithLabelValues(name, eventTypeWatchIdle),
+		loadDuration:      loopWatcherLoadDuration.WithLabelValues(name),
+	}
+}
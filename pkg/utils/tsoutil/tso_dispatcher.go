@@ -175,12 +175,12 @@ func (s *TSODispatcher) processRequests(forwardStream stream, requests []Request
 	// `logical` is the largest ts's logical part here, we need to do the subtracting before we finish each TSO request.
 	// This is different from the logic of client batch, for example, if we have a largest ts whose logical part is 10,
 	// count is 5, then the splitting results should be 5 and 10.
-	firstLogical := addLogical(logical, -int64(count), suffixBits)
+	firstLogical := AddLogical(logical, -int64(count), suffixBits)
 	return s.finishRequest(requests, physical, firstLogical, suffixBits)
 }
 
-// Because of the suffix, we need to shift the count before we add it to the logical part.
-func addLogical(logical, count int64, suffixBits uint32) int64 {
+// AddLogical shifts the count before we add it to the logical part because of the suffix.
+func AddLogical(logical, count int64, suffixBits uint32) int64 {
 	return logical + count<<suffixBits
 }
 
@@ -92,7 +92,7 @@ func (r *TSOProtoRequest) postProcess(countSum, physical, firstLogical int64, su
 		Count:  count,
 		Timestamp: &pdpb.Timestamp{
 			Physical:   physical,
-			Logical:    addLogical(firstLogical, countSum, suffixBits),
+			Logical:    AddLogical(firstLogical, countSum, suffixBits),
 			SuffixBits: suffixBits,
 		},
 	}
@@ -153,7 +153,7 @@ func (r *PDProtoRequest) postProcess(countSum, physical, firstLogical int64, suf
 		Count:  count,
 		Timestamp: &pdpb.Timestamp{
 			Physical:   physical,
-			Logical:    addLogical(firstLogical, countSum, suffixBits),
+			Logical:    AddLogical(firstLogical, countSum, suffixBits),
 			SuffixBits: suffixBits,
 		},
 	}
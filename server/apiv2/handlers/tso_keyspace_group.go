@@ -15,19 +15,24 @@
 package handlers
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
 	"sync"
 
 	"github.com/gin-gonic/gin"
+	"github.com/pingcap/log"
 	"github.com/pkg/errors"
 	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/pkg/keyspace"
 	"github.com/tikv/pd/pkg/mcs/utils"
 	"github.com/tikv/pd/pkg/slice"
 	"github.com/tikv/pd/pkg/storage/endpoint"
 	"github.com/tikv/pd/server"
 	"github.com/tikv/pd/server/apiv2/middlewares"
+	"go.uber.org/zap"
 )
 
 const groupManagerUninitializedErr = "keyspace group manager is not initialized"
@@ -38,15 +43,21 @@ func RegisterTSOKeyspaceGroup(r *gin.RouterGroup) {
 	router.Use(middlewares.BootstrapChecker())
 	router.POST("", CreateKeyspaceGroups)
 	router.GET("", GetKeyspaceGroups)
+	router.POST("/reallocate", ReallocateKeyspaceGroups)
+	router.GET("/watch", WatchKeyspaceGroups)
 	router.GET("/:id", GetKeyspaceGroupByID)
 	router.DELETE("/:id", DeleteKeyspaceGroupByID)
+	router.GET("/:id/keyspaces", GetKeyspacesInKeyspaceGroup)
 	router.PATCH("/:id", SetNodesForKeyspaceGroup)          // only to support set nodes
 	router.PATCH("/:id/*node", SetPriorityForKeyspaceGroup) // only to support set priority
 	router.POST("/:id/alloc", AllocNodesForKeyspaceGroup)
 	router.POST("/:id/split", SplitKeyspaceGroupByID)
 	router.DELETE("/:id/split", FinishSplitKeyspaceByID)
+	router.POST("/:id/rollback-split", RollbackSplitKeyspaceGroupByID)
 	router.POST("/:id/merge", MergeKeyspaceGroups)
 	router.DELETE("/:id/merge", FinishMergeKeyspaceByID)
+	router.GET("/:id/progress", GetKeyspaceGroupProgress)
+	router.POST("/:id/reset-state", ResetKeyspaceGroupState)
 }
 
 // CreateKeyspaceGroupParams defines the params for creating keyspace groups.
@@ -89,8 +100,12 @@ func CreateKeyspaceGroups(c *gin.Context) {
 	c.JSON(http.StatusOK, nil)
 }
 
+// keyspaceGroupsStreamPageSize is the number of keyspace groups loaded in each page when streaming.
+const keyspaceGroupsStreamPageSize = 100
+
 // GetKeyspaceGroups gets keyspace groups from the start ID with limit.
 // If limit is 0, it will load all keyspace groups from the start ID.
+// If the query `stream` is true, the keyspace groups will be streamed as NDJSON page by page.
 func GetKeyspaceGroups(c *gin.Context) {
 	scanStart, scanLimit, err := parseLoadAllQuery(c)
 	if err != nil {
@@ -104,35 +119,81 @@ func GetKeyspaceGroups(c *gin.Context) {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, groupManagerUninitializedErr)
 		return
 	}
+	state, _ := c.GetQuery("state")
+	if stream, _ := c.GetQuery("stream"); stream == "true" {
+		streamKeyspaceGroups(c, manager, scanStart, scanLimit, state)
+		return
+	}
 	keyspaceGroups, err := manager.GetKeyspaceGroups(scanStart, scanLimit)
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
 		return
 	}
-	var kgs []*endpoint.KeyspaceGroup
-	state, set := c.GetQuery("state")
-	if set {
-		state := strings.ToLower(state)
-		switch state {
-		case "merge":
-			for _, keyspaceGroup := range keyspaceGroups {
-				if keyspaceGroup.MergeState != nil {
-					kgs = append(kgs, keyspaceGroup)
-				}
+
+	c.IndentedJSON(http.StatusOK, filterKeyspaceGroupsByState(keyspaceGroups, state))
+}
+
+// streamKeyspaceGroups writes the keyspace groups from the start ID as NDJSON, one keyspace group
+// per line. The keyspace groups are loaded and flushed page by page to avoid holding all of them in memory.
+// Same as the non-streaming path, at most scanLimit keyspace groups are loaded if scanLimit is not 0.
+func streamKeyspaceGroups(c *gin.Context, manager *keyspace.GroupManager, scanStart uint32, scanLimit int, state string) {
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(http.StatusOK)
+	encoder := json.NewEncoder(c.Writer)
+	for {
+		pageSize := keyspaceGroupsStreamPageSize
+		if scanLimit > 0 && scanLimit < pageSize {
+			pageSize = scanLimit
+		}
+		keyspaceGroups, err := manager.GetKeyspaceGroups(scanStart, pageSize)
+		if err != nil {
+			// The status code has been sent, so we can only log the error and break the stream.
+			log.Error("failed to stream keyspace groups", zap.Uint32("start-id", scanStart), errs.ZapError(err))
+			return
+		}
+		for _, kg := range filterKeyspaceGroupsByState(keyspaceGroups, state) {
+			if err := encoder.Encode(kg); err != nil {
+				log.Warn("failed to write the streamed keyspace group", zap.Uint32("keyspace-group-id", kg.ID), errs.ZapError(err))
+				return
 			}
-		case "split":
-			for _, keyspaceGroup := range keyspaceGroups {
-				if keyspaceGroup.SplitState != nil {
-					kgs = append(kgs, keyspaceGroup)
-				}
+		}
+		c.Writer.Flush()
+		if len(keyspaceGroups) < pageSize {
+			return
+		}
+		if scanLimit > 0 {
+			scanLimit -= len(keyspaceGroups)
+			if scanLimit == 0 {
+				return
 			}
-		default:
 		}
-	} else {
-		kgs = keyspaceGroups
+		scanStart = keyspaceGroups[len(keyspaceGroups)-1].ID + 1
 	}
+}
 
-	c.IndentedJSON(http.StatusOK, kgs)
+// filterKeyspaceGroupsByState filters the keyspace groups by the given state, which
+// can be "merge" or "split". All keyspace groups are returned if the state is empty.
+func filterKeyspaceGroupsByState(keyspaceGroups []*endpoint.KeyspaceGroup, state string) []*endpoint.KeyspaceGroup {
+	if state == "" {
+		return keyspaceGroups
+	}
+	var kgs []*endpoint.KeyspaceGroup
+	switch strings.ToLower(state) {
+	case "merge":
+		for _, keyspaceGroup := range keyspaceGroups {
+			if keyspaceGroup.MergeState != nil {
+				kgs = append(kgs, keyspaceGroup)
+			}
+		}
+	case "split":
+		for _, keyspaceGroup := range keyspaceGroups {
+			if keyspaceGroup.SplitState != nil {
+				kgs = append(kgs, keyspaceGroup)
+			}
+		}
+	default:
+	}
+	return kgs
 }
 
 // GetKeyspaceGroupByID gets keyspace group by ID.
@@ -158,6 +219,79 @@ func GetKeyspaceGroupByID(c *gin.Context) {
 	c.IndentedJSON(http.StatusOK, kg)
 }
 
+// KeyspacesInKeyspaceGroup is a page of the keyspaces in a keyspace group.
+type KeyspacesInKeyspaceGroup struct {
+	ID        uint32   `json:"id"`
+	Keyspaces []uint32 `json:"keyspaces"`
+	// Total is the number of all the keyspaces in the keyspace group.
+	Total int `json:"total"`
+}
+
+// GetKeyspacesInKeyspaceGroup gets the keyspaces in the keyspace group by ID from the offset with limit.
+// If limit is 0, it will return all keyspaces from the offset.
+func GetKeyspacesInKeyspaceGroup(c *gin.Context) {
+	id, err := validateKeyspaceGroupID(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, "invalid keyspace group id")
+		return
+	}
+	offset, limit, err := parseOffsetLimitQuery(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	svr := c.MustGet(middlewares.ServerContextKey).(*server.Server)
+	manager := svr.GetKeyspaceGroupManager()
+	if manager == nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, groupManagerUninitializedErr)
+		return
+	}
+	kg, err := manager.GetKeyspaceGroupByID(id)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	if kg == nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, "keyspace group does not exist")
+		return
+	}
+	total := len(kg.Keyspaces)
+	if offset > 0 && offset >= total {
+		c.AbortWithStatusJSON(http.StatusBadRequest,
+			fmt.Sprintf("offset %d is out of range, keyspace group %d only has %d keyspaces", offset, id, total))
+		return
+	}
+	end := total
+	if limit > 0 && offset+limit < total {
+		end = offset + limit
+	}
+	c.IndentedJSON(http.StatusOK, &KeyspacesInKeyspaceGroup{
+		ID:        id,
+		Keyspaces: kg.Keyspaces[offset:end],
+		Total:     total,
+	})
+}
+
+// parseOffsetLimitQuery parses the non-negative `offset` and `limit` queries, which are 0 if unset.
+func parseOffsetLimitQuery(c *gin.Context) (offset, limit int, err error) {
+	if offsetStr, set := c.GetQuery("offset"); set && offsetStr != "" {
+		offset64, err := strconv.ParseUint(offsetStr, 10, 32)
+		if err != nil {
+			return 0, 0, errors.Errorf("invalid offset: %s", offsetStr)
+		}
+		offset = int(offset64)
+	}
+	if limitStr, set := c.GetQuery("limit"); set && limitStr != "" {
+		limit64, err := strconv.ParseUint(limitStr, 10, 32)
+		if err != nil {
+			return 0, 0, errors.Errorf("invalid limit: %s", limitStr)
+		}
+		limit = int(limit64)
+	}
+	return offset, limit, nil
+}
+
 // DeleteKeyspaceGroupByID deletes keyspace group by ID.
 func DeleteKeyspaceGroupByID(c *gin.Context) {
 	id, err := validateKeyspaceGroupID(c)
@@ -187,6 +321,8 @@ type SplitKeyspaceGroupByIDParams struct {
 	// StartKeyspaceID and EndKeyspaceID are used to indicate the range of keyspaces to be split.
 	StartKeyspaceID uint32 `json:"start-keyspace-id"`
 	EndKeyspaceID   uint32 `json:"end-keyspace-id"`
+	// DryRun is used to only preview the split result without saving it.
+	DryRun bool `json:"dry-run"`
 }
 
 var patrolKeyspaceAssignmentState struct {
@@ -196,6 +332,7 @@ var patrolKeyspaceAssignmentState struct {
 
 // SplitKeyspaceGroupByID splits keyspace group by ID into a new keyspace group with the given new ID.
 // And the keyspaces in the old keyspace group will be moved to the new keyspace group.
+// In the dry-run mode, the projected keyspace groups are returned without being saved.
 func SplitKeyspaceGroupByID(c *gin.Context) {
 	id, err := validateKeyspaceGroupID(c)
 	if err != nil {
@@ -223,6 +360,23 @@ func SplitKeyspaceGroupByID(c *gin.Context) {
 	}
 
 	svr := c.MustGet(middlewares.ServerContextKey).(*server.Server)
+	if splitParams.DryRun {
+		// Preview the split without patrolling the keyspace assignment, which may save the keyspace groups.
+		groupManager := svr.GetKeyspaceGroupManager()
+		if groupManager == nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, groupManagerUninitializedErr)
+			return
+		}
+		preview, err := groupManager.PreviewSplitKeyspaceGroupByID(
+			id, splitParams.NewID,
+			splitParams.Keyspaces, splitParams.StartKeyspaceID, splitParams.EndKeyspaceID)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.IndentedJSON(http.StatusOK, preview)
+		return
+	}
 	patrolKeyspaceAssignmentState.Lock()
 	if !patrolKeyspaceAssignmentState.patrolled {
 		// Patrol keyspace assignment before splitting keyspace group.
@@ -274,12 +428,38 @@ func FinishSplitKeyspaceByID(c *gin.Context) {
 	c.JSON(http.StatusOK, nil)
 }
 
+// RollbackSplitKeyspaceGroupByID aborts the in-progress split of the keyspace group by ID,
+// and returns the restored split source keyspace group.
+func RollbackSplitKeyspaceGroupByID(c *gin.Context) {
+	id, err := validateKeyspaceGroupID(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, "invalid keyspace group id")
+		return
+	}
+
+	svr := c.MustGet(middlewares.ServerContextKey).(*server.Server)
+	manager := svr.GetKeyspaceGroupManager()
+	if manager == nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, groupManagerUninitializedErr)
+		return
+	}
+	kg, err := manager.RollbackSplitKeyspaceGroupByID(id)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.IndentedJSON(http.StatusOK, kg)
+}
+
 // MergeKeyspaceGroupsParams defines the params for merging the keyspace groups.
 type MergeKeyspaceGroupsParams struct {
 	MergeList []uint32 `json:"merge-list"`
+	// DryRun is used to only preview the merge result without saving it.
+	DryRun bool `json:"dry-run"`
 }
 
 // MergeKeyspaceGroups merges the keyspace groups in the merge list into the target keyspace group.
+// In the dry-run mode, the projected keyspace group is returned without being saved.
 func MergeKeyspaceGroups(c *gin.Context) {
 	id, err := validateKeyspaceGroupID(c)
 	if err != nil {
@@ -309,6 +489,15 @@ func MergeKeyspaceGroups(c *gin.Context) {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, groupManagerUninitializedErr)
 		return
 	}
+	if mergeParams.DryRun {
+		preview, err := groupManager.PreviewMergeKeyspaceGroups(id, mergeParams.MergeList)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.IndentedJSON(http.StatusOK, preview)
+		return
+	}
 	// Merge keyspace group.
 	err = groupManager.MergeKeyspaceGroups(id, mergeParams.MergeList)
 	if err != nil {
@@ -336,6 +525,136 @@ func FinishMergeKeyspaceByID(c *gin.Context) {
 	c.JSON(http.StatusOK, nil)
 }
 
+// GetKeyspaceGroupProgress gets the progress of the split or merge of the keyspace group,
+// i.e., the keyspace groups involved in the split or merge with their TSO primaries.
+func GetKeyspaceGroupProgress(c *gin.Context) {
+	id, err := validateKeyspaceGroupID(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, "invalid keyspace group id")
+		return
+	}
+
+	svr := c.MustGet(middlewares.ServerContextKey).(*server.Server)
+	manager := svr.GetKeyspaceGroupManager()
+	if manager == nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, groupManagerUninitializedErr)
+		return
+	}
+	progress, err := manager.GetKeyspaceGroupProgress(id)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.IndentedJSON(http.StatusOK, progress)
+}
+
+// ResetKeyspaceGroupStateParams defines the params for resetting the split/merge state of a keyspace group.
+type ResetKeyspaceGroupStateParams struct {
+	DryRun bool `json:"dry-run"`
+}
+
+// ResetKeyspaceGroupState forcibly clears the stuck split/merge state of the keyspace group,
+// and returns the reset keyspace groups.
+func ResetKeyspaceGroupState(c *gin.Context) {
+	id, err := validateKeyspaceGroupID(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, "invalid keyspace group id")
+		return
+	}
+	resetParams := &ResetKeyspaceGroupStateParams{}
+	err = c.BindJSON(resetParams)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, errs.ErrBindJSON.Wrap(err).GenWithStackByCause())
+		return
+	}
+
+	svr := c.MustGet(middlewares.ServerContextKey).(*server.Server)
+	manager := svr.GetKeyspaceGroupManager()
+	if manager == nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, groupManagerUninitializedErr)
+		return
+	}
+	kgs, err := manager.ResetKeyspaceGroupState(id, resetParams.DryRun)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.IndentedJSON(http.StatusOK, kgs)
+}
+
+// ReallocateKeyspaceGroupsParams defines the params for reallocating nodes for the under-replicated keyspace groups.
+type ReallocateKeyspaceGroupsParams struct {
+	DryRun bool `json:"dry-run"`
+}
+
+// ReallocateKeyspaceGroups allocates nodes for all the under-replicated keyspace groups,
+// and returns the reallocated keyspace groups.
+func ReallocateKeyspaceGroups(c *gin.Context) {
+	reallocateParams := &ReallocateKeyspaceGroupsParams{}
+	err := c.BindJSON(reallocateParams)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, errs.ErrBindJSON.Wrap(err).GenWithStackByCause())
+		return
+	}
+
+	svr := c.MustGet(middlewares.ServerContextKey).(*server.Server)
+	manager := svr.GetKeyspaceGroupManager()
+	if manager == nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, groupManagerUninitializedErr)
+		return
+	}
+	reallocations, err := manager.ReallocateKeyspaceGroups(reallocateParams.DryRun)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.IndentedJSON(http.StatusOK, reallocations)
+}
+
+// WatchKeyspaceGroups streams the keyspace group change events as NDJSON, one event per line,
+// until the client disconnects. If the query `id` is given, only the events of that keyspace group are streamed.
+func WatchKeyspaceGroups(c *gin.Context) {
+	var (
+		id       uint32
+		filterID bool
+	)
+	if idStr, ok := c.GetQuery("id"); ok {
+		groupID, err := strconv.ParseUint(idStr, 10, 32)
+		if err != nil || !isValid(uint32(groupID)) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, "invalid keyspace group id")
+			return
+		}
+		id, filterID = uint32(groupID), true
+	}
+
+	svr := c.MustGet(middlewares.ServerContextKey).(*server.Server)
+	manager := svr.GetKeyspaceGroupManager()
+	if manager == nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, groupManagerUninitializedErr)
+		return
+	}
+	events, err := manager.WatchKeyspaceGroups(c.Request.Context())
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(http.StatusOK)
+	// Flush the header to let the client know the watch has been established.
+	c.Writer.Flush()
+	encoder := json.NewEncoder(c.Writer)
+	for event := range events {
+		if filterID && event.ID != id {
+			continue
+		}
+		if err := encoder.Encode(event); err != nil {
+			log.Warn("failed to write the keyspace group event", zap.Uint32("keyspace-group-id", event.ID), errs.ZapError(err))
+			return
+		}
+		c.Writer.Flush()
+	}
+}
+
 // AllocNodesForKeyspaceGroupParams defines the params for allocating nodes for keyspace groups.
 type AllocNodesForKeyspaceGroupParams struct {
 	Replica int `json:"replica"`
@@ -88,6 +88,19 @@ type Config struct {
 	// TSOProxyRecvFromClientTimeout is the timeout for the TSO proxy to receive a tso request from a client via grpc TSO stream.
 	// After the timeout, the TSO proxy will close the grpc TSO stream.
 	TSOProxyRecvFromClientTimeout typeutil.Duration `toml:"tso-proxy-recv-from-client-timeout" json:"tso-proxy-recv-from-client-timeout"`
+	// TSOProxyStreamRateLimit is the max number of the TSO requests per second the TSO proxy accepts from each
+	// client stream, so that a misbehaving client can't starve the others. The stream exceeding the limit is
+	// closed with a throttling error. Set this to 0 will disable the limit.
+	TSOProxyStreamRateLimit float64 `toml:"tso-proxy-stream-rate-limit" json:"tso-proxy-stream-rate-limit"`
+	// TSOProxyStreamRateBurst is the max burst of the TSO requests the TSO proxy accepts from each client stream.
+	// It defaults to the rate limit rounded up.
+	TSOProxyStreamRateBurst int `toml:"tso-proxy-stream-rate-burst" json:"tso-proxy-stream-rate-burst"`
+	// EnableTSOProxyBatch is used to enable the TSO proxy to merge the pending TSO requests from different client
+	// streams into one request to the TSO service, and split the returned timestamps back to each client.
+	EnableTSOProxyBatch bool `toml:"enable-tso-proxy-batch" json:"enable-tso-proxy-batch"`
+	// TSOProxySlowForwardThreshold is the threshold of the time for the TSO proxy to forward a TSO request to the
+	// TSO service, the slower ones are logged with the address of the TSO service.
+	TSOProxySlowForwardThreshold typeutil.Duration `toml:"tso-proxy-slow-forward-threshold" json:"tso-proxy-slow-forward-threshold"`
 
 	// TSOSaveInterval is the interval to save timestamp.
 	TSOSaveInterval typeutil.Duration `toml:"tso-save-interval" json:"tso-save-interval"`
@@ -230,6 +243,8 @@ const (
 
 	defaultMaxConcurrentTSOProxyStreamings = 5000
 	defaultTSOProxyRecvFromClientTimeout   = 1 * time.Hour
+	// defaultTSOProxySlowForwardThreshold is the same as the threshold of the slow etcd requests.
+	defaultTSOProxySlowForwardThreshold = time.Second
 
 	defaultTSOSaveInterval = time.Duration(defaultLeaderLease) * time.Second
 	// defaultTSOUpdatePhysicalInterval is the default value of the config `TSOUpdatePhysicalInterval`.
@@ -456,6 +471,13 @@ func (c *Config) Adjust(meta *toml.MetaData, reloading bool) error {
 
 	configutil.AdjustInt(&c.MaxConcurrentTSOProxyStreamings, defaultMaxConcurrentTSOProxyStreamings)
 	configutil.AdjustDuration(&c.TSOProxyRecvFromClientTimeout, defaultTSOProxyRecvFromClientTimeout)
+	configutil.AdjustDuration(&c.TSOProxySlowForwardThreshold, defaultTSOProxySlowForwardThreshold)
+	if c.TSOProxyStreamRateLimit < 0 {
+		return errors.Errorf("tso-proxy-stream-rate-limit %v should not be negative", c.TSOProxyStreamRateLimit)
+	}
+	if c.TSOProxyStreamRateLimit > 0 && c.TSOProxyStreamRateBurst <= 0 {
+		c.TSOProxyStreamRateBurst = int(math.Ceil(c.TSOProxyStreamRateLimit))
+	}
 
 	configutil.AdjustInt64(&c.LeaderLease, defaultLeaderLease)
 	configutil.AdjustDuration(&c.TSOSaveInterval, defaultTSOSaveInterval)
@@ -1276,6 +1298,22 @@ func (c *Config) GetTSOProxyRecvFromClientTimeout() time.Duration {
 	return c.TSOProxyRecvFromClientTimeout.Duration
 }
 
+// GetTSOProxyStreamRateLimit returns the rate limit and the burst of the TSO requests from each client stream
+// of the TSO proxy. If the limit is 0, there is no limit.
+func (c *Config) GetTSOProxyStreamRateLimit() (limit float64, burst int) {
+	return c.TSOProxyStreamRateLimit, c.TSOProxyStreamRateBurst
+}
+
+// IsTSOProxyBatchEnabled returns if the TSO proxy merges the TSO requests from different client streams.
+func (c *Config) IsTSOProxyBatchEnabled() bool {
+	return c.EnableTSOProxyBatch
+}
+
+// GetTSOProxySlowForwardThreshold returns the threshold of the slow TSO proxy forwarding.
+func (c *Config) GetTSOProxySlowForwardThreshold() time.Duration {
+	return c.TSOProxySlowForwardThreshold.Duration
+}
+
 // GetTSOUpdatePhysicalInterval returns TSO update physical interval.
 func (c *Config) GetTSOUpdatePhysicalInterval() time.Duration {
 	return c.TSOUpdatePhysicalInterval.Duration
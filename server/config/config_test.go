@@ -189,6 +189,7 @@ leader-schedule-limit = 0
 	re.Equal("http://127.0.0.1:9090", cfg.PDServerCfg.MetricStorage)
 
 	re.Equal(defaultTSOUpdatePhysicalInterval, cfg.TSOUpdatePhysicalInterval.Duration)
+	re.Equal(defaultTSOProxySlowForwardThreshold, cfg.TSOProxySlowForwardThreshold.Duration)
 
 	// Check undefined config fields
 	cfgData = `
@@ -244,6 +245,28 @@ tso-update-physical-interval = "15s"
 	re.NoError(err)
 
 	re.Equal(maxTSOUpdatePhysicalInterval, cfg.TSOUpdatePhysicalInterval.Duration)
+
+	// Test the default burst of the TSO proxy stream rate limit
+	cfgData = `
+tso-proxy-stream-rate-limit = 2.5
+`
+	cfg = NewConfig()
+	meta, err = toml.Decode(cfgData, &cfg)
+	re.NoError(err)
+	err = cfg.Adjust(&meta, false)
+	re.NoError(err)
+	limit, burst := cfg.GetTSOProxyStreamRateLimit()
+	re.Equal(2.5, limit)
+	re.Equal(3, burst)
+
+	cfgData = `
+tso-proxy-stream-rate-limit = -1.0
+`
+	cfg = NewConfig()
+	meta, err = toml.Decode(cfgData, &cfg)
+	re.NoError(err)
+	err = cfg.Adjust(&meta, false)
+	re.Error(err)
 }
 
 func TestMigrateFlags(t *testing.T) {
@@ -34,6 +34,7 @@ import (
 	"github.com/tikv/pd/pkg/core"
 	"github.com/tikv/pd/pkg/errs"
 	"github.com/tikv/pd/pkg/mcs/utils"
+	"github.com/tikv/pd/pkg/ratelimit"
 	"github.com/tikv/pd/pkg/storage/endpoint"
 	"github.com/tikv/pd/pkg/storage/kv"
 	"github.com/tikv/pd/pkg/tso"
@@ -55,6 +56,16 @@ const (
 	retryIntervalRequestTSOServer = 500 * time.Millisecond
 	getMinTSFromTSOServerTimeout  = 1 * time.Second
 	defaultGRPCDialTimeout        = 3 * time.Second
+	// maxTSOProxyBatchSize is the max number of the TSO requests from different client
+	// streams that the TSO proxy merges into one request.
+	maxTSOProxyBatchSize = 10000
+	// maxTSOProxyBatchCount is the max count of timestamps the TSO proxy merges into one request,
+	// so that a merged request won't exhaust the logical part of a physical time.
+	maxTSOProxyBatchCount = 1 << 16
+	// tsoProxyBatcherCheckInterval is the interval for a TSO proxy batcher to check whether it should exit.
+	tsoProxyBatcherCheckInterval = 10 * time.Second
+	// tsoProxyBatcherIdleTimeout is the duration after which an idle TSO proxy batcher exits.
+	tsoProxyBatcherIdleTimeout = time.Minute
 )
 
 // gRPC errors
@@ -68,6 +79,7 @@ var (
 	ErrForwardTSOTimeout                = status.Errorf(codes.DeadlineExceeded, "forward tso request timeout")
 	ErrMaxCountTSOProxyRoutinesExceeded = status.Errorf(codes.ResourceExhausted, "max count of concurrent tso proxy routines exceeded")
 	ErrTSOProxyRecvFromClientTimeout    = status.Errorf(codes.DeadlineExceeded, "tso proxy timeout when receiving from client; stream closed by server")
+	ErrTSOProxyStreamRateLimitExceeded  = status.Errorf(codes.ResourceExhausted, "tso proxy stream rate limit exceeded; stream closed by server")
 )
 
 // GrpcServer wraps Server to provide grpc service.
@@ -409,12 +421,17 @@ func (s *GrpcServer) forwardTSO(stream pdpb.PD_TsoServer) error {
 		forwardCtx        context.Context
 		cancelForward     context.CancelFunc
 		lastForwardedHost string
+		// streamingHost is the TSO service this stream is counted in the streamings gauge of.
+		streamingHost string
 	)
 	defer func() {
 		s.concurrentTSOProxyStreamings.Add(-1)
 		if cancelForward != nil {
 			cancelForward()
 		}
+		if streamingHost != "" {
+			tsoProxyStreamingsGauge.WithLabelValues(streamingHost).Dec()
+		}
 	}()
 
 	maxConcurrentTSOProxyStreamings := int32(s.GetMaxConcurrentTSOProxyStreamings())
@@ -424,6 +441,12 @@ func (s *GrpcServer) forwardTSO(stream pdpb.PD_TsoServer) error {
 		}
 	}
 
+	// The limiter is per stream, so an over-limit client doesn't affect the others.
+	var limiter *ratelimit.RateLimiter
+	if limit, burst := s.GetTSOProxyStreamRateLimit(); limit > 0 {
+		limiter = ratelimit.NewRateLimiter(limit, burst)
+	}
+
 	tsDeadlineCh := make(chan *tsoutil.TSDeadline, 1)
 	go tsoutil.WatchTSDeadline(stream.Context(), tsDeadlineCh)
 
@@ -447,33 +470,64 @@ func (s *GrpcServer) forwardTSO(stream pdpb.PD_TsoServer) error {
 			err = errs.ErrGenerateTimestamp.FastGenByArgs("tso count should be positive")
 			return status.Errorf(codes.Unknown, err.Error())
 		}
+		if limiter != nil && !limiter.Allow() {
+			return errors.WithStack(ErrTSOProxyStreamRateLimitExceeded)
+		}
 
 		forwardedHost, ok := s.GetServicePrimaryAddr(stream.Context(), utils.TSOServiceName)
 		if !ok || len(forwardedHost) == 0 {
 			return errors.WithStack(ErrNotFoundTSOAddr)
 		}
-		if forwardStream == nil || lastForwardedHost != forwardedHost {
-			if cancelForward != nil {
-				cancelForward()
-			}
 
-			clientConn, err := s.getDelegateClient(s.ctx, forwardedHost)
-			if err != nil {
-				return errors.WithStack(err)
+		if streamingHost != forwardedHost {
+			if streamingHost != "" {
+				tsoProxyStreamingsGauge.WithLabelValues(streamingHost).Dec()
 			}
-			forwardStream, forwardCtx, cancelForward, err =
-				s.createTSOForwardStream(stream.Context(), clientConn)
-			if err != nil {
-				return errors.WithStack(err)
+			tsoProxyStreamingsGauge.WithLabelValues(forwardedHost).Inc()
+			streamingHost = forwardedHost
+		}
+		tsoProxyForwardedCounter.WithLabelValues(forwardedHost).Inc()
+
+		var (
+			tsopbResp *tsopb.TsoResponse
+			start     time.Time
+		)
+		if s.IsTSOProxyBatchEnabled() {
+			start = time.Now()
+			tsopbResp, err = s.forwardTSORequestInBatch(stream.Context(), forwardedHost, request)
+		} else {
+			if forwardStream == nil || lastForwardedHost != forwardedHost {
+				if cancelForward != nil {
+					cancelForward()
+				}
+
+				clientConn, err := s.getDelegateClient(s.ctx, forwardedHost)
+				if err != nil {
+					return errors.WithStack(err)
+				}
+				forwardStream, forwardCtx, cancelForward, err =
+					s.createTSOForwardStream(stream.Context(), clientConn)
+				if err != nil {
+					return errors.WithStack(err)
+				}
+				lastForwardedHost = forwardedHost
 			}
-			lastForwardedHost = forwardedHost
-		}
 
-		tsopbResp, err := s.forwardTSORequestWithDeadLine(
-			forwardCtx, cancelForward, forwardStream, request, tsDeadlineCh)
+			start = time.Now()
+			tsopbResp, err = s.forwardTSORequestWithDeadLine(
+				forwardCtx, cancelForward, forwardStream, request, tsDeadlineCh)
+		}
 		if err != nil {
 			return errors.WithStack(err)
 		}
+		cost := time.Since(start)
+		tsoProxyForwardDuration.WithLabelValues(forwardedHost).Observe(cost.Seconds())
+		if cost > s.GetTSOProxySlowForwardThreshold() {
+			log.Warn("tso proxy forwards the tso request slowly",
+				zap.String("tso-addr", forwardedHost),
+				zap.Uint32("count", request.GetCount()),
+				zap.Duration("cost", cost))
+		}
 
 		// The error types defined for tsopb and pdpb are different, so we need to convert them.
 		var pdpbErr *pdpb.Error
@@ -581,6 +635,261 @@ func (s *GrpcServer) forwardTSORequest(
 	return forwardStream.Recv()
 }
 
+// tsoProxyBatchKey identifies the TSO requests which can be merged into one request by the TSO proxy.
+type tsoProxyBatchKey struct {
+	forwardedHost string
+	dcLocation    string
+}
+
+// tsoProxyBatcher merges the TSO requests with the same tsoProxyBatchKey from different client streams.
+type tsoProxyBatcher struct {
+	reqCh chan *tsoProxyBatchRequest
+	// done is closed once the batcher exits, and the requests left in reqCh are never handled after that.
+	done chan struct{}
+}
+
+// tsoProxyBatchRequest is a TSO request from a client stream waiting to be merged and forwarded.
+type tsoProxyBatchRequest struct {
+	request *pdpb.TsoRequest
+	// respCh receives the response split from the merged response, or the error of the merged request.
+	respCh chan *tsoProxyBatchResult
+}
+
+type tsoProxyBatchResult struct {
+	resp *tsopb.TsoResponse
+	err  error
+}
+
+// forwardTSORequestInBatch hands the request over to the batcher of the forwarded host, which merges it
+// with the pending requests from the other client streams, and waits for the split response.
+func (s *GrpcServer) forwardTSORequestInBatch(
+	ctx context.Context,
+	forwardedHost string,
+	request *pdpb.TsoRequest,
+) (*tsopb.TsoResponse, error) {
+	// The merged request uses the header of one of the requests, so reject the mismatched ones
+	// here to avoid failing the requests from the other client streams.
+	if request.GetHeader().GetClusterId() != s.clusterID {
+		return nil, status.Errorf(codes.FailedPrecondition,
+			"mismatch cluster id, need %d but got %d", s.clusterID, request.GetHeader().GetClusterId())
+	}
+	key := tsoProxyBatchKey{forwardedHost: forwardedHost, dcLocation: request.GetDcLocation()}
+	req := &tsoProxyBatchRequest{
+		request: request,
+		respCh:  make(chan *tsoProxyBatchResult, 1),
+	}
+	for {
+		batcher := s.getTSOProxyBatcher(key)
+		select {
+		case batcher.reqCh <- req:
+		case <-batcher.done:
+			// The batcher has exited, retry with a new one.
+			continue
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-s.ctx.Done():
+			return nil, s.ctx.Err()
+		}
+		select {
+		case result := <-req.respCh:
+			return result.resp, result.err
+		case <-batcher.done:
+			// The request may be finished right before the batcher exits,
+			// otherwise it's left unhandled and should be retried.
+			select {
+			case result := <-req.respCh:
+				return result.resp, result.err
+			default:
+			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-s.ctx.Done():
+			return nil, s.ctx.Err()
+		}
+	}
+}
+
+// getTSOProxyBatcher returns the running batcher of the given key, or starts a new one if there is none.
+func (s *GrpcServer) getTSOProxyBatcher(key tsoProxyBatchKey) *tsoProxyBatcher {
+	if val, ok := s.tsoProxyBatchers.Load(key); ok {
+		return val.(*tsoProxyBatcher)
+	}
+	batcher := &tsoProxyBatcher{
+		reqCh: make(chan *tsoProxyBatchRequest, maxTSOProxyBatchSize),
+		done:  make(chan struct{}),
+	}
+	val, loaded := s.tsoProxyBatchers.LoadOrStore(key, batcher)
+	if loaded {
+		return val.(*tsoProxyBatcher)
+	}
+	go s.runTSOProxyBatcher(key, batcher)
+	return batcher
+}
+
+// runTSOProxyBatcher merges the requests arriving while the previous merged request is in flight into
+// one request, forwards it through the stream to the forwarded host, and splits the response back.
+// It exits once it's idle for tsoProxyBatcherIdleTimeout or the forwarded host is no longer the primary.
+func (s *GrpcServer) runTSOProxyBatcher(key tsoProxyBatchKey, batcher *tsoProxyBatcher) {
+	defer logutil.LogPanic()
+	defer func() {
+		s.tsoProxyBatchers.CompareAndDelete(key, batcher)
+		close(batcher.done)
+	}()
+
+	checkInterval, idleTimeout := tsoProxyBatcherCheckInterval, tsoProxyBatcherIdleTimeout
+	failpoint.Inject("fastCheckTSOProxyBatcher", func() {
+		checkInterval, idleTimeout = 100*time.Millisecond, time.Second
+	})
+
+	var (
+		forwardStream tsopb.TSO_TsoClient
+		forwardCtx    context.Context
+		cancelForward context.CancelFunc
+	)
+	resetForwardStream := func() {
+		if cancelForward != nil {
+			cancelForward()
+		}
+		forwardStream, forwardCtx, cancelForward = nil, nil, nil
+	}
+	defer resetForwardStream()
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	defer cancel()
+	tsDeadlineCh := make(chan *tsoutil.TSDeadline, 1)
+	go tsoutil.WatchTSDeadline(ctx, tsDeadlineCh)
+
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	lastActive := time.Now()
+	requests := make([]*tsoProxyBatchRequest, 0, maxTSOProxyBatchSize+1)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			idle := time.Since(lastActive) >= idleTimeout
+			if primary, ok := s.servicePrimaryMap.Load(utils.TSOServiceName); idle || !ok || primary.(string) != key.forwardedHost {
+				log.Info("tso proxy batcher exits",
+					zap.String("forwarded-host", key.forwardedHost), zap.Bool("idle", idle))
+				return
+			}
+		case first := <-batcher.reqCh:
+			lastActive = time.Now()
+			pendingCount := len(batcher.reqCh)
+			requests = append(requests[:0], first)
+			for i := 0; i < pendingCount; i++ {
+				requests = append(requests, <-batcher.reqCh)
+			}
+			for len(requests) > 0 {
+				if forwardStream == nil {
+					clientConn, err := s.getDelegateClient(ctx, key.forwardedHost)
+					if err == nil {
+						forwardStream, forwardCtx, cancelForward, err = s.createTSOForwardStream(ctx, clientConn)
+					}
+					if err != nil {
+						log.Error("create tso proxy forwarding stream error",
+							zap.String("forwarded-host", key.forwardedHost),
+							errs.ZapError(errs.ErrGRPCCreateStream, err))
+						resetForwardStream()
+						finishTSOProxyBatch(requests, nil, err)
+						break
+					}
+				}
+				batchSize := nextTSOProxyBatchSize(requests)
+				if err := s.processTSOProxyBatch(
+					forwardCtx, cancelForward, forwardStream, requests[:batchSize], tsDeadlineCh); err != nil {
+					log.Error("tso proxy forward merged tso request error",
+						zap.String("forwarded-host", key.forwardedHost),
+						errs.ZapError(errs.ErrGRPCSend, err))
+					resetForwardStream()
+				}
+				requests = requests[batchSize:]
+			}
+		}
+	}
+}
+
+// nextTSOProxyBatchSize returns the number of the leading requests to merge, so that the count of
+// timestamps to retrieve doesn't exceed maxTSOProxyBatchCount unless there is only one request.
+func nextTSOProxyBatchSize(requests []*tsoProxyBatchRequest) int {
+	count := int64(requests[0].request.GetCount())
+	for i := 1; i < len(requests); i++ {
+		count += int64(requests[i].request.GetCount())
+		if count > maxTSOProxyBatchCount {
+			return i
+		}
+	}
+	return len(requests)
+}
+
+// processTSOProxyBatch forwards the merged request of the given requests and finishes each of
+// them with its own part of the timestamps in the merged response.
+func (s *GrpcServer) processTSOProxyBatch(
+	forwardCtx context.Context,
+	cancelForward context.CancelFunc,
+	forwardStream tsopb.TSO_TsoClient,
+	requests []*tsoProxyBatchRequest,
+	tsDeadlineCh chan<- *tsoutil.TSDeadline,
+) error {
+	count := uint32(0)
+	for _, req := range requests {
+		count += req.request.GetCount()
+	}
+	first := requests[0].request
+	mergedReq := &pdpb.TsoRequest{
+		Header:     first.GetHeader(),
+		Count:      count,
+		DcLocation: first.GetDcLocation(),
+	}
+	resp, err := s.forwardTSORequestWithDeadLine(forwardCtx, cancelForward, forwardStream, mergedReq, tsDeadlineCh)
+	if err != nil {
+		finishTSOProxyBatch(requests, nil, err)
+		return err
+	}
+	// Let each client stream handle the error in the header by itself.
+	if tsopbErr := resp.GetHeader().GetError(); tsopbErr != nil && tsopbErr.Type != tsopb.ErrorType_OK {
+		finishTSOProxyBatch(requests, resp, nil)
+		return nil
+	}
+	if resp.GetCount() != count {
+		err = status.Errorf(codes.Unknown,
+			"tso proxy requested %d timestamps but got %d from the tso service", count, resp.GetCount())
+		finishTSOProxyBatch(requests, nil, err)
+		return err
+	}
+
+	// Split the response
+	ts := resp.GetTimestamp()
+	physical, logical, suffixBits := ts.GetPhysical(), ts.GetLogical(), ts.GetSuffixBits()
+	// `logical` is the largest ts's logical part here, so each request gets the largest ts of its own
+	// range, which makes the response the same as the one returned by the TSO service directly.
+	firstLogical := tsoutil.AddLogical(logical, -int64(count), suffixBits)
+	countSum := int64(0)
+	for _, req := range requests {
+		reqCount := req.request.GetCount()
+		countSum += int64(reqCount)
+		req.respCh <- &tsoProxyBatchResult{
+			resp: &tsopb.TsoResponse{
+				Header: resp.GetHeader(),
+				Count:  reqCount,
+				Timestamp: &pdpb.Timestamp{
+					Physical:   physical,
+					Logical:    tsoutil.AddLogical(firstLogical, countSum, suffixBits),
+					SuffixBits: suffixBits,
+				},
+			},
+		}
+	}
+	return nil
+}
+
+func finishTSOProxyBatch(requests []*tsoProxyBatchRequest, resp *tsopb.TsoResponse, err error) {
+	for _, req := range requests {
+		req.respCh <- &tsoProxyBatchResult{resp: resp, err: err}
+	}
+}
+
 // tsoServer wraps PD_TsoServer to ensure when any error
 // occurs on Send() or Recv(), both endpoints will be closed.
 type tsoServer struct {
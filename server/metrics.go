@@ -14,7 +14,10 @@
 
 package server
 
-import "github.com/prometheus/client_golang/prometheus"
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tikv/pd/pkg/utils/etcdutil"
+)
 
 var (
 	timeJumpBackCounter = prometheus.NewCounter(
@@ -90,6 +93,31 @@ var (
 			Help:      "Counter of timeouts when tso proxy forwarding tso requests to tso service.",
 		})
 
+	tsoProxyForwardedCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "pd",
+			Subsystem: "server",
+			Name:      "tso_proxy_forwarded_requests_total",
+			Help:      "Counter of tso requests forwarded by tso proxy to each tso service.",
+		}, []string{"tso_address"})
+
+	tsoProxyStreamingsGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "pd",
+			Subsystem: "server",
+			Name:      "tso_proxy_streamings",
+			Help:      "The number of tso proxy streamings forwarding to each tso service.",
+		}, []string{"tso_address"})
+
+	tsoProxyForwardDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "pd",
+			Subsystem: "server",
+			Name:      "tso_proxy_forward_duration_seconds",
+			Help:      "Bucketed histogram of the round-trip time (s) of tso proxy forwarding tso requests to each tso service.",
+			Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 13),
+		}, []string{"tso_address"})
+
 	tsoHandleDuration = prometheus.NewHistogram(
 		prometheus.HistogramOpts{
 			Namespace: "pd",
@@ -170,6 +198,9 @@ func init() {
 	prometheus.MustRegister(tsoProxyHandleDuration)
 	prometheus.MustRegister(tsoProxyBatchSize)
 	prometheus.MustRegister(tsoProxyForwardTimeoutCounter)
+	prometheus.MustRegister(tsoProxyForwardedCounter)
+	prometheus.MustRegister(tsoProxyStreamingsGauge)
+	prometheus.MustRegister(tsoProxyForwardDuration)
 	prometheus.MustRegister(tsoHandleDuration)
 	prometheus.MustRegister(regionHeartbeatHandleDuration)
 	prometheus.MustRegister(storeHeartbeatHandleDuration)
@@ -179,4 +210,5 @@ func init() {
 	prometheus.MustRegister(serviceAuditHistogram)
 	prometheus.MustRegister(bucketReportInterval)
 	prometheus.MustRegister(serverMaxProcs)
+	etcdutil.RegisterMetrics(prometheus.DefaultRegisterer)
 }
@@ -206,6 +206,9 @@ type Server struct {
 	// tsoDispatcher is used to dispatch different TSO requests to
 	// the corresponding forwarding TSO channel.
 	tsoDispatcher *tsoutil.TSODispatcher
+	// tsoProxyBatchers is used to merge the TSO requests from different client streams
+	// before the TSO proxy forwards them to the TSO service.
+	tsoProxyBatchers sync.Map // Store as map[tsoProxyBatchKey]*tsoProxyBatcher
 	// tsoProtoFactory is the abstract factory for creating tso
 	// related data structures defined in the TSO grpc service
 	tsoProtoFactory *tsoutil.TSOProtoFactory
@@ -464,7 +467,7 @@ func (s *Server) startServer(ctx context.Context) error {
 		Step:      keyspace.AllocStep,
 	})
 	if s.IsAPIServiceMode() {
-		s.keyspaceGroupManager = keyspace.NewKeyspaceGroupManager(s.ctx, s.storage, s.client, s.clusterID)
+		s.keyspaceGroupManager = keyspace.NewKeyspaceGroupManager(s.ctx, s.storage, s.client, s.clusterID, s.rootPath)
 	}
 	s.keyspaceManager = keyspace.NewKeyspaceManager(s.ctx, s.storage, s.cluster, keyspaceIDAllocator, &s.cfg.Keyspace, s.keyspaceGroupManager)
 	s.safePointV2Manager = gc.NewSafePointManagerV2(s.ctx, s.storage, s.storage, s.storage)
@@ -1844,6 +1847,17 @@ func (s *Server) SetServicePrimaryAddr(serviceName, addr string) {
 	s.servicePrimaryMap.Store(serviceName, addr)
 }
 
+// GetTSOProxyBatcherCount returns the count of the running TSO proxy batchers.
+// Note: This function is only used for test.
+func (s *Server) GetTSOProxyBatcherCount() int {
+	count := 0
+	s.tsoProxyBatchers.Range(func(_, _ interface{}) bool {
+		count++
+		return true
+	})
+	return count
+}
+
 func (s *Server) servicePrimaryKey(serviceName string) string {
 	return fmt.Sprintf("/ms/%d/%s/%s/%s", s.clusterID, serviceName, fmt.Sprintf("%05d", 0), "primary")
 }
@@ -1923,6 +1937,22 @@ func (s *Server) GetTSOProxyRecvFromClientTimeout() time.Duration {
 	return s.cfg.GetTSOProxyRecvFromClientTimeout()
 }
 
+// GetTSOProxyStreamRateLimit returns the rate limit and the burst of the TSO requests from each client stream
+// of the TSO proxy. If the limit is 0, there is no limit.
+func (s *Server) GetTSOProxyStreamRateLimit() (limit float64, burst int) {
+	return s.cfg.GetTSOProxyStreamRateLimit()
+}
+
+// IsTSOProxyBatchEnabled returns if the TSO proxy merges the TSO requests from different client streams.
+func (s *Server) IsTSOProxyBatchEnabled() bool {
+	return s.cfg.IsTSOProxyBatchEnabled()
+}
+
+// GetTSOProxySlowForwardThreshold returns the threshold of the slow TSO proxy forwarding.
+func (s *Server) GetTSOProxySlowForwardThreshold() time.Duration {
+	return s.cfg.GetTSOProxySlowForwardThreshold()
+}
+
 // GetLeaderLease returns the leader lease.
 func (s *Server) GetLeaderLease() int64 {
 	return s.cfg.GetLeaderLease()
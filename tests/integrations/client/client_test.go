@@ -18,6 +18,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
 	"path"
@@ -33,6 +34,7 @@ import (
 	"github.com/pingcap/kvproto/pkg/meta_storagepb"
 	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 	pd "github.com/tikv/pd/client"
@@ -408,6 +410,15 @@ func TestGlobalAndLocalTSO(t *testing.T) {
 	cluster.CheckClusterDCLocation()
 	cluster.WaitAllLeaders(re, dcLocationConfig)
 
+	// Test the available dc-locations.
+	innerCli, ok := cli.(interface{ GetServiceDiscovery() pd.ServiceDiscovery })
+	re.True(ok)
+	testutil.Eventually(re, func() bool {
+		innerCli.GetServiceDiscovery().ScheduleCheckMemberChanged()
+		dcLocations := cli.GetAvailableDCLocations()
+		return reflect.DeepEqual([]string{"dc-1", "dc-2", "dc-3", "dc-4"}, dcLocations)
+	})
+
 	// Test a nonexistent dc-location for Local TSO
 	p, l, err := cli.GetLocalTS(context.TODO(), "nonexistent-dc")
 	re.Equal(int64(0), p)
@@ -423,7 +434,8 @@ func TestGlobalAndLocalTSO(t *testing.T) {
 	err = cluster.ResignLeader()
 	re.NoError(err)
 	re.NotEmpty(cluster.WaitLeader())
-	_, _, err = cli.GetTS(ctx)
+	// The async call returns the leader change error directly.
+	_, _, err = cli.GetTSAsync(ctx).Wait()
 	re.Error(err)
 	re.True(pd.IsLeaderChange(err))
 	_, _, err = cli.GetTS(ctx)
@@ -513,6 +525,86 @@ func TestCustomTimeout(t *testing.T) {
 	re.Less(time.Since(start), 2*time.Second)
 }
 
+func TestWaitForLeader(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cluster, err := tests.NewTestCluster(ctx, 3)
+	re.NoError(err)
+	defer cluster.Destroy()
+
+	endpoints := runServer(re, cluster)
+	cli := setupCli(re, ctx, endpoints)
+	defer cli.Close()
+	re.NoError(cli.WaitForLeader(ctx))
+	oldLeader := cluster.GetLeader()
+	re.Equal(cluster.GetServer(oldLeader).GetAddr(), cli.GetLeaderAddr())
+
+	// Stop the leader to make the cluster elect a new one.
+	re.NoError(cluster.GetServer(oldLeader).Stop())
+	waitCtx, waitCancel := context.WithTimeout(ctx, 30*time.Second)
+	defer waitCancel()
+	re.NoError(cli.WaitForLeader(waitCtx))
+	newLeader := cluster.WaitLeader()
+	re.NotEqual(oldLeader, newLeader)
+	re.Equal(cluster.GetServer(newLeader).GetAddr(), cli.GetLeaderAddr())
+
+	// Stop the rest servers, there will be no leader anymore.
+	for name, s := range cluster.GetServers() {
+		if name != oldLeader {
+			re.NoError(s.Stop())
+		}
+	}
+	// The connection may still be ready for a while before the client notices the server is stopped.
+	testutil.Eventually(re, func() bool {
+		waitCtx, waitCancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer waitCancel()
+		return errors.Is(cli.WaitForLeader(waitCtx), context.DeadlineExceeded)
+	})
+}
+
+func TestGetTSAtLeast(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cluster, err := tests.NewTestCluster(ctx, 1)
+	re.NoError(err)
+	defer cluster.Destroy()
+
+	endpoints := runServer(re, cluster)
+	cli := setupCli(re, ctx, endpoints)
+	defer cli.Close()
+
+	lastPhysical, _, err := cli.GetTS(ctx)
+	re.NoError(err)
+	// The min physical is slightly ahead of the latest TSO.
+	minPhysical := lastPhysical + 100
+	physical, logical, err := cli.GetTSAtLeast(ctx, 0, minPhysical)
+	re.NoError(err)
+	re.GreaterOrEqual(physical, minPhysical)
+	nextPhysical, nextLogical, err := cli.GetTS(ctx)
+	re.NoError(err)
+	re.Less(tsoutil.ComposeTS(physical, logical), tsoutil.ComposeTS(nextPhysical, nextLogical))
+
+	// The min physical which is already reached should return immediately.
+	physical, _, err = cli.GetTSAtLeast(ctx, 0, lastPhysical)
+	re.NoError(err)
+	re.GreaterOrEqual(physical, lastPhysical)
+
+	// The min physical which is too far ahead can not be reached.
+	minPhysical = physical + time.Hour.Milliseconds()
+	_, _, err = cli.GetTSAtLeast(ctx, 0, minPhysical)
+	re.ErrorContains(err, "still below the required minimum")
+	// The caller's deadline is respected.
+	reqCtx, reqCancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer reqCancel()
+	_, _, err = cli.GetTSAtLeast(reqCtx, 0, minPhysical)
+	re.ErrorIs(err, context.DeadlineExceeded)
+	// The keyspace should be the one the client serves.
+	_, _, err = cli.GetTSAtLeast(ctx, 1, 0)
+	re.Error(err)
+}
+
 func TestGetRegionFromFollowerClient(t *testing.T) {
 	re := require.New(t)
 	ctx, cancel := context.WithCancel(context.Background())
@@ -1132,6 +1224,43 @@ func (suite *clientTestSuite) TestGetStore() {
 	}
 }
 
+func (suite *clientTestSuite) TestRPCDurationMetrics() {
+	getSampleCount := func(method, outcome string) uint64 {
+		families, err := prometheus.DefaultGatherer.Gather()
+		suite.NoError(err)
+		for _, family := range families {
+			if family.GetName() != "pd_client_request_rpc_duration_seconds" {
+				continue
+			}
+			for _, m := range family.GetMetric() {
+				labels := make(map[string]string)
+				for _, label := range m.GetLabel() {
+					labels[label.GetName()] = label.GetValue()
+				}
+				if labels["method"] == method && labels["outcome"] == outcome {
+					return m.GetHistogram().GetSampleCount()
+				}
+			}
+		}
+		return 0
+	}
+
+	getRegionCount := getSampleCount("get_region", "success")
+	getStoreCount := getSampleCount("get_store", "success")
+	getMembersCount := getSampleCount("get_members", "success")
+
+	_, err := suite.client.GetRegion(context.Background(), []byte("a"))
+	suite.NoError(err)
+	_, err = suite.client.GetStore(context.Background(), stores[0].GetId())
+	suite.NoError(err)
+	_, err = suite.client.GetAllMembers(context.Background())
+	suite.NoError(err)
+
+	suite.Greater(getSampleCount("get_region", "success"), getRegionCount)
+	suite.Greater(getSampleCount("get_store", "success"), getStoreCount)
+	suite.Greater(getSampleCount("get_members", "success"), getMembersCount)
+}
+
 func (suite *clientTestSuite) checkGCSafePoint(expectedSafePoint uint64) {
 	req := &pdpb.GetGCSafePointRequest{
 		Header: newHeader(suite.srv),
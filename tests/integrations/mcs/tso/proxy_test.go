@@ -27,10 +27,13 @@ import (
 	"github.com/pingcap/failpoint"
 	"github.com/pingcap/kvproto/pkg/pdpb"
 	"github.com/pingcap/log"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 	"github.com/tikv/pd/client/tsoutil"
+	"github.com/tikv/pd/pkg/mcs/utils"
 	"github.com/tikv/pd/pkg/utils/testutil"
+	"github.com/tikv/pd/server/config"
 	"github.com/tikv/pd/tests"
 	"github.com/tikv/pd/tests/integrations/mcs"
 	"go.uber.org/zap"
@@ -48,19 +51,29 @@ type tsoProxyTestSuite struct {
 	defaultReq       *pdpb.TsoRequest
 	streams          []pdpb.PD_TsoClient
 	cleanupFuncs     []testutil.CleanupFunc
+	// enableBatch enables the TSO Proxy to merge the TSO requests from different client streams.
+	enableBatch bool
 }
 
 func TestTSOProxyTestSuite(t *testing.T) {
 	suite.Run(t, new(tsoProxyTestSuite))
 }
 
+// TestTSOProxyBatchTestSuite runs the same tests while the TSO Proxy merges the TSO requests
+// from different client streams into one request to the TSO service.
+func TestTSOProxyBatchTestSuite(t *testing.T) {
+	suite.Run(t, &tsoProxyTestSuite{enableBatch: true})
+}
+
 func (s *tsoProxyTestSuite) SetupSuite() {
 	re := s.Require()
 
 	var err error
 	s.ctx, s.cancel = context.WithCancel(context.Background())
 	// Create an API cluster with 1 server
-	s.apiCluster, err = tests.NewTestAPICluster(s.ctx, 1)
+	s.apiCluster, err = tests.NewTestAPICluster(s.ctx, 1, func(conf *config.Config, _ string) {
+		conf.EnableTSOProxyBatch = s.enableBatch
+	})
 	re.NoError(err)
 	err = s.apiCluster.RunInitialServers()
 	re.NoError(err)
@@ -166,6 +179,123 @@ func TestTSOProxyStress(t *testing.T) {
 	s.TearDownSuite()
 }
 
+// TestTSOProxyStreamRateLimit tests the stream which exceeds its rate limit is closed by the TSO Proxy,
+// while the other streams still get the valid timestamps monotonic increasing.
+func TestTSOProxyStreamRateLimit(t *testing.T) {
+	re := require.New(t)
+	const (
+		rateLimit   = 50
+		clientCount = 20
+	)
+
+	var err error
+	s := new(tsoProxyTestSuite)
+	s.SetT(t)
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	defer s.cancel()
+	s.apiCluster, err = tests.NewTestAPICluster(s.ctx, 1, func(conf *config.Config, _ string) {
+		conf.TSOProxyStreamRateLimit = rateLimit
+		conf.TSOProxyStreamRateBurst = rateLimit
+	})
+	re.NoError(err)
+	defer s.apiCluster.Destroy()
+	re.NoError(s.apiCluster.RunInitialServers())
+	s.apiLeader = s.apiCluster.GetServer(s.apiCluster.WaitLeader())
+	s.backendEndpoints = s.apiLeader.GetAddr()
+	re.NoError(s.apiLeader.BootstrapCluster())
+	s.tsoCluster, err = mcs.NewTestTSOCluster(s.ctx, 1, s.backendEndpoints)
+	re.NoError(err)
+	defer s.tsoCluster.Destroy()
+	s.tsoCluster.WaitForDefaultPrimaryServing(re)
+
+	streams, cleanupFuncs := createTSOStreams(re, s.ctx, s.backendEndpoints, clientCount)
+	defer s.cleanupGRPCStreams(cleanupFuncs)
+
+	req := &pdpb.TsoRequest{
+		Header: &pdpb.RequestHeader{ClusterId: s.apiLeader.GetClusterID()},
+		Count:  1,
+	}
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	streamErrCh := make(chan error, 1)
+	// Drive the first stream far above its rate limit.
+	go func() {
+		defer wg.Done()
+		var streamErr error
+		for i := 0; i < rateLimit*100; i++ {
+			if streamErr = streams[0].Send(req); streamErr != nil {
+				break
+			}
+			if _, streamErr = streams[0].Recv(); streamErr != nil {
+				break
+			}
+		}
+		streamErrCh <- streamErr
+	}()
+	// The other streams stay within their burst, so they are not affected.
+	re.NoError(s.verifyTSOProxy(s.ctx, streams[1:], cleanupFuncs[1:], 10, true))
+	wg.Wait()
+	re.ErrorContains(<-streamErrCh, "tso proxy stream rate limit exceeded")
+}
+
+// TestTSOProxyBatcherExit tests the TSO Proxy batcher exits once it's idle or the forwarded host
+// is no longer the primary, so that the batchers don't leak after the primary changes.
+func TestTSOProxyBatcherExit(t *testing.T) {
+	re := require.New(t)
+	// Make the batcher check every 100ms and exit after being idle for 1s.
+	re.NoError(failpoint.Enable("github.com/tikv/pd/server/fastCheckTSOProxyBatcher", `return(true)`))
+	defer func() {
+		re.NoError(failpoint.Disable("github.com/tikv/pd/server/fastCheckTSOProxyBatcher"))
+	}()
+
+	var err error
+	s := new(tsoProxyTestSuite)
+	s.SetT(t)
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	defer s.cancel()
+	s.apiCluster, err = tests.NewTestAPICluster(s.ctx, 1, func(conf *config.Config, _ string) {
+		conf.EnableTSOProxyBatch = true
+	})
+	re.NoError(err)
+	defer s.apiCluster.Destroy()
+	re.NoError(s.apiCluster.RunInitialServers())
+	s.apiLeader = s.apiCluster.GetServer(s.apiCluster.WaitLeader())
+	s.backendEndpoints = s.apiLeader.GetAddr()
+	re.NoError(s.apiLeader.BootstrapCluster())
+	s.tsoCluster, err = mcs.NewTestTSOCluster(s.ctx, 1, s.backendEndpoints)
+	re.NoError(err)
+	defer s.tsoCluster.Destroy()
+	tsoAddr := s.tsoCluster.WaitForDefaultPrimaryServing(re).GetAddr()
+	s.defaultReq = &pdpb.TsoRequest{
+		Header: &pdpb.RequestHeader{ClusterId: s.apiLeader.GetClusterID()},
+		Count:  1,
+	}
+
+	streams, cleanupFuncs := createTSOStreams(re, s.ctx, s.backendEndpoints, 10)
+	defer s.cleanupGRPCStreams(cleanupFuncs)
+	svr := s.apiLeader.GetServer()
+
+	// The batcher exits once it's idle.
+	re.NoError(s.verifyTSOProxy(s.ctx, streams, cleanupFuncs, 10, true))
+	re.Equal(1, svr.GetTSOProxyBatcherCount())
+	testutil.Eventually(re, func() bool {
+		return svr.GetTSOProxyBatcherCount() == 0
+	})
+
+	// The batcher exits before being idle once the forwarded host is no longer the primary.
+	re.NoError(s.verifyTSOProxy(s.ctx, streams, cleanupFuncs, 10, true))
+	re.Equal(1, svr.GetTSOProxyBatcherCount())
+	svr.SetServicePrimaryAddr(utils.TSOServiceName, "http://127.0.0.1:1")
+	testutil.Eventually(re, func() bool {
+		return svr.GetTSOProxyBatcherCount() == 0
+	}, testutil.WithWaitFor(500*time.Millisecond), testutil.WithTickInterval(50*time.Millisecond))
+
+	// A new batcher is started for the primary again.
+	svr.SetServicePrimaryAddr(utils.TSOServiceName, tsoAddr)
+	re.NoError(s.verifyTSOProxy(s.ctx, streams, cleanupFuncs, 10, true))
+	re.Equal(1, svr.GetTSOProxyBatcherCount())
+}
+
 // TestTSOProxyClientsWithSameContext tests the TSO Proxy can work correctly while the grpc streams
 // are created with the same context.
 func (s *tsoProxyTestSuite) TestTSOProxyClientsWithSameContext() {
@@ -195,6 +325,45 @@ func (s *tsoProxyTestSuite) TestTSOProxyClientsWithSameContext() {
 	s.cleanupGRPCStreams(cleanupFuncs)
 }
 
+// TestTSOProxyMetrics tests the TSO Proxy reports the metrics of forwarding TSO requests
+// labeled by the address of the TSO service.
+func (s *tsoProxyTestSuite) TestTSOProxyMetrics() {
+	re := s.Require()
+	const requestsPerClient = 10
+	tsoAddr := s.tsoCluster.WaitForDefaultPrimaryServing(re).GetAddr()
+	getMetrics := func() (forwarded float64, forwardSamples uint64, streamings float64) {
+		families, err := prometheus.DefaultGatherer.Gather()
+		re.NoError(err)
+		for _, family := range families {
+			for _, m := range family.GetMetric() {
+				labels := make(map[string]string)
+				for _, label := range m.GetLabel() {
+					labels[label.GetName()] = label.GetValue()
+				}
+				if labels["tso_address"] != tsoAddr {
+					continue
+				}
+				switch family.GetName() {
+				case "pd_server_tso_proxy_forwarded_requests_total":
+					forwarded = m.GetCounter().GetValue()
+				case "pd_server_tso_proxy_forward_duration_seconds":
+					forwardSamples = m.GetHistogram().GetSampleCount()
+				case "pd_server_tso_proxy_streamings":
+					streamings = m.GetGauge().GetValue()
+				}
+			}
+		}
+		return
+	}
+
+	forwarded, forwardSamples, _ := getMetrics()
+	re.NoError(s.verifyTSOProxy(s.ctx, s.streams, s.cleanupFuncs, requestsPerClient, true))
+	newForwarded, newForwardSamples, streamings := getMetrics()
+	re.GreaterOrEqual(newForwarded-forwarded, float64(len(s.streams)*requestsPerClient))
+	re.GreaterOrEqual(newForwardSamples-forwardSamples, uint64(len(s.streams)*requestsPerClient))
+	re.GreaterOrEqual(streamings, float64(len(s.streams)))
+}
+
 // TestTSOProxyRecvFromClientTimeout tests the TSO Proxy can properly close the grpc stream on the server side
 // when the client does not send any request to the server for a long time.
 func (s *tsoProxyTestSuite) TestTSOProxyRecvFromClientTimeout() {
@@ -440,15 +609,22 @@ func tsoProxy(
 }
 
 var benmarkTSOProxyTable = []struct {
+	enableBatch       bool
 	concurrentClient  bool
 	requestsPerClient int
 }{
-	{true, 2},
-	{true, 10},
-	{true, 100},
-	{false, 2},
-	{false, 10},
-	{false, 100},
+	{false, true, 2},
+	{false, true, 10},
+	{false, true, 100},
+	{false, false, 2},
+	{false, false, 10},
+	{false, false, 100},
+	{true, true, 2},
+	{true, true, 10},
+	{true, true, 100},
+	{true, false, 2},
+	{true, false, 10},
+	{true, false, 100},
 }
 
 // BenchmarkTSOProxy10Clients benchmarks TSO proxy performance with 10 clients.
@@ -468,20 +644,40 @@ func BenchmarkTSOProxy1000Clients(b *testing.B) {
 
 // benchmarkTSOProxyNClients benchmarks TSO proxy performance.
 func benchmarkTSOProxyNClients(clientCount int, b *testing.B) {
-	suite := new(tsoProxyTestSuite)
-	suite.SetT(&testing.T{})
-	suite.SetupSuite()
-	re := suite.Require()
-
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	streams, cleanupFuncs := createTSOStreams(re, ctx, suite.backendEndpoints, clientCount)
+	suites := make(map[bool]*tsoProxyTestSuite)
+	streams := make(map[bool][]pdpb.PD_TsoClient)
+	defer func() {
+		for _, suite := range suites {
+			suite.TearDownSuite()
+		}
+	}()
 
 	// Benchmark TSO proxy
 	b.ResetTimer()
 	for _, t := range benmarkTSOProxyTable {
+		suite, ok := suites[t.enableBatch]
+		if !ok {
+			b.StopTimer()
+			suite = &tsoProxyTestSuite{enableBatch: t.enableBatch}
+			suite.SetT(&testing.T{})
+			suite.SetupSuite()
+			suites[t.enableBatch] = suite
+			// Let the suite clean up the benchmark streams when it is torn down.
+			var cleanupFuncs []testutil.CleanupFunc
+			streams[t.enableBatch], cleanupFuncs = createTSOStreams(suite.Require(), ctx, suite.backendEndpoints, clientCount)
+			suite.cleanupFuncs = append(suite.cleanupFuncs, cleanupFuncs...)
+			b.StartTimer()
+		}
+		re := suite.Require()
 		var builder strings.Builder
+		if t.enableBatch {
+			builder.WriteString("Batch_")
+		} else {
+			builder.WriteString("NoBatch_")
+		}
 		if t.concurrentClient {
 			builder.WriteString("ConcurrentClients_")
 		} else {
@@ -489,14 +685,10 @@ func benchmarkTSOProxyNClients(clientCount int, b *testing.B) {
 		}
 		b.Run(fmt.Sprintf("%s_%dReqsPerClient", builder.String(), t.requestsPerClient), func(b *testing.B) {
 			for i := 0; i < b.N; i++ {
-				err := tsoProxy(suite.defaultReq, streams, t.concurrentClient, t.requestsPerClient)
+				err := tsoProxy(suite.defaultReq, streams[t.enableBatch], t.concurrentClient, t.requestsPerClient)
 				re.NoError(err)
 			}
 		})
 	}
 	b.StopTimer()
-
-	suite.cleanupGRPCStreams(cleanupFuncs)
-
-	suite.TearDownSuite()
 }
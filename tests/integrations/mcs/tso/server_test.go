@@ -582,6 +582,42 @@ func (suite *CommonTestSuite) TestMetrics() {
 	re.Contains(string(respBytes), "tso_server_info")
 }
 
+func (suite *CommonTestSuite) TestKeyspaceGroupWatermarkMetric() {
+	re := suite.Require()
+	getWatermark := func() float64 {
+		resp, err := http.Get(suite.tsoDefaultPrimaryServer.GetConfig().GetAdvertiseListenAddr() + "/metrics")
+		re.NoError(err)
+		defer resp.Body.Close()
+		re.Equal(http.StatusOK, resp.StatusCode)
+		respBytes, err := io.ReadAll(resp.Body)
+		re.NoError(err)
+		prefix := fmt.Sprintf("pd_tso_keyspace_group_watermark{dc=\"global\",group=\"%d\"} ", utils.DefaultKeyspaceGroupID)
+		for _, line := range strings.Split(string(respBytes), "\n") {
+			if strings.HasPrefix(line, prefix) {
+				watermark, err := strconv.ParseFloat(strings.TrimPrefix(line, prefix), 64)
+				re.NoError(err)
+				return watermark
+			}
+		}
+		return 0
+	}
+
+	pdClient, err := pd.NewClientWithContext(suite.ctx, []string{suite.backendEndpoints}, pd.SecurityOption{})
+	re.NoError(err)
+	defer pdClient.Close()
+	physical, _, err := pdClient.GetTS(suite.ctx)
+	re.NoError(err)
+	watermark := getWatermark()
+	re.GreaterOrEqual(watermark, float64(physical))
+	// The watermark advances as the timestamps are allocated.
+	testutil.Eventually(re, func() bool {
+		physical, _, err = pdClient.GetTS(suite.ctx)
+		re.NoError(err)
+		return getWatermark() > watermark
+	})
+	re.GreaterOrEqual(getWatermark(), float64(physical))
+}
+
 func (suite *CommonTestSuite) TestBootstrapDefaultKeyspaceGroup() {
 	re := suite.Require()
 
@@ -231,6 +231,32 @@ func (suite *tsoClientTestSuite) TestGetTSAsync() {
 	wg.Wait()
 }
 
+func (suite *tsoClientTestSuite) TestGetTSRetryOnPrimaryChange() {
+	re := suite.Require()
+	client := suite.clients[0]
+	_, _, err := client.GetTS(suite.ctx)
+	re.NoError(err)
+
+	// Hold the request in the client until the primary has been moved.
+	re.NoError(failpoint.Enable("github.com/tikv/pd/client/delayProcessTSORequests", `pause`))
+	errCh := make(chan error, 1)
+	go func() {
+		_, _, err := client.GetTS(suite.ctx)
+		errCh <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+	if suite.legacy {
+		re.NoError(suite.cluster.ResignLeader())
+		suite.cluster.WaitLeader()
+	} else {
+		re.NoError(suite.tsoCluster.ResignPrimary(mcsutils.DefaultKeyspaceID, mcsutils.DefaultKeyspaceGroupID))
+		suite.tsoCluster.WaitForPrimaryServing(re, mcsutils.DefaultKeyspaceID, mcsutils.DefaultKeyspaceGroupID)
+	}
+	re.NoError(failpoint.Disable("github.com/tikv/pd/client/delayProcessTSORequests"))
+	// The request sent to the old primary should succeed after the internal retry.
+	re.NoError(<-errCh)
+}
+
 func (suite *tsoClientTestSuite) TestDiscoverTSOServiceWithLegacyPath() {
 	re := suite.Require()
 	keyspaceID := uint32(1000000)
@@ -525,3 +551,40 @@ func checkTSO(ctx context.Context, re *require.Assertions, wg *sync.WaitGroup, b
 		}()
 	}
 }
+
+var benchmarkTSOBatchWaitTable = []time.Duration{0, time.Millisecond, 5 * time.Millisecond}
+
+// BenchmarkTSOBatchWaitDuration benchmarks the throughput of the concurrent GetTS calls with
+// different max TSO batch wait durations.
+func BenchmarkTSOBatchWaitDuration(b *testing.B) {
+	re := require.New(b)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cluster, err := tests.NewTestCluster(ctx, 1)
+	re.NoError(err)
+	defer cluster.Destroy()
+	re.NoError(cluster.RunInitialServers())
+	leaderServer := cluster.GetServer(cluster.WaitLeader())
+	re.NoError(leaderServer.BootstrapCluster())
+
+	b.ResetTimer()
+	for _, wait := range benchmarkTSOBatchWaitTable {
+		b.Run(fmt.Sprintf("BatchWait_%s", wait), func(b *testing.B) {
+			cli, err := pd.NewClientWithContext(ctx, []string{leaderServer.GetAddr()}, pd.SecurityOption{},
+				pd.WithTSOBatchWaitDuration(wait))
+			re.NoError(err)
+			defer cli.Close()
+			// Simulate many concurrent callers.
+			b.SetParallelism(100)
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, _, err := cli.GetTS(ctx); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+	b.StopTimer()
+}
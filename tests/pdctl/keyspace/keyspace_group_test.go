@@ -18,12 +18,16 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/pingcap/failpoint"
 	"github.com/stretchr/testify/require"
+	"github.com/tikv/pd/pkg/keyspace"
 	"github.com/tikv/pd/pkg/mcs/utils"
 	"github.com/tikv/pd/pkg/storage/endpoint"
 	"github.com/tikv/pd/pkg/utils/tempurl"
@@ -89,6 +93,502 @@ func TestKeyspaceGroup(t *testing.T) {
 	re.Equal(keyspaceGroup.Keyspaces, []uint32{222, 333})
 }
 
+func TestKeyspaceGroupDiff(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tc, err := tests.NewTestAPICluster(ctx, 1)
+	re.NoError(err)
+	err = tc.RunInitialServers()
+	re.NoError(err)
+	tc.WaitLeader()
+	leaderServer := tc.GetServer(tc.GetLeader())
+	re.NoError(leaderServer.BootstrapCluster())
+	pdAddr := tc.GetConfig().GetClientURL()
+	cmd := pdctlCmd.GetRootCmd()
+
+	handlersutil.MustCreateKeyspaceGroup(re, leaderServer, &handlers.CreateKeyspaceGroupParams{
+		KeyspaceGroups: []*endpoint.KeyspaceGroup{
+			{
+				ID:       1,
+				UserKind: endpoint.Standard.String(),
+				Members: []endpoint.KeyspaceGroupMember{
+					{Address: "http://127.0.0.1:3379", Priority: 10},
+					{Address: "http://127.0.0.1:3380", Priority: 0},
+				},
+				Keyspaces: []uint32{111, 222, 333},
+			},
+			{
+				ID:       2,
+				UserKind: endpoint.Standard.String(),
+				Members: []endpoint.KeyspaceGroupMember{
+					{Address: "http://127.0.0.1:3379", Priority: 0},
+					{Address: "http://127.0.0.1:3381", Priority: 0},
+				},
+				Keyspaces: []uint32{222, 444},
+			},
+		},
+	})
+
+	args := []string{"-u", pdAddr, "keyspace-group", "diff", "1", "2"}
+	output, err := pdctl.ExecuteCommand(cmd, args...)
+	re.NoError(err)
+	var diff struct {
+		FirstID               uint32   `json:"first-id"`
+		SecondID              uint32   `json:"second-id"`
+		KeyspacesOnlyInFirst  []uint32 `json:"keyspaces-only-in-first"`
+		KeyspacesOnlyInSecond []uint32 `json:"keyspaces-only-in-second"`
+		Members               []struct {
+			Address        string `json:"address"`
+			FirstPriority  *int   `json:"first-priority"`
+			SecondPriority *int   `json:"second-priority"`
+		} `json:"members"`
+	}
+	err = json.Unmarshal(output, &diff)
+	re.NoError(err)
+	re.Equal(uint32(1), diff.FirstID)
+	re.Equal(uint32(2), diff.SecondID)
+	re.Equal([]uint32{111, 333}, diff.KeyspacesOnlyInFirst)
+	re.Equal([]uint32{444}, diff.KeyspacesOnlyInSecond)
+	re.Len(diff.Members, 3)
+	re.Equal("http://127.0.0.1:3379", diff.Members[0].Address)
+	re.Equal(10, *diff.Members[0].FirstPriority)
+	re.Equal(0, *diff.Members[0].SecondPriority)
+	re.Equal("http://127.0.0.1:3380", diff.Members[1].Address)
+	re.Nil(diff.Members[1].SecondPriority)
+	re.Equal("http://127.0.0.1:3381", diff.Members[2].Address)
+	re.Nil(diff.Members[2].FirstPriority)
+
+	// params error for diff.
+	args = []string{"-u", pdAddr, "keyspace-group", "diff", "1", "xxx"}
+	output, err = pdctl.ExecuteCommand(cmd, args...)
+	re.NoError(err)
+	re.Contains(string(output), "Failed to parse the keyspace group ID")
+	args = []string{"-u", pdAddr, "keyspace-group", "diff", "1", "3"}
+	output, err = pdctl.ExecuteCommand(cmd, args...)
+	re.NoError(err)
+	re.Contains(string(output), "Failed to get the keyspace group information")
+}
+
+func TestListKeyspacesInKeyspaceGroup(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tc, err := tests.NewTestAPICluster(ctx, 1)
+	re.NoError(err)
+	err = tc.RunInitialServers()
+	re.NoError(err)
+	tc.WaitLeader()
+	leaderServer := tc.GetServer(tc.GetLeader())
+	re.NoError(leaderServer.BootstrapCluster())
+	pdAddr := tc.GetConfig().GetClientURL()
+	cmd := pdctlCmd.GetRootCmd()
+
+	keyspaces := make([]uint32, 0, 130)
+	for i := 0; i < 130; i++ {
+		keyspaces = append(keyspaces, uint32(i+1))
+	}
+	handlersutil.MustCreateKeyspaceGroup(re, leaderServer, &handlers.CreateKeyspaceGroupParams{
+		KeyspaceGroups: []*endpoint.KeyspaceGroup{
+			{
+				ID:        1,
+				UserKind:  endpoint.Standard.String(),
+				Members:   make([]endpoint.KeyspaceGroupMember, utils.DefaultKeyspaceGroupReplicaCount),
+				Keyspaces: keyspaces,
+			},
+		},
+	})
+	listKeyspaces := func(limit, offset int) *handlers.KeyspacesInKeyspaceGroup {
+		args := []string{"-u", pdAddr, "keyspace-group", "list-keyspaces", "1",
+			fmt.Sprintf("--limit=%d", limit), fmt.Sprintf("--offset=%d", offset)}
+		output, err := pdctl.ExecuteCommand(cmd, args...)
+		re.NoError(err)
+		var page handlers.KeyspacesInKeyspaceGroup
+		re.NoError(json.Unmarshal(output, &page))
+		return &page
+	}
+
+	page := listKeyspaces(50, 0)
+	re.Equal(uint32(1), page.ID)
+	re.Equal(130, page.Total)
+	re.Equal(keyspaces[:50], page.Keyspaces)
+	page = listKeyspaces(50, 100)
+	re.Equal(130, page.Total)
+	re.Equal(keyspaces[100:], page.Keyspaces)
+	// All the keyspaces from the offset are listed without the limit.
+	page = listKeyspaces(0, 10)
+	re.Equal(keyspaces[10:], page.Keyspaces)
+
+	// The offset is past the end.
+	args := []string{"-u", pdAddr, "keyspace-group", "list-keyspaces", "1", "--limit=10", "--offset=130"}
+	output, err := pdctl.ExecuteCommand(cmd, args...)
+	re.NoError(err)
+	re.Contains(string(output), "offset 130 is out of range")
+	// The keyspace group does not exist.
+	args = []string{"-u", pdAddr, "keyspace-group", "list-keyspaces", "2", "--limit=0", "--offset=0"}
+	output, err = pdctl.ExecuteCommand(cmd, args...)
+	re.NoError(err)
+	re.Contains(string(output), "keyspace group does not exist")
+	// params error for list-keyspaces.
+	args = []string{"-u", pdAddr, "keyspace-group", "list-keyspaces", "xxx"}
+	output, err = pdctl.ExecuteCommand(cmd, args...)
+	re.NoError(err)
+	re.Contains(string(output), "Failed to parse the keyspace group ID")
+}
+
+func TestKeyspaceGroupNodeLoad(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tc, err := tests.NewTestAPICluster(ctx, 1)
+	re.NoError(err)
+	err = tc.RunInitialServers()
+	re.NoError(err)
+	tc.WaitLeader()
+	leaderServer := tc.GetServer(tc.GetLeader())
+	re.NoError(leaderServer.BootstrapCluster())
+	pdAddr := tc.GetConfig().GetClientURL()
+	cmd := pdctlCmd.GetRootCmd()
+
+	const (
+		node1 = "http://127.0.0.1:3379"
+		node2 = "http://127.0.0.1:3380"
+		node3 = "http://127.0.0.1:3381"
+	)
+	handlersutil.MustCreateKeyspaceGroup(re, leaderServer, &handlers.CreateKeyspaceGroupParams{
+		KeyspaceGroups: []*endpoint.KeyspaceGroup{
+			{
+				ID:       1,
+				UserKind: endpoint.Standard.String(),
+				Members:  []endpoint.KeyspaceGroupMember{{Address: node1, Priority: 10}, {Address: node2, Priority: 0}},
+			},
+			{
+				ID:       2,
+				UserKind: endpoint.Standard.String(),
+				Members:  []endpoint.KeyspaceGroupMember{{Address: node1, Priority: 10}, {Address: node3, Priority: 0}},
+			},
+			{
+				ID:       3,
+				UserKind: endpoint.Standard.String(),
+				Members:  []endpoint.KeyspaceGroupMember{{Address: node2, Priority: 0}, {Address: node3, Priority: 10}},
+			},
+			{
+				// The primary can not be determined since the priorities are the same.
+				ID:       4,
+				UserKind: endpoint.Standard.String(),
+				Members:  []endpoint.KeyspaceGroupMember{{Address: node1, Priority: 0}, {Address: node2, Priority: 0}},
+			},
+		},
+	})
+
+	args := []string{"-u", pdAddr, "keyspace-group", "node-load"}
+	output, err := pdctl.ExecuteCommand(cmd, args...)
+	re.NoError(err)
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	re.Equal([]string{"ADDRESS", "PRIMARY", "SECONDARY", "TOTAL"}, strings.Fields(lines[0]))
+	loads := make([][]string, 0, len(lines)-1)
+	for _, line := range lines[1:] {
+		loads = append(loads, strings.Fields(line))
+	}
+	// The loads are sorted by the total count in descending order.
+	re.Equal([][]string{
+		{node1, "2", "1", "3"},
+		{node2, "0", "3", "3"},
+		{node3, "1", "1", "2"},
+	}, loads)
+
+	// params error for node-load.
+	args = []string{"-u", pdAddr, "keyspace-group", "node-load", "1"}
+	output, err = pdctl.ExecuteCommand(cmd, args...)
+	re.NoError(err)
+	re.Contains(string(output), "Usage")
+}
+
+func TestKeyspaceGroupByNode(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tc, err := tests.NewTestAPICluster(ctx, 1)
+	re.NoError(err)
+	err = tc.RunInitialServers()
+	re.NoError(err)
+	tc.WaitLeader()
+	leaderServer := tc.GetServer(tc.GetLeader())
+	re.NoError(leaderServer.BootstrapCluster())
+	pdAddr := tc.GetConfig().GetClientURL()
+	cmd := pdctlCmd.GetRootCmd()
+
+	const (
+		node1 = "http://127.0.0.1:3379"
+		node2 = "http://127.0.0.1:3380"
+		node3 = "http://127.0.0.1:3381"
+	)
+	handlersutil.MustCreateKeyspaceGroup(re, leaderServer, &handlers.CreateKeyspaceGroupParams{
+		KeyspaceGroups: []*endpoint.KeyspaceGroup{
+			{
+				ID:        1,
+				UserKind:  endpoint.Standard.String(),
+				Members:   []endpoint.KeyspaceGroupMember{{Address: node1, Priority: 10}, {Address: node2, Priority: 0}},
+				Keyspaces: []uint32{111},
+			},
+			{
+				ID:        2,
+				UserKind:  endpoint.Standard.String(),
+				Members:   []endpoint.KeyspaceGroupMember{{Address: node2, Priority: 10}, {Address: node3, Priority: 0}},
+				Keyspaces: []uint32{222},
+			},
+			{
+				ID:        3,
+				UserKind:  endpoint.Standard.String(),
+				Members:   []endpoint.KeyspaceGroupMember{{Address: node1, Priority: 0}, {Address: node3, Priority: 10}},
+				Keyspaces: []uint32{333},
+			},
+		},
+	})
+
+	type membership struct {
+		ID        uint32   `json:"id"`
+		Role      string   `json:"role"`
+		Priority  int      `json:"priority"`
+		Keyspaces []uint32 `json:"keyspaces"`
+	}
+	byNode := func(node string) []membership {
+		args := []string{"-u", pdAddr, "keyspace-group", "by-node", node}
+		output, err := pdctl.ExecuteCommand(cmd, args...)
+		re.NoError(err)
+		var memberships []membership
+		re.NoError(json.Unmarshal(output, &memberships), string(output))
+		return memberships
+	}
+	re.Equal([]membership{
+		{ID: 1, Role: "primary", Priority: 10, Keyspaces: []uint32{111}},
+		{ID: 3, Role: "secondary", Priority: 0, Keyspaces: []uint32{333}},
+	}, byNode(node1))
+	re.Equal([]membership{
+		{ID: 1, Role: "secondary", Priority: 0, Keyspaces: []uint32{111}},
+		{ID: 2, Role: "primary", Priority: 10, Keyspaces: []uint32{222}},
+	}, byNode(node2))
+	re.Equal([]membership{
+		{ID: 2, Role: "secondary", Priority: 0, Keyspaces: []uint32{222}},
+		{ID: 3, Role: "primary", Priority: 10, Keyspaces: []uint32{333}},
+	}, byNode(node3))
+	re.Empty(byNode("http://127.0.0.1:3382"))
+
+	// params error for by-node.
+	args := []string{"-u", pdAddr, "keyspace-group", "by-node", "127.0.0.1:3379"}
+	output, err := pdctl.ExecuteCommand(cmd, args...)
+	re.NoError(err)
+	re.Contains(string(output), "Failed to parse the tso node address")
+}
+
+func TestKeyspaceGroupProgress(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	re.NoError(failpoint.Enable("github.com/tikv/pd/pkg/keyspace/acceleratedAllocNodes", `return(true)`))
+	re.NoError(failpoint.Enable("github.com/tikv/pd/server/delayStartServerLoop", `return(true)`))
+	// Keep the split in progress since the TSO node fails to finish it.
+	re.NoError(failpoint.Enable("github.com/tikv/pd/pkg/tso/failedToFinishSplit", `return(true)`))
+	keyspaces := make([]string, 0)
+	for i := 0; i < 10; i++ {
+		keyspaces = append(keyspaces, fmt.Sprintf("keyspace_%d", i))
+	}
+	tc, err := tests.NewTestAPICluster(ctx, 1, func(conf *config.Config, serverName string) {
+		conf.Keyspace.PreAlloc = keyspaces
+	})
+	re.NoError(err)
+	err = tc.RunInitialServers()
+	re.NoError(err)
+	pdAddr := tc.GetConfig().GetClientURL()
+
+	_, tsoServerCleanup1, err := tests.StartSingleTSOTestServer(ctx, re, pdAddr, tempurl.Alloc())
+	defer tsoServerCleanup1()
+	re.NoError(err)
+	_, tsoServerCleanup2, err := tests.StartSingleTSOTestServer(ctx, re, pdAddr, tempurl.Alloc())
+	defer tsoServerCleanup2()
+	re.NoError(err)
+	cmd := pdctlCmd.GetRootCmd()
+
+	tc.WaitLeader()
+	leaderServer := tc.GetServer(tc.GetLeader())
+	re.NoError(leaderServer.BootstrapCluster())
+
+	args := []string{"-u", pdAddr, "keyspace-group"}
+	mustExecute := func(cmdArgs ...string) {
+		testutil.Eventually(re, func() bool {
+			output, err := pdctl.ExecuteCommand(cmd, append(args, cmdArgs...)...)
+			re.NoError(err)
+			return strings.Contains(string(output), "Success")
+		})
+	}
+	getProgress := func(id string) *keyspace.KeyspaceGroupProgress {
+		output, err := pdctl.ExecuteCommand(cmd, append(args, id)...)
+		re.NoError(err)
+		var keyspaceGroup struct {
+			Progress *keyspace.KeyspaceGroupProgress `json:"progress"`
+		}
+		re.NoError(json.Unmarshal(output, &keyspaceGroup), string(output))
+		return keyspaceGroup.Progress
+	}
+	re.Nil(getProgress("0"))
+
+	// The split reports both the split source and target with their TSO primaries.
+	mustExecute("split", "0", "1", "2", "4")
+	for _, id := range []string{"0", "1"} {
+		testutil.Eventually(re, func() bool {
+			progress := getProgress(id)
+			re.NotNil(progress)
+			re.Equal(uint32(0), progress.SplitSource.ID)
+			re.Equal(uint32(1), progress.SplitTarget.ID)
+			re.Nil(progress.MergeTarget)
+			return len(progress.SplitSource.Primary) != 0 && len(progress.SplitTarget.Primary) != 0
+		})
+	}
+	// The progress is cleared after the split is finished.
+	mustExecute("finish-split", "1")
+	re.Nil(getProgress("0"))
+	re.Nil(getProgress("1"))
+
+	// The merge reports the merge target with its TSO primary and the merge list.
+	mustExecute("merge", "0", "1")
+	progress := getProgress("0")
+	re.NotNil(progress)
+	re.Nil(progress.SplitSource)
+	re.Nil(progress.SplitTarget)
+	re.Equal(uint32(0), progress.MergeTarget.ID)
+	re.NotEmpty(progress.MergeTarget.Primary)
+	re.Equal([]uint32{1}, progress.MergeList)
+	// The merge sources are removed from the pending list once their TSO primaries are gone,
+	// then the merge is finished by the merge target TSO primary.
+	testutil.Eventually(re, func() bool {
+		progress := getProgress("0")
+		return progress == nil || len(progress.PendingMergeList) == 0
+	})
+	testutil.Eventually(re, func() bool {
+		return getProgress("0") == nil
+	})
+
+	re.NoError(failpoint.Disable("github.com/tikv/pd/pkg/keyspace/acceleratedAllocNodes"))
+	re.NoError(failpoint.Disable("github.com/tikv/pd/server/delayStartServerLoop"))
+	re.NoError(failpoint.Disable("github.com/tikv/pd/pkg/tso/failedToFinishSplit"))
+}
+
+func TestStreamKeyspaceGroups(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tc, err := tests.NewTestAPICluster(ctx, 1)
+	re.NoError(err)
+	err = tc.RunInitialServers()
+	re.NoError(err)
+	tc.WaitLeader()
+	leaderServer := tc.GetServer(tc.GetLeader())
+	re.NoError(leaderServer.BootstrapCluster())
+	pdAddr := tc.GetConfig().GetClientURL()
+	cmd := pdctlCmd.GetRootCmd()
+
+	// Create many keyspace groups which span multiple pages.
+	const groupCount = 350
+	for start := 1; start <= groupCount; start += 100 {
+		kgs := make([]*endpoint.KeyspaceGroup, 0, 100)
+		for id := start; id < start+100 && id <= groupCount; id++ {
+			kgs = append(kgs, &endpoint.KeyspaceGroup{
+				ID:        uint32(id),
+				UserKind:  endpoint.Standard.String(),
+				Keyspaces: []uint32{uint32(id)},
+			})
+		}
+		handlersutil.MustCreateKeyspaceGroup(re, leaderServer, &handlers.CreateKeyspaceGroupParams{KeyspaceGroups: kgs})
+	}
+
+	// The response is chunked rather than a single body.
+	resp, err := http.Get(leaderServer.GetAddr() + "/pd/api/v2/tso/keyspace-groups?stream=true")
+	re.NoError(err)
+	re.NoError(resp.Body.Close())
+	re.Equal(http.StatusOK, resp.StatusCode)
+	re.Equal([]string{"chunked"}, resp.TransferEncoding)
+	re.Equal("application/x-ndjson", resp.Header.Get("Content-Type"))
+
+	output, err := pdctl.ExecuteCommand(cmd, "-u", pdAddr, "keyspace-group", "--stream")
+	re.NoError(err)
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	// Including the default keyspace group.
+	re.Len(lines, groupCount+1)
+	for i, line := range lines {
+		var kg endpoint.KeyspaceGroup
+		re.NoError(json.Unmarshal([]byte(line), &kg))
+		re.Equal(uint32(i), kg.ID)
+	}
+
+	// The limit also works with the stream, which returns the same keyspace groups as the non-streaming path.
+	query := "/pd/api/v2/tso/keyspace-groups?page_token=10&limit=150"
+	resp, err = http.Get(leaderServer.GetAddr() + query)
+	re.NoError(err)
+	var expected []*endpoint.KeyspaceGroup
+	re.NoError(json.NewDecoder(resp.Body).Decode(&expected))
+	re.NoError(resp.Body.Close())
+	re.NotEmpty(expected)
+	resp, err = http.Get(leaderServer.GetAddr() + query + "&stream=true")
+	re.NoError(err)
+	var streamed []*endpoint.KeyspaceGroup
+	decoder := json.NewDecoder(resp.Body)
+	for decoder.More() {
+		var kg endpoint.KeyspaceGroup
+		re.NoError(decoder.Decode(&kg))
+		streamed = append(streamed, &kg)
+	}
+	re.NoError(resp.Body.Close())
+	re.Equal(expected, streamed)
+
+	// The state filter also works with the stream.
+	output, err = pdctl.ExecuteCommand(cmd, "-u", pdAddr, "keyspace-group", "--state=split", "--stream")
+	re.NoError(err)
+	re.Empty(strings.TrimSpace(string(output)))
+}
+
+func TestWatchKeyspaceGroups(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tc, err := tests.NewTestAPICluster(ctx, 1)
+	re.NoError(err)
+	err = tc.RunInitialServers()
+	re.NoError(err)
+	tc.WaitLeader()
+	leaderServer := tc.GetServer(tc.GetLeader())
+	re.NoError(leaderServer.BootstrapCluster())
+	pdAddr := tc.GetConfig().GetClientURL()
+	cmd := pdctlCmd.GetRootCmd()
+
+	// Only watch the keyspace group 1.
+	outputCh := make(chan string, 1)
+	go func() {
+		output, err := pdctl.ExecuteCommand(cmd, "-u", pdAddr, "keyspace-group", "watch", "1", "--timeout=3s")
+		re.NoError(err)
+		outputCh <- string(output)
+	}()
+	// Wait for the watch to be established.
+	time.Sleep(time.Second)
+	handlersutil.MustCreateKeyspaceGroup(re, leaderServer, &handlers.CreateKeyspaceGroupParams{
+		KeyspaceGroups: []*endpoint.KeyspaceGroup{
+			{ID: 1, UserKind: endpoint.Standard.String(), Keyspaces: []uint32{111}},
+			{ID: 2, UserKind: endpoint.Standard.String(), Keyspaces: []uint32{222}},
+		},
+	})
+	handlersutil.MustDeleteKeyspaceGroup(re, leaderServer, 1)
+
+	output := <-outputCh
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	re.Len(lines, 2, output)
+	re.Contains(lines[0], "put keyspace group 1: ")
+	kg := &endpoint.KeyspaceGroup{}
+	re.NoError(json.Unmarshal([]byte(lines[0][strings.Index(lines[0], "{"):]), kg))
+	re.Equal(uint32(1), kg.ID)
+	re.Equal([]uint32{111}, kg.Keyspaces)
+	re.Contains(lines[1], "delete keyspace group 1")
+	re.NotContains(output, "keyspace group 2")
+}
+
 func TestSplitKeyspaceGroup(t *testing.T) {
 	re := require.New(t)
 	ctx, cancel := context.WithCancel(context.Background())
@@ -96,11 +596,560 @@ func TestSplitKeyspaceGroup(t *testing.T) {
 	re.NoError(failpoint.Enable("github.com/tikv/pd/pkg/keyspace/acceleratedAllocNodes", `return(true)`))
 	re.NoError(failpoint.Enable("github.com/tikv/pd/server/delayStartServerLoop", `return(true)`))
 	keyspaces := make([]string, 0)
-	// we test the case which exceed the default max txn ops limit in etcd, which is 128.
-	for i := 0; i < 129; i++ {
+	// we test the case which exceed the default max txn ops limit in etcd, which is 128.
+	for i := 0; i < 129; i++ {
+		keyspaces = append(keyspaces, fmt.Sprintf("keyspace_%d", i))
+	}
+	tc, err := tests.NewTestAPICluster(ctx, 3, func(conf *config.Config, serverName string) {
+		conf.Keyspace.PreAlloc = keyspaces
+	})
+	re.NoError(err)
+	err = tc.RunInitialServers()
+	re.NoError(err)
+	pdAddr := tc.GetConfig().GetClientURL()
+
+	_, tsoServerCleanup1, err := tests.StartSingleTSOTestServer(ctx, re, pdAddr, tempurl.Alloc())
+	defer tsoServerCleanup1()
+	re.NoError(err)
+	_, tsoServerCleanup2, err := tests.StartSingleTSOTestServer(ctx, re, pdAddr, tempurl.Alloc())
+	defer tsoServerCleanup2()
+	re.NoError(err)
+	cmd := pdctlCmd.GetRootCmd()
+
+	tc.WaitLeader()
+	leaderServer := tc.GetServer(tc.GetLeader())
+	re.NoError(leaderServer.BootstrapCluster())
+
+	// split keyspace group.
+	testutil.Eventually(re, func() bool {
+		args := []string{"-u", pdAddr, "keyspace-group", "split", "0", "1", "2"}
+		output, err := pdctl.ExecuteCommand(cmd, args...)
+		re.NoError(err)
+		return strings.Contains(string(output), "Success")
+	})
+
+	// get all keyspaces
+	args := []string{"-u", pdAddr, "keyspace-group"}
+	output, err := pdctl.ExecuteCommand(cmd, args...)
+	re.NoError(err)
+	strings.Contains(string(output), "Success")
+	var keyspaceGroups []*endpoint.KeyspaceGroup
+	err = json.Unmarshal(output, &keyspaceGroups)
+	re.NoError(err)
+	re.Len(keyspaceGroups, 2)
+	re.Equal(keyspaceGroups[0].ID, uint32(0))
+	re.Equal(keyspaceGroups[1].ID, uint32(1))
+
+	re.NoError(failpoint.Disable("github.com/tikv/pd/pkg/keyspace/acceleratedAllocNodes"))
+	re.NoError(failpoint.Disable("github.com/tikv/pd/server/delayStartServerLoop"))
+}
+
+func TestRollbackSplitKeyspaceGroup(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	re.NoError(failpoint.Enable("github.com/tikv/pd/pkg/keyspace/acceleratedAllocNodes", `return(true)`))
+	re.NoError(failpoint.Enable("github.com/tikv/pd/server/delayStartServerLoop", `return(true)`))
+	// Keep the split in progress since the TSO node fails to finish it.
+	re.NoError(failpoint.Enable("github.com/tikv/pd/pkg/tso/failedToFinishSplit", `return(true)`))
+	keyspaces := make([]string, 0)
+	for i := 0; i < 10; i++ {
+		keyspaces = append(keyspaces, fmt.Sprintf("keyspace_%d", i))
+	}
+	tc, err := tests.NewTestAPICluster(ctx, 1, func(conf *config.Config, serverName string) {
+		conf.Keyspace.PreAlloc = keyspaces
+	})
+	re.NoError(err)
+	err = tc.RunInitialServers()
+	re.NoError(err)
+	pdAddr := tc.GetConfig().GetClientURL()
+
+	_, tsoServerCleanup1, err := tests.StartSingleTSOTestServer(ctx, re, pdAddr, tempurl.Alloc())
+	defer tsoServerCleanup1()
+	re.NoError(err)
+	_, tsoServerCleanup2, err := tests.StartSingleTSOTestServer(ctx, re, pdAddr, tempurl.Alloc())
+	defer tsoServerCleanup2()
+	re.NoError(err)
+	cmd := pdctlCmd.GetRootCmd()
+
+	tc.WaitLeader()
+	leaderServer := tc.GetServer(tc.GetLeader())
+	re.NoError(leaderServer.BootstrapCluster())
+
+	getKeyspaceGroup := func(id string) *endpoint.KeyspaceGroup {
+		output, err := pdctl.ExecuteCommand(cmd, "-u", pdAddr, "keyspace-group", id)
+		re.NoError(err)
+		var kg endpoint.KeyspaceGroup
+		re.NoError(json.Unmarshal(output, &kg))
+		return &kg
+	}
+	splitKeyspaceGroup := func(keyspaces ...string) {
+		testutil.Eventually(re, func() bool {
+			args := append([]string{"-u", pdAddr, "keyspace-group", "split", "0", "1"}, keyspaces...)
+			output, err := pdctl.ExecuteCommand(cmd, args...)
+			re.NoError(err)
+			return strings.Contains(string(output), "Success")
+		})
+	}
+	originalKeyspaces := getKeyspaceGroup("0").Keyspaces
+	re.Len(originalKeyspaces, len(keyspaces)+1)
+
+	// Split the keyspace group and roll it back by the split target.
+	splitKeyspaceGroup("2", "4", "6")
+	re.True(getKeyspaceGroup("1").IsSplitTarget())
+	kg := getKeyspaceGroup("0")
+	re.True(kg.IsSplitSource())
+	re.NotContains(kg.Keyspaces, uint32(4))
+	args := []string{"-u", pdAddr, "keyspace-group", "rollback-split", "1"}
+	output, err := pdctl.ExecuteCommand(cmd, args...)
+	re.NoError(err)
+	kg = &endpoint.KeyspaceGroup{}
+	re.NoError(json.Unmarshal(output, kg))
+	re.Equal(uint32(0), kg.ID)
+	re.Equal(originalKeyspaces, kg.Keyspaces)
+	kg = getKeyspaceGroup("0")
+	re.False(kg.IsSplitting())
+	re.Equal(originalKeyspaces, kg.Keyspaces)
+	output, err = pdctl.ExecuteCommand(cmd, "-u", pdAddr, "keyspace-group")
+	re.NoError(err)
+	var keyspaceGroups []*endpoint.KeyspaceGroup
+	re.NoError(json.Unmarshal(output, &keyspaceGroups))
+	re.Len(keyspaceGroups, 1)
+	// There is no split to roll back anymore.
+	output, err = pdctl.ExecuteCommand(cmd, args...)
+	re.NoError(err)
+	re.Contains(string(output), "Failed to rollback the split")
+
+	// Split the keyspace group again and roll it back by the split source.
+	splitKeyspaceGroup("3")
+	output, err = pdctl.ExecuteCommand(cmd, "-u", pdAddr, "keyspace-group", "rollback-split", "0")
+	re.NoError(err)
+	kg = &endpoint.KeyspaceGroup{}
+	re.NoError(json.Unmarshal(output, kg))
+	re.Equal(originalKeyspaces, kg.Keyspaces)
+	re.False(getKeyspaceGroup("0").IsSplitting())
+
+	// The finished split can not be rolled back.
+	splitKeyspaceGroup("5")
+	output, err = pdctl.ExecuteCommand(cmd, "-u", pdAddr, "keyspace-group", "finish-split", "1")
+	re.NoError(err)
+	re.Contains(string(output), "Success")
+	output, err = pdctl.ExecuteCommand(cmd, args...)
+	re.NoError(err)
+	re.Contains(string(output), "not in split state")
+	re.Equal([]uint32{5}, getKeyspaceGroup("1").Keyspaces)
+	re.NotContains(getKeyspaceGroup("0").Keyspaces, uint32(5))
+
+	re.NoError(failpoint.Disable("github.com/tikv/pd/pkg/keyspace/acceleratedAllocNodes"))
+	re.NoError(failpoint.Disable("github.com/tikv/pd/server/delayStartServerLoop"))
+	re.NoError(failpoint.Disable("github.com/tikv/pd/pkg/tso/failedToFinishSplit"))
+}
+
+func TestResetKeyspaceGroupState(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	re.NoError(failpoint.Enable("github.com/tikv/pd/pkg/keyspace/acceleratedAllocNodes", `return(true)`))
+	re.NoError(failpoint.Enable("github.com/tikv/pd/server/delayStartServerLoop", `return(true)`))
+	// Simulate the split is stuck since the TSO node fails to finish it.
+	re.NoError(failpoint.Enable("github.com/tikv/pd/pkg/tso/failedToFinishSplit", `return(true)`))
+	tc, err := tests.NewTestAPICluster(ctx, 1, func(conf *config.Config, serverName string) {
+		conf.Keyspace.PreAlloc = []string{"keyspace_a", "keyspace_b"}
+	})
+	re.NoError(err)
+	err = tc.RunInitialServers()
+	re.NoError(err)
+	pdAddr := tc.GetConfig().GetClientURL()
+
+	_, tsoServerCleanup1, err := tests.StartSingleTSOTestServer(ctx, re, pdAddr, tempurl.Alloc())
+	defer tsoServerCleanup1()
+	re.NoError(err)
+	_, tsoServerCleanup2, err := tests.StartSingleTSOTestServer(ctx, re, pdAddr, tempurl.Alloc())
+	defer tsoServerCleanup2()
+	re.NoError(err)
+	cmd := pdctlCmd.GetRootCmd()
+
+	tc.WaitLeader()
+	leaderServer := tc.GetServer(tc.GetLeader())
+	re.NoError(leaderServer.BootstrapCluster())
+
+	testutil.Eventually(re, func() bool {
+		args := []string{"-u", pdAddr, "keyspace-group", "split", "0", "1", "2"}
+		output, err := pdctl.ExecuteCommand(cmd, args...)
+		re.NoError(err)
+		return strings.Contains(string(output), "Success")
+	})
+	getKeyspaceGroup := func(id string) *endpoint.KeyspaceGroup {
+		output, err := pdctl.ExecuteCommand(cmd, "-u", pdAddr, "keyspace-group", id)
+		re.NoError(err)
+		var kg endpoint.KeyspaceGroup
+		re.NoError(json.Unmarshal(output, &kg))
+		return &kg
+	}
+	re.True(getKeyspaceGroup("1").IsSplitTarget())
+	re.True(getKeyspaceGroup("0").IsSplitSource())
+
+	// Preview the reset without --force.
+	args := []string{"-u", pdAddr, "keyspace-group", "reset-state", "1"}
+	output, err := pdctl.ExecuteCommand(cmd, args...)
+	re.NoError(err)
+	re.Contains(string(output), "use --force to apply")
+	var keyspaceGroups []*endpoint.KeyspaceGroup
+	re.NoError(json.Unmarshal(output[strings.Index(string(output), "\n")+1:], &keyspaceGroups))
+	re.Len(keyspaceGroups, 2)
+	re.Equal(uint32(1), keyspaceGroups[0].ID)
+	re.Equal(uint32(0), keyspaceGroups[1].ID)
+	for _, kg := range keyspaceGroups {
+		re.False(kg.IsSplitting())
+	}
+	re.True(getKeyspaceGroup("1").IsSplitTarget())
+	re.True(getKeyspaceGroup("0").IsSplitSource())
+
+	// Reset the state with --force.
+	output, err = pdctl.ExecuteCommand(cmd, append(args, "--force")...)
+	re.NoError(err)
+	keyspaceGroups = nil
+	re.NoError(json.Unmarshal(output, &keyspaceGroups))
+	re.Len(keyspaceGroups, 2)
+	kg := getKeyspaceGroup("1")
+	re.False(kg.IsSplitting())
+	re.Equal([]uint32{2}, kg.Keyspaces)
+	kg = getKeyspaceGroup("0")
+	re.False(kg.IsSplitting())
+	re.NotContains(kg.Keyspaces, uint32(2))
+
+	// The keyspace group is not in split/merge state anymore.
+	output, err = pdctl.ExecuteCommand(cmd, append(args, "--force")...)
+	re.NoError(err)
+	re.Contains(string(output), "neither in split nor merging state")
+
+	re.NoError(failpoint.Disable("github.com/tikv/pd/pkg/keyspace/acceleratedAllocNodes"))
+	re.NoError(failpoint.Disable("github.com/tikv/pd/server/delayStartServerLoop"))
+	re.NoError(failpoint.Disable("github.com/tikv/pd/pkg/tso/failedToFinishSplit"))
+}
+
+func TestExternalAllocNodeWhenStart(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// external alloc node for keyspace group, when keyspace manager update keyspace info to keyspace group
+	// we hope the keyspace group can be updated correctly.
+	re.NoError(failpoint.Enable("github.com/tikv/pd/pkg/keyspace/externalAllocNode", `return("127.0.0.1:2379,127.0.0.1:2380")`))
+	re.NoError(failpoint.Enable("github.com/tikv/pd/pkg/keyspace/acceleratedAllocNodes", `return(true)`))
+	re.NoError(failpoint.Enable("github.com/tikv/pd/server/delayStartServerLoop", `return(true)`))
+	keyspaces := make([]string, 0)
+	for i := 0; i < 10; i++ {
+		keyspaces = append(keyspaces, fmt.Sprintf("keyspace_%d", i))
+	}
+	tc, err := tests.NewTestAPICluster(ctx, 1, func(conf *config.Config, serverName string) {
+		conf.Keyspace.PreAlloc = keyspaces
+	})
+	re.NoError(err)
+	err = tc.RunInitialServers()
+	re.NoError(err)
+	pdAddr := tc.GetConfig().GetClientURL()
+
+	cmd := pdctlCmd.GetRootCmd()
+
+	tc.WaitLeader()
+	leaderServer := tc.GetServer(tc.GetLeader())
+	re.NoError(leaderServer.BootstrapCluster())
+
+	// check keyspace group information.
+	defaultKeyspaceGroupID := fmt.Sprintf("%d", utils.DefaultKeyspaceGroupID)
+	args := []string{"-u", pdAddr, "keyspace-group"}
+	testutil.Eventually(re, func() bool {
+		output, err := pdctl.ExecuteCommand(cmd, append(args, defaultKeyspaceGroupID)...)
+		re.NoError(err)
+		var keyspaceGroup endpoint.KeyspaceGroup
+		err = json.Unmarshal(output, &keyspaceGroup)
+		re.NoError(err)
+		return len(keyspaceGroup.Keyspaces) == len(keyspaces)+1 && len(keyspaceGroup.Members) == 2
+	})
+
+	re.NoError(failpoint.Disable("github.com/tikv/pd/pkg/keyspace/externalAllocNode"))
+	re.NoError(failpoint.Disable("github.com/tikv/pd/pkg/keyspace/acceleratedAllocNodes"))
+	re.NoError(failpoint.Disable("github.com/tikv/pd/server/delayStartServerLoop"))
+}
+
+func TestSetNodeAndPriorityKeyspaceGroup(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	keyspaces := make([]string, 0)
+	for i := 0; i < 10; i++ {
+		keyspaces = append(keyspaces, fmt.Sprintf("keyspace_%d", i))
+	}
+	tc, err := tests.NewTestAPICluster(ctx, 3, func(conf *config.Config, serverName string) {
+		conf.Keyspace.PreAlloc = keyspaces
+	})
+	re.NoError(err)
+	err = tc.RunInitialServers()
+	re.NoError(err)
+	pdAddr := tc.GetConfig().GetClientURL()
+
+	s1, tsoServerCleanup1, err := tests.StartSingleTSOTestServer(ctx, re, pdAddr, tempurl.Alloc())
+	defer tsoServerCleanup1()
+	re.NoError(err)
+	s2, tsoServerCleanup2, err := tests.StartSingleTSOTestServer(ctx, re, pdAddr, tempurl.Alloc())
+	defer tsoServerCleanup2()
+	re.NoError(err)
+	cmd := pdctlCmd.GetRootCmd()
+
+	tc.WaitLeader()
+	leaderServer := tc.GetServer(tc.GetLeader())
+	re.NoError(leaderServer.BootstrapCluster())
+
+	// set-node keyspace group.
+	defaultKeyspaceGroupID := fmt.Sprintf("%d", utils.DefaultKeyspaceGroupID)
+	testutil.Eventually(re, func() bool {
+		args := []string{"-u", pdAddr, "keyspace-group", "set-node", defaultKeyspaceGroupID, s1.GetAddr(), s2.GetAddr()}
+		output, err := pdctl.ExecuteCommand(cmd, args...)
+		re.NoError(err)
+		return strings.Contains(string(output), "Success")
+	})
+
+	// set-priority keyspace group.
+	checkPriority := func(p int) {
+		testutil.Eventually(re, func() bool {
+			args := []string{"-u", pdAddr, "keyspace-group", "set-priority", defaultKeyspaceGroupID, s1.GetAddr()}
+			if p >= 0 {
+				args = append(args, strconv.Itoa(p))
+			} else {
+				args = append(args, "--", strconv.Itoa(p))
+			}
+			output, err := pdctl.ExecuteCommand(cmd, args...)
+			re.NoError(err)
+			return strings.Contains(string(output), "Success")
+		})
+
+		// check keyspace group information.
+		args := []string{"-u", pdAddr, "keyspace-group"}
+		output, err := pdctl.ExecuteCommand(cmd, append(args, defaultKeyspaceGroupID)...)
+		re.NoError(err)
+		var keyspaceGroup endpoint.KeyspaceGroup
+		err = json.Unmarshal(output, &keyspaceGroup)
+		re.NoError(err)
+		re.Equal(utils.DefaultKeyspaceGroupID, keyspaceGroup.ID)
+		re.Len(keyspaceGroup.Members, 2)
+		for _, member := range keyspaceGroup.Members {
+			re.Contains([]string{s1.GetAddr(), s2.GetAddr()}, member.Address)
+			if member.Address == s1.GetAddr() {
+				re.Equal(p, member.Priority)
+			} else {
+				re.Equal(0, member.Priority)
+			}
+		}
+	}
+
+	checkPriority(200)
+	checkPriority(-200)
+
+	// params error for set-node.
+	args := []string{"-u", pdAddr, "keyspace-group", "set-node", defaultKeyspaceGroupID, s1.GetAddr()}
+	output, err := pdctl.ExecuteCommand(cmd, args...)
+	re.NoError(err)
+	re.Contains(string(output), "invalid num of nodes")
+	args = []string{"-u", pdAddr, "keyspace-group", "set-node", defaultKeyspaceGroupID, "", ""}
+	output, err = pdctl.ExecuteCommand(cmd, args...)
+	re.NoError(err)
+	re.Contains(string(output), "Failed to parse the tso node address")
+	args = []string{"-u", pdAddr, "keyspace-group", "set-node", defaultKeyspaceGroupID, s1.GetAddr(), "http://pingcap.com"}
+	output, err = pdctl.ExecuteCommand(cmd, args...)
+	re.NoError(err)
+	re.Contains(string(output), "node does not exist")
+
+	// params error for set-priority.
+	args = []string{"-u", pdAddr, "keyspace-group", "set-priority", defaultKeyspaceGroupID, "", "200"}
+	output, err = pdctl.ExecuteCommand(cmd, args...)
+	re.NoError(err)
+	re.Contains(string(output), "Failed to parse the tso node address")
+	args = []string{"-u", pdAddr, "keyspace-group", "set-priority", defaultKeyspaceGroupID, "http://pingcap.com", "200"}
+	output, err = pdctl.ExecuteCommand(cmd, args...)
+	re.NoError(err)
+	re.Contains(string(output), "node does not exist")
+	args = []string{"-u", pdAddr, "keyspace-group", "set-priority", defaultKeyspaceGroupID, s1.GetAddr(), "xxx"}
+	output, err = pdctl.ExecuteCommand(cmd, args...)
+	re.NoError(err)
+	re.Contains(string(output), "Failed to parse the priority")
+}
+
+func TestReallocateKeyspaceGroups(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tc, err := tests.NewTestAPICluster(ctx, 1)
+	re.NoError(err)
+	err = tc.RunInitialServers()
+	re.NoError(err)
+	pdAddr := tc.GetConfig().GetClientURL()
+
+	s1, tsoServerCleanup1, err := tests.StartSingleTSOTestServer(ctx, re, pdAddr, tempurl.Alloc())
+	defer tsoServerCleanup1()
+	re.NoError(err)
+	_, tsoServerCleanup2, err := tests.StartSingleTSOTestServer(ctx, re, pdAddr, tempurl.Alloc())
+	defer tsoServerCleanup2()
+	re.NoError(err)
+	cmd := pdctlCmd.GetRootCmd()
+
+	tc.WaitLeader()
+	leaderServer := tc.GetServer(tc.GetLeader())
+	re.NoError(leaderServer.BootstrapCluster())
+	// Wait for the default keyspace group to be allocated with nodes, so the background allocation stops.
+	testutil.Eventually(re, func() bool {
+		kg := handlersutil.MustLoadKeyspaceGroupByID(re, leaderServer, utils.DefaultKeyspaceGroupID)
+		return len(kg.Members) == utils.DefaultKeyspaceGroupReplicaCount
+	})
+
+	// Create the under-replicated keyspace groups.
+	handlersutil.MustCreateKeyspaceGroup(re, leaderServer, &handlers.CreateKeyspaceGroupParams{
+		KeyspaceGroups: []*endpoint.KeyspaceGroup{
+			{
+				ID:        1,
+				UserKind:  endpoint.Standard.String(),
+				Members:   []endpoint.KeyspaceGroupMember{{Address: s1.GetAddr(), Priority: utils.DefaultKeyspaceGroupReplicaPriority}},
+				Keyspaces: []uint32{111},
+			},
+			{
+				ID:        2,
+				UserKind:  endpoint.Standard.String(),
+				Members:   []endpoint.KeyspaceGroupMember{{Address: s1.GetAddr(), Priority: utils.DefaultKeyspaceGroupReplicaPriority}},
+				Keyspaces: []uint32{222},
+			},
+		},
+	})
+
+	type reallocation struct {
+		ID           uint32                         `json:"id"`
+		Members      []endpoint.KeyspaceGroupMember `json:"members"`
+		AddedMembers []endpoint.KeyspaceGroupMember `json:"added-members"`
+	}
+	reallocate := func(dryRun bool) []reallocation {
+		// Always set the flag explicitly since the flags of the root command are kept between the executions.
+		args := []string{"-u", pdAddr, "keyspace-group", "reallocate", fmt.Sprintf("--dry-run=%t", dryRun)}
+		output, err := pdctl.ExecuteCommand(cmd, args...)
+		re.NoError(err)
+		if dryRun {
+			// Skip the hint line.
+			re.Contains(string(output), "run without --dry-run to apply")
+			output = output[strings.Index(string(output), "\n")+1:]
+		}
+		var reallocations []reallocation
+		re.NoError(json.Unmarshal(output, &reallocations), string(output))
+		return reallocations
+	}
+
+	// The dry-run only reports the under-replicated keyspace groups.
+	reallocations := reallocate(true)
+	re.Len(reallocations, 2)
+	for i, r := range reallocations {
+		re.Equal(uint32(i+1), r.ID)
+		re.Len(r.Members, 1)
+		re.Empty(r.AddedMembers)
+		re.Len(handlersutil.MustLoadKeyspaceGroupByID(re, leaderServer, r.ID).Members, 1)
+	}
+
+	// Add a new node and reallocate.
+	s3, tsoServerCleanup3, err := tests.StartSingleTSOTestServer(ctx, re, pdAddr, tempurl.Alloc())
+	defer tsoServerCleanup3()
+	re.NoError(err)
+	testutil.Eventually(re, func() bool {
+		return leaderServer.GetServer().GetKeyspaceGroupManager().IsExistNode(s3.GetAddr())
+	})
+	reallocations = reallocate(false)
+	re.Len(reallocations, 2)
+	for i, r := range reallocations {
+		re.Equal(uint32(i+1), r.ID)
+		re.Len(r.AddedMembers, utils.DefaultKeyspaceGroupReplicaCount-1)
+		kg := handlersutil.MustLoadKeyspaceGroupByID(re, leaderServer, r.ID)
+		re.Len(kg.Members, utils.DefaultKeyspaceGroupReplicaCount)
+		re.Contains(kg.Members, endpoint.KeyspaceGroupMember{Address: s1.GetAddr(), Priority: utils.DefaultKeyspaceGroupReplicaPriority})
+		re.Contains(kg.Members, r.AddedMembers[0])
+	}
+	// Nothing to reallocate after all the keyspace groups reach the replica count.
+	re.Empty(reallocate(false))
+}
+
+func TestMergeKeyspaceGroup(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	re.NoError(failpoint.Enable("github.com/tikv/pd/pkg/keyspace/acceleratedAllocNodes", `return(true)`))
+	re.NoError(failpoint.Enable("github.com/tikv/pd/server/delayStartServerLoop", `return(true)`))
+	keyspaces := make([]string, 0)
+	// we test the case which exceed the default max txn ops limit in etcd, which is 128.
+	for i := 0; i < 129; i++ {
+		keyspaces = append(keyspaces, fmt.Sprintf("keyspace_%d", i))
+	}
+	tc, err := tests.NewTestAPICluster(ctx, 1, func(conf *config.Config, serverName string) {
+		conf.Keyspace.PreAlloc = keyspaces
+	})
+	re.NoError(err)
+	err = tc.RunInitialServers()
+	re.NoError(err)
+	pdAddr := tc.GetConfig().GetClientURL()
+
+	_, tsoServerCleanup1, err := tests.StartSingleTSOTestServer(ctx, re, pdAddr, tempurl.Alloc())
+	defer tsoServerCleanup1()
+	re.NoError(err)
+	_, tsoServerCleanup2, err := tests.StartSingleTSOTestServer(ctx, re, pdAddr, tempurl.Alloc())
+	defer tsoServerCleanup2()
+	re.NoError(err)
+	cmd := pdctlCmd.GetRootCmd()
+
+	tc.WaitLeader()
+	leaderServer := tc.GetServer(tc.GetLeader())
+	re.NoError(leaderServer.BootstrapCluster())
+
+	// split keyspace group.
+	testutil.Eventually(re, func() bool {
+		args := []string{"-u", pdAddr, "keyspace-group", "split", "0", "1", "2"}
+		output, err := pdctl.ExecuteCommand(cmd, args...)
+		re.NoError(err)
+		return strings.Contains(string(output), "Success")
+	})
+
+	args := []string{"-u", pdAddr, "keyspace-group", "finish-split", "1"}
+	output, err := pdctl.ExecuteCommand(cmd, args...)
+	re.NoError(err)
+	strings.Contains(string(output), "Success")
+
+	// merge keyspace group.
+	testutil.Eventually(re, func() bool {
+		args := []string{"-u", pdAddr, "keyspace-group", "merge", "0", "1"}
+		output, err := pdctl.ExecuteCommand(cmd, args...)
+		re.NoError(err)
+		return strings.Contains(string(output), "Success")
+	})
+
+	args = []string{"-u", pdAddr, "keyspace-group", "finish-merge", "0"}
+	output, err = pdctl.ExecuteCommand(cmd, args...)
+	re.NoError(err)
+	strings.Contains(string(output), "Success")
+	args = []string{"-u", pdAddr, "keyspace-group", "0"}
+	output, err = pdctl.ExecuteCommand(cmd, args...)
+	re.NoError(err)
+	var keyspaceGroup endpoint.KeyspaceGroup
+	err = json.Unmarshal(output, &keyspaceGroup)
+	re.NoError(err)
+	re.Len(keyspaceGroup.Keyspaces, 130)
+	re.Nil(keyspaceGroup.MergeState)
+
+	re.NoError(failpoint.Disable("github.com/tikv/pd/pkg/keyspace/acceleratedAllocNodes"))
+	re.NoError(failpoint.Disable("github.com/tikv/pd/server/delayStartServerLoop"))
+}
+
+func TestSplitAndMergeKeyspaceGroupDryRun(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	re.NoError(failpoint.Enable("github.com/tikv/pd/pkg/keyspace/acceleratedAllocNodes", `return(true)`))
+	re.NoError(failpoint.Enable("github.com/tikv/pd/server/delayStartServerLoop", `return(true)`))
+	keyspaces := make([]string, 0)
+	for i := 0; i < 10; i++ {
 		keyspaces = append(keyspaces, fmt.Sprintf("keyspace_%d", i))
 	}
-	tc, err := tests.NewTestAPICluster(ctx, 3, func(conf *config.Config, serverName string) {
+	tc, err := tests.NewTestAPICluster(ctx, 1, func(conf *config.Config, serverName string) {
 		conf.Keyspace.PreAlloc = keyspaces
 	})
 	re.NoError(err)
@@ -120,83 +1169,350 @@ func TestSplitKeyspaceGroup(t *testing.T) {
 	leaderServer := tc.GetServer(tc.GetLeader())
 	re.NoError(leaderServer.BootstrapCluster())
 
-	// split keyspace group.
+	getKeyspaceGroups := func() []*endpoint.KeyspaceGroup {
+		output, err := pdctl.ExecuteCommand(cmd, "-u", pdAddr, "keyspace-group")
+		re.NoError(err)
+		var kgs []*endpoint.KeyspaceGroup
+		re.NoError(json.Unmarshal(output, &kgs))
+		return kgs
+	}
+	parsePreview := func(output []byte) *keyspace.KeyspaceGroupOperationPreview {
+		re.Contains(string(output), "remove --dry-run to apply")
+		var preview keyspace.KeyspaceGroupOperationPreview
+		re.NoError(json.Unmarshal(output[strings.Index(string(output), "\n")+1:], &preview))
+		return &preview
+	}
+
+	// Preview the split of the keyspace group.
+	var output []byte
 	testutil.Eventually(re, func() bool {
-		args := []string{"-u", pdAddr, "keyspace-group", "split", "0", "1", "2"}
-		output, err := pdctl.ExecuteCommand(cmd, args...)
+		args := []string{"-u", pdAddr, "keyspace-group", "split", "0", "1", "2", "3", "--dry-run=true"}
+		output, err = pdctl.ExecuteCommand(cmd, args...)
 		re.NoError(err)
-		return strings.Contains(string(output), "Success")
+		return strings.Contains(string(output), "remove --dry-run to apply")
 	})
+	preview := parsePreview(output)
+	re.Len(preview.KeyspaceGroups, 2)
+	re.Equal(uint32(0), preview.KeyspaceGroups[0].ID)
+	re.NotContains(preview.KeyspaceGroups[0].Keyspaces, uint32(2))
+	re.True(preview.KeyspaceGroups[0].IsSplitSource())
+	re.Equal(uint32(1), preview.KeyspaceGroups[1].ID)
+	re.Equal([]uint32{2, 3}, preview.KeyspaceGroups[1].Keyspaces)
+	re.True(preview.KeyspaceGroups[1].IsSplitTarget())
+	re.Len(preview.KeyspaceGroups[1].Members, utils.DefaultKeyspaceGroupReplicaCount)
+	re.Equal(4, preview.EtcdTxnOps)
+	re.Positive(preview.MaxEtcdTxnOps)
+	// Nothing is changed by the dry run.
+	kgs := getKeyspaceGroups()
+	re.Len(kgs, 1)
+	re.Len(kgs[0].Keyspaces, len(keyspaces)+1)
+	re.False(kgs[0].IsSplitting())
+	// Preview the split range.
+	output, err = pdctl.ExecuteCommand(cmd, "-u", pdAddr, "keyspace-group", "split-range", "0", "1", "4", "6", "--dry-run=true")
+	re.NoError(err)
+	preview = parsePreview(output)
+	re.Equal([]uint32{4, 5, 6}, preview.KeyspaceGroups[1].Keyspaces)
+	re.Len(getKeyspaceGroups(), 1)
+	// Preview the invalid split.
+	output, err = pdctl.ExecuteCommand(cmd, "-u", pdAddr, "keyspace-group", "split", "0", "1", "100", "--dry-run=true")
+	re.NoError(err)
+	re.Contains(string(output), "Failed to preview the split")
 
-	// get all keyspaces
-	args := []string{"-u", pdAddr, "keyspace-group"}
-	output, err := pdctl.ExecuteCommand(cmd, args...)
+	// Split the keyspace group for real.
+	output, err = pdctl.ExecuteCommand(cmd, "-u", pdAddr, "keyspace-group", "split", "0", "1", "2", "3", "--dry-run=false")
 	re.NoError(err)
-	strings.Contains(string(output), "Success")
-	var keyspaceGroups []*endpoint.KeyspaceGroup
-	err = json.Unmarshal(output, &keyspaceGroups)
+	re.Contains(string(output), "Success")
+	output, err = pdctl.ExecuteCommand(cmd, "-u", pdAddr, "keyspace-group", "finish-split", "1")
 	re.NoError(err)
-	re.Len(keyspaceGroups, 2)
-	re.Equal(keyspaceGroups[0].ID, uint32(0))
-	re.Equal(keyspaceGroups[1].ID, uint32(1))
+	re.Contains(string(output), "Success")
+
+	// Preview the merge of the keyspace group.
+	output, err = pdctl.ExecuteCommand(cmd, "-u", pdAddr, "keyspace-group", "merge", "0", "1", "--dry-run=true")
+	re.NoError(err)
+	preview = parsePreview(output)
+	re.Len(preview.KeyspaceGroups, 1)
+	re.Equal(uint32(0), preview.KeyspaceGroups[0].ID)
+	re.Len(preview.KeyspaceGroups[0].Keyspaces, len(keyspaces)+1)
+	re.True(preview.KeyspaceGroups[0].IsMergeTarget())
+	re.Equal([]uint32{1}, preview.DeletedKeyspaceGroups)
+	re.Equal(4, preview.EtcdTxnOps)
+	// Nothing is changed by the dry run.
+	kgs = getKeyspaceGroups()
+	re.Len(kgs, 2)
+	re.False(kgs[0].IsMerging())
+	re.Equal([]uint32{2, 3}, kgs[1].Keyspaces)
 
 	re.NoError(failpoint.Disable("github.com/tikv/pd/pkg/keyspace/acceleratedAllocNodes"))
 	re.NoError(failpoint.Disable("github.com/tikv/pd/server/delayStartServerLoop"))
 }
 
-func TestExternalAllocNodeWhenStart(t *testing.T) {
+func TestRebalanceKeyspaceGroups(t *testing.T) {
 	re := require.New(t)
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
-	// external alloc node for keyspace group, when keyspace manager update keyspace info to keyspace group
-	// we hope the keyspace group can be updated correctly.
-	re.NoError(failpoint.Enable("github.com/tikv/pd/pkg/keyspace/externalAllocNode", `return("127.0.0.1:2379,127.0.0.1:2380")`))
-	re.NoError(failpoint.Enable("github.com/tikv/pd/pkg/keyspace/acceleratedAllocNodes", `return(true)`))
-	re.NoError(failpoint.Enable("github.com/tikv/pd/server/delayStartServerLoop", `return(true)`))
-	keyspaces := make([]string, 0)
-	for i := 0; i < 10; i++ {
-		keyspaces = append(keyspaces, fmt.Sprintf("keyspace_%d", i))
-	}
-	tc, err := tests.NewTestAPICluster(ctx, 1, func(conf *config.Config, serverName string) {
-		conf.Keyspace.PreAlloc = keyspaces
-	})
+	tc, err := tests.NewTestAPICluster(ctx, 1)
 	re.NoError(err)
 	err = tc.RunInitialServers()
 	re.NoError(err)
+	tc.WaitLeader()
+	leaderServer := tc.GetServer(tc.GetLeader())
+	re.NoError(leaderServer.BootstrapCluster())
 	pdAddr := tc.GetConfig().GetClientURL()
-
 	cmd := pdctlCmd.GetRootCmd()
 
+	// Pre-allocate the skewed keyspaces.
+	rangeKeyspaces := func(start, end uint32) []uint32 {
+		keyspaces := make([]uint32, 0, end-start+1)
+		for i := start; i <= end; i++ {
+			keyspaces = append(keyspaces, i)
+		}
+		return keyspaces
+	}
+	members := []endpoint.KeyspaceGroupMember{
+		{Address: "http://127.0.0.1:3379", Priority: 10},
+		{Address: "http://127.0.0.1:3380", Priority: 0},
+	}
+	handlersutil.MustCreateKeyspaceGroup(re, leaderServer, &handlers.CreateKeyspaceGroupParams{
+		KeyspaceGroups: []*endpoint.KeyspaceGroup{
+			{ID: 1, UserKind: endpoint.Standard.String(), Members: members, Keyspaces: rangeKeyspaces(1, 25)},
+			{ID: 2, UserKind: endpoint.Standard.String(), Members: members, Keyspaces: rangeKeyspaces(26, 35)},
+			{ID: 3, UserKind: endpoint.Standard.String(), Members: members, Keyspaces: rangeKeyspaces(36, 36)},
+			{ID: 4, UserKind: endpoint.Standard.String(), Members: members},
+		},
+	})
+	getKeyspaceGroups := func() map[uint32]*endpoint.KeyspaceGroup {
+		output, err := pdctl.ExecuteCommand(cmd, "-u", pdAddr, "keyspace-group")
+		re.NoError(err)
+		var kgs []*endpoint.KeyspaceGroup
+		re.NoError(json.Unmarshal(output, &kgs))
+		kgsByID := make(map[uint32]*endpoint.KeyspaceGroup, len(kgs))
+		for _, kg := range kgs {
+			kgsByID[kg.ID] = kg
+		}
+		return kgsByID
+	}
+	const (
+		targetSize = 9
+		tolerance  = 1
+	)
+	targetSizeFlag := fmt.Sprintf("--target-size=%d", targetSize)
+
+	// Show the plan with --dry-run.
+	args := []string{"-u", pdAddr, "keyspace-group", "rebalance", targetSizeFlag, "--dry-run=true"}
+	output, err := pdctl.ExecuteCommand(cmd, args...)
+	re.NoError(err)
+	re.Contains(string(output), "remove --dry-run to apply")
+	var plan []struct {
+		Source    uint32   `json:"source"`
+		Target    uint32   `json:"target"`
+		Keyspaces []uint32 `json:"keyspaces"`
+	}
+	re.NoError(json.Unmarshal(output[strings.Index(string(output), "\n")+1:], &plan))
+	re.Len(plan, 3)
+	moved := make(map[uint32]int)
+	for _, step := range plan {
+		re.Contains([]uint32{1, 2}, step.Source)
+		re.Contains([]uint32{3, 4}, step.Target)
+		moved[step.Source] -= len(step.Keyspaces)
+		moved[step.Target] += len(step.Keyspaces)
+	}
+	re.Equal(map[uint32]int{1: -16, 2: -1, 3: 8, 4: 9}, moved)
+	// Nothing is changed by the dry run.
+	kgs := getKeyspaceGroups()
+	re.Len(kgs[1].Keyspaces, 25)
+	re.Empty(kgs[4].Keyspaces)
+
+	// Simulate the TSO nodes to finish the split and merge.
+	manager := leaderServer.GetServer().GetKeyspaceGroupManager()
+	go func() {
+		ticker := time.NewTicker(50 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			kgs, err := manager.GetKeyspaceGroups(utils.DefaultKeyspaceGroupID, 0)
+			if err != nil {
+				continue
+			}
+			for _, kg := range kgs {
+				if kg.IsSplitTarget() {
+					_ = manager.FinishSplitKeyspaceByID(kg.ID)
+				}
+				if kg.IsMergeTarget() {
+					_ = manager.FinishMergeKeyspaceByID(kg.ID)
+				}
+			}
+		}
+	}()
+
+	// Rebalance the keyspace groups.
+	args = []string{"-u", pdAddr, "keyspace-group", "rebalance", targetSizeFlag, "--dry-run=false", "--timeout=10s"}
+	output, err = pdctl.ExecuteCommand(cmd, args...)
+	re.NoError(err)
+	re.NotContains(string(output), "Failed")
+	re.Contains(string(output), "Moved 8 keyspaces from keyspace group 1 to keyspace group 4")
+	header := "The keyspace groups after rebalancing are:\n"
+	re.Contains(string(output), header)
+	var finalKgs []*endpoint.KeyspaceGroup
+	re.NoError(json.Unmarshal(output[strings.Index(string(output), header)+len(header):], &finalKgs))
+	// The temporary keyspace groups are merged and the keyspaces are preserved.
+	re.Len(finalKgs, 5)
+	kgs = getKeyspaceGroups()
+	re.Len(kgs, 5)
+	allKeyspaces := make([]uint32, 0)
+	for id := uint32(1); id <= 4; id++ {
+		kg := kgs[id]
+		re.NotNil(kg)
+		re.False(kg.IsSplitting())
+		re.False(kg.IsMerging())
+		re.InDelta(targetSize, len(kg.Keyspaces), tolerance)
+		allKeyspaces = append(allKeyspaces, kg.Keyspaces...)
+	}
+	sort.Slice(allKeyspaces, func(i, j int) bool { return allKeyspaces[i] < allKeyspaces[j] })
+	re.Equal(rangeKeyspaces(1, 36), allKeyspaces)
+
+	// The balanced keyspace groups need no more steps.
+	output, err = pdctl.ExecuteCommand(cmd, args...)
+	re.NoError(err)
+	re.Contains(string(output), "The rebalance plan is:\n[]")
+	// The target size is required.
+	output, err = pdctl.ExecuteCommand(cmd, "-u", pdAddr, "keyspace-group", "rebalance", "--target-size=0")
+	re.NoError(err)
+	re.Contains(string(output), "the target size should be greater than 0")
+}
+
+func TestRebalanceKeyspaceGroupsInBatches(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tc, err := tests.NewTestAPICluster(ctx, 1)
+	re.NoError(err)
+	err = tc.RunInitialServers()
+	re.NoError(err)
 	tc.WaitLeader()
 	leaderServer := tc.GetServer(tc.GetLeader())
 	re.NoError(leaderServer.BootstrapCluster())
+	pdAddr := tc.GetConfig().GetClientURL()
+	cmd := pdctlCmd.GetRootCmd()
 
-	// check keyspace group information.
-	defaultKeyspaceGroupID := fmt.Sprintf("%d", utils.DefaultKeyspaceGroupID)
-	args := []string{"-u", pdAddr, "keyspace-group"}
-	testutil.Eventually(re, func() bool {
-		output, err := pdctl.ExecuteCommand(cmd, append(args, defaultKeyspaceGroupID)...)
+	// Each donor moves one keyspace to the same receiver, so the plan needs more than one merge.
+	const (
+		targetSize = keyspace.MaxMergeListSize + 1
+		donorCount = targetSize
+		receiverID = donorCount + 1
+		tempID     = receiverID + 1
+	)
+	members := []endpoint.KeyspaceGroupMember{
+		{Address: "http://127.0.0.1:3379", Priority: 10},
+		{Address: "http://127.0.0.1:3380", Priority: 0},
+	}
+	kgs := make([]*endpoint.KeyspaceGroup, 0, donorCount+1)
+	allKeyspaces := make([]uint32, 0, donorCount*(targetSize+1))
+	for id := uint32(1); id <= donorCount; id++ {
+		keyspaces := make([]uint32, 0, targetSize+1)
+		for i := uint32(0); i < targetSize+1; i++ {
+			keyspaces = append(keyspaces, (id-1)*(targetSize+1)+i+1)
+		}
+		allKeyspaces = append(allKeyspaces, keyspaces...)
+		kgs = append(kgs, &endpoint.KeyspaceGroup{
+			ID: id, UserKind: endpoint.Standard.String(), Members: members, Keyspaces: keyspaces,
+		})
+	}
+	kgs = append(kgs, &endpoint.KeyspaceGroup{ID: receiverID, UserKind: endpoint.Standard.String(), Members: members})
+	handlersutil.MustCreateKeyspaceGroup(re, leaderServer, &handlers.CreateKeyspaceGroupParams{KeyspaceGroups: kgs})
+	getKeyspaceGroups := func() map[uint32]*endpoint.KeyspaceGroup {
+		output, err := pdctl.ExecuteCommand(cmd, "-u", pdAddr, "keyspace-group")
 		re.NoError(err)
-		var keyspaceGroup endpoint.KeyspaceGroup
-		err = json.Unmarshal(output, &keyspaceGroup)
+		var kgs []*endpoint.KeyspaceGroup
+		re.NoError(json.Unmarshal(output, &kgs))
+		kgsByID := make(map[uint32]*endpoint.KeyspaceGroup, len(kgs))
+		for _, kg := range kgs {
+			kgsByID[kg.ID] = kg
+		}
+		return kgsByID
+	}
+	targetSizeFlag := fmt.Sprintf("--target-size=%d", targetSize)
+
+	// The split is not finished without the TSO nodes, the temporary keyspace group is left behind.
+	args := []string{"-u", pdAddr, "keyspace-group", "rebalance", targetSizeFlag, "--dry-run=false", "--timeout=100ms"}
+	output, err := pdctl.ExecuteCommand(cmd, args...)
+	re.NoError(err)
+	re.Contains(string(output), "Failed to rebalance the keyspace groups")
+	re.Contains(string(output), fmt.Sprintf("The temporary keyspace group %d split from keyspace group 1 is left behind, "+
+		"merge it back by `keyspace-group merge 1 %d`", tempID, tempID))
+
+	// Simulate the TSO nodes to finish the split and merge.
+	manager := leaderServer.GetServer().GetKeyspaceGroupManager()
+	go func() {
+		ticker := time.NewTicker(50 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			kgs, err := manager.GetKeyspaceGroups(utils.DefaultKeyspaceGroupID, 0)
+			if err != nil {
+				continue
+			}
+			for _, kg := range kgs {
+				if kg.IsSplitTarget() {
+					_ = manager.FinishSplitKeyspaceByID(kg.ID)
+				}
+				if kg.IsMergeTarget() {
+					_ = manager.FinishMergeKeyspaceByID(kg.ID)
+				}
+			}
+		}
+	}()
+	// Merge the temporary keyspace group back as suggested.
+	testutil.Eventually(re, func() bool {
+		output, err := pdctl.ExecuteCommand(cmd, "-u", pdAddr, "keyspace-group", "merge", "1", strconv.Itoa(tempID))
 		re.NoError(err)
-		return len(keyspaceGroup.Keyspaces) == len(keyspaces)+1 && len(keyspaceGroup.Members) == 2
+		return strings.Contains(string(output), "Success")
+	})
+	testutil.Eventually(re, func() bool {
+		kgs := getKeyspaceGroups()
+		return kgs[tempID] == nil && !kgs[1].IsMerging() && len(kgs[1].Keyspaces) == targetSize+1
 	})
 
-	re.NoError(failpoint.Disable("github.com/tikv/pd/pkg/keyspace/externalAllocNode"))
-	re.NoError(failpoint.Disable("github.com/tikv/pd/pkg/keyspace/acceleratedAllocNodes"))
-	re.NoError(failpoint.Disable("github.com/tikv/pd/server/delayStartServerLoop"))
+	// Rebalance the keyspace groups with more steps than a merge could include.
+	args = []string{"-u", pdAddr, "keyspace-group", "rebalance", targetSizeFlag, "--dry-run=false", "--timeout=10s"}
+	output, err = pdctl.ExecuteCommand(cmd, args...)
+	re.NoError(err)
+	re.NotContains(string(output), "Failed")
+	re.Equal(donorCount, strings.Count(string(output), "keyspaces from keyspace group"))
+	re.Contains(string(output), fmt.Sprintf("Moved 1 keyspaces from keyspace group %d to keyspace group %d", donorCount, receiverID))
+	rebalanced := getKeyspaceGroups()
+	re.Len(rebalanced, donorCount+2)
+	keyspaces := make([]uint32, 0, len(allKeyspaces))
+	for id := uint32(1); id <= receiverID; id++ {
+		kg := rebalanced[id]
+		re.NotNil(kg)
+		re.False(kg.IsSplitting())
+		re.False(kg.IsMerging())
+		re.Len(kg.Keyspaces, targetSize)
+		keyspaces = append(keyspaces, kg.Keyspaces...)
+	}
+	sort.Slice(keyspaces, func(i, j int) bool { return keyspaces[i] < keyspaces[j] })
+	re.Equal(allKeyspaces, keyspaces)
 }
 
-func TestSetNodeAndPriorityKeyspaceGroup(t *testing.T) {
+func TestShowMultipleKeyspaceGroups(t *testing.T) {
 	re := require.New(t)
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
+	re.NoError(failpoint.Enable("github.com/tikv/pd/pkg/keyspace/acceleratedAllocNodes", `return(true)`))
+	re.NoError(failpoint.Enable("github.com/tikv/pd/server/delayStartServerLoop", `return(true)`))
 	keyspaces := make([]string, 0)
 	for i := 0; i < 10; i++ {
 		keyspaces = append(keyspaces, fmt.Sprintf("keyspace_%d", i))
 	}
-	tc, err := tests.NewTestAPICluster(ctx, 3, func(conf *config.Config, serverName string) {
+	tc, err := tests.NewTestAPICluster(ctx, 1, func(conf *config.Config, serverName string) {
 		conf.Keyspace.PreAlloc = keyspaces
 	})
 	re.NoError(err)
@@ -204,10 +1520,10 @@ func TestSetNodeAndPriorityKeyspaceGroup(t *testing.T) {
 	re.NoError(err)
 	pdAddr := tc.GetConfig().GetClientURL()
 
-	s1, tsoServerCleanup1, err := tests.StartSingleTSOTestServer(ctx, re, pdAddr, tempurl.Alloc())
+	_, tsoServerCleanup1, err := tests.StartSingleTSOTestServer(ctx, re, pdAddr, tempurl.Alloc())
 	defer tsoServerCleanup1()
 	re.NoError(err)
-	s2, tsoServerCleanup2, err := tests.StartSingleTSOTestServer(ctx, re, pdAddr, tempurl.Alloc())
+	_, tsoServerCleanup2, err := tests.StartSingleTSOTestServer(ctx, re, pdAddr, tempurl.Alloc())
 	defer tsoServerCleanup2()
 	re.NoError(err)
 	cmd := pdctlCmd.GetRootCmd()
@@ -216,89 +1532,52 @@ func TestSetNodeAndPriorityKeyspaceGroup(t *testing.T) {
 	leaderServer := tc.GetServer(tc.GetLeader())
 	re.NoError(leaderServer.BootstrapCluster())
 
-	// set-node keyspace group.
-	defaultKeyspaceGroupID := fmt.Sprintf("%d", utils.DefaultKeyspaceGroupID)
 	testutil.Eventually(re, func() bool {
-		args := []string{"-u", pdAddr, "keyspace-group", "set-node", defaultKeyspaceGroupID, s1.GetAddr(), s2.GetAddr()}
-		output, err := pdctl.ExecuteCommand(cmd, args...)
+		output, err := pdctl.ExecuteCommand(cmd, "-u", pdAddr, "keyspace-group", "split", "0", "1", "2", "3")
 		re.NoError(err)
 		return strings.Contains(string(output), "Success")
 	})
+	output, err := pdctl.ExecuteCommand(cmd, "-u", pdAddr, "keyspace-group", "finish-split", "1")
+	re.NoError(err)
+	re.Contains(string(output), "Success")
 
-	// set-priority keyspace group.
-	checkPriority := func(p int) {
-		testutil.Eventually(re, func() bool {
-			args := []string{"-u", pdAddr, "keyspace-group", "set-priority", defaultKeyspaceGroupID, s1.GetAddr()}
-			if p >= 0 {
-				args = append(args, strconv.Itoa(p))
-			} else {
-				args = append(args, "--", strconv.Itoa(p))
-			}
-			output, err := pdctl.ExecuteCommand(cmd, args...)
-			re.NoError(err)
-			return strings.Contains(string(output), "Success")
-		})
-
-		// check keyspace group information.
-		args := []string{"-u", pdAddr, "keyspace-group"}
-		output, err := pdctl.ExecuteCommand(cmd, append(args, defaultKeyspaceGroupID)...)
-		re.NoError(err)
-		var keyspaceGroup endpoint.KeyspaceGroup
-		err = json.Unmarshal(output, &keyspaceGroup)
-		re.NoError(err)
-		re.Equal(utils.DefaultKeyspaceGroupID, keyspaceGroup.ID)
-		re.Len(keyspaceGroup.Members, 2)
-		for _, member := range keyspaceGroup.Members {
-			re.Contains([]string{s1.GetAddr(), s2.GetAddr()}, member.Address)
-			if member.Address == s1.GetAddr() {
-				re.Equal(p, member.Priority)
-			} else {
-				re.Equal(0, member.Priority)
-			}
-		}
+	// Fetch the existing groups together with an unknown one.
+	output, err = pdctl.ExecuteCommand(cmd, "-u", pdAddr, "keyspace-group", "1", "9", "0")
+	re.NoError(err)
+	var results []struct {
+		ID        uint32   `json:"id"`
+		Keyspaces []uint32 `json:"keyspaces"`
+		Error     string   `json:"error"`
 	}
+	re.NoError(json.Unmarshal(output, &results), string(output))
+	re.Len(results, 3)
+	re.Equal(uint32(1), results[0].ID)
+	re.Equal([]uint32{2, 3}, results[0].Keyspaces)
+	re.Empty(results[0].Error)
+	re.Equal(uint32(9), results[1].ID)
+	re.Empty(results[1].Keyspaces)
+	re.Contains(results[1].Error, "does not exist")
+	re.Equal(uint32(0), results[2].ID)
+	re.NotContains(results[2].Keyspaces, uint32(2))
+	re.Empty(results[2].Error)
 
-	checkPriority(200)
-	checkPriority(-200)
-
-	// params error for set-node.
-	args := []string{"-u", pdAddr, "keyspace-group", "set-node", defaultKeyspaceGroupID, s1.GetAddr()}
-	output, err := pdctl.ExecuteCommand(cmd, args...)
-	re.NoError(err)
-	re.Contains(string(output), "invalid num of nodes")
-	args = []string{"-u", pdAddr, "keyspace-group", "set-node", defaultKeyspaceGroupID, "", ""}
-	output, err = pdctl.ExecuteCommand(cmd, args...)
-	re.NoError(err)
-	re.Contains(string(output), "Failed to parse the tso node address")
-	args = []string{"-u", pdAddr, "keyspace-group", "set-node", defaultKeyspaceGroupID, s1.GetAddr(), "http://pingcap.com"}
-	output, err = pdctl.ExecuteCommand(cmd, args...)
+	// An invalid ID fails the whole command.
+	output, err = pdctl.ExecuteCommand(cmd, "-u", pdAddr, "keyspace-group", "1", "a")
 	re.NoError(err)
-	re.Contains(string(output), "node does not exist")
+	re.Contains(string(output), "keyspace_group_id should be a number")
 
-	// params error for set-priority.
-	args = []string{"-u", pdAddr, "keyspace-group", "set-priority", defaultKeyspaceGroupID, "", "200"}
-	output, err = pdctl.ExecuteCommand(cmd, args...)
-	re.NoError(err)
-	re.Contains(string(output), "Failed to parse the tso node address")
-	args = []string{"-u", pdAddr, "keyspace-group", "set-priority", defaultKeyspaceGroupID, "http://pingcap.com", "200"}
-	output, err = pdctl.ExecuteCommand(cmd, args...)
-	re.NoError(err)
-	re.Contains(string(output), "node does not exist")
-	args = []string{"-u", pdAddr, "keyspace-group", "set-priority", defaultKeyspaceGroupID, s1.GetAddr(), "xxx"}
-	output, err = pdctl.ExecuteCommand(cmd, args...)
-	re.NoError(err)
-	re.Contains(string(output), "Failed to parse the priority")
+	re.NoError(failpoint.Disable("github.com/tikv/pd/pkg/keyspace/acceleratedAllocNodes"))
+	re.NoError(failpoint.Disable("github.com/tikv/pd/server/delayStartServerLoop"))
 }
 
-func TestMergeKeyspaceGroup(t *testing.T) {
+func TestKeyspaceGroupInTransition(t *testing.T) {
 	re := require.New(t)
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	re.NoError(failpoint.Enable("github.com/tikv/pd/pkg/keyspace/acceleratedAllocNodes", `return(true)`))
 	re.NoError(failpoint.Enable("github.com/tikv/pd/server/delayStartServerLoop", `return(true)`))
 	keyspaces := make([]string, 0)
-	// we test the case which exceed the default max txn ops limit in etcd, which is 128.
-	for i := 0; i < 129; i++ {
+	for i := 0; i < 10; i++ {
 		keyspaces = append(keyspaces, fmt.Sprintf("keyspace_%d", i))
 	}
 	tc, err := tests.NewTestAPICluster(ctx, 1, func(conf *config.Config, serverName string) {
@@ -321,39 +1600,57 @@ func TestMergeKeyspaceGroup(t *testing.T) {
 	leaderServer := tc.GetServer(tc.GetLeader())
 	re.NoError(leaderServer.BootstrapCluster())
 
-	// split keyspace group.
-	testutil.Eventually(re, func() bool {
-		args := []string{"-u", pdAddr, "keyspace-group", "split", "0", "1", "2"}
-		output, err := pdctl.ExecuteCommand(cmd, args...)
+	mustExecute := func(args ...string) {
+		testutil.Eventually(re, func() bool {
+			output, err := pdctl.ExecuteCommand(cmd, append([]string{"-u", pdAddr, "keyspace-group"}, args...)...)
+			re.NoError(err)
+			return strings.Contains(string(output), "Success")
+		})
+	}
+	type transition struct {
+		ID          uint32                          `json:"id"`
+		Phase       string                          `json:"phase"`
+		SplitSource *uint32                         `json:"split-source"`
+		MergeList   []uint32                        `json:"merge-list"`
+		Since       string                          `json:"since"`
+		Progress    *keyspace.KeyspaceGroupProgress `json:"progress"`
+	}
+	inTransition := func() []transition {
+		output, err := pdctl.ExecuteCommand(cmd, "-u", pdAddr, "keyspace-group", "in-transition")
 		re.NoError(err)
-		return strings.Contains(string(output), "Success")
-	})
+		var transitions []transition
+		re.NoError(json.Unmarshal(output, &transitions), string(output))
+		return transitions
+	}
+	re.Empty(inTransition())
 
-	args := []string{"-u", pdAddr, "keyspace-group", "finish-split", "1"}
-	output, err := pdctl.ExecuteCommand(cmd, args...)
-	re.NoError(err)
-	strings.Contains(string(output), "Success")
+	// Prepare the keyspace groups 1 and 2.
+	mustExecute("split", "0", "1", "2", "3")
+	mustExecute("finish-split", "1")
+	mustExecute("split", "0", "2", "4")
+	mustExecute("finish-split", "2")
+	// Start a merge and a split without finishing them.
+	mustExecute("merge", "0", "2")
+	mustExecute("split", "1", "3", "3")
 
-	// merge keyspace group.
-	testutil.Eventually(re, func() bool {
-		args := []string{"-u", pdAddr, "keyspace-group", "merge", "0", "1"}
-		output, err := pdctl.ExecuteCommand(cmd, args...)
+	transitions := inTransition()
+	re.Len(transitions, 3)
+	for _, tr := range transitions {
+		re.NotEmpty(tr.Since)
+		_, err := time.Parse(time.RFC3339, tr.Since)
 		re.NoError(err)
-		return strings.Contains(string(output), "Success")
-	})
-
-	args = []string{"-u", pdAddr, "keyspace-group", "finish-merge", "0"}
-	output, err = pdctl.ExecuteCommand(cmd, args...)
-	re.NoError(err)
-	strings.Contains(string(output), "Success")
-	args = []string{"-u", pdAddr, "keyspace-group", "0"}
-	output, err = pdctl.ExecuteCommand(cmd, args...)
-	re.NoError(err)
-	var keyspaceGroup endpoint.KeyspaceGroup
-	err = json.Unmarshal(output, &keyspaceGroup)
-	re.NoError(err)
-	re.Len(keyspaceGroup.Keyspaces, 130)
-	re.Nil(keyspaceGroup.MergeState)
+		re.NotEmpty(tr.Progress)
+	}
+	re.Equal(uint32(0), transitions[0].ID)
+	re.Equal("merge-target", transitions[0].Phase)
+	re.Equal([]uint32{2}, transitions[0].MergeList)
+	re.Nil(transitions[0].SplitSource)
+	re.Equal(uint32(1), transitions[1].ID)
+	re.Equal("split-source", transitions[1].Phase)
+	re.Equal(uint32(1), *transitions[1].SplitSource)
+	re.Equal(uint32(3), transitions[2].ID)
+	re.Equal("split-target", transitions[2].Phase)
+	re.Equal(uint32(1), *transitions[2].SplitSource)
 
 	re.NoError(failpoint.Disable("github.com/tikv/pd/pkg/keyspace/acceleratedAllocNodes"))
 	re.NoError(failpoint.Disable("github.com/tikv/pd/server/delayStartServerLoop"))
@@ -15,12 +15,21 @@
 package tso_test
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/pingcap/failpoint"
 	"github.com/stretchr/testify/require"
+	"github.com/tikv/pd/pkg/storage/endpoint"
+	"github.com/tikv/pd/pkg/utils/tempurl"
+	"github.com/tikv/pd/pkg/utils/testutil"
+	"github.com/tikv/pd/server/config"
+	"github.com/tikv/pd/tests"
 	"github.com/tikv/pd/tests/pdctl"
 	pdctlCmd "github.com/tikv/pd/tools/pd-ctl/pdctl"
 )
@@ -47,3 +56,106 @@ func TestTSO(t *testing.T) {
 	str := fmt.Sprintln("system: ", physicalTime) + fmt.Sprintln("logic:  ", logicalTime)
 	re.Equal(string(output), str)
 }
+
+func TestVerifyMonotonicAcrossPrimarySwitch(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cluster, err := tests.NewTestCluster(ctx, 3)
+	re.NoError(err)
+	defer cluster.Destroy()
+	re.NoError(cluster.RunInitialServers())
+	leader := cluster.WaitLeader()
+	re.NotEmpty(leader)
+	leaderServer := cluster.GetServer(leader)
+	re.NoError(leaderServer.BootstrapCluster())
+	pdAddr := leaderServer.GetAddr()
+	cmd := pdctlCmd.GetRootCmd()
+
+	// Always set the flag explicitly since the flags of the root command are kept between the executions.
+	args := []string{"-u", pdAddr, "tso", "verify-monotonic", "--switch=false"}
+	output, err := pdctl.ExecuteCommand(cmd, args...)
+	re.NoError(err)
+	re.Contains(string(output), "Success!")
+	re.Equal(leader, cluster.GetLeader())
+
+	args = []string{"-u", pdAddr, "tso", "verify-monotonic", "--switch=true"}
+	output, err = pdctl.ExecuteCommand(cmd, args...)
+	re.NoError(err)
+	re.Contains(string(output), "Success!", string(output))
+	newLeader := cluster.WaitLeader()
+	re.NotEmpty(newLeader)
+	re.NotEqual(leader, newLeader)
+	re.Contains(string(output), fmt.Sprintf("(primary: %s)", leader))
+	re.Contains(string(output), fmt.Sprintf("(primary: %s)", newLeader))
+}
+
+func TestVerifyMonotonicAcrossPrimarySwitchInAPIServiceMode(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	re.NoError(failpoint.Enable("github.com/tikv/pd/pkg/keyspace/acceleratedAllocNodes", `return(true)`))
+	re.NoError(failpoint.Enable("github.com/tikv/pd/pkg/tso/fastPrimaryPriorityCheck", `return(true)`))
+	defer func() {
+		re.NoError(failpoint.Disable("github.com/tikv/pd/pkg/keyspace/acceleratedAllocNodes"))
+		re.NoError(failpoint.Disable("github.com/tikv/pd/pkg/tso/fastPrimaryPriorityCheck"))
+	}()
+	cluster, err := tests.NewTestAPICluster(ctx, 1, func(conf *config.Config, serverName string) {
+		conf.Keyspace.PreAlloc = []string{"keyspace_a"}
+	})
+	re.NoError(err)
+	defer cluster.Destroy()
+	re.NoError(cluster.RunInitialServers())
+	re.NotEmpty(cluster.WaitLeader())
+	leaderServer := cluster.GetServer(cluster.GetLeader())
+	re.NoError(leaderServer.BootstrapCluster())
+	pdAddr := leaderServer.GetAddr()
+	_, tsoServerCleanup1, err := tests.StartSingleTSOTestServer(ctx, re, pdAddr, tempurl.Alloc())
+	defer tsoServerCleanup1()
+	re.NoError(err)
+	_, tsoServerCleanup2, err := tests.StartSingleTSOTestServer(ctx, re, pdAddr, tempurl.Alloc())
+	defer tsoServerCleanup2()
+	re.NoError(err)
+	cmd := pdctlCmd.GetRootCmd()
+
+	getPriorities := func() map[string]int {
+		output, err := pdctl.ExecuteCommand(cmd, "-u", pdAddr, "keyspace-group", "0")
+		re.NoError(err)
+		var kg endpoint.KeyspaceGroup
+		if json.Unmarshal(output, &kg) != nil {
+			return nil
+		}
+		priorities := make(map[string]int, len(kg.Members))
+		for _, member := range kg.Members {
+			priorities[member.Address] = member.Priority
+		}
+		return priorities
+	}
+	// Wait for both tso nodes to join the default keyspace group.
+	testutil.Eventually(re, func() bool {
+		return len(getPriorities()) == 2
+	})
+	priorities := getPriorities()
+
+	args := []string{"-u", pdAddr, "tso", "verify-monotonic", "--switch=false"}
+	testutil.Eventually(re, func() bool {
+		output, err := pdctl.ExecuteCommand(cmd, args...)
+		re.NoError(err)
+		return strings.Contains(string(output), "Success!")
+	})
+	leader := cluster.GetLeader()
+
+	// The primary of the default keyspace group is transferred instead of resigning the PD leader.
+	args = []string{"-u", pdAddr, "tso", "verify-monotonic", "--switch=true"}
+	output, err := pdctl.ExecuteCommand(cmd, args...)
+	re.NoError(err)
+	re.Contains(string(output), "Success!", string(output))
+	re.Equal(leader, cluster.GetLeader())
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	re.Len(lines, 3)
+	firstPrimary := lines[0][strings.Index(lines[0], "(primary: "):]
+	secondPrimary := lines[1][strings.Index(lines[1], "(primary: "):]
+	re.NotEqual(firstPrimary, secondPrimary)
+	// The priority raised for the transfer is restored after the switch is verified.
+	re.Equal(priorities, getPriorities())
+}
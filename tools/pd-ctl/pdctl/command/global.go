@@ -16,6 +16,7 @@ package command
 
 import (
 	"bytes"
+	"crypto/tls"
 	"encoding/json"
 	"io"
 	"net/http"
@@ -34,6 +35,8 @@ var (
 	dialClient                = &http.Client{
 		Transport: apiutil.NewComponentSignatureRoundTripper(http.DefaultTransport, pdControllerComponentName),
 	}
+	// tlsConfig is the TLS config of the gRPC connections, nil means insecure.
+	tlsConfig  *tls.Config
 	pingPrefix = "pd/api/v1/ping"
 )
 
@@ -44,7 +47,8 @@ func InitHTTPSClient(caPath, certPath, keyPath string) error {
 		KeyFile:       keyPath,
 		TrustedCAFile: caPath,
 	}
-	tlsConfig, err := tlsInfo.ClientConfig()
+	var err error
+	tlsConfig, err = tlsInfo.ClientConfig()
 	if err != nil {
 		return errors.WithStack(err)
 	}
@@ -15,52 +15,79 @@
 package command
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
+	"text/tabwriter"
+	"time"
 
+	"github.com/pingcap/errors"
 	"github.com/spf13/cobra"
+	"github.com/tikv/pd/pkg/keyspace"
+	"github.com/tikv/pd/pkg/mcs/utils"
 	"github.com/tikv/pd/pkg/storage/endpoint"
 )
 
-const keyspaceGroupsPrefix = "pd/api/v2/tso/keyspace-groups"
+const (
+	keyspaceGroupsPrefix = "pd/api/v2/tso/keyspace-groups"
+	// rebalanceCheckInterval is the interval to check whether a split/merge of the rebalance is finished.
+	rebalanceCheckInterval = 100 * time.Millisecond
+)
 
 // NewKeyspaceGroupCommand return a keyspace group subcommand of rootCmd
 func NewKeyspaceGroupCommand() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "keyspace-group [command] [flags]",
+		Use:   "keyspace-group [command] [flags] [<keyspace_group_id>...]",
 		Short: "show keyspace group information",
 		Run:   showKeyspaceGroupsCommandFunc,
 	}
 	cmd.AddCommand(newSplitKeyspaceGroupCommand())
 	cmd.AddCommand(newSplitRangeKeyspaceGroupCommand())
 	cmd.AddCommand(newFinishSplitKeyspaceGroupCommand())
+	cmd.AddCommand(newRollbackSplitKeyspaceGroupCommand())
 	cmd.AddCommand(newMergeKeyspaceGroupCommand())
 	cmd.AddCommand(newFinishMergeKeyspaceGroupCommand())
 	cmd.AddCommand(newSetNodesKeyspaceGroupCommand())
 	cmd.AddCommand(newSetPriorityKeyspaceGroupCommand())
+	cmd.AddCommand(newDiffKeyspaceGroupCommand())
+	cmd.AddCommand(newResetStateKeyspaceGroupCommand())
+	cmd.AddCommand(newNodeLoadKeyspaceGroupCommand())
+	cmd.AddCommand(newByNodeKeyspaceGroupCommand())
+	cmd.AddCommand(newReallocateKeyspaceGroupCommand())
+	cmd.AddCommand(newWatchKeyspaceGroupCommand())
+	cmd.AddCommand(newInTransitionKeyspaceGroupCommand())
+	cmd.AddCommand(newListKeyspacesKeyspaceGroupCommand())
+	cmd.AddCommand(newRebalanceKeyspaceGroupCommand())
 	cmd.Flags().String("state", "", "state filter")
+	cmd.Flags().Bool("stream", false, "print the keyspace groups one per line as they arrive instead of loading all of them at once")
 	return cmd
 }
 
 func newSplitKeyspaceGroupCommand() *cobra.Command {
 	r := &cobra.Command{
-		Use:   "split <keyspace_group_id> <new_keyspace_group_id> [<keyspace_id>]",
+		Use:   "split <keyspace_group_id> <new_keyspace_group_id> [<keyspace_id>] [--dry-run]",
 		Short: "split the keyspace group with the given ID and transfer the keyspaces into the newly split one",
 		Run:   splitKeyspaceGroupCommandFunc,
 	}
+	r.Flags().Bool("dry-run", false, "only show the keyspace groups after the split and the etcd txn ops it needs without applying it")
 	return r
 }
 
 func newSplitRangeKeyspaceGroupCommand() *cobra.Command {
 	r := &cobra.Command{
-		Use:   "split-range <keyspace_group_id> <new_keyspace_group_id> <start_keyspace_id> <end_keyspace_id>",
+		Use:   "split-range <keyspace_group_id> <new_keyspace_group_id> <start_keyspace_id> <end_keyspace_id> [--dry-run]",
 		Short: "split the keyspace group with the given ID and transfer the keyspaces in the given range (both ends inclusive) into the newly split one",
 		Run:   splitRangeKeyspaceGroupCommandFunc,
 	}
+	r.Flags().Bool("dry-run", false, "only show the keyspace groups after the split and the etcd txn ops it needs without applying it")
 	return r
 }
 
@@ -74,12 +101,22 @@ func newFinishSplitKeyspaceGroupCommand() *cobra.Command {
 	return r
 }
 
+func newRollbackSplitKeyspaceGroupCommand() *cobra.Command {
+	r := &cobra.Command{
+		Use:   "rollback-split <keyspace_group_id>",
+		Short: "abort the in-progress split of the keyspace group with the given split source or target ID and move the keyspaces back to the split source",
+		Run:   rollbackSplitKeyspaceGroupCommandFunc,
+	}
+	return r
+}
+
 func newMergeKeyspaceGroupCommand() *cobra.Command {
 	r := &cobra.Command{
-		Use:   "merge <target_keyspace_group_id> [<keyspace_group_id>]",
+		Use:   "merge <target_keyspace_group_id> [<keyspace_group_id>] [--dry-run]",
 		Short: "merge the keyspace group with the given IDs into the target one",
 		Run:   mergeKeyspaceGroupCommandFunc,
 	}
+	r.Flags().Bool("dry-run", false, "only show the keyspace groups after the merge and the etcd txn ops it needs without applying it")
 	return r
 }
 
@@ -111,20 +148,111 @@ func newSetPriorityKeyspaceGroupCommand() *cobra.Command {
 	return r
 }
 
+func newDiffKeyspaceGroupCommand() *cobra.Command {
+	r := &cobra.Command{
+		Use:   "diff <keyspace_group_id> <keyspace_group_id>",
+		Short: "show the differences of the keyspaces and members between the two keyspace groups with the given IDs",
+		Run:   diffKeyspaceGroupCommandFunc,
+	}
+	return r
+}
+
+func newResetStateKeyspaceGroupCommand() *cobra.Command {
+	r := &cobra.Command{
+		Use:   "reset-state <keyspace_group_id> [--force]",
+		Short: "forcibly clear the stuck split/merge state of the keyspace group with the given ID, only preview the result without --force",
+		Run:   resetStateKeyspaceGroupCommandFunc,
+	}
+	r.Flags().Bool("force", false, "clear the split/merge state instead of previewing it")
+	return r
+}
+
+func newReallocateKeyspaceGroupCommand() *cobra.Command {
+	r := &cobra.Command{
+		Use:   "reallocate [--dry-run]",
+		Short: "allocate the available tso nodes for all the keyspace groups below the replica count",
+		Run:   reallocateKeyspaceGroupCommandFunc,
+	}
+	r.Flags().Bool("dry-run", false, "only show the under-replicated keyspace groups without allocating nodes")
+	return r
+}
+
+func newWatchKeyspaceGroupCommand() *cobra.Command {
+	r := &cobra.Command{
+		Use:   "watch [<id>] [--timeout=<duration>]",
+		Short: "watch and print the keyspace group changes with the timestamp until Ctrl-C, only the given keyspace group is watched if the id is specified",
+		Run:   watchKeyspaceGroupCommandFunc,
+	}
+	r.Flags().Duration("timeout", 0, "stop watching after the duration, 0 means watching until Ctrl-C")
+	return r
+}
+
+func newInTransitionKeyspaceGroupCommand() *cobra.Command {
+	r := &cobra.Command{
+		Use:   "in-transition",
+		Short: "show all the keyspace groups in the split or merge state with the phase and the start time",
+		Run:   inTransitionKeyspaceGroupCommandFunc,
+	}
+	return r
+}
+
+func newListKeyspacesKeyspaceGroupCommand() *cobra.Command {
+	r := &cobra.Command{
+		Use:   "list-keyspaces <keyspace_group_id> [--limit=<limit>] [--offset=<offset>]",
+		Short: "show the keyspaces in the keyspace group with the given ID page by page with the total count, all the keyspaces from the offset are shown if the limit is 0",
+		Run:   listKeyspacesKeyspaceGroupCommandFunc,
+	}
+	r.Flags().Uint32("limit", 0, "the max number of the keyspaces to show, 0 means no limit")
+	r.Flags().Uint32("offset", 0, "the number of the keyspaces to skip")
+	return r
+}
+
+func newRebalanceKeyspaceGroupCommand() *cobra.Command {
+	r := &cobra.Command{
+		Use:   "rebalance --target-size=<size> [--dry-run] [--timeout=<duration>]",
+		Short: "move the keyspaces between the keyspace groups of the same user kind so that each one has about the target size of keyspaces, by splitting them out and merging them into the others",
+		Run:   rebalanceKeyspaceGroupCommandFunc,
+	}
+	r.Flags().Uint32("target-size", 0, "the expected number of the keyspaces in each keyspace group")
+	r.Flags().Bool("dry-run", false, "only show the rebalance plan without applying it")
+	r.Flags().Duration("timeout", 5*time.Minute, "the max time to wait for each split/merge to be finished")
+	return r
+}
+
+func newNodeLoadKeyspaceGroupCommand() *cobra.Command {
+	r := &cobra.Command{
+		Use: "node-load",
+		Short: "show the number of keyspace groups each tso node serves as primary and secondary, " +
+			"the member with the unique highest priority of a keyspace group is regarded as its primary",
+		Run: nodeLoadKeyspaceGroupCommandFunc,
+	}
+	return r
+}
+
+func newByNodeKeyspaceGroupCommand() *cobra.Command {
+	r := &cobra.Command{
+		Use: "by-node <tso_node_addr>",
+		Short: "show the keyspace groups which the tso node with the given address is a member of, " +
+			"the member with the unique highest priority of a keyspace group is regarded as its primary",
+		Run: byNodeKeyspaceGroupCommandFunc,
+	}
+	return r
+}
+
 func showKeyspaceGroupsCommandFunc(cmd *cobra.Command, args []string) {
 	prefix := keyspaceGroupsPrefix
 	if len(args) > 1 {
-		cmd.Usage()
+		showMultipleKeyspaceGroups(cmd, args)
 		return
 	}
-	cFunc := convertToKeyspaceGroups
+	cFunc := convertToKeyspaceGroupsWithProgress
 	if len(args) == 1 {
 		if _, err := strconv.Atoi(args[0]); err != nil {
 			cmd.Println("keyspace_group_id should be a number")
 			return
 		}
 		prefix = fmt.Sprintf("%s/%s", keyspaceGroupsPrefix, args[0])
-		cFunc = convertToKeyspaceGroup
+		cFunc = convertToKeyspaceGroupWithProgress
 	} else {
 		flags := cmd.Flags()
 		state, err := flags.GetString("state")
@@ -146,16 +274,107 @@ func showKeyspaceGroupsCommandFunc(cmd *cobra.Command, args []string) {
 		if len(stateValue) != 0 {
 			prefix = fmt.Sprintf("%v?%v", keyspaceGroupsPrefix, stateValue)
 		}
+		if stream, _ := flags.GetBool("stream"); stream {
+			if len(stateValue) != 0 {
+				prefix += "&stream=true"
+			} else {
+				prefix += "?stream=true"
+			}
+			streamKeyspaceGroups(cmd, prefix)
+			return
+		}
 	}
 	r, err := doRequest(cmd, prefix, http.MethodGet, http.Header{})
 	if err != nil {
 		cmd.Printf("Failed to get the keyspace groups information: %s\n", err)
 		return
 	}
-	r = cFunc(r)
+	r = cFunc(cmd, r)
 	cmd.Println(r)
 }
 
+// keyspaceGroupShowError is the entry of an ID which fails to be fetched in the bulk show result.
+type keyspaceGroupShowError struct {
+	ID    uint32 `json:"id"`
+	Error string `json:"error"`
+}
+
+// showMultipleKeyspaceGroups fetches the keyspace groups with the given IDs one by one and prints
+// them as a JSON array in the same order. An ID which fails to be fetched is reported in its own
+// entry without aborting the others.
+func showMultipleKeyspaceGroups(cmd *cobra.Command, args []string) {
+	ids := make([]uint32, 0, len(args))
+	for _, arg := range args {
+		id, err := strconv.ParseUint(arg, 10, 32)
+		if err != nil {
+			cmd.Println("keyspace_group_id should be a number")
+			return
+		}
+		ids = append(ids, uint32(id))
+	}
+	results := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		r, err := doRequest(cmd, fmt.Sprintf("%s/%d", keyspaceGroupsPrefix, id), http.MethodGet, http.Header{})
+		if err != nil {
+			results = append(results, &keyspaceGroupShowError{ID: id, Error: strings.TrimSpace(err.Error())})
+			continue
+		}
+		var kg *endpoint.KeyspaceGroup
+		if err := json.Unmarshal([]byte(r), &kg); err != nil {
+			results = append(results, &keyspaceGroupShowError{ID: id, Error: err.Error()})
+			continue
+		}
+		if kg == nil {
+			results = append(results, &keyspaceGroupShowError{ID: id, Error: "keyspace group does not exist"})
+			continue
+		}
+		results = append(results, withProgress(cmd, kg))
+	}
+	byteArr, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		cmd.Printf("Failed to marshal the keyspace groups: %s\n", err)
+		return
+	}
+	cmd.Println(string(byteArr))
+}
+
+// streamKeyspaceGroups prints the keyspace groups streamed as NDJSON line by line.
+func streamKeyspaceGroups(cmd *cobra.Command, prefix string) {
+	err := tryURLs(cmd, getEndpoints(cmd), func(addr string) error {
+		resp, err := dialClient.Get(addr + "/" + prefix)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			msg, err := io.ReadAll(resp.Body)
+			if err != nil {
+				return err
+			}
+			return errors.Errorf("[%d] %s", resp.StatusCode, msg)
+		}
+		scanner := bufio.NewScanner(resp.Body)
+		// A keyspace group may contain lots of keyspaces.
+		scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), 64*1024*1024)
+		for scanner.Scan() {
+			kg := &endpoint.KeyspaceGroup{}
+			if err := json.Unmarshal(scanner.Bytes(), kg); err != nil {
+				cmd.Println(scanner.Text())
+				continue
+			}
+			line, err := json.Marshal(withProgress(cmd, kg))
+			if err != nil {
+				return err
+			}
+			cmd.Println(string(line))
+		}
+		return scanner.Err()
+	})
+	if err != nil {
+		cmd.Printf("Failed to get the keyspace groups information: %s\n", err)
+	}
+}
+
 func splitKeyspaceGroupCommandFunc(cmd *cobra.Command, args []string) {
 	if len(args) < 3 {
 		cmd.Usage()
@@ -180,7 +399,7 @@ func splitKeyspaceGroupCommandFunc(cmd *cobra.Command, args []string) {
 		}
 		keyspaces = append(keyspaces, uint32(id))
 	}
-	postJSON(cmd, fmt.Sprintf("%s/%s/split", keyspaceGroupsPrefix, args[0]), map[string]interface{}{
+	requestKeyspaceGroupOperation(cmd, fmt.Sprintf("%s/%s/split", keyspaceGroupsPrefix, args[0]), map[string]interface{}{
 		"new-id":    uint32(newID),
 		"keyspaces": keyspaces,
 	})
@@ -211,7 +430,7 @@ func splitRangeKeyspaceGroupCommandFunc(cmd *cobra.Command, args []string) {
 		cmd.Printf("Failed to parse the end keyspace ID: %s\n", err)
 		return
 	}
-	postJSON(cmd, fmt.Sprintf("%s/%s/split", keyspaceGroupsPrefix, args[0]), map[string]interface{}{
+	requestKeyspaceGroupOperation(cmd, fmt.Sprintf("%s/%s/split", keyspaceGroupsPrefix, args[0]), map[string]interface{}{
 		"new-id":            uint32(newID),
 		"start-keyspace-id": uint32(startKeyspaceID),
 		"end-keyspace-id":   uint32(endKeyspaceID),
@@ -236,6 +455,24 @@ func finishSplitKeyspaceGroupCommandFunc(cmd *cobra.Command, args []string) {
 	cmd.Println("Success!")
 }
 
+func rollbackSplitKeyspaceGroupCommandFunc(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.Usage()
+		return
+	}
+	_, err := strconv.ParseUint(args[0], 10, 32)
+	if err != nil {
+		cmd.Printf("Failed to parse the keyspace group ID: %s\n", err)
+		return
+	}
+	r, err := doRequest(cmd, fmt.Sprintf("%s/%s/rollback-split", keyspaceGroupsPrefix, args[0]), http.MethodPost, http.Header{})
+	if err != nil {
+		cmd.Printf("Failed to rollback the split of the keyspace group: %s\n", err)
+		return
+	}
+	cmd.Println(convertToKeyspaceGroup(r))
+}
+
 func mergeKeyspaceGroupCommandFunc(cmd *cobra.Command, args []string) {
 	if len(args) < 2 {
 		cmd.Usage()
@@ -255,11 +492,39 @@ func mergeKeyspaceGroupCommandFunc(cmd *cobra.Command, args []string) {
 		}
 		groups = append(groups, uint32(id))
 	}
-	postJSON(cmd, fmt.Sprintf("%s/%s/merge", keyspaceGroupsPrefix, args[0]), map[string]interface{}{
+	requestKeyspaceGroupOperation(cmd, fmt.Sprintf("%s/%s/merge", keyspaceGroupsPrefix, args[0]), map[string]interface{}{
 		"merge-list": groups,
 	})
 }
 
+// requestKeyspaceGroupOperation sends the split/merge request, or prints the projected
+// keyspace groups and the etcd txn ops the operation needs if --dry-run is set.
+func requestKeyspaceGroupOperation(cmd *cobra.Command, prefix string, input map[string]interface{}) {
+	dryRun, err := cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		cmd.Printf("Failed to get dry-run: %s\n", err)
+		return
+	}
+	if !dryRun {
+		postJSON(cmd, prefix, input)
+		return
+	}
+	input["dry-run"] = true
+	data, err := json.Marshal(input)
+	if err != nil {
+		cmd.Println(err)
+		return
+	}
+	r, err := doRequest(cmd, prefix, http.MethodPost,
+		http.Header{"Content-Type": {"application/json"}}, WithBody(bytes.NewBuffer(data)))
+	if err != nil {
+		cmd.Printf("Failed to preview the %s: %s\n", cmd.Name(), err)
+		return
+	}
+	cmd.Println("The keyspace groups after the operation will be (remove --dry-run to apply):")
+	cmd.Println(r)
+}
+
 func finishMergeKeyspaceGroupCommandFunc(cmd *cobra.Command, args []string) {
 	if len(args) < 1 {
 		cmd.Usage()
@@ -278,6 +543,39 @@ func finishMergeKeyspaceGroupCommandFunc(cmd *cobra.Command, args []string) {
 	cmd.Println("Success!")
 }
 
+func listKeyspacesKeyspaceGroupCommandFunc(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.Usage()
+		return
+	}
+	_, err := strconv.ParseUint(args[0], 10, 32)
+	if err != nil {
+		cmd.Printf("Failed to parse the keyspace group ID: %s\n", err)
+		return
+	}
+	flags := cmd.Flags()
+	limit, err := flags.GetUint32("limit")
+	if err != nil {
+		cmd.Printf("Failed to get limit: %s\n", err)
+		return
+	}
+	offset, err := flags.GetUint32("offset")
+	if err != nil {
+		cmd.Printf("Failed to get offset: %s\n", err)
+		return
+	}
+	query := url.Values{}
+	query.Set("limit", strconv.FormatUint(uint64(limit), 10))
+	query.Set("offset", strconv.FormatUint(uint64(offset), 10))
+	r, err := doRequest(cmd, fmt.Sprintf("%s/%s/keyspaces?%s", keyspaceGroupsPrefix, args[0], query.Encode()),
+		http.MethodGet, http.Header{})
+	if err != nil {
+		cmd.Printf("Failed to list the keyspaces of the keyspace group: %s\n", err)
+		return
+	}
+	cmd.Println(r)
+}
+
 func setNodesKeyspaceGroupCommandFunc(cmd *cobra.Command, args []string) {
 	if len(args) < 2 {
 		cmd.Usage()
@@ -290,8 +588,7 @@ func setNodesKeyspaceGroupCommandFunc(cmd *cobra.Command, args []string) {
 	}
 	nodes := make([]string, 0, len(args)-1)
 	for _, arg := range args[1:] {
-		u, err := url.ParseRequestURI(arg)
-		if u == nil || err != nil {
+		if err := validateNodeAddr(arg); err != nil {
 			cmd.Printf("Failed to parse the tso node address: %s\n", err)
 			return
 		}
@@ -314,8 +611,7 @@ func setPriorityKeyspaceGroupCommandFunc(cmd *cobra.Command, args []string) {
 	}
 
 	node := args[1]
-	u, err := url.ParseRequestURI(node)
-	if u == nil || err != nil {
+	if err := validateNodeAddr(node); err != nil {
 		cmd.Printf("Failed to parse the tso node address: %s\n", err)
 		return
 	}
@@ -337,6 +633,809 @@ func setPriorityKeyspaceGroupCommandFunc(cmd *cobra.Command, args []string) {
 	})
 }
 
+// keyspaceGroupDiff is the difference between two keyspace groups.
+type keyspaceGroupDiff struct {
+	FirstID  uint32 `json:"first-id"`
+	SecondID uint32 `json:"second-id"`
+	// KeyspacesOnlyInFirst are the keyspaces which only belong to the first keyspace group.
+	KeyspacesOnlyInFirst []uint32 `json:"keyspaces-only-in-first"`
+	// KeyspacesOnlyInSecond are the keyspaces which only belong to the second keyspace group.
+	KeyspacesOnlyInSecond []uint32 `json:"keyspaces-only-in-second"`
+	// Members compares the members of the two keyspace groups by their addresses.
+	Members []keyspaceGroupMemberDiff `json:"members"`
+}
+
+// keyspaceGroupMemberDiff compares the priorities of a member in two keyspace groups.
+// The priority is nil if the member does not belong to the corresponding keyspace group.
+type keyspaceGroupMemberDiff struct {
+	Address        string `json:"address"`
+	FirstPriority  *int   `json:"first-priority"`
+	SecondPriority *int   `json:"second-priority"`
+}
+
+func resetStateKeyspaceGroupCommandFunc(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.Usage()
+		return
+	}
+	_, err := strconv.ParseUint(args[0], 10, 32)
+	if err != nil {
+		cmd.Printf("Failed to parse the keyspace group ID: %s\n", err)
+		return
+	}
+	force, err := cmd.Flags().GetBool("force")
+	if err != nil {
+		cmd.Printf("Failed to get force: %s\n", err)
+		return
+	}
+	data, err := json.Marshal(map[string]interface{}{
+		"dry-run": !force,
+	})
+	if err != nil {
+		cmd.Println(err)
+		return
+	}
+	r, err := doRequest(cmd, fmt.Sprintf("%s/%s/reset-state", keyspaceGroupsPrefix, args[0]), http.MethodPost,
+		http.Header{"Content-Type": {"application/json"}}, WithBody(bytes.NewBuffer(data)))
+	if err != nil {
+		cmd.Printf("Failed to reset the keyspace group state: %s\n", err)
+		return
+	}
+	if !force {
+		cmd.Println("The keyspace groups after resetting will be (use --force to apply):")
+	}
+	cmd.Println(convertToKeyspaceGroups(r))
+}
+
+func reallocateKeyspaceGroupCommandFunc(cmd *cobra.Command, args []string) {
+	if len(args) != 0 {
+		cmd.Usage()
+		return
+	}
+	dryRun, err := cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		cmd.Printf("Failed to get dry-run: %s\n", err)
+		return
+	}
+	data, err := json.Marshal(map[string]interface{}{
+		"dry-run": dryRun,
+	})
+	if err != nil {
+		cmd.Println(err)
+		return
+	}
+	r, err := doRequest(cmd, fmt.Sprintf("%s/reallocate", keyspaceGroupsPrefix), http.MethodPost,
+		http.Header{"Content-Type": {"application/json"}}, WithBody(bytes.NewBuffer(data)))
+	if err != nil {
+		cmd.Printf("Failed to reallocate the keyspace groups: %s\n", err)
+		return
+	}
+	if dryRun {
+		cmd.Println("The under-replicated keyspace groups to be reallocated are (run without --dry-run to apply):")
+	}
+	cmd.Println(r)
+}
+
+func watchKeyspaceGroupCommandFunc(cmd *cobra.Command, args []string) {
+	if len(args) > 1 {
+		cmd.Usage()
+		return
+	}
+	query := url.Values{}
+	if len(args) == 1 {
+		if _, err := strconv.ParseUint(args[0], 10, 32); err != nil {
+			cmd.Printf("Failed to parse the keyspace group ID: %s\n", err)
+			return
+		}
+		query.Set("id", args[0])
+	}
+	timeout, err := cmd.Flags().GetDuration("timeout")
+	if err != nil {
+		cmd.Printf("Failed to get timeout: %s\n", err)
+		return
+	}
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	prefix := fmt.Sprintf("%s/watch?%s", keyspaceGroupsPrefix, query.Encode())
+	err = tryURLs(cmd, getEndpoints(cmd), func(addr string) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, addr+"/"+prefix, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := dialClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			msg, err := io.ReadAll(resp.Body)
+			if err != nil {
+				return err
+			}
+			return errors.Errorf("[%d] %s", resp.StatusCode, msg)
+		}
+		scanner := bufio.NewScanner(resp.Body)
+		// A keyspace group may contain lots of keyspaces.
+		scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), 64*1024*1024)
+		for scanner.Scan() {
+			printKeyspaceGroupEvent(cmd, scanner.Bytes())
+		}
+		if ctx.Err() != nil {
+			// The watch is stopped by the timeout.
+			return nil
+		}
+		return scanner.Err()
+	})
+	if err != nil {
+		cmd.Printf("Failed to watch the keyspace groups: %s\n", err)
+	}
+}
+
+// printKeyspaceGroupEvent prints the keyspace group event with the local timestamp it is received at.
+func printKeyspaceGroupEvent(cmd *cobra.Command, line []byte) {
+	now := time.Now().Format("2006-01-02 15:04:05.000")
+	event := &keyspace.KeyspaceGroupEvent{}
+	if err := json.Unmarshal(line, event); err != nil {
+		cmd.Printf("[%s] %s\n", now, line)
+		return
+	}
+	if event.KeyspaceGroup == nil {
+		cmd.Printf("[%s] %s keyspace group %d\n", now, event.Type, event.ID)
+		return
+	}
+	kg, err := json.Marshal(withProgress(cmd, event.KeyspaceGroup))
+	if err != nil {
+		cmd.Printf("[%s] %s\n", now, line)
+		return
+	}
+	cmd.Printf("[%s] %s keyspace group %d: %s\n", now, event.Type, event.ID, kg)
+}
+
+func rebalanceKeyspaceGroupCommandFunc(cmd *cobra.Command, args []string) {
+	if len(args) != 0 {
+		cmd.Usage()
+		return
+	}
+	flags := cmd.Flags()
+	targetSize, err := flags.GetUint32("target-size")
+	if err != nil {
+		cmd.Printf("Failed to get target-size: %s\n", err)
+		return
+	}
+	if targetSize == 0 {
+		cmd.Println("Failed to rebalance the keyspace groups: the target size should be greater than 0")
+		return
+	}
+	dryRun, err := flags.GetBool("dry-run")
+	if err != nil {
+		cmd.Printf("Failed to get dry-run: %s\n", err)
+		return
+	}
+	timeout, err := flags.GetDuration("timeout")
+	if err != nil {
+		cmd.Printf("Failed to get timeout: %s\n", err)
+		return
+	}
+	r, err := doRequest(cmd, keyspaceGroupsPrefix, http.MethodGet, http.Header{})
+	if err != nil {
+		cmd.Printf("Failed to get the keyspace groups information: %s\n", err)
+		return
+	}
+	var kgs []*endpoint.KeyspaceGroup
+	if err = json.Unmarshal([]byte(r), &kgs); err != nil {
+		cmd.Printf("Failed to parse the keyspace groups information: %s\n", err)
+		return
+	}
+	for _, kg := range kgs {
+		if kg.IsSplitting() || kg.IsMerging() {
+			cmd.Printf("Failed to rebalance the keyspace groups: keyspace group %d is in the split or merge state\n", kg.ID)
+			return
+		}
+	}
+	plan := planKeyspaceGroupRebalance(kgs, int(targetSize))
+	byteArr, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		cmd.Printf("Failed to marshal the rebalance plan: %s\n", err)
+		return
+	}
+	if dryRun {
+		cmd.Println("The rebalance plan is (remove --dry-run to apply):")
+		cmd.Println(string(byteArr))
+		return
+	}
+	cmd.Println("The rebalance plan is:")
+	cmd.Println(string(byteArr))
+	if len(plan) > 0 {
+		if err = executeKeyspaceGroupRebalance(cmd, kgs, plan, timeout); err != nil {
+			cmd.Printf("Failed to rebalance the keyspace groups: %s\n", err)
+			return
+		}
+	}
+	r, err = doRequest(cmd, keyspaceGroupsPrefix, http.MethodGet, http.Header{})
+	if err != nil {
+		cmd.Printf("Failed to get the keyspace groups information: %s\n", err)
+		return
+	}
+	cmd.Println("The keyspace groups after rebalancing are:")
+	cmd.Println(convertToKeyspaceGroups(r))
+}
+
+// keyspaceGroupRebalanceStep moves the keyspaces from the source keyspace group to the target one.
+type keyspaceGroupRebalanceStep struct {
+	Source    uint32   `json:"source"`
+	Target    uint32   `json:"target"`
+	Keyspaces []uint32 `json:"keyspaces"`
+}
+
+// planKeyspaceGroupRebalance moves the keyspaces with the largest IDs out of the keyspace groups larger than
+// the target size into the ones smaller than it with the same user kind. The default keyspace is never moved.
+// The given keyspace groups are not modified.
+func planKeyspaceGroupRebalance(kgs []*endpoint.KeyspaceGroup, targetSize int) []*keyspaceGroupRebalanceStep {
+	type candidate struct {
+		id        uint32
+		keyspaces []uint32
+		count     int
+	}
+	kinds := make([]string, 0)
+	donorsByKind := make(map[string][]*candidate)
+	receiversByKind := make(map[string][]*candidate)
+	sorted := make([]*endpoint.KeyspaceGroup, len(kgs))
+	copy(sorted, kgs)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+	for _, kg := range sorted {
+		if _, ok := donorsByKind[kg.UserKind]; !ok {
+			kinds = append(kinds, kg.UserKind)
+			donorsByKind[kg.UserKind] = make([]*candidate, 0)
+		}
+		switch size := len(kg.Keyspaces); {
+		case size > targetSize:
+			movable := make([]uint32, 0, size)
+			for _, keyspace := range kg.Keyspaces {
+				if keyspace != utils.DefaultKeyspaceID {
+					movable = append(movable, keyspace)
+				}
+			}
+			sort.Slice(movable, func(i, j int) bool { return movable[i] < movable[j] })
+			count := size - targetSize
+			if count > len(movable) {
+				count = len(movable)
+			}
+			donorsByKind[kg.UserKind] = append(donorsByKind[kg.UserKind],
+				&candidate{id: kg.ID, keyspaces: movable[len(movable)-count:], count: count})
+		case size < targetSize:
+			receiversByKind[kg.UserKind] = append(receiversByKind[kg.UserKind],
+				&candidate{id: kg.ID, count: targetSize - size})
+		}
+	}
+	plan := make([]*keyspaceGroupRebalanceStep, 0)
+	for _, kind := range kinds {
+		donors, receivers := donorsByKind[kind], receiversByKind[kind]
+		for i, j := 0, 0; i < len(donors) && j < len(receivers); {
+			donor, receiver := donors[i], receivers[j]
+			count := donor.count
+			if receiver.count < count {
+				count = receiver.count
+			}
+			if count > 0 {
+				plan = append(plan, &keyspaceGroupRebalanceStep{
+					Source:    donor.id,
+					Target:    receiver.id,
+					Keyspaces: donor.keyspaces[:count],
+				})
+			}
+			donor.keyspaces, donor.count = donor.keyspaces[count:], donor.count-count
+			receiver.count -= count
+			if donor.count == 0 {
+				i++
+			}
+			if receiver.count == 0 {
+				j++
+			}
+		}
+	}
+	return plan
+}
+
+// executeKeyspaceGroupRebalance applies the rebalance plan with the split and merge, that is, the keyspaces of
+// each step are split out of the source keyspace group into a temporary one first, then the temporary keyspace
+// groups of the same target are merged into it. To fit in one etcd txn, a merge only includes a limited number
+// of the temporary keyspace groups, so the steps of a target may need several merges. If it fails, the temporary
+// keyspace groups which are not merged yet are printed to be merged back into their source keyspace groups.
+func executeKeyspaceGroupRebalance(
+	cmd *cobra.Command, kgs []*endpoint.KeyspaceGroup, plan []*keyspaceGroupRebalanceStep, timeout time.Duration,
+) (err error) {
+	// leftBehind is the temporary keyspace groups split out but not merged yet, mapping to their sources.
+	type tempKeyspaceGroup struct {
+		id, source uint32
+	}
+	var leftBehind []tempKeyspaceGroup
+	defer func() {
+		if err == nil {
+			return
+		}
+		for _, temp := range leftBehind {
+			cmd.Printf("The temporary keyspace group %d split from keyspace group %d is left behind, "+
+				"merge it back by `keyspace-group merge %d %d` after its split is finished\n",
+				temp.id, temp.source, temp.source, temp.id)
+		}
+	}()
+	usedIDs := make(map[uint32]struct{}, len(kgs))
+	for _, kg := range kgs {
+		usedIDs[kg.ID] = struct{}{}
+	}
+	allocTempID := func() (uint32, error) {
+		for id := utils.DefaultKeyspaceGroupID + 1; id <= utils.MaxKeyspaceGroupCountInUse; id++ {
+			if _, ok := usedIDs[id]; !ok {
+				usedIDs[id] = struct{}{}
+				return id, nil
+			}
+		}
+		return 0, errors.New("no available keyspace group id for the rebalance")
+	}
+	targets := make([]uint32, 0)
+	stepsByTarget := make(map[uint32][]*keyspaceGroupRebalanceStep)
+	for _, step := range plan {
+		if _, ok := stepsByTarget[step.Target]; !ok {
+			targets = append(targets, step.Target)
+		}
+		stepsByTarget[step.Target] = append(stepsByTarget[step.Target], step)
+	}
+	for _, target := range targets {
+		steps := stepsByTarget[target]
+		for len(steps) > 0 {
+			batch := steps
+			if len(batch) > keyspace.MaxMergeListSize {
+				batch = batch[:keyspace.MaxMergeListSize]
+			}
+			steps = steps[len(batch):]
+			mergeList := make([]uint32, 0, len(batch))
+			for _, step := range batch {
+				tempID, err := allocTempID()
+				if err != nil {
+					return err
+				}
+				if _, err = sendKeyspaceGroupOperation(cmd, fmt.Sprintf("%s/%d/split", keyspaceGroupsPrefix, step.Source),
+					map[string]interface{}{"new-id": tempID, "keyspaces": step.Keyspaces}); err != nil {
+					return err
+				}
+				leftBehind = append(leftBehind, tempKeyspaceGroup{id: tempID, source: step.Source})
+				if err = waitKeyspaceGroup(cmd, tempID, timeout, func(kg *endpoint.KeyspaceGroup) bool {
+					return !kg.IsSplitting()
+				}); err != nil {
+					return errors.Annotatef(err, "failed to wait for the split of keyspace group %d", step.Source)
+				}
+				mergeList = append(mergeList, tempID)
+			}
+			if _, err = sendKeyspaceGroupOperation(cmd, fmt.Sprintf("%s/%d/merge", keyspaceGroupsPrefix, target),
+				map[string]interface{}{"merge-list": mergeList}); err != nil {
+				return err
+			}
+			// The temporary keyspace groups are taken over by the merge target once the merge starts.
+			leftBehind = leftBehind[:0]
+			if err = waitKeyspaceGroup(cmd, target, timeout, func(kg *endpoint.KeyspaceGroup) bool {
+				return !kg.IsMerging()
+			}); err != nil {
+				return errors.Annotatef(err, "failed to wait for the merge of keyspace group %d", target)
+			}
+			for _, id := range mergeList {
+				delete(usedIDs, id)
+			}
+			for _, step := range batch {
+				cmd.Printf("Moved %d keyspaces from keyspace group %d to keyspace group %d\n",
+					len(step.Keyspaces), step.Source, step.Target)
+			}
+		}
+	}
+	return nil
+}
+
+// sendKeyspaceGroupOperation posts the split/merge request and returns the response.
+func sendKeyspaceGroupOperation(cmd *cobra.Command, prefix string, input map[string]interface{}) (string, error) {
+	data, err := json.Marshal(input)
+	if err != nil {
+		return "", err
+	}
+	return doRequest(cmd, prefix, http.MethodPost,
+		http.Header{"Content-Type": {"application/json"}}, WithBody(bytes.NewBuffer(data)))
+}
+
+// waitKeyspaceGroup waits until the keyspace group satisfies the condition or the timeout is reached.
+func waitKeyspaceGroup(
+	cmd *cobra.Command, id uint32, timeout time.Duration, condition func(*endpoint.KeyspaceGroup) bool,
+) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		r, err := doRequest(cmd, fmt.Sprintf("%s/%d", keyspaceGroupsPrefix, id), http.MethodGet, http.Header{})
+		if err != nil {
+			return err
+		}
+		var kg *endpoint.KeyspaceGroup
+		if err = json.Unmarshal([]byte(r), &kg); err != nil {
+			return err
+		}
+		if kg == nil {
+			return errors.Errorf("keyspace group %d does not exist", id)
+		}
+		if condition(kg) {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return errors.Errorf("timeout after %s", timeout)
+		}
+		time.Sleep(rebalanceCheckInterval)
+	}
+}
+
+func nodeLoadKeyspaceGroupCommandFunc(cmd *cobra.Command, args []string) {
+	if len(args) != 0 {
+		cmd.Usage()
+		return
+	}
+	r, err := doRequest(cmd, keyspaceGroupsPrefix, http.MethodGet, http.Header{})
+	if err != nil {
+		cmd.Printf("Failed to get the keyspace groups information: %s\n", err)
+		return
+	}
+	var kgs []*endpoint.KeyspaceGroup
+	if err = json.Unmarshal([]byte(r), &kgs); err != nil {
+		cmd.Printf("Failed to parse the keyspace groups information: %s\n", err)
+		return
+	}
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ADDRESS\tPRIMARY\tSECONDARY\tTOTAL")
+	for _, load := range aggregateNodeLoads(kgs) {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\n", load.Address, load.Primary, load.Secondary, load.Total)
+	}
+	w.Flush()
+}
+
+// nodeLoad is the number of keyspace groups served by a tso node.
+type nodeLoad struct {
+	Address   string
+	Primary   int
+	Secondary int
+	Total     int
+}
+
+// aggregateNodeLoads counts the keyspace groups served by each tso node and returns the loads
+// sorted by the total count in descending order.
+func aggregateNodeLoads(kgs []*endpoint.KeyspaceGroup) []*nodeLoad {
+	loads := make(map[string]*nodeLoad)
+	for _, kg := range kgs {
+		primary := primaryMemberIndex(kg)
+		for i, member := range kg.Members {
+			load, ok := loads[member.Address]
+			if !ok {
+				load = &nodeLoad{Address: member.Address}
+				loads[member.Address] = load
+			}
+			if i == primary {
+				load.Primary++
+			} else {
+				load.Secondary++
+			}
+			load.Total++
+		}
+	}
+	result := make([]*nodeLoad, 0, len(loads))
+	for _, load := range loads {
+		result = append(result, load)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Total != result[j].Total {
+			return result[i].Total > result[j].Total
+		}
+		if result[i].Primary != result[j].Primary {
+			return result[i].Primary > result[j].Primary
+		}
+		return result[i].Address < result[j].Address
+	})
+	return result
+}
+
+// primaryMemberIndex returns the index of the member with the unique highest priority, which is
+// regarded as the primary of the keyspace group. It returns -1 if there is no such member, e.g. all
+// the members have the same priority.
+func primaryMemberIndex(kg *endpoint.KeyspaceGroup) int {
+	primary := -1
+	for i, member := range kg.Members {
+		if primary < 0 || member.Priority > kg.Members[primary].Priority {
+			primary = i
+		}
+	}
+	for i, member := range kg.Members {
+		if i != primary && member.Priority == kg.Members[primary].Priority {
+			return -1
+		}
+	}
+	return primary
+}
+
+func byNodeKeyspaceGroupCommandFunc(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.Usage()
+		return
+	}
+	node := args[0]
+	if err := validateNodeAddr(node); err != nil {
+		cmd.Printf("Failed to parse the tso node address: %s\n", err)
+		return
+	}
+	r, err := doRequest(cmd, keyspaceGroupsPrefix, http.MethodGet, http.Header{})
+	if err != nil {
+		cmd.Printf("Failed to get the keyspace groups information: %s\n", err)
+		return
+	}
+	var kgs []*endpoint.KeyspaceGroup
+	if err = json.Unmarshal([]byte(r), &kgs); err != nil {
+		cmd.Printf("Failed to parse the keyspace groups information: %s\n", err)
+		return
+	}
+	byteArr, err := json.MarshalIndent(filterKeyspaceGroupsByNode(kgs, node), "", "  ")
+	if err != nil {
+		cmd.Printf("Failed to marshal the keyspace groups: %s\n", err)
+		return
+	}
+	cmd.Println(string(byteArr))
+}
+
+func inTransitionKeyspaceGroupCommandFunc(cmd *cobra.Command, args []string) {
+	if len(args) != 0 {
+		cmd.Usage()
+		return
+	}
+	r, err := doRequest(cmd, keyspaceGroupsPrefix, http.MethodGet, http.Header{})
+	if err != nil {
+		cmd.Printf("Failed to get the keyspace groups information: %s\n", err)
+		return
+	}
+	var kgs []*endpoint.KeyspaceGroup
+	if err = json.Unmarshal([]byte(r), &kgs); err != nil {
+		cmd.Printf("Failed to parse the keyspace groups information: %s\n", err)
+		return
+	}
+	transitions := filterKeyspaceGroupsInTransition(kgs)
+	for _, transition := range transitions {
+		transition.Progress = getKeyspaceGroupProgress(cmd, transition.ID)
+	}
+	byteArr, err := json.MarshalIndent(transitions, "", "  ")
+	if err != nil {
+		cmd.Printf("Failed to marshal the keyspace groups: %s\n", err)
+		return
+	}
+	cmd.Println(string(byteArr))
+}
+
+// keyspaceGroupTransition is the split or merge phase of a keyspace group.
+type keyspaceGroupTransition struct {
+	ID uint32 `json:"id"`
+	// Phase is one of "split-source", "split-target" and "merge-target".
+	Phase       string   `json:"phase"`
+	SplitSource *uint32  `json:"split-source,omitempty"`
+	MergeList   []uint32 `json:"merge-list,omitempty"`
+	// Since is empty if the start time is unknown, e.g., the transition is started by an old version.
+	Since    string                          `json:"since,omitempty"`
+	Progress *keyspace.KeyspaceGroupProgress `json:"progress,omitempty"`
+}
+
+// filterKeyspaceGroupsInTransition returns the phases of the keyspace groups in the split or merge state.
+func filterKeyspaceGroupsInTransition(kgs []*endpoint.KeyspaceGroup) []*keyspaceGroupTransition {
+	transitions := make([]*keyspaceGroupTransition, 0)
+	for _, kg := range kgs {
+		var (
+			transition = &keyspaceGroupTransition{ID: kg.ID}
+			startTime  int64
+		)
+		switch {
+		case kg.IsSplitting():
+			transition.Phase = "split-target"
+			if kg.IsSplitSource() {
+				transition.Phase = "split-source"
+			}
+			splitSource := kg.SplitSource()
+			transition.SplitSource = &splitSource
+			startTime = kg.SplitState.StartTime
+		case kg.IsMerging():
+			transition.Phase = "merge-target"
+			transition.MergeList = kg.MergeState.MergeList
+			startTime = kg.MergeState.StartTime
+		default:
+			continue
+		}
+		if startTime > 0 {
+			transition.Since = time.Unix(startTime, 0).Format(time.RFC3339)
+		}
+		transitions = append(transitions, transition)
+	}
+	return transitions
+}
+
+// nodeMembership is the membership of a tso node in a keyspace group.
+type nodeMembership struct {
+	ID        uint32   `json:"id"`
+	Role      string   `json:"role"`
+	Priority  int      `json:"priority"`
+	Keyspaces []uint32 `json:"keyspaces"`
+}
+
+// filterKeyspaceGroupsByNode returns the memberships of the given node in the keyspace groups.
+func filterKeyspaceGroupsByNode(kgs []*endpoint.KeyspaceGroup, node string) []*nodeMembership {
+	memberships := make([]*nodeMembership, 0)
+	for _, kg := range kgs {
+		primary := primaryMemberIndex(kg)
+		for i, member := range kg.Members {
+			if member.Address != node {
+				continue
+			}
+			role := "secondary"
+			if i == primary {
+				role = "primary"
+			}
+			memberships = append(memberships, &nodeMembership{
+				ID:        kg.ID,
+				Role:      role,
+				Priority:  member.Priority,
+				Keyspaces: kg.Keyspaces,
+			})
+			break
+		}
+	}
+	return memberships
+}
+
+// validateNodeAddr checks whether the given tso node address is valid.
+func validateNodeAddr(addr string) error {
+	_, err := url.ParseRequestURI(addr)
+	return err
+}
+
+func diffKeyspaceGroupCommandFunc(cmd *cobra.Command, args []string) {
+	if len(args) != 2 {
+		cmd.Usage()
+		return
+	}
+	groups := make([]*endpoint.KeyspaceGroup, 0, len(args))
+	for _, arg := range args {
+		if _, err := strconv.ParseUint(arg, 10, 32); err != nil {
+			cmd.Printf("Failed to parse the keyspace group ID: %s\n", err)
+			return
+		}
+		r, err := doRequest(cmd, fmt.Sprintf("%s/%s", keyspaceGroupsPrefix, arg), http.MethodGet, http.Header{})
+		if err != nil {
+			cmd.Printf("Failed to get the keyspace group information: %s\n", err)
+			return
+		}
+		var kg *endpoint.KeyspaceGroup
+		if err = json.Unmarshal([]byte(r), &kg); err != nil {
+			cmd.Printf("Failed to parse the keyspace group information: %s\n", err)
+			return
+		}
+		if kg == nil {
+			cmd.Printf("Failed to get the keyspace group information: keyspace group %s does not exist\n", arg)
+			return
+		}
+		groups = append(groups, kg)
+	}
+	byteArr, err := json.MarshalIndent(diffKeyspaceGroups(groups[0], groups[1]), "", "  ")
+	if err != nil {
+		cmd.Printf("Failed to marshal the keyspace group diff: %s\n", err)
+		return
+	}
+	cmd.Println(string(byteArr))
+}
+
+func diffKeyspaceGroups(first, second *endpoint.KeyspaceGroup) *keyspaceGroupDiff {
+	diff := &keyspaceGroupDiff{
+		FirstID:               first.ID,
+		SecondID:              second.ID,
+		KeyspacesOnlyInFirst:  subtractKeyspaces(first.Keyspaces, second.Keyspaces),
+		KeyspacesOnlyInSecond: subtractKeyspaces(second.Keyspaces, first.Keyspaces),
+		Members:               make([]keyspaceGroupMemberDiff, 0, len(first.Members)+len(second.Members)),
+	}
+	memberDiffs := make(map[string]*keyspaceGroupMemberDiff)
+	getMemberDiff := func(addr string) *keyspaceGroupMemberDiff {
+		if _, ok := memberDiffs[addr]; !ok {
+			memberDiffs[addr] = &keyspaceGroupMemberDiff{Address: addr}
+		}
+		return memberDiffs[addr]
+	}
+	for _, member := range first.Members {
+		priority := member.Priority
+		getMemberDiff(member.Address).FirstPriority = &priority
+	}
+	for _, member := range second.Members {
+		priority := member.Priority
+		getMemberDiff(member.Address).SecondPriority = &priority
+	}
+	for _, memberDiff := range memberDiffs {
+		diff.Members = append(diff.Members, *memberDiff)
+	}
+	sort.Slice(diff.Members, func(i, j int) bool {
+		return diff.Members[i].Address < diff.Members[j].Address
+	})
+	return diff
+}
+
+// subtractKeyspaces returns the sorted keyspaces which are in a but not in b.
+func subtractKeyspaces(a, b []uint32) []uint32 {
+	inB := make(map[uint32]struct{}, len(b))
+	for _, keyspace := range b {
+		inB[keyspace] = struct{}{}
+	}
+	result := make([]uint32, 0)
+	for _, keyspace := range a {
+		if _, ok := inB[keyspace]; !ok {
+			result = append(result, keyspace)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result
+}
+
+// keyspaceGroupWithProgress decorates the keyspace group with its split/merge progress.
+type keyspaceGroupWithProgress struct {
+	*endpoint.KeyspaceGroup
+	Progress *keyspace.KeyspaceGroupProgress `json:"progress,omitempty"`
+}
+
+// withProgress decorates the keyspace group with the split/merge progress fetched from the server
+// if it's in the split/merge state.
+func withProgress(cmd *cobra.Command, kg *endpoint.KeyspaceGroup) *keyspaceGroupWithProgress {
+	kgp := &keyspaceGroupWithProgress{KeyspaceGroup: kg}
+	if kg.IsSplitting() || kg.IsMerging() {
+		kgp.Progress = getKeyspaceGroupProgress(cmd, kg.ID)
+	}
+	return kgp
+}
+
+// getKeyspaceGroupProgress fetches the split/merge progress of the keyspace group from the server,
+// and returns nil if it fails.
+func getKeyspaceGroupProgress(cmd *cobra.Command, id uint32) *keyspace.KeyspaceGroupProgress {
+	r, err := doRequest(cmd, fmt.Sprintf("%s/%d/progress", keyspaceGroupsPrefix, id), http.MethodGet, http.Header{})
+	if err != nil {
+		return nil
+	}
+	progress := &keyspace.KeyspaceGroupProgress{}
+	if err := json.Unmarshal([]byte(r), progress); err != nil {
+		return nil
+	}
+	return progress
+}
+
+func convertToKeyspaceGroupWithProgress(cmd *cobra.Command, content string) string {
+	kg := endpoint.KeyspaceGroup{}
+	err := json.Unmarshal([]byte(content), &kg)
+	if err != nil {
+		return content
+	}
+	byteArr, err := json.MarshalIndent(withProgress(cmd, &kg), "", "  ")
+	if err != nil {
+		return content
+	}
+	return string(byteArr)
+}
+
+func convertToKeyspaceGroupsWithProgress(cmd *cobra.Command, content string) string {
+	kgs := []*endpoint.KeyspaceGroup{}
+	err := json.Unmarshal([]byte(content), &kgs)
+	if err != nil {
+		return content
+	}
+	kgps := make([]*keyspaceGroupWithProgress, 0, len(kgs))
+	for _, kg := range kgs {
+		kgps = append(kgps, withProgress(cmd, kg))
+	}
+	byteArr, err := json.MarshalIndent(kgps, "", "  ")
+	if err != nil {
+		return content
+	}
+	return string(byteArr)
+}
+
 func convertToKeyspaceGroup(content string) string {
 	kg := endpoint.KeyspaceGroup{}
 	err := json.Unmarshal([]byte(content), &kg)
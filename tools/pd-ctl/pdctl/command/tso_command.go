@@ -15,10 +15,35 @@
 package command
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/pingcap/errors"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/kvproto/pkg/pdpb"
 	"github.com/spf13/cobra"
+	"github.com/tikv/pd/pkg/mcs/utils"
+	"github.com/tikv/pd/pkg/storage/endpoint"
+	"github.com/tikv/pd/pkg/utils/apiutil/multiservicesapi"
+	"github.com/tikv/pd/pkg/utils/grpcutil"
 	"github.com/tikv/pd/pkg/utils/tsoutil"
+	"google.golang.org/grpc"
+)
+
+const (
+	// verifyMonotonicTimeout is the timeout of getting a timestamp or waiting for the new primary.
+	verifyMonotonicTimeout = time.Minute
+	// verifyMonotonicRetryInterval is the interval to retry getting a timestamp or the new primary.
+	verifyMonotonicRetryInterval = 100 * time.Millisecond
+	// tsoKeyspaceGroupMembersPrefix is the path of the TSO node API to get the keyspace group members it serves.
+	tsoKeyspaceGroupMembersPrefix = "tso/api/v1/keyspace-groups/members"
 )
 
 // NewTSOCommand return a TSO subcommand of rootCmd
@@ -28,9 +53,25 @@ func NewTSOCommand() *cobra.Command {
 		Short: "parse TSO to the system and logic time",
 		Run:   showTSOCommandFunc,
 	}
+	cmd.AddCommand(newVerifyMonotonicTSOCommand())
 	return cmd
 }
 
+func newVerifyMonotonicTSOCommand() *cobra.Command {
+	r := &cobra.Command{
+		Use: "verify-monotonic [--switch]",
+		Short: "verify the timestamps allocated by the TSO primary are strictly increasing, " +
+			"with --switch the primary is switched between the two timestamps to verify across a primary switch",
+		Long: "verify the timestamps allocated by the TSO primary are strictly increasing. The primary is the PD leader, " +
+			"or the primary of the default keyspace group in the API service mode. With --switch, the PD leader is resigned, " +
+			"or the primary of the default keyspace group is transferred to another tso node by raising its priority, " +
+			"which is restored after the switch is verified.",
+		Run: verifyMonotonicTSOCommandFunc,
+	}
+	r.Flags().Bool("switch", false, "switch the primary between getting the two timestamps")
+	return r
+}
+
 func showTSOCommandFunc(cmd *cobra.Command, args []string) {
 	if len(args) != 1 {
 		cmd.Println("Usage: tso <timestamp>")
@@ -46,3 +87,258 @@ func showTSOCommandFunc(cmd *cobra.Command, args []string) {
 	cmd.Println("system: ", physicalTime)
 	cmd.Println("logic:  ", logical)
 }
+
+func verifyMonotonicTSOCommandFunc(cmd *cobra.Command, args []string) {
+	if len(args) != 0 {
+		cmd.Usage()
+		return
+	}
+	switchPrimary, err := cmd.Flags().GetBool("switch")
+	if err != nil {
+		cmd.Printf("Failed to get switch: %s\n", err)
+		return
+	}
+	r, err := doRequest(cmd, clusterPrefix, http.MethodGet, http.Header{})
+	if err != nil {
+		cmd.Printf("Failed to get the cluster information: %s\n", err)
+		return
+	}
+	cluster := &metapb.Cluster{}
+	if err = json.Unmarshal([]byte(r), cluster); err != nil {
+		cmd.Printf("Failed to parse the cluster information: %s\n", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), verifyMonotonicTimeout)
+	defer cancel()
+	serviceMode, err := getServiceMode(ctx, cmd)
+	if err != nil {
+		cmd.Printf("Failed to get the service mode: %s\n", err)
+		return
+	}
+	// The default keyspace group is only available in the API service mode.
+	var defaultKeyspaceGroup *endpoint.KeyspaceGroup
+	if serviceMode == pdpb.ServiceMode_API_SVC_MODE {
+		r, err = doRequest(cmd, fmt.Sprintf("%s/%d", keyspaceGroupsPrefix, utils.DefaultKeyspaceGroupID), http.MethodGet, http.Header{})
+		if err != nil {
+			cmd.Printf("Failed to get the default keyspace group: %s\n", err)
+			return
+		}
+		defaultKeyspaceGroup = &endpoint.KeyspaceGroup{}
+		if err = json.Unmarshal([]byte(r), defaultKeyspaceGroup); err != nil {
+			cmd.Printf("Failed to parse the default keyspace group: %s\n", err)
+			return
+		}
+	}
+	getTS := func() (*pdpb.Timestamp, string, error) {
+		if defaultKeyspaceGroup == nil {
+			ts, leader, err := getTSFromLeader(ctx, cmd, cluster.GetId())
+			return ts, leader.GetName(), err
+		}
+		return getTSFromTSOPrimary(ctx, cmd, cluster.GetId(), defaultKeyspaceGroup)
+	}
+
+	firstTS, firstPrimary, err := getTS()
+	if err != nil {
+		cmd.Printf("Failed to get the first timestamp: %s\n", err)
+		return
+	}
+	if switchPrimary {
+		var restorePriority func() error
+		if defaultKeyspaceGroup == nil {
+			_, err = doRequest(cmd, leaderMemberPrefix+"/resign", http.MethodPost, http.Header{})
+		} else {
+			restorePriority, err = transferTSOPrimary(cmd, defaultKeyspaceGroup, firstPrimary)
+		}
+		if err != nil {
+			cmd.Printf("Failed to switch the primary: %s\n", err)
+			return
+		}
+		if restorePriority != nil {
+			// Restore the priority once the switch is verified or fails to be verified.
+			defer func() {
+				if err := restorePriority(); err != nil {
+					cmd.Printf("Failed to restore the priority of the new primary: %s\n", err)
+				}
+			}()
+		}
+	}
+	var (
+		secondTS      *pdpb.Timestamp
+		secondPrimary string
+	)
+	for {
+		secondTS, secondPrimary, err = getTS()
+		// Retry until the timestamp is got from the new primary if switching.
+		if err == nil && (!switchPrimary || secondPrimary != firstPrimary) {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			if err == nil {
+				err = errors.New("the primary is not switched")
+			}
+			cmd.Printf("Failed to get the second timestamp: %s\n", err)
+			return
+		case <-time.After(verifyMonotonicRetryInterval):
+		}
+	}
+
+	cmd.Printf("first timestamp: %d (primary: %s)\n", tsoutil.GenerateTS(firstTS), firstPrimary)
+	cmd.Printf("second timestamp: %d (primary: %s)\n", tsoutil.GenerateTS(secondTS), secondPrimary)
+	if tsoutil.CompareTimestamp(secondTS, firstTS) <= 0 {
+		cmd.Println("Failed! The second timestamp is not greater than the first one")
+		return
+	}
+	cmd.Println("Success! The timestamps are monotonic")
+}
+
+// getTSFromTSOPrimary gets a timestamp forwarded by the PD leader to the primary of the default
+// keyspace group, and returns the address of the primary together. It fails if the primary is
+// switched meanwhile, since it's unknown which primary allocates the timestamp then.
+func getTSFromTSOPrimary(
+	ctx context.Context, cmd *cobra.Command, clusterID uint64, kg *endpoint.KeyspaceGroup,
+) (*pdpb.Timestamp, string, error) {
+	primary, err := getTSOPrimary(cmd, kg)
+	if err != nil {
+		return nil, "", err
+	}
+	ts, _, err := getTSFromLeader(ctx, cmd, clusterID)
+	if err != nil {
+		return nil, "", err
+	}
+	newPrimary, err := getTSOPrimary(cmd, kg)
+	if err != nil {
+		return nil, "", err
+	}
+	if newPrimary != primary {
+		return nil, "", errors.Errorf("the primary is switched from %s to %s", primary, newPrimary)
+	}
+	return ts, primary, nil
+}
+
+// getTSOPrimary returns the address of the tso node which is the primary of the default keyspace group.
+func getTSOPrimary(cmd *cobra.Command, kg *endpoint.KeyspaceGroup) (string, error) {
+	// Ask each tso node directly, otherwise a non-primary one redirects the request to the primary.
+	header := http.Header{multiservicesapi.ServiceAllowDirectHandle: {"true"}}
+	for _, member := range kg.Members {
+		r, err := doRequestSingleEndpoint(cmd, member.Address, tsoKeyspaceGroupMembersPrefix, http.MethodGet, header)
+		if err != nil {
+			continue
+		}
+		var members map[uint32]*struct {
+			IsPrimary bool `json:"is_primary"`
+		}
+		if err = json.Unmarshal([]byte(r), &members); err != nil {
+			return "", errors.WithStack(err)
+		}
+		if m, ok := members[kg.ID]; ok && m.IsPrimary {
+			return member.Address, nil
+		}
+	}
+	return "", errors.New("no primary")
+}
+
+// transferTSOPrimary transfers the primary of the keyspace group to another member by raising its
+// priority above all the others, so that the current primary resigns for it. It returns a function
+// to restore the original priority of the member.
+func transferTSOPrimary(cmd *cobra.Command, kg *endpoint.KeyspaceGroup, primary string) (func() error, error) {
+	var (
+		target         string
+		targetPriority int
+		maxPriority    int
+	)
+	for _, member := range kg.Members {
+		if member.Priority > maxPriority {
+			maxPriority = member.Priority
+		}
+		if target == "" && member.Address != primary {
+			target, targetPriority = member.Address, member.Priority
+		}
+	}
+	if target == "" {
+		return nil, errors.New("no other member to transfer the primary to")
+	}
+	setPriority := func(priority int) error {
+		data, err := json.Marshal(map[string]interface{}{"priority": priority})
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		// See setPriorityKeyspaceGroupCommandFunc for the escaping.
+		node := strings.ReplaceAll(url.PathEscape(target), "%", "\\%")
+		_, err = doRequest(cmd, fmt.Sprintf("%s/%d/%s", keyspaceGroupsPrefix, kg.ID, node), http.MethodPatch,
+			http.Header{"Content-Type": {"application/json"}}, WithBody(bytes.NewBuffer(data)))
+		return err
+	}
+	if err := setPriority(maxPriority + 1); err != nil {
+		return nil, err
+	}
+	return func() error { return setPriority(targetPriority) }, nil
+}
+
+// getServiceMode gets the service mode reported by the current PD leader.
+func getServiceMode(ctx context.Context, cmd *cobra.Command) (pdpb.ServiceMode, error) {
+	cc, _, err := dialLeader(ctx, cmd)
+	if err != nil {
+		return pdpb.ServiceMode_UNKNOWN_SVC_MODE, err
+	}
+	defer cc.Close()
+	resp, err := pdpb.NewPDClient(cc).GetClusterInfo(ctx, &pdpb.GetClusterInfoRequest{})
+	if err != nil {
+		return pdpb.ServiceMode_UNKNOWN_SVC_MODE, errors.WithStack(err)
+	}
+	if resp.GetHeader().GetError() != nil {
+		return pdpb.ServiceMode_UNKNOWN_SVC_MODE, errors.New(resp.GetHeader().GetError().String())
+	}
+	if len(resp.GetServiceModes()) == 0 {
+		return pdpb.ServiceMode_UNKNOWN_SVC_MODE, errors.New("no service mode")
+	}
+	return resp.GetServiceModes()[0], nil
+}
+
+// dialLeader connects to the current PD leader, and returns the leader together.
+func dialLeader(ctx context.Context, cmd *cobra.Command) (*grpc.ClientConn, *pdpb.Member, error) {
+	r, err := doRequest(cmd, leaderMemberPrefix, http.MethodGet, http.Header{})
+	if err != nil {
+		return nil, nil, err
+	}
+	leader := &pdpb.Member{}
+	if err = json.Unmarshal([]byte(r), leader); err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	if len(leader.GetClientUrls()) == 0 {
+		return nil, nil, errors.New("no leader")
+	}
+	cc, err := grpcutil.GetClientConn(ctx, leader.GetClientUrls()[0], tlsConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cc, leader, nil
+}
+
+// getTSFromLeader gets a timestamp from the current PD leader, and returns the leader together.
+func getTSFromLeader(ctx context.Context, cmd *cobra.Command, clusterID uint64) (*pdpb.Timestamp, *pdpb.Member, error) {
+	cc, leader, err := dialLeader(ctx, cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer cc.Close()
+	stream, err := pdpb.NewPDClient(cc).Tso(ctx)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	defer stream.CloseSend()
+	if err = stream.Send(&pdpb.TsoRequest{
+		Header: &pdpb.RequestHeader{ClusterId: clusterID},
+		Count:  1,
+	}); err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	if resp.GetHeader().GetError() != nil {
+		return nil, nil, errors.New(resp.GetHeader().GetError().String())
+	}
+	return resp.GetTimestamp(), leader, nil
+}